@@ -3,16 +3,23 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/crossplay/backend/internal/api"
 	"github.com/crossplay/backend/internal/db"
 	"github.com/crossplay/backend/internal/models"
 	"github.com/crossplay/backend/internal/puzzle"
+	"github.com/crossplay/backend/internal/tournament"
+	"github.com/crossplay/backend/internal/wordfilter"
+	"github.com/crossplay/backend/pkg/clues"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 )
 
@@ -109,6 +116,9 @@ func main() {
 		qualityCmd.Parse(os.Args[2:])
 		runQuality(*qualityFile, *qualityID)
 
+	case "tournament":
+		runTournament(os.Args[2:])
+
 	case "config":
 		runConfig()
 
@@ -137,6 +147,7 @@ Commands:
   publish     Publish a draft puzzle
   list        List puzzles in the database
   quality     Analyze puzzle quality
+  tournament  Create, advance, list and show tournaments
   config      Show current configuration
 
 Examples:
@@ -147,15 +158,22 @@ Examples:
   admin week -start 2024-01-01 -save
   admin quality -file puzzle.json
   admin publish -id abc123 -date 2024-01-15
+  admin tournament create -name "Friday Cup" -format bracket -heat-size 4 -advance 2
+  admin tournament advance -id abc123
   admin config
 
 Generation Method:
   Uses dictionary-based generation with CSP algorithm.
   No external LLM API required.
+  For LLM-written clues with a spend limit, use crossgen generate --budget.
 
 Database Configuration:
   DATABASE_URL       PostgreSQL connection string (for save/publish)
-  REDIS_URL          Redis connection string (optional)`)
+  REDIS_URL          Redis connection string (optional)
+
+Content Filter:
+  BANNED_WORDS       Comma-separated words answers must not contain (shared with chat)
+  BANNED_WORDS_FILE  File with one banned word per line`)
 }
 
 func getAPIKey() string {
@@ -163,6 +181,18 @@ func getAPIKey() string {
 	return ""
 }
 
+// pipelineConfig returns the default pipeline configuration with the banned
+// words the server's chat filter uses
+func pipelineConfig() puzzle.PipelineConfig {
+	config := puzzle.DefaultPipelineConfig()
+	filter, err := wordfilter.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load banned words: %v", err)
+	}
+	config.WordFilter = filter
+	return config
+}
+
 func runConfig() {
 	fmt.Println("CrossPlay Puzzle Generator Configuration")
 	fmt.Println("=========================================")
@@ -206,7 +236,7 @@ func runGenerate(difficulty, size, theme, output string, save bool) {
 		fmt.Printf("Theme: %s\n", theme)
 	}
 
-	config := puzzle.DefaultPipelineConfig()
+	config := pipelineConfig()
 	pipeline := puzzle.NewProductionPipeline(apiKey, config)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
@@ -289,7 +319,7 @@ func runBatch(size, difficulty string, count int, theme, output string) {
 		fmt.Printf("Theme: %s\n", theme)
 	}
 
-	config := puzzle.DefaultPipelineConfig()
+	config := pipelineConfig()
 	config.CandidatesPerBatch = count
 	pipeline := puzzle.NewProductionPipeline(apiKey, config)
 
@@ -369,7 +399,7 @@ func runWeek(startDate, output string, save bool) {
 	fmt.Printf("Generating puzzles for week starting: %s\n", start.Format("2006-01-02"))
 	fmt.Println("This may take several minutes...")
 
-	config := puzzle.DefaultPipelineConfig()
+	config := pipelineConfig()
 	pipeline := puzzle.NewProductionPipeline(apiKey, config)
 	schedule := puzzle.NewDailyProductionSchedule(pipeline)
 
@@ -692,6 +722,11 @@ func runImport(path string) {
 			continue
 		}
 
+		// Lint clues; issues are reported as warnings and don't block import
+		for _, warning := range lintPuzzleClues(&puzzleData) {
+			fmt.Printf("! %s: %s\n", file, warning)
+		}
+
 		// Ensure status is set (default to draft)
 		if puzzleData.Status == "" {
 			puzzleData.Status = "draft"
@@ -721,3 +756,205 @@ func runImport(path string) {
 	fmt.Printf("  Failed: %d\n", failed)
 	fmt.Printf("  Total: %d\n", len(files))
 }
+
+// lintPuzzleClues runs the shared clue linter over a puzzle's clues
+func lintPuzzleClues(p *models.Puzzle) []string {
+	var entries []clues.LintEntry
+	for _, clue := range p.CluesAcross {
+		entries = append(entries, clues.LintEntry{Key: fmt.Sprintf("%d-across", clue.Number), Answer: clue.Answer, Clue: clue.Text})
+	}
+	for _, clue := range p.CluesDown {
+		entries = append(entries, clues.LintEntry{Key: fmt.Sprintf("%d-down", clue.Number), Answer: clue.Answer, Clue: clue.Text})
+	}
+
+	var warnings []string
+	for key, issues := range clues.NewLinter().LintPuzzle(entries) {
+		for _, issue := range issues {
+			warnings = append(warnings, fmt.Sprintf("clue %s: %s", key, issue.Message))
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+func runTournament(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: admin tournament <create|advance|list|show> [options]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "create":
+		createCmd := flag.NewFlagSet("tournament create", flag.ExitOnError)
+		name := createCmd.String("name", "", "Tournament name")
+		format := createCmd.String("format", "bracket", "Format (bracket, swiss)")
+		heatSize := createCmd.Int("heat-size", 0, "Most players in one race room (default 4)")
+		advance := createCmd.Int("advance", 0, "Bracket: players going through from each heat (default half the heat)")
+		rounds := createCmd.Int("rounds", 0, "Swiss: rounds to play (default 3)")
+		difficulty := createCmd.String("difficulty", "", "Puzzle difficulty (easy, medium, hard; default any)")
+		timer := createCmd.Int("timer", 0, "Heat time limit in seconds (0 = untimed)")
+		createCmd.Parse(args[1:])
+		runTournamentCreate(&models.Tournament{
+			Name:         *name,
+			Format:       models.TournamentFormat(*format),
+			HeatSize:     *heatSize,
+			Advance:      *advance,
+			Rounds:       *rounds,
+			Difficulty:   models.Difficulty(*difficulty),
+			TimerSeconds: *timer,
+		})
+
+	case "advance":
+		advanceCmd := flag.NewFlagSet("tournament advance", flag.ExitOnError)
+		id := advanceCmd.String("id", "", "Tournament ID")
+		force := advanceCmd.Bool("force", false, "Close heats still playing, placing unfinished players by seed")
+		advanceCmd.Parse(args[1:])
+		runTournamentAdvance(*id, *force)
+
+	case "list":
+		listCmd := flag.NewFlagSet("tournament list", flag.ExitOnError)
+		status := listCmd.String("status", "", "Filter by status (registration, running, completed)")
+		limit := listCmd.Int("limit", 20, "Maximum results")
+		listCmd.Parse(args[1:])
+		runTournamentList(*status, *limit)
+
+	case "show":
+		showCmd := flag.NewFlagSet("tournament show", flag.ExitOnError)
+		id := showCmd.String("id", "", "Tournament ID")
+		showCmd.Parse(args[1:])
+		runTournamentShow(*id)
+
+	default:
+		fmt.Printf("Unknown tournament command: %s\n", args[0])
+		fmt.Println("Usage: admin tournament <create|advance|list|show> [options]")
+		os.Exit(1)
+	}
+}
+
+func runTournamentCreate(t *models.Tournament) {
+	if err := tournament.Validate(t); err != nil {
+		log.Fatalf("Invalid tournament: %v", err)
+	}
+	t.ID = uuid.New().String()
+	t.Status = models.TournamentStatusRegistration
+	t.CreatedAt = time.Now()
+
+	database := getDatabase()
+	defer database.Close()
+
+	if err := database.CreateTournament(t); err != nil {
+		log.Fatalf("Failed to create tournament: %v", err)
+	}
+
+	fmt.Printf("✓ Created tournament %q\n", t.Name)
+	fmt.Printf("  ID: %s\n", t.ID)
+	fmt.Printf("  Format: %s, heats of %d\n", t.Format, t.HeatSize)
+	fmt.Println("  Registration is open; run 'admin tournament advance' to start it")
+}
+
+func runTournamentAdvance(id string, force bool) {
+	if id == "" {
+		log.Fatal("Tournament ID is required (-id)")
+	}
+
+	database := getDatabase()
+	defer database.Close()
+
+	t, err := api.NewHandlers(database, nil).AdvanceTournament(id, force)
+	if errors.Is(err, tournament.ErrRoundInProgress) {
+		log.Fatalf("Round %d isn't finished; use -force to close its heats", t.Round)
+	}
+	if err != nil {
+		log.Fatalf("Failed to advance tournament: %v", err)
+	}
+
+	if t.Status == models.TournamentStatusCompleted {
+		winner := "nobody"
+		if t.WinnerID != nil {
+			winner = *t.WinnerID
+		}
+		fmt.Printf("✓ Tournament %q is over, won by %s\n", t.Name, winner)
+		return
+	}
+	fmt.Printf("✓ Tournament %q is on round %d\n", t.Name, t.Round)
+	heats, err := database.GetTournamentHeats(t.ID)
+	printTournamentHeats(tournament.RoundHeats(heats, t.Round), err)
+}
+
+func runTournamentList(status string, limit int) {
+	database := getDatabase()
+	defer database.Close()
+
+	tournaments, err := database.ListTournaments(models.TournamentStatus(status), limit)
+	if err != nil {
+		log.Fatalf("Failed to list tournaments: %v", err)
+	}
+
+	if len(tournaments) == 0 {
+		fmt.Println("No tournaments found")
+		return
+	}
+
+	fmt.Printf("Found %d tournaments:\n\n", len(tournaments))
+	fmt.Printf("%-36s %-20s %-8s %-12s %-5s\n", "ID", "Name", "Format", "Status", "Round")
+	fmt.Println(strings.Repeat("-", 85))
+
+	for _, t := range tournaments {
+		fmt.Printf("%-36s %-20s %-8s %-12s %-5d\n", t.ID, truncate(t.Name, 20), t.Format, t.Status, t.Round)
+	}
+}
+
+func runTournamentShow(id string) {
+	if id == "" {
+		log.Fatal("Tournament ID is required (-id)")
+	}
+
+	database := getDatabase()
+	defer database.Close()
+
+	t, err := database.GetTournament(id)
+	if err != nil {
+		log.Fatalf("Failed to load tournament: %v", err)
+	}
+	if t == nil {
+		log.Fatalf("Tournament not found: %s", id)
+	}
+	players, err := database.GetTournamentPlayers(id)
+	if err != nil {
+		log.Fatalf("Failed to load players: %v", err)
+	}
+	heats, err := database.GetTournamentHeats(id)
+	if err != nil {
+		log.Fatalf("Failed to load heats: %v", err)
+	}
+
+	fmt.Printf("%s (%s, %s)\n", t.Name, t.Format, t.Status)
+	fmt.Printf("Round %d, %d players\n\n", t.Round, len(players))
+
+	printTournamentHeats(heats, nil)
+	fmt.Println()
+
+	fmt.Printf("%-4s %-20s %-4s %-6s %-5s\n", "Rank", "Player", "Seed", "Points", "Out")
+	fmt.Println(strings.Repeat("-", 45))
+	for _, s := range tournament.Standings(t, players, heats) {
+		out := ""
+		if s.Eliminated {
+			out = "yes"
+		}
+		fmt.Printf("%-4d %-20s %-4d %-6d %-5s\n", s.Rank, truncate(s.DisplayName, 20), s.Seed, s.Points, out)
+	}
+}
+
+func printTournamentHeats(heats []models.TournamentHeat, err error) {
+	if err != nil {
+		log.Printf("Failed to load heats: %v", err)
+		return
+	}
+	for _, heat := range heats {
+		state := "playing"
+		if heat.FinishedAt != nil {
+			state = "finished: " + strings.Join(heat.Results, ", ")
+		}
+		fmt.Printf("  Round %d heat %d  room %s  %d players  %s\n", heat.Round, heat.Number, heat.RoomCode, len(heat.Players), state)
+	}
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/crossplay/backend/pkg/clues"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncDB        string
+	syncPostgres  string
+	syncDirection string
+)
+
+var cluesCmd = &cobra.Command{
+	Use:   "clues",
+	Short: "Manage the clue cache",
+	Long:  `Commands for managing the clue cache shared by puzzle generation and the server.`,
+}
+
+var cluesSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Sync the local clue cache with the server database",
+	Long: `Copy clues between the local SQLite clue cache and the server's Postgres database.
+
+Clues are matched by word, clue text and difficulty, so running sync repeatedly
+never creates duplicates.
+
+Directions:
+  - push: copy local clues to Postgres
+  - pull: copy Postgres clues to the local cache
+  - both: push, then pull (default)
+
+Examples:
+  # Push newly generated clues to production
+  crossgen clues sync --direction push --postgres "$DATABASE_URL"
+
+  # Sync both ways using a custom local cache
+  crossgen clues sync --db /path/to/cache.db`,
+	RunE: runCluesSync,
+}
+
+func init() {
+	rootCmd.AddCommand(cluesCmd)
+	cluesCmd.AddCommand(cluesSyncCmd)
+
+	cluesSyncCmd.Flags().StringVarP(&syncDB, "db", "d", "./clue_cache.db", "path to local clue cache database")
+	cluesSyncCmd.Flags().StringVar(&syncPostgres, "postgres", "", "Postgres connection string (default: $DATABASE_URL)")
+	cluesSyncCmd.Flags().StringVar(&syncDirection, "direction", "both", "sync direction (push, pull, both)")
+}
+
+func runCluesSync(cmd *cobra.Command, args []string) error {
+	direction := strings.ToLower(syncDirection)
+	if direction != "push" && direction != "pull" && direction != "both" {
+		return fmt.Errorf("invalid direction: %s (must be push, pull, or both)", syncDirection)
+	}
+
+	postgresURL := syncPostgres
+	if postgresURL == "" {
+		postgresURL = os.Getenv("DATABASE_URL")
+	}
+	if postgresURL == "" {
+		return fmt.Errorf("--postgres flag or DATABASE_URL environment variable is required")
+	}
+
+	// Open local SQLite cache
+	localDB, err := sql.Open("sqlite3", syncDB)
+	if err != nil {
+		return fmt.Errorf("failed to open local cache: %w", err)
+	}
+	defer localDB.Close()
+
+	if err := clues.InitDB(localDB); err != nil {
+		return fmt.Errorf("failed to initialize local cache: %w", err)
+	}
+
+	local, err := clues.NewClueCache(localDB)
+	if err != nil {
+		return fmt.Errorf("failed to create local cache: %w", err)
+	}
+
+	// Open Postgres cache
+	remoteDB, err := sql.Open("postgres", postgresURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	defer remoteDB.Close()
+
+	if err := remoteDB.Ping(); err != nil {
+		return fmt.Errorf("failed to ping postgres: %w", err)
+	}
+
+	if err := clues.InitPostgresDB(remoteDB); err != nil {
+		return fmt.Errorf("failed to initialize postgres cache: %w", err)
+	}
+
+	remote, err := clues.NewPostgresClueCache(remoteDB)
+	if err != nil {
+		return fmt.Errorf("failed to create postgres cache: %w", err)
+	}
+
+	if verbosity > 0 {
+		fmt.Printf("Syncing %s (%s) with Postgres\n", syncDB, direction)
+	}
+
+	return syncClueStores(local, remote, direction)
+}
+
+// syncClueStores copies clues between the local and remote stores in the given direction
+func syncClueStores(local, remote clues.ClueStore, direction string) error {
+	if direction == "push" || direction == "both" {
+		added, err := clues.SyncClues(local, remote)
+		if err != nil {
+			return fmt.Errorf("push failed: %w", err)
+		}
+		fmt.Printf("Pushed %d new clue(s) to Postgres\n", added)
+	}
+
+	if direction == "pull" || direction == "both" {
+		added, err := clues.SyncClues(remote, local)
+		if err != nil {
+			return fmt.Errorf("pull failed: %w", err)
+		}
+		fmt.Printf("Pulled %d new clue(s) into the local cache\n", added)
+	}
+
+	return nil
+}
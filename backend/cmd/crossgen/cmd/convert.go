@@ -115,6 +115,11 @@ func runConvert(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Lint imported clues; issues are reported but don't block conversion
+	for _, warning := range lintPuzzleClues(puzzle) {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+
 	// Convert to target format
 	var outputData []byte
 	switch targetFormat {
@@ -149,3 +154,16 @@ func runConvert(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// lintPuzzleClues runs the shared clue linter over an imported puzzle's clues
+func lintPuzzleClues(puzzle *models.Puzzle) []string {
+	across := make([]clueData, 0, len(puzzle.CluesAcross))
+	for _, clue := range puzzle.CluesAcross {
+		across = append(across, clueData{Number: clue.Number, Text: clue.Text, Answer: clue.Answer, Length: clue.Length})
+	}
+	down := make([]clueData, 0, len(puzzle.CluesDown))
+	for _, clue := range puzzle.CluesDown {
+		down = append(down, clueData{Number: clue.Number, Text: clue.Text, Answer: clue.Answer, Length: clue.Length})
+	}
+	return lintClues(across, down)
+}
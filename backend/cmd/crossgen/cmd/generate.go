@@ -3,9 +3,11 @@ package cmd
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -27,6 +29,13 @@ var (
 	genFormat     string
 	genWordlist   string
 	genLLM        string
+	genTheme      string
+	genBudget     float64
+	genParallel   int
+
+	genThemeEntries []string
+	genRevealer     string
+	genCrossRefs    []string
 )
 
 var generateCmd = &cobra.Command{
@@ -42,7 +51,17 @@ Examples:
   crossgen generate --difficulty hard --format all --output ./puzzle.json
 
   # Generate using cache-only mode (no LLM API calls)
-  crossgen generate --llm cache-only --count 5`,
+  crossgen generate --llm cache-only --count 5
+
+  # Generate a themed puzzle; the theme is passed to the clue writer
+  crossgen generate --theme "Breakfast foods" --wordlist words.txt
+
+  # Mark theme entries, a revealer and a cross-reference pair by slot
+  crossgen generate --theme "Breakfast foods" --theme-entry 17-across --theme-entry 56-across \
+    --revealer 60-across --cross-ref 17-across:56-across --wordlist words.txt
+
+  # Stop generating once $2.50 of LLM usage has been spent
+  crossgen generate --count 50 --budget 2.50 --wordlist words.txt`,
 	RunE: runGenerate,
 }
 
@@ -55,6 +74,12 @@ func init() {
 	generateCmd.Flags().StringVarP(&genFormat, "format", "f", "json", "output format (json, puz, ipuz, all)")
 	generateCmd.Flags().StringVarP(&genWordlist, "wordlist", "w", "", "path to wordlist file (Peter Broda format)")
 	generateCmd.Flags().StringVarP(&genLLM, "llm", "l", "anthropic", "LLM provider (anthropic, ollama, cache-only)")
+	generateCmd.Flags().StringVar(&genTheme, "theme", "", "puzzle theme description passed to the clue writer")
+	generateCmd.Flags().StringSliceVar(&genThemeEntries, "theme-entry", nil, "theme answer entry key, e.g. 17-across (repeatable)")
+	generateCmd.Flags().StringVar(&genRevealer, "revealer", "", "revealer entry key, e.g. 60-across")
+	generateCmd.Flags().StringSliceVar(&genCrossRefs, "cross-ref", nil, "cross-reference pair FROM:TO, e.g. 17-across:56-across (repeatable)")
+	generateCmd.Flags().IntVar(&genParallel, "parallel", 0, "max clue batches sent to the LLM at once (0 = provider default)")
+	generateCmd.Flags().Float64Var(&genBudget, "budget", 0, "maximum LLM spend in USD for this run (0 = unlimited)")
 }
 
 func runGenerate(cmd *cobra.Command, args []string) error {
@@ -71,6 +96,15 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid format: %w", err)
 	}
 
+	if genBudget < 0 {
+		return fmt.Errorf("--budget must not be negative")
+	}
+
+	crossRefs, err := parseCrossRefs(genCrossRefs)
+	if err != nil {
+		return fmt.Errorf("invalid --cross-ref: %w", err)
+	}
+
 	// Load wordlist
 	if genWordlist == "" {
 		return fmt.Errorf("--wordlist flag is required")
@@ -90,7 +124,7 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	}
 
 	// Set up clue generator
-	clueGen, err := setupClueGenerator(genLLM, difficulty)
+	clueGen, usage, err := setupClueGenerator(genLLM, difficulty, genBudget, genParallel)
 	if err != nil {
 		return fmt.Errorf("failed to setup clue generator: %w", err)
 	}
@@ -106,8 +140,16 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	// Generate puzzles with progress tracking
 	fmt.Printf("Generating %d puzzle(s) with difficulty: %s\n", genCount, genDifficulty)
 
+	generated := 0
 	for i := 1; i <= genCount; i++ {
+		if usage.Exceeded() {
+			fmt.Printf("Budget of $%.2f reached, stopping\n", usage.Budget())
+			break
+		}
+
 		startTime := time.Now()
+		puzzleID := fmt.Sprintf("puzzle_%03d", i)
+		usage.SetPuzzle(puzzleID)
 
 		// Show progress
 		fmt.Printf("[%d/%d] Generating puzzle... ", i, genCount)
@@ -121,10 +163,19 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 			MaxRetries: 100,
 			Title:      fmt.Sprintf("Crossword Puzzle %d - %s", i, time.Now().Format("2006-01-02")),
 			Author:     "Crossy Generator",
-			Theme:      "",
+			Theme:      genTheme,
+
+			ThemeEntries:    genThemeEntries,
+			Revealer:        genRevealer,
+			CrossReferences: crossRefs,
 		}
 
 		puz, err := puzzleGen.GeneratePuzzle(ctx, puzzleConfig)
+		if errors.Is(err, clues.ErrBudgetExceeded) {
+			fmt.Printf("STOPPED\n")
+			fmt.Printf("Budget of $%.2f reached, stopping\n", usage.Budget())
+			break
+		}
 		if err != nil {
 			fmt.Printf("FAILED\n")
 			return fmt.Errorf("failed to generate puzzle %d: %w", i, err)
@@ -139,14 +190,38 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to write output files for puzzle %d: %w", i, err)
 		}
 
+		generated++
 		elapsed := time.Since(startTime)
-		fmt.Printf("OK (%.1fs)\n", elapsed.Seconds())
+		fmt.Printf("OK (%.1fs, $%.4f)\n", elapsed.Seconds(), usage.ByPuzzle()[puzzleID].CostUSD)
 	}
 
-	fmt.Printf("\nSuccessfully generated %d puzzle(s) in %s\n", genCount, genOutput)
+	fmt.Printf("\nSuccessfully generated %d puzzle(s) in %s\n", generated, genOutput)
+	printUsageSummary(usage)
 	return nil
 }
 
+// printUsageSummary prints the LLM tokens and cost spent by this run
+func printUsageSummary(usage *clues.UsageTracker) {
+	total := usage.Total()
+	if total.Calls == 0 {
+		return
+	}
+
+	fmt.Printf("LLM usage (batch %s): %d call(s), %d input + %d output tokens, $%.4f\n",
+		usage.BatchID(), total.Calls, total.InputTokens, total.OutputTokens, total.CostUSD)
+
+	byProvider := usage.ByProvider()
+	keys := make([]string, 0, len(byProvider))
+	for key := range byProvider {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		t := byProvider[key]
+		fmt.Printf("  %-45s %6d call(s)  $%.4f\n", key, t.Calls, t.CostUSD)
+	}
+}
+
 // parseDifficulty converts string difficulty to grid.Difficulty
 func parseDifficulty(diff string) (grid.Difficulty, error) {
 	switch strings.ToLower(diff) {
@@ -163,6 +238,19 @@ func parseDifficulty(diff string) (grid.Difficulty, error) {
 	}
 }
 
+// parseCrossRefs converts FROM:TO pairs of entry keys to cross-references
+func parseCrossRefs(pairs []string) ([]clues.CrossReference, error) {
+	refs := make([]clues.CrossReference, 0, len(pairs))
+	for _, pair := range pairs {
+		from, to, found := strings.Cut(strings.ToLower(pair), ":")
+		if !found || from == "" || to == "" {
+			return nil, fmt.Errorf("%s (must be FROM:TO, e.g. 17-across:56-across)", pair)
+		}
+		refs = append(refs, clues.CrossReference{From: from, To: to})
+	}
+	return refs, nil
+}
+
 // parseFormats converts format string to list of formats
 func parseFormats(format string) ([]string, error) {
 	format = strings.ToLower(format)
@@ -183,17 +271,24 @@ func parseFormats(format string) ([]string, error) {
 	return []string{format}, nil
 }
 
-// setupClueGenerator creates a clue generator based on the LLM provider
-func setupClueGenerator(llmProvider string, difficulty grid.Difficulty) (*clues.Generator, error) {
+// setupClueGenerator creates a clue generator based on the LLM provider, along
+// with a usage tracker that records its LLM spend in the cache database and
+// enforces budget (USD, 0 = unlimited). parallel caps concurrent LLM requests
+// (0 = the provider's default).
+func setupClueGenerator(llmProvider string, difficulty grid.Difficulty, budget float64, parallel int) (*clues.Generator, *clues.UsageTracker, error) {
 	// Open clue cache database
 	cacheDB, err := sql.Open("sqlite3", "./clue_cache.db")
 	if err != nil {
-		return nil, fmt.Errorf("failed to open cache database: %w", err)
+		return nil, nil, fmt.Errorf("failed to open cache database: %w", err)
+	}
+
+	if err := clues.InitDB(cacheDB); err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize cache database: %w", err)
 	}
 
 	cache, err := clues.NewClueCache(cacheDB)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create clue cache: %w", err)
+		return nil, nil, fmt.Errorf("failed to create clue cache: %w", err)
 	}
 
 	// Convert grid.Difficulty to clues.Difficulty
@@ -217,30 +312,36 @@ func setupClueGenerator(llmProvider string, difficulty grid.Difficulty) (*clues.
 	case "anthropic":
 		apiKey := os.Getenv("ANTHROPIC_API_KEY")
 		if apiKey == "" {
-			return nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable not set")
+			return nil, nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable not set")
 		}
 		var clientErr error
 		llmClient, clientErr = providers.NewAnthropicClient(providers.AnthropicConfig{
-			APIKey: apiKey,
-			Model:  providers.ModelHaiku,
+			APIKey:         apiKey,
+			Model:          providers.ModelHaiku,
+			MaxConcurrency: parallel,
 		})
 		if clientErr != nil {
-			return nil, fmt.Errorf("failed to create Anthropic client: %w", clientErr)
+			return nil, nil, fmt.Errorf("failed to create Anthropic client: %w", clientErr)
 		}
 	case "ollama":
 		var clientErr error
 		llmClient, clientErr = providers.NewOllamaClient(providers.OllamaConfig{
-			BaseURL: "http://localhost:11434/api/generate",
-			Model:   providers.ModelLlama2,
+			BaseURL:        "http://localhost:11434/api/generate",
+			Model:          providers.ModelLlama2,
+			MaxConcurrency: parallel,
 		})
 		if clientErr != nil {
-			return nil, fmt.Errorf("failed to create Ollama client: %w", clientErr)
+			return nil, nil, fmt.Errorf("failed to create Ollama client: %w", clientErr)
 		}
 	default:
-		return nil, fmt.Errorf("invalid LLM provider: %s (must be anthropic, ollama, or cache-only)", llmProvider)
+		return nil, nil, fmt.Errorf("invalid LLM provider: %s (must be anthropic, ollama, or cache-only)", llmProvider)
 	}
 
-	return clues.NewGenerator(cache, llmClient, clueDifficulty), nil
+	usage := clues.NewUsageTracker(cache, "gen-"+time.Now().Format("20060102-150405"), budget)
+	gen := clues.NewGenerator(cache, llmClient, clueDifficulty)
+	gen.SetUsageTracker(usage)
+
+	return gen, usage, nil
 }
 
 // writeOutputFiles writes puzzle to disk in the specified formats
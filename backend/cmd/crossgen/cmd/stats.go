@@ -23,6 +23,7 @@ Shows information about:
   - Cache hit rate (if available)
   - Most frequently cached words
   - Least frequently cached words
+  - LLM token usage and cost by provider and by batch
   - Database size and growth
 
 Examples:
@@ -82,6 +83,11 @@ func runStats(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// LLM usage and cost
+	if err := displayCostSummary(db); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -197,3 +203,90 @@ func displayLeastCommonWords(db *sql.DB) error {
 
 	return rows.Err()
 }
+
+func displayCostSummary(db *sql.DB) error {
+	fmt.Println("LLM Cost Summary:")
+	fmt.Println("-----------------")
+
+	// Databases created before usage tracking have no llm_usage table
+	var tableName string
+	err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'llm_usage'`).Scan(&tableName)
+	if err == sql.ErrNoRows {
+		fmt.Println("  No LLM usage recorded")
+		fmt.Println()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check for usage table: %w", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT provider, model, COUNT(*), SUM(input_tokens), SUM(output_tokens), SUM(cost_usd)
+		FROM llm_usage
+		GROUP BY provider, model
+		ORDER BY SUM(cost_usd) DESC, provider, model
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query usage by provider: %w", err)
+	}
+	defer rows.Close()
+
+	var totalCost float64
+	hasRows := false
+	for rows.Next() {
+		hasRows = true
+		var provider, model string
+		var calls, inputTokens, outputTokens int
+		var cost float64
+		if err := rows.Scan(&provider, &model, &calls, &inputTokens, &outputTokens, &cost); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		fmt.Printf("  %-10s %-30s: %d call(s), %d in / %d out tokens, $%.4f\n",
+			provider, model, calls, inputTokens, outputTokens, cost)
+		totalCost += cost
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if !hasRows {
+		fmt.Println("  No LLM usage recorded")
+		fmt.Println()
+		return nil
+	}
+	fmt.Printf("  %-41s: $%.4f\n", "TOTAL", totalCost)
+	fmt.Println()
+
+	fmt.Println("  Recent batches:")
+	batchRows, err := db.Query(`
+		SELECT batch_id, COUNT(DISTINCT puzzle_id), SUM(cost_usd), MAX(created_at)
+		FROM llm_usage
+		GROUP BY batch_id
+		ORDER BY MAX(created_at) DESC, batch_id
+		LIMIT 10
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query usage by batch: %w", err)
+	}
+	defer batchRows.Close()
+
+	for batchRows.Next() {
+		var batchID, lastUsed string
+		var puzzles int
+		var cost float64
+		if err := batchRows.Scan(&batchID, &puzzles, &cost, &lastUsed); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if batchID == "" {
+			batchID = "(none)"
+		}
+		perPuzzle := 0.0
+		if puzzles > 0 {
+			perPuzzle = cost / float64(puzzles)
+		}
+		fmt.Printf("    %-24s: %d puzzle(s), $%.4f ($%.4f/puzzle)\n", batchID, puzzles, cost, perPuzzle)
+	}
+	fmt.Println()
+
+	return batchRows.Err()
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/crossplay/backend/pkg/clues"
 	"github.com/crossplay/backend/pkg/grid"
 	"github.com/spf13/cobra"
 )
@@ -33,6 +35,8 @@ Checks include:
   - Grid connectivity (all white cells reachable)
   - Minimum word length requirements
   - Clue completeness
+  - Clue lint rules (answer leakage, abbreviation and agreement markers,
+    clue length, duplicate clues, cross-references to missing entries)
   - Format correctness
 
 Examples:
@@ -169,6 +173,9 @@ func validatePuzzleFile(filePath string) (bool, error) {
 	clueErrors := validateClueCompleteness(g, puzzleData.Across, puzzleData.Down)
 	errors = append(errors, clueErrors...)
 
+	// 5. Lint clue text against answers
+	errors = append(errors, lintClues(puzzleData.Across, puzzleData.Down)...)
+
 	// Report errors
 	if len(errors) > 0 {
 		fmt.Printf("❌ %s: INVALID\n", filepath.Base(filePath))
@@ -345,6 +352,42 @@ func validateClueCompleteness(g *grid.Grid, acrossClues, downClues []clueData) [
 	return errors
 }
 
+// lintClues runs the shared clue linter over all clues and returns one message per issue
+func lintClues(acrossClues, downClues []clueData) []string {
+	var entries []clues.LintEntry
+	for _, clue := range acrossClues {
+		entries = append(entries, clues.LintEntry{Key: fmt.Sprintf("%d-across", clue.Number), Answer: clue.Answer, Clue: clue.Text})
+	}
+	for _, clue := range downClues {
+		entries = append(entries, clues.LintEntry{Key: fmt.Sprintf("%d-down", clue.Number), Answer: clue.Answer, Clue: clue.Text})
+	}
+
+	linter := clues.NewLinter()
+	results := linter.LintPuzzle(entries)
+	for key, issues := range linter.LintCrossReferences(entries, nil) {
+		results[key] = append(results[key], issues...)
+	}
+
+	keys := make([]string, 0, len(results))
+	for key := range results {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errors []string
+	for _, key := range keys {
+		for _, issue := range results[key] {
+			// Empty clues are already reported by validateClueCompleteness
+			if issue.Rule == clues.RuleEmpty {
+				continue
+			}
+			errors = append(errors, fmt.Sprintf("clue %s: %s", key, issue.Message))
+		}
+	}
+
+	return errors
+}
+
 // isSymmetric checks if the grid has 180-degree rotational symmetry
 func isSymmetric(g *grid.Grid) bool {
 	size := g.Size
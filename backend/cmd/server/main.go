@@ -16,6 +16,7 @@ import (
 	"github.com/crossplay/backend/internal/middleware"
 	"github.com/crossplay/backend/internal/puzzle"
 	"github.com/crossplay/backend/internal/realtime"
+	"github.com/crossplay/backend/internal/wordfilter"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
@@ -64,9 +65,27 @@ func main() {
 
 	// Initialize WebSocket hub
 	var hub *realtime.Hub
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	hubDone := make(chan struct{})
 	if database != nil {
 		hub = realtime.NewHub(database)
-		go hub.Run()
+		go func() {
+			hub.Run(hubCtx)
+			close(hubDone)
+		}()
+
+		// Chat stars out the banned words the puzzle pipeline rejects
+		wordFilter, err := wordfilter.FromEnv()
+		if err != nil {
+			log.Fatalf("Failed to load banned words: %v", err)
+		}
+		hub.SetWordFilter(wordFilter)
+
+		// Room changes made over REST reach lobby watchers through the hub
+		handlers.SetLobbyNotifier(hub)
+
+		// Finished tournament heats are scored and advanced by the handlers
+		hub.SetHeatReporter(handlers)
 	}
 
 	// Setup Gin router
@@ -85,7 +104,12 @@ func main() {
 
 	// Performance metrics endpoint
 	router.GET("/metrics", func(c *gin.Context) {
-		c.JSON(http.StatusOK, middleware.GetMetrics())
+		metrics := middleware.GetMetrics()
+		if hub != nil {
+			metrics["grid_persistence"] = hub.PersistenceMetrics()
+			metrics["inbound"] = hub.InboundMetrics()
+		}
+		c.JSON(http.StatusOK, metrics)
 	})
 
 	// API routes
@@ -145,14 +169,24 @@ func main() {
 		{
 			if handlers != nil {
 				roomsGroup.POST("", handlers.CreateRoom)
+				roomsGroup.GET("/public", handlers.ListPublicRooms)
+				roomsGroup.POST("/quickmatch", handlers.QuickMatch)
+				roomsGroup.DELETE("/quickmatch", handlers.LeaveQuickMatch)
 				roomsGroup.GET("/:code", handlers.GetRoomByCode)
+				roomsGroup.GET("/:code/replay", handlers.GetRoomReplay)
+				roomsGroup.GET("/:code/messages", handlers.GetRoomMessages)
 				roomsGroup.POST("/join", handlers.JoinRoomByCode)
 				roomsGroup.POST("/:id/join", handlers.JoinRoom)
 				roomsGroup.POST("/:id/start", handlers.StartRoom)
 				roomsGroup.DELETE("/:id", handlers.CloseRoom)
 			} else {
 				roomsGroup.POST("", demoCreateRoomHandler)
+				roomsGroup.GET("/public", demoPublicRoomsHandler)
+				roomsGroup.POST("/quickmatch", demoQuickMatchHandler)
+				roomsGroup.DELETE("/quickmatch", demoLeaveQuickMatchHandler)
 				roomsGroup.GET("/:code", demoGetRoomHandler)
+				roomsGroup.GET("/:code/replay", demoRoomReplayHandler)
+				roomsGroup.GET("/:code/messages", demoRoomMessagesHandler)
 				roomsGroup.POST("/join", demoJoinRoomHandler)
 				roomsGroup.POST("/:id/join", demoJoinRoomHandler)
 				roomsGroup.POST("/:id/start", demoStartRoomHandler)
@@ -160,6 +194,24 @@ func main() {
 			}
 		}
 
+		// Tournament routes - listing and standings are public, registering needs a user
+		tournamentsGroup := apiGroup.Group("/tournaments")
+		{
+			if handlers != nil {
+				tournamentsGroup.GET("", handlers.ListTournaments)
+				tournamentsGroup.GET("/:id", handlers.GetTournament)
+				tournamentsGroup.GET("/:id/standings", handlers.GetTournamentStandings)
+				tournamentsGroup.POST("/:id/register", authMiddleware.RequireAuth(), handlers.RegisterForTournament)
+				tournamentsGroup.DELETE("/:id/register", authMiddleware.RequireAuth(), handlers.UnregisterFromTournament)
+			} else {
+				tournamentsGroup.GET("", demoTournamentsHandler)
+				tournamentsGroup.GET("/:id", demoTournamentHandler)
+				tournamentsGroup.GET("/:id/standings", demoTournamentHandler)
+				tournamentsGroup.POST("/:id/register", authMiddleware.RequireAuth(), demoTournamentHandler)
+				tournamentsGroup.DELETE("/:id/register", authMiddleware.RequireAuth(), demoTournamentHandler)
+			}
+		}
+
 		// Return JSON instead of HTML for unknown API routes
 		router.NoRoute(func(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -173,6 +225,27 @@ func main() {
 		// Run: go run ./cmd/admin --help for puzzle generation and management
 	}
 
+	// WebSocket endpoint for watching the lobby of public rooms - /api/lobby/ws
+	apiGroup.GET("/lobby/ws", func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
+			return
+		}
+
+		claims, err := authService.ValidateToken(token)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+
+		if hub != nil {
+			realtime.ServeWs(hub, c.Writer, c.Request, claims.UserID, claims.DisplayName)
+		} else {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "WebSocket not available in demo mode"})
+		}
+	})
+
 	// WebSocket endpoint - /api/rooms/:code/ws
 	apiGroup.GET("/rooms/:code/ws", func(c *gin.Context) {
 		token := c.Query("token")
@@ -229,6 +302,12 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Stop the hub, which saves grid edits that haven't been written behind yet
+	stopHub()
+	if hub != nil {
+		<-hubDone
+	}
+
 	if database != nil {
 		database.Close()
 	}
@@ -431,6 +510,26 @@ func demoCreateRoomHandler(c *gin.Context) {
 	})
 }
 
+func demoPublicRoomsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"rooms": []gin.H{}})
+}
+
+func demoTournamentsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"tournaments": []gin.H{}})
+}
+
+func demoTournamentHandler(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{"error": "tournament not found"})
+}
+
+func demoQuickMatchHandler(c *gin.Context) {
+	c.JSON(http.StatusAccepted, gin.H{"status": "queued", "waiting": 1})
+}
+
+func demoLeaveQuickMatchHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"message": "left quick-match"})
+}
+
 func demoGetRoomHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"room": gin.H{
@@ -442,6 +541,20 @@ func demoGetRoomHandler(c *gin.Context) {
 	})
 }
 
+func demoRoomReplayHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"roomId": c.Param("code"),
+		"mode":   "collaborative",
+		"events": []gin.H{},
+	})
+}
+
+func demoRoomMessagesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"messages": []gin.H{},
+	})
+}
+
 func demoJoinRoomHandler(c *gin.Context) {
 	claims := middleware.GetAuthUser(c)
 	c.JSON(http.StatusOK, gin.H{
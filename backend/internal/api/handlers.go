@@ -20,6 +20,7 @@ import (
 type Handlers struct {
 	db          *db.Database
 	authService *auth.AuthService
+	lobby       LobbyNotifier // optional; see lobby.go
 }
 
 func NewHandlers(database *db.Database, authService *auth.AuthService) *Handlers {
@@ -554,6 +555,22 @@ func sanitizePuzzleForClient(puzzle *models.Puzzle) *models.Puzzle {
 
 // Room Handlers
 
+// maxSpectatorDelay is the longest a room may delay its spectators' view, in seconds
+const maxSpectatorDelay = 300
+
+// maxHintPenalty is the most a race room may add to a time per revealed letter, in seconds
+const maxHintPenalty = 600
+
+// Bounds on relay turns: seconds per turn and words per turn
+const (
+	minTurnSeconds  = 10
+	maxTurnSeconds  = 600
+	maxWordsPerTurn = 20
+)
+
+// maxSeriesGames is the longest series of rematches a room can score
+const maxSeriesGames = 10
+
 type CreateRoomRequest struct {
 	PuzzleID string            `json:"puzzleId" binding:"required"`
 	Mode     models.RoomMode   `json:"mode" binding:"required"`
@@ -597,6 +614,40 @@ func (h *Handlers) CreateRoom(c *gin.Context) {
 	if req.Config.TimerMode == "" {
 		req.Config.TimerMode = "none"
 	}
+	if req.Mode == models.RoomModeTeam && req.Config.TeamCount == 0 {
+		req.Config.TeamCount = 2
+	}
+	// Only quick-match creates quick-match rooms
+	req.Config.QuickMatch = false
+	req.Config.SkillRating = 0
+	if req.Config.SpectatorDelay < 0 || req.Config.SpectatorDelay > maxSpectatorDelay {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "spectatorDelay must be between 0 and 300 seconds"})
+		return
+	}
+	if req.Config.Ranked && req.Config.HintsEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ranked rooms can't enable hints"})
+		return
+	}
+	if req.Config.MaxReveals < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "maxReveals can't be negative"})
+		return
+	}
+	if req.Config.HintPenalty < 0 || req.Config.HintPenalty > maxHintPenalty {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hintPenalty must be between 0 and 600 seconds"})
+		return
+	}
+	if req.Config.TurnSeconds != 0 && (req.Config.TurnSeconds < minTurnSeconds || req.Config.TurnSeconds > maxTurnSeconds) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "turnSeconds must be between 10 and 600 seconds"})
+		return
+	}
+	if req.Config.WordsPerTurn < 0 || req.Config.WordsPerTurn > maxWordsPerTurn {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wordsPerTurn must be between 0 and 20"})
+		return
+	}
+	if req.Config.SeriesGames < 0 || req.Config.SeriesGames > maxSeriesGames {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seriesGames must be between 0 and 10"})
+		return
+	}
 
 	// Generate room code
 	roomCode := generateRoomCode()
@@ -640,6 +691,8 @@ func (h *Handlers) CreateRoom(c *gin.Context) {
 		return
 	}
 
+	h.notifyLobby(room.ID)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"room":   room,
 		"player": player,
@@ -699,12 +752,28 @@ func (h *Handlers) JoinRoom(c *gin.Context) {
 		return
 	}
 
+	banned, err := h.db.IsBanned(room.ID, claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if banned {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you are banned from this room"})
+		return
+	}
+
 	players, err := h.db.GetRoomPlayers(room.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
 		return
 	}
 
+	// A locked room only lets its players back in
+	if room.Config.Locked && !isRoomPlayer(players, claims.UserID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "room is locked"})
+		return
+	}
+
 	// Check max players
 	activePlayers := 0
 	for _, p := range players {
@@ -713,6 +782,10 @@ func (h *Handlers) JoinRoom(c *gin.Context) {
 		}
 	}
 
+	if req.IsSpectator && !room.Config.SpectatorMode {
+		c.JSON(http.StatusForbidden, gin.H{"error": "room doesn't allow spectators"})
+		return
+	}
 	if !req.IsSpectator && activePlayers >= room.Config.MaxPlayers {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "room is full"})
 		return
@@ -737,13 +810,14 @@ func (h *Handlers) JoinRoom(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to join room"})
 		return
 	}
+	h.notifyLobby(room.ID)
 
 	// Get puzzle for response
 	puzzle, _ := h.db.GetPuzzleByID(room.PuzzleID)
 	sanitizedPuzzle := sanitizePuzzleForClient(puzzle)
 
 	// Get grid state
-	gridState, _ := h.db.GetGridState(room.ID)
+	gridState, _ := h.joinGridState(room, player)
 
 	c.JSON(http.StatusOK, gin.H{
 		"room":      room,
@@ -793,6 +867,17 @@ func (h *Handlers) JoinRoomByCode(c *gin.Context) {
 		return
 	}
 
+	banned, err := h.db.IsBanned(room.ID, claims.UserID)
+	if err != nil {
+		log.Printf("JoinRoomByCode: Database error checking bans: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if banned {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you are banned from this room"})
+		return
+	}
+
 	// Get current players
 	players, err := h.db.GetRoomPlayers(room.ID)
 	if err != nil {
@@ -801,6 +886,12 @@ func (h *Handlers) JoinRoomByCode(c *gin.Context) {
 		return
 	}
 
+	// A locked room only lets its players back in
+	if room.Config.Locked && !isRoomPlayer(players, claims.UserID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "room is locked"})
+		return
+	}
+
 	// Check if room is full (only for non-spectators)
 	activePlayers := 0
 	for _, p := range players {
@@ -809,6 +900,10 @@ func (h *Handlers) JoinRoomByCode(c *gin.Context) {
 		}
 	}
 
+	if req.IsSpectator && !room.Config.SpectatorMode {
+		c.JSON(http.StatusForbidden, gin.H{"error": "room doesn't allow spectators"})
+		return
+	}
 	if !req.IsSpectator && activePlayers >= room.Config.MaxPlayers {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "room is full"})
 		return
@@ -836,6 +931,7 @@ func (h *Handlers) JoinRoomByCode(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to join room"})
 		return
 	}
+	h.notifyLobby(room.ID)
 
 	// Get puzzle for response
 	puzzle, err := h.db.GetPuzzleByID(room.PuzzleID)
@@ -847,7 +943,7 @@ func (h *Handlers) JoinRoomByCode(c *gin.Context) {
 	sanitizedPuzzle := sanitizePuzzleForClient(puzzle)
 
 	// Get grid state
-	gridState, err := h.db.GetGridState(room.ID)
+	gridState, err := h.joinGridState(room, player)
 	if err != nil {
 		log.Printf("JoinRoomByCode: Failed to get grid state: %v", err)
 	}
@@ -900,6 +996,7 @@ func (h *Handlers) StartRoom(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start room"})
 		return
 	}
+	h.notifyLobby(room.ID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "room started"})
 }
@@ -931,10 +1028,151 @@ func (h *Handlers) CloseRoom(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to close room"})
 		return
 	}
+	h.notifyLobby(room.ID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "room closed"})
 }
 
+// GetRoomReplay returns the room's recorded cell, hint and cursor events in
+// order. The route shares its parameter with GetRoomByCode, so it accepts
+// either the room ID or its code. Players can fetch it once the game is over;
+// spectators can fetch it at any time, up to the room's spectator delay.
+func (h *Handlers) GetRoomReplay(c *gin.Context) {
+	claims := middleware.GetAuthUser(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	ref := c.Param("code")
+	room, err := h.db.GetRoomByID(ref)
+	if err == nil && room == nil {
+		room, err = h.db.GetRoomByCode(ref)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return
+	}
+
+	if room.State != models.RoomStateCompleted {
+		players, err := h.db.GetRoomPlayers(room.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		isSpectator := false
+		for _, p := range players {
+			if p.UserID == claims.UserID {
+				isSpectator = p.IsSpectator
+			}
+		}
+		if !isSpectator {
+			c.JSON(http.StatusForbidden, gin.H{"error": "replay is available to spectators or after the game"})
+			return
+		}
+	}
+
+	events, err := h.db.GetRoomEvents(room.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load replay"})
+		return
+	}
+
+	// Spectators of a running game only see as far as their delayed view
+	if room.State != models.RoomStateCompleted && room.Config.SpectatorDelay > 0 {
+		cutoff := time.Now().Add(-time.Duration(room.Config.SpectatorDelay) * time.Second)
+		n := 0
+		for n < len(events) && !events[n].CreatedAt.After(cutoff) {
+			n++
+		}
+		events = events[:n]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"roomId": room.ID,
+		"mode":   room.Mode,
+		"events": events,
+	})
+}
+
+// Chat history pages
+const (
+	defaultMessagePage = 50
+	maxMessagePage     = 100
+)
+
+// GetRoomMessages returns a page of the room's chat history to its players
+// and spectators, oldest first. Without ?before it is the latest messages;
+// ?before=<message ID> pages back from that message. nextCursor, when set, is
+// the ?before value of the previous page. Like GetRoomReplay, it takes the
+// room ID or code.
+func (h *Handlers) GetRoomMessages(c *gin.Context) {
+	claims := middleware.GetAuthUser(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	limit := defaultMessagePage
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxMessagePage {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+		limit = n
+	}
+
+	ref := c.Param("code")
+	room, err := h.db.GetRoomByID(ref)
+	if err == nil && room == nil {
+		room, err = h.db.GetRoomByCode(ref)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return
+	}
+
+	players, err := h.db.GetRoomPlayers(room.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	inRoom := false
+	for _, p := range players {
+		if p.UserID == claims.UserID {
+			inRoom = true
+		}
+	}
+	if !inRoom {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not in this room"})
+		return
+	}
+
+	messages, err := h.db.GetRoomMessagesBefore(room.ID, c.Query("before"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load messages"})
+		return
+	}
+	if messages == nil {
+		messages = []models.Message{}
+	}
+
+	response := gin.H{"messages": messages}
+	if len(messages) == limit {
+		response["nextCursor"] = messages[0].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
 // Helper functions
 
 // updateUserStatsAfterSoloPuzzle updates user stats after completing a solo puzzle
@@ -1016,6 +1254,25 @@ var playerColors = []string{
 	"#FFEAA7", "#DDA0DD", "#98D8C8", "#F7DC6F",
 }
 
+// joinGridState returns the grid to send a player who just joined. Spectators
+// of a room with a spectator delay get none: the live grid would run ahead of
+// their view, which comes from the delayed feed once they connect.
+func (h *Handlers) joinGridState(room *models.Room, player *models.Player) (*models.GridState, error) {
+	if player.IsSpectator && room.Config.SpectatorDelay > 0 {
+		return nil, nil
+	}
+	return h.db.GetGridState(room.ID)
+}
+
+func isRoomPlayer(players []models.Player, userID string) bool {
+	for _, p := range players {
+		if p.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
 func getPlayerColor(index int) string {
 	return playerColors[index%len(playerColors)]
 }
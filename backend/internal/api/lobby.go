@@ -0,0 +1,341 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/crossplay/backend/internal/middleware"
+	"github.com/crossplay/backend/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Public rooms are listed in the lobby until they start or are locked. Quick-
+// match puts a user into a public race room whose players have a similar
+// skill rating, or queues them until enough similar players are waiting and
+// then creates a room for all of them. Queued users poll the endpoint; once
+// matched, the next poll returns the room they were added to.
+
+const (
+	publicRoomsLimit = 50
+
+	quickMatchMinPlayers = 2
+	quickMatchMaxPlayers = 4
+	quickMatchSkillRange = 250              // largest rating difference between matched players
+	quickMatchMaxIdle    = 30 * time.Second // queued users who stop polling for longer are skipped
+
+	baseSkillRating = 1000
+)
+
+// LobbyNotifier is told when a room's lobby listing may have changed, so it
+// can update clients watching the lobby (see realtime.Hub.NotifyRoomChanged)
+type LobbyNotifier interface {
+	NotifyRoomChanged(roomID string)
+}
+
+// SetLobbyNotifier makes the handlers report room changes to n
+func (h *Handlers) SetLobbyNotifier(n LobbyNotifier) {
+	h.lobby = n
+}
+
+func (h *Handlers) notifyLobby(roomID string) {
+	if h.lobby != nil {
+		h.lobby.NotifyRoomChanged(roomID)
+	}
+}
+
+// ListPublicRooms lists public rooms waiting for players. Optional filters:
+// mode, difficulty and seats (open seats, default 1).
+func (h *Handlers) ListPublicRooms(c *gin.Context) {
+	filter := models.PublicRoomFilter{
+		Mode:       models.RoomMode(c.Query("mode")),
+		Difficulty: models.Difficulty(c.Query("difficulty")),
+	}
+	filter.MinSeats, _ = strconv.Atoi(c.DefaultQuery("seats", "1"))
+
+	rooms, err := h.db.ListPublicRooms(filter, publicRoomsLimit)
+	if err != nil {
+		log.Printf("ListPublicRooms: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rooms": rooms})
+}
+
+type QuickMatchRequest struct {
+	Difficulty models.Difficulty `json:"difficulty"` // optional
+}
+
+// QuickMatch puts the user into a race room with players of similar skill.
+// It answers 200 with the room once matched and 202 while the user waits.
+func (h *Handlers) QuickMatch(c *gin.Context) {
+	claims := middleware.GetAuthUser(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	var req QuickMatchRequest
+	c.ShouldBindJSON(&req) // Allow empty body for any difficulty
+
+	stats, err := h.db.GetUserStats(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	entry := &models.QuickMatchEntry{
+		UserID:      claims.UserID,
+		DisplayName: claims.DisplayName,
+		SkillRating: skillRating(stats),
+		Difficulty:  req.Difficulty,
+	}
+	if err := h.db.QueueQuickMatch(entry); err != nil {
+		log.Printf("QuickMatch: failed to queue %s: %v", claims.UserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to join quick-match"})
+		return
+	}
+
+	// Another player's request already put this user in a room
+	if entry.RoomID != "" {
+		room, err := h.db.GetRoomByID(entry.RoomID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		h.db.LeaveQuickMatch(claims.UserID)
+		if room != nil {
+			h.respondQuickMatch(c, room, claims.UserID)
+			return
+		}
+
+		// The room was closed before the user came back; queue them again
+		if err := h.db.QueueQuickMatch(entry); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to join quick-match"})
+			return
+		}
+	}
+
+	// Join a waiting room of similar players
+	rooms, err := h.db.ListPublicRooms(models.PublicRoomFilter{
+		Mode:       models.RoomModeRace,
+		Difficulty: req.Difficulty,
+		MinSeats:   1,
+	}, publicRoomsLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	rooms, err = withoutBannedRooms(rooms, func(roomID string) (bool, error) {
+		return h.db.IsBanned(roomID, claims.UserID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if match := pickQuickMatchRoom(rooms, entry.SkillRating); match != nil {
+		room, err := h.db.GetRoomByID(match.ID)
+		if err == nil && room != nil {
+			if err := h.addQuickMatchPlayer(room, *entry); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to join room"})
+				return
+			}
+			h.db.LeaveQuickMatch(claims.UserID)
+			h.notifyLobby(room.ID)
+			h.respondQuickMatch(c, room, claims.UserID)
+			return
+		}
+	}
+
+	h.matchQueuedPlayers(c, entry)
+}
+
+// matchQueuedPlayers creates a room for the user and the similar players
+// waiting with them, or answers 202 if there aren't enough yet
+func (h *Handlers) matchQueuedPlayers(c *gin.Context, entry *models.QuickMatchEntry) {
+	waiting := func(n int) {
+		c.JSON(http.StatusAccepted, gin.H{"status": "queued", "waiting": n})
+	}
+
+	candidates, err := h.db.CountQuickMatchCandidates(entry, quickMatchSkillRange, quickMatchMaxIdle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if candidates < quickMatchMinPlayers {
+		waiting(candidates)
+		return
+	}
+
+	puzzle, err := h.db.GetRandomPuzzle(string(entry.Difficulty))
+	if err != nil || puzzle == nil {
+		log.Printf("QuickMatch: no puzzle for difficulty %q: %v", entry.Difficulty, err)
+		waiting(0)
+		return
+	}
+
+	// The room exists before anyone is claimed for it, so a claimed user's
+	// next poll always finds it. It is listed once the claim succeeds.
+	room := &models.Room{
+		ID:       uuid.New().String(),
+		Code:     generateRoomCode(),
+		HostID:   entry.UserID,
+		PuzzleID: puzzle.ID,
+		Mode:     models.RoomModeRace,
+		Config: models.RoomConfig{
+			MaxPlayers:  quickMatchMaxPlayers,
+			TimerMode:   "none",
+			QuickMatch:  true,
+			SkillRating: entry.SkillRating,
+			Ranked:      true,
+		},
+		State:     models.RoomStateLobby,
+		CreatedAt: time.Now(),
+	}
+	if err := h.db.CreateRoom(room); err != nil {
+		log.Printf("QuickMatch: failed to create room: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create room"})
+		return
+	}
+
+	claimed, err := h.db.ClaimQuickMatchPlayers(room.ID, entry, quickMatchSkillRange, quickMatchMaxPlayers, quickMatchMaxIdle)
+	if err != nil || len(claimed) < quickMatchMinPlayers {
+		if err != nil {
+			log.Printf("QuickMatch: failed to claim players: %v", err)
+		}
+		h.db.ReleaseQuickMatchPlayers(room.ID)
+		h.db.DeleteRoom(room.ID)
+		waiting(len(claimed))
+		return
+	}
+
+	if err := h.db.CreateGridState(initializeGridState(room.ID, puzzle)); err != nil {
+		log.Printf("QuickMatch: failed to initialize grid for room %s: %v", room.ID, err)
+	}
+	for _, e := range claimed {
+		if err := h.addQuickMatchPlayer(room, e); err != nil {
+			log.Printf("QuickMatch: failed to add %s to room %s: %v", e.UserID, room.ID, err)
+		}
+	}
+	h.db.LeaveQuickMatch(entry.UserID)
+
+	room.Config.IsPublic = true
+	if err := h.db.UpdateRoomConfig(room.ID, room.Config); err != nil {
+		log.Printf("QuickMatch: failed to list room %s: %v", room.ID, err)
+	}
+	h.notifyLobby(room.ID)
+
+	h.respondQuickMatch(c, room, entry.UserID)
+}
+
+// addQuickMatchPlayer adds a matched user to a room as a player
+func (h *Handlers) addQuickMatchPlayer(room *models.Room, entry models.QuickMatchEntry) error {
+	players, err := h.db.GetRoomPlayers(room.ID)
+	if err != nil {
+		return err
+	}
+	return h.db.AddPlayer(&models.Player{
+		UserID:      entry.UserID,
+		RoomID:      room.ID,
+		DisplayName: entry.DisplayName,
+		IsConnected: true,
+		Color:       getPlayerColor(len(players)),
+		JoinedAt:    time.Now(),
+	})
+}
+
+// respondQuickMatch answers with the room the user was matched into, like JoinRoom
+func (h *Handlers) respondQuickMatch(c *gin.Context, room *models.Room, userID string) {
+	players, _ := h.db.GetRoomPlayers(room.ID)
+	puzzle, _ := h.db.GetPuzzleByID(room.PuzzleID)
+	gridState, _ := h.db.GetGridState(room.ID)
+
+	var player *models.Player
+	for i := range players {
+		if players[i].UserID == userID {
+			player = &players[i]
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "matched",
+		"room":      room,
+		"player":    player,
+		"puzzle":    sanitizePuzzleForClient(puzzle),
+		"gridState": gridState,
+	})
+}
+
+// LeaveQuickMatch takes the user out of the quick-match queue
+func (h *Handlers) LeaveQuickMatch(c *gin.Context) {
+	claims := middleware.GetAuthUser(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	if err := h.db.LeaveQuickMatch(claims.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to leave quick-match"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "left quick-match"})
+}
+
+// skillRating scores a player for quick-match. Solved puzzles and
+// multiplayer wins raise it (up to 50 and 40 of each count), and an average
+// solve under 20 minutes adds up to 300 more.
+func skillRating(stats *models.UserStats) int {
+	if stats == nil {
+		return baseSkillRating
+	}
+
+	rating := baseSkillRating + 10*min(stats.PuzzlesSolved, 50) + 25*min(stats.MultiplayerWins, 40)
+	if stats.AvgSolveTime > 0 && stats.AvgSolveTime < 1200 {
+		rating += int((1200 - stats.AvgSolveTime) / 4)
+	}
+	return rating
+}
+
+// withoutBannedRooms drops the rooms the user is banned from, so quick-match
+// never puts them back in a room they were banned from
+func withoutBannedRooms(rooms []models.PublicRoom, isBanned func(roomID string) (bool, error)) ([]models.PublicRoom, error) {
+	allowed := make([]models.PublicRoom, 0, len(rooms))
+	for _, room := range rooms {
+		banned, err := isBanned(room.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !banned {
+			allowed = append(allowed, room)
+		}
+	}
+	return allowed, nil
+}
+
+// pickQuickMatchRoom returns the waiting quick-match room whose rating is
+// closest to rating and within quickMatchSkillRange, preferring fuller rooms on a tie
+func pickQuickMatchRoom(rooms []models.PublicRoom, rating int) *models.PublicRoom {
+	var best *models.PublicRoom
+	bestDiff := 0
+	for i := range rooms {
+		room := &rooms[i]
+		if !room.QuickMatch || room.OpenSeats < 1 {
+			continue
+		}
+		diff := room.SkillRating - rating
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > quickMatchSkillRange {
+			continue
+		}
+		if best == nil || diff < bestDiff || (diff == bestDiff && room.Players > best.Players) {
+			best, bestDiff = room, diff
+		}
+	}
+	return best
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+func TestSkillRating(t *testing.T) {
+	if got := skillRating(nil); got != baseSkillRating {
+		t.Errorf("New player rating = %d, want %d", got, baseSkillRating)
+	}
+
+	casual := skillRating(&models.UserStats{PuzzlesSolved: 3, AvgSolveTime: 1500})
+	regular := skillRating(&models.UserStats{PuzzlesSolved: 40, MultiplayerWins: 5, AvgSolveTime: 600})
+	if !(casual < regular) {
+		t.Errorf("Expected casual (%d) < regular (%d)", casual, regular)
+	}
+
+	// Counts stop adding up past their caps
+	capped := skillRating(&models.UserStats{PuzzlesSolved: 50, MultiplayerWins: 40})
+	if got := skillRating(&models.UserStats{PuzzlesSolved: 500, MultiplayerWins: 400}); got != capped {
+		t.Errorf("Rating past the caps = %d, want %d", got, capped)
+	}
+}
+
+func TestPickQuickMatchRoom(t *testing.T) {
+	rooms := []models.PublicRoom{
+		{ID: "custom", SkillRating: 1000, OpenSeats: 3},
+		{ID: "full", QuickMatch: true, SkillRating: 1000, OpenSeats: 0},
+		{ID: "far", QuickMatch: true, SkillRating: 1000 + quickMatchSkillRange + 1, OpenSeats: 3},
+		{ID: "near", QuickMatch: true, SkillRating: 1100, Players: 1, OpenSeats: 3},
+		{ID: "nearer", QuickMatch: true, SkillRating: 1050, Players: 1, OpenSeats: 3},
+		{ID: "nearer-fuller", QuickMatch: true, SkillRating: 950, Players: 2, OpenSeats: 2},
+	}
+
+	if got := pickQuickMatchRoom(rooms, 1000); got == nil || got.ID != "nearer-fuller" {
+		t.Errorf("pickQuickMatchRoom = %+v, want nearer-fuller", got)
+	}
+	if got := pickQuickMatchRoom(rooms[:3], 1000); got != nil {
+		t.Errorf("Expected no match among custom, full and far rooms, got %s", got.ID)
+	}
+}
+
+func TestQuickMatchSkipsBannedRooms(t *testing.T) {
+	rooms := []models.PublicRoom{
+		{ID: "banned", QuickMatch: true, SkillRating: 1000, Players: 2, OpenSeats: 2},
+		{ID: "other", QuickMatch: true, SkillRating: 1100, Players: 1, OpenSeats: 3},
+	}
+	isBanned := func(roomID string) (bool, error) { return roomID == "banned", nil }
+
+	allowed, err := withoutBannedRooms(rooms, isBanned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pickQuickMatchRoom(allowed, 1000); got == nil || got.ID != "other" {
+		t.Errorf("pickQuickMatchRoom = %+v, want the room the user isn't banned from", got)
+	}
+
+	// A ban list that can't be read matches nobody
+	failing := func(roomID string) (bool, error) { return false, errors.New("connection refused") }
+	if _, err := withoutBannedRooms(rooms, failing); err == nil {
+		t.Error("Expected the ban check error")
+	}
+}
@@ -0,0 +1,392 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/crossplay/backend/internal/middleware"
+	"github.com/crossplay/backend/internal/models"
+	"github.com/crossplay/backend/internal/tournament"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Tournaments are created and advanced with the admin CLI (see "admin
+// tournament"); users register over REST while registration is open. Each
+// round's heats are locked race rooms with their players already in them, the
+// top seed as host. When a heat's race ends the hub reports the finishing
+// order (see HeatFinished), and once every heat of the round has finished the
+// next round is drawn, or the tournament ends.
+
+const tournamentsLimit = 50
+
+// errTournamentNotFound is returned by AdvanceTournament for an unknown ID
+var errTournamentNotFound = errors.New("tournament not found")
+
+// TournamentRound is one round of a tournament's bracket
+type TournamentRound struct {
+	Round int                     `json:"round"`
+	Heats []models.TournamentHeat `json:"heats"`
+}
+
+// ListTournaments lists tournaments, newest first. Optional filter: status.
+func (h *Handlers) ListTournaments(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(tournamentsLimit)))
+	if limit < 1 || limit > tournamentsLimit {
+		limit = tournamentsLimit
+	}
+
+	tournaments, err := h.db.ListTournaments(models.TournamentStatus(c.Query("status")), limit)
+	if err != nil {
+		log.Printf("ListTournaments: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if tournaments == nil {
+		tournaments = []models.Tournament{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tournaments": tournaments})
+}
+
+// GetTournament returns a tournament with its players and its bracket: the
+// heats of each round played so far, with their rooms and results
+func (h *Handlers) GetTournament(c *gin.Context) {
+	t, players, heats, ok := h.loadTournament(c)
+	if !ok {
+		return
+	}
+
+	rounds := make([]TournamentRound, 0, t.Round)
+	for round := 1; round <= t.Round; round++ {
+		rounds = append(rounds, TournamentRound{Round: round, Heats: tournament.RoundHeats(heats, round)})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tournament": t,
+		"players":    players,
+		"rounds":     rounds,
+	})
+}
+
+// GetTournamentStandings ranks a tournament's players
+func (h *Handlers) GetTournamentStandings(c *gin.Context) {
+	t, players, heats, ok := h.loadTournament(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tournament": t,
+		"standings":  tournament.Standings(t, players, heats),
+	})
+}
+
+// loadTournament loads the tournament named in the URL with its players and
+// heats, answering the request itself if it can't
+func (h *Handlers) loadTournament(c *gin.Context) (*models.Tournament, []models.TournamentPlayer, []models.TournamentHeat, bool) {
+	t, err := h.db.GetTournament(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return nil, nil, nil, false
+	}
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tournament not found"})
+		return nil, nil, nil, false
+	}
+
+	players, err := h.db.GetTournamentPlayers(t.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return nil, nil, nil, false
+	}
+	heats, err := h.db.GetTournamentHeats(t.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return nil, nil, nil, false
+	}
+	if players == nil {
+		players = []models.TournamentPlayer{}
+	}
+	return t, players, heats, true
+}
+
+// RegisterForTournament signs the user up for a tournament that hasn't started.
+// Their quick-match skill rating decides their seed.
+func (h *Handlers) RegisterForTournament(c *gin.Context) {
+	claims := middleware.GetAuthUser(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	t, ok := h.registeringTournament(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.db.GetUserStats(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	player := &models.TournamentPlayer{
+		TournamentID: t.ID,
+		UserID:       claims.UserID,
+		DisplayName:  claims.DisplayName,
+		Rating:       skillRating(stats),
+		RegisteredAt: time.Now(),
+	}
+	if err := h.db.AddTournamentPlayer(player); err != nil {
+		log.Printf("RegisterForTournament: failed to register %s for %s: %v", claims.UserID, t.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tournament": t, "player": player})
+}
+
+// UnregisterFromTournament takes the user out of a tournament that hasn't started
+func (h *Handlers) UnregisterFromTournament(c *gin.Context) {
+	claims := middleware.GetAuthUser(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "not authenticated"})
+		return
+	}
+
+	t, ok := h.registeringTournament(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.RemoveTournamentPlayer(t.ID, claims.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unregister"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "unregistered"})
+}
+
+// registeringTournament loads the tournament named in the URL if it is open
+// for registration, answering the request itself otherwise
+func (h *Handlers) registeringTournament(c *gin.Context) (*models.Tournament, bool) {
+	t, err := h.db.GetTournament(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return nil, false
+	}
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tournament not found"})
+		return nil, false
+	}
+	if t.Status != models.TournamentStatusRegistration {
+		c.JSON(http.StatusConflict, gin.H{"error": tournament.ErrNotRegistering.Error()})
+		return nil, false
+	}
+	return t, true
+}
+
+// AdvanceTournament moves a tournament on: it starts a tournament still
+// taking registrations with its first round, and otherwise draws the next
+// round or ends the tournament once the current round is over. With force,
+// heats still playing are closed first, their players placed by seed after
+// anyone who already finished.
+func (h *Handlers) AdvanceTournament(id string, force bool) (*models.Tournament, error) {
+	t, err := h.db.GetTournament(id)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, errTournamentNotFound
+	}
+
+	switch t.Status {
+	case models.TournamentStatusCompleted:
+		return t, tournament.ErrOver
+
+	case models.TournamentStatusRegistration:
+		players, err := h.db.GetTournamentPlayers(t.ID)
+		if err != nil {
+			return t, err
+		}
+		seeded, err := tournament.Seed(players)
+		if err != nil {
+			return t, err
+		}
+		started, err := h.db.StartTournament(t.ID, seeded)
+		if err != nil {
+			return t, err
+		}
+		if !started {
+			return h.db.GetTournament(t.ID)
+		}
+		t.Status = models.TournamentStatusRunning
+		return t, h.startRound(t, seeded)
+	}
+
+	if t.Round == 0 {
+		// Started, but the first round couldn't be drawn
+		players, err := h.db.GetTournamentPlayers(t.ID)
+		if err != nil {
+			return t, err
+		}
+		return t, h.startRound(t, players)
+	}
+
+	heats, err := h.db.GetTournamentHeats(t.ID)
+	if err != nil {
+		return t, err
+	}
+	roundHeats := tournament.RoundHeats(heats, t.Round)
+	if !tournament.RoundFinished(roundHeats) {
+		if !force {
+			return t, tournament.ErrRoundInProgress
+		}
+		for i := range roundHeats {
+			if roundHeats[i].FinishedAt == nil {
+				if err := h.finishHeat(t, &roundHeats[i], nil, len(roundHeats) == 1); err != nil {
+					return t, err
+				}
+			}
+		}
+		if heats, err = h.db.GetTournamentHeats(t.ID); err != nil {
+			return t, err
+		}
+	}
+
+	players, err := h.db.GetTournamentPlayers(t.ID)
+	if err != nil {
+		return t, err
+	}
+	if tournament.Over(t, roundHeats) {
+		standings := tournament.Standings(t, players, heats)
+		if _, err := h.db.CompleteTournament(t.ID, standings[0].UserID); err != nil {
+			return t, err
+		}
+		log.Printf("AdvanceTournament: %s won by %s", t.ID, standings[0].UserID)
+		return h.db.GetTournament(t.ID)
+	}
+	return t, h.startRound(t, players)
+}
+
+// startRound draws the tournament's next round and creates a race room for
+// each heat. Only one caller gets to start a round.
+func (h *Handlers) startRound(t *models.Tournament, players []models.TournamentPlayer) error {
+	puzzle, err := h.db.GetRandomPuzzle(string(t.Difficulty))
+	if err != nil || puzzle == nil {
+		return fmt.Errorf("no puzzle for difficulty %q: %v", t.Difficulty, err)
+	}
+
+	round := t.Round + 1
+	claimed, err := h.db.ClaimTournamentRound(t.ID, t.Round, round)
+	if err != nil || !claimed {
+		return err
+	}
+	t.Round = round
+
+	names := make(map[string]string, len(players))
+	for _, p := range players {
+		names[p.UserID] = p.DisplayName
+	}
+
+	for i, heatPlayers := range tournament.Draw(t, players) {
+		room := &models.Room{
+			ID:       uuid.New().String(),
+			Code:     generateRoomCode(),
+			HostID:   heatPlayers[0],
+			PuzzleID: puzzle.ID,
+			Mode:     models.RoomModeRace,
+			Config: models.RoomConfig{
+				MaxPlayers:   len(heatPlayers),
+				TimerMode:    "none",
+				Locked:       true,
+				Ranked:       true,
+				TournamentID: t.ID,
+			},
+			State:     models.RoomStateLobby,
+			CreatedAt: time.Now(),
+		}
+		if t.TimerSeconds > 0 {
+			room.Config.TimerMode = "countdown"
+			room.Config.TimerSeconds = t.TimerSeconds
+		}
+		if err := h.db.CreateRoom(room); err != nil {
+			return fmt.Errorf("failed to create room for heat %d: %w", i+1, err)
+		}
+		if err := h.db.CreateGridState(initializeGridState(room.ID, puzzle)); err != nil {
+			log.Printf("startRound: failed to initialize grid for room %s: %v", room.ID, err)
+		}
+		for j, userID := range heatPlayers {
+			if err := h.db.AddPlayer(&models.Player{
+				UserID:      userID,
+				RoomID:      room.ID,
+				DisplayName: names[userID],
+				Color:       getPlayerColor(j),
+				JoinedAt:    time.Now(),
+			}); err != nil {
+				log.Printf("startRound: failed to add %s to room %s: %v", userID, room.ID, err)
+			}
+		}
+
+		if err := h.db.CreateTournamentHeat(&models.TournamentHeat{
+			ID:           uuid.New().String(),
+			TournamentID: t.ID,
+			Round:        round,
+			Number:       i + 1,
+			RoomID:       room.ID,
+			RoomCode:     room.Code,
+			Players:      heatPlayers,
+		}); err != nil {
+			return fmt.Errorf("failed to record heat %d: %w", i+1, err)
+		}
+	}
+
+	log.Printf("startRound: tournament %s round %d started", t.ID, round)
+	return nil
+}
+
+// finishHeat records a heat's results from its finishing order and scores them
+func (h *Handlers) finishHeat(t *models.Tournament, heat *models.TournamentHeat, finishOrder []string, final bool) error {
+	heat.Results = tournament.OrderResults(heat.Players, finishOrder)
+	points, eliminated := tournament.ScoreHeat(t, heat, final)
+	_, err := h.db.FinishTournamentHeat(heat, points, eliminated)
+	return err
+}
+
+// HeatFinished records the finishing order of a tournament heat's race and
+// moves the tournament on if that was the last heat of the round. The hub
+// calls it for every finished game in a tournament room (see
+// realtime.HeatReporter).
+func (h *Handlers) HeatFinished(roomID string, finishOrder []string) {
+	heat, err := h.db.GetTournamentHeatByRoom(roomID)
+	if err != nil || heat == nil {
+		if err != nil {
+			log.Printf("HeatFinished: room %s: %v", roomID, err)
+		}
+		return
+	}
+	t, err := h.db.GetTournament(heat.TournamentID)
+	if err != nil || t == nil || t.Status != models.TournamentStatusRunning || t.Round != heat.Round {
+		return
+	}
+
+	heats, err := h.db.GetTournamentHeats(t.ID)
+	if err != nil {
+		log.Printf("HeatFinished: tournament %s: %v", t.ID, err)
+		return
+	}
+	final := len(tournament.RoundHeats(heats, t.Round)) == 1
+	if err := h.finishHeat(t, heat, finishOrder, final); err != nil {
+		log.Printf("HeatFinished: failed to record heat %s: %v", heat.ID, err)
+		return
+	}
+
+	if _, err := h.AdvanceTournament(t.ID, false); err != nil && !errors.Is(err, tournament.ErrRoundInProgress) {
+		log.Printf("HeatFinished: failed to advance tournament %s: %v", t.ID, err)
+	}
+}
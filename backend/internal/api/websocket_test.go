@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -43,7 +44,9 @@ func setupTestServer(t *testing.T) (*gin.Engine, *db.Database, *realtime.Hub, *a
 
 	// Initialize WebSocket hub
 	hub := realtime.NewHub(database)
-	go hub.Run()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go hub.Run(ctx)
 
 	// Setup router
 	router := gin.New()
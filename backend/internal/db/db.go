@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/crossplay/backend/internal/models"
+	"github.com/crossplay/backend/pkg/clues"
 	_ "github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 )
@@ -129,11 +130,13 @@ func (d *Database) InitSchema() error {
 		PRIMARY KEY (user_id, room_id)
 	);
 
+	-- user_id is the player for race grids, "team:<id>" for team grids and '' for the room's shared grid
 	CREATE TABLE IF NOT EXISTS grid_states (
 		room_id VARCHAR(36) REFERENCES rooms(id) ON DELETE CASCADE,
-		user_id VARCHAR(36) DEFAULT '' REFERENCES users(id) ON DELETE CASCADE,
+		user_id VARCHAR(36) DEFAULT '',
 		cells JSONB NOT NULL,
 		completed_clues JSONB DEFAULT '[]',
+		clue_notes JSONB NOT NULL DEFAULT '{}',
 		last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		PRIMARY KEY (room_id, user_id)
 	);
@@ -153,11 +156,33 @@ func (d *Database) InitSchema() error {
 		user_id VARCHAR(36) REFERENCES users(id) ON DELETE CASCADE,
 		display_name VARCHAR(100) NOT NULL,
 		text TEXT NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		deleted_at TIMESTAMP
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_messages_room_id ON messages(room_id);
 
+	CREATE TABLE IF NOT EXISTS room_events (
+		id BIGSERIAL PRIMARY KEY,
+		room_id VARCHAR(36) REFERENCES rooms(id) ON DELETE CASCADE,
+		user_id VARCHAR(36) NOT NULL DEFAULT '',
+		type VARCHAR(10) NOT NULL,
+		x SMALLINT NOT NULL DEFAULT 0,
+		y SMALLINT NOT NULL DEFAULT 0,
+		value VARCHAR(10) NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_room_events_room_id ON room_events(room_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS room_bans (
+		room_id VARCHAR(36) REFERENCES rooms(id) ON DELETE CASCADE,
+		user_id VARCHAR(36) REFERENCES users(id) ON DELETE CASCADE,
+		banned_by VARCHAR(36) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (room_id, user_id)
+	);
+
 	CREATE TABLE IF NOT EXISTS reactions (
 		id VARCHAR(36) PRIMARY KEY,
 		room_id VARCHAR(36) REFERENCES rooms(id) ON DELETE CASCADE,
@@ -181,6 +206,66 @@ func (d *Database) InitSchema() error {
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
 
+	CREATE TABLE IF NOT EXISTS quick_match_queue (
+		user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		display_name VARCHAR(100) NOT NULL,
+		skill_rating INTEGER NOT NULL,
+		difficulty VARCHAR(20) NOT NULL DEFAULT '',
+		room_id VARCHAR(36),
+		queued_at TIMESTAMP NOT NULL,
+		polled_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS tournaments (
+		id VARCHAR(36) PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		format VARCHAR(20) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		heat_size INTEGER NOT NULL,
+		advance INTEGER NOT NULL DEFAULT 0,
+		rounds INTEGER NOT NULL DEFAULT 0,
+		round INTEGER NOT NULL DEFAULT 0,
+		difficulty VARCHAR(20) NOT NULL DEFAULT '',
+		timer_seconds INTEGER NOT NULL DEFAULT 0,
+		winner_id VARCHAR(36) REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		started_at TIMESTAMP,
+		ended_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS tournament_players (
+		tournament_id VARCHAR(36) REFERENCES tournaments(id) ON DELETE CASCADE,
+		user_id VARCHAR(36) REFERENCES users(id) ON DELETE CASCADE,
+		display_name VARCHAR(100) NOT NULL,
+		rating INTEGER NOT NULL DEFAULT 0,
+		seed INTEGER NOT NULL DEFAULT 0,
+		points INTEGER NOT NULL DEFAULT 0,
+		eliminated BOOLEAN NOT NULL DEFAULT FALSE,
+		registered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (tournament_id, user_id)
+	);
+
+	-- Heats outlive their rooms, so room_id is not a foreign key
+	CREATE TABLE IF NOT EXISTS tournament_heats (
+		id VARCHAR(36) PRIMARY KEY,
+		tournament_id VARCHAR(36) REFERENCES tournaments(id) ON DELETE CASCADE,
+		round INTEGER NOT NULL,
+		number INTEGER NOT NULL,
+		room_id VARCHAR(36) NOT NULL,
+		room_code VARCHAR(6) NOT NULL,
+		players JSONB NOT NULL DEFAULT '[]',
+		results JSONB NOT NULL DEFAULT '[]',
+		finished_at TIMESTAMP,
+		UNIQUE (tournament_id, round, number)
+	);
+
+	-- Upgrades for databases created before team mode, chat muting, clue notes and message deletion
+	ALTER TABLE players ADD COLUMN IF NOT EXISTS team_id VARCHAR(20) NOT NULL DEFAULT '';
+	ALTER TABLE players ADD COLUMN IF NOT EXISTS is_muted BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE grid_states DROP CONSTRAINT IF EXISTS grid_states_user_id_fkey;
+	ALTER TABLE grid_states ADD COLUMN IF NOT EXISTS clue_notes JSONB NOT NULL DEFAULT '{}';
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
 	CREATE INDEX IF NOT EXISTS idx_puzzle_history_user_id ON puzzle_history(user_id);
 	CREATE INDEX IF NOT EXISTS idx_puzzle_history_puzzle_id ON puzzle_history(puzzle_id);
 	CREATE INDEX IF NOT EXISTS idx_puzzle_history_completed_at ON puzzle_history(completed_at);
@@ -188,8 +273,12 @@ func (d *Database) InitSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_rooms_host_id ON rooms(host_id);
 	CREATE INDEX IF NOT EXISTS idx_players_room_id ON players(room_id);
 	CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+	CREATE INDEX IF NOT EXISTS idx_tournament_heats_room_id ON tournament_heats(room_id);
 	`
 
+	// Clue cache shared with the crossgen CLI (see "crossgen clues sync")
+	schema += clues.PostgresSchema
+
 	_, err := d.DB.Exec(schema)
 	return err
 }
@@ -597,6 +686,57 @@ func (d *Database) GetRandomPuzzleForUser(userID, difficulty string) (*models.Pu
 	return puzzle, nil
 }
 
+// GetNextArchivePuzzle returns the published puzzle that follows puzzleID in
+// the archive (newest first), wrapping round to the newest. It returns nil if
+// there is no other published puzzle.
+func (d *Database) GetNextArchivePuzzle(puzzleID string) (*models.Puzzle, error) {
+	const columns = `id, date, title, author, difficulty, grid_width, grid_height,
+			   grid, clues_across, clues_down, theme, avg_solve_time, status, created_at, published_at`
+
+	// The current puzzle may not be in the archive itself; then the archive starts over
+	next, err := d.scanPuzzle(d.DB.QueryRow(`
+		SELECT `+columns+`
+		FROM puzzles WHERE status = 'published' AND id != $1
+		  AND (COALESCE(published_at, created_at), date) < (
+			SELECT COALESCE(published_at, created_at), date FROM puzzles WHERE id = $1
+		  )
+		ORDER BY COALESCE(published_at, created_at) DESC, date DESC
+		LIMIT 1
+	`, puzzleID))
+	if err != nil || next != nil {
+		return next, err
+	}
+
+	return d.scanPuzzle(d.DB.QueryRow(`
+		SELECT `+columns+`
+		FROM puzzles WHERE status = 'published' AND id != $1
+		ORDER BY COALESCE(published_at, created_at) DESC, date DESC
+		LIMIT 1
+	`, puzzleID))
+}
+
+// scanPuzzle reads a puzzle row, returning nil if there is none
+func (d *Database) scanPuzzle(row *sql.Row) (*models.Puzzle, error) {
+	puzzle := &models.Puzzle{}
+	var gridJSON, cluesAcrossJSON, cluesDownJSON []byte
+
+	err := row.Scan(&puzzle.ID, &puzzle.Date, &puzzle.Title, &puzzle.Author, &puzzle.Difficulty,
+		&puzzle.GridWidth, &puzzle.GridHeight, &gridJSON, &cluesAcrossJSON, &cluesDownJSON,
+		&puzzle.Theme, &puzzle.AvgSolveTime, &puzzle.Status, &puzzle.CreatedAt, &puzzle.PublishedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(gridJSON, &puzzle.Grid)
+	json.Unmarshal(cluesAcrossJSON, &puzzle.CluesAcross)
+	json.Unmarshal(cluesDownJSON, &puzzle.CluesDown)
+
+	return puzzle, nil
+}
+
 func (d *Database) UpdatePuzzleStatus(id, status string) error {
 	query := `UPDATE puzzles SET status = $2`
 	if status == "published" {
@@ -693,6 +833,49 @@ func (d *Database) UpdateRoomState(id string, state models.RoomState) error {
 	return err
 }
 
+func (d *Database) UpdateRoomHost(id, hostID string) error {
+	_, err := d.DB.Exec(`UPDATE rooms SET host_id = $2 WHERE id = $1`, id, hostID)
+	return err
+}
+
+func (d *Database) UpdateRoomConfig(id string, config models.RoomConfig) error {
+	configJSON, _ := json.Marshal(config)
+	_, err := d.DB.Exec(`UPDATE rooms SET config = $2 WHERE id = $1`, id, configJSON)
+	return err
+}
+
+// ResetRoomForRematch puts a finished room back in the lobby with a new
+// puzzle. Players, teams, chat and bans stay; the grids, relay turns, event
+// log and contributions of the last game go.
+func (d *Database) ResetRoomForRematch(id, puzzleID string) error {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin rematch: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE rooms SET puzzle_id = $2, state = $3, started_at = NULL, ended_at = NULL WHERE id = $1`, []interface{}{id, puzzleID, models.RoomStateLobby}},
+		{`UPDATE players SET contribution = 0 WHERE room_id = $1`, []interface{}{id}},
+		{`DELETE FROM grid_states WHERE room_id = $1`, []interface{}{id}},
+		{`DELETE FROM relay_states WHERE room_id = $1`, []interface{}{id}},
+		{`DELETE FROM room_events WHERE room_id = $1`, []interface{}{id}},
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+			return fmt.Errorf("failed to reset room %s: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rematch: %w", err)
+	}
+	return nil
+}
+
 func (d *Database) DeleteRoom(id string) error {
 	_, err := d.DB.Exec(`DELETE FROM rooms WHERE id = $1`, id)
 	return err
@@ -701,19 +884,19 @@ func (d *Database) DeleteRoom(id string) error {
 // Player operations
 func (d *Database) AddPlayer(player *models.Player) error {
 	_, err := d.DB.Exec(`
-		INSERT INTO players (user_id, room_id, display_name, cursor_x, cursor_y, is_spectator, is_connected, contribution, color, joined_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO players (user_id, room_id, display_name, cursor_x, cursor_y, is_spectator, is_connected, contribution, color, team_id, joined_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		ON CONFLICT (user_id, room_id) DO UPDATE SET
 			is_connected = EXCLUDED.is_connected,
 			display_name = EXCLUDED.display_name
 	`, player.UserID, player.RoomID, player.DisplayName, player.CursorX, player.CursorY,
-		player.IsSpectator, player.IsConnected, player.Contribution, player.Color, player.JoinedAt)
+		player.IsSpectator, player.IsConnected, player.Contribution, player.Color, player.TeamID, player.JoinedAt)
 	return err
 }
 
 func (d *Database) GetRoomPlayers(roomID string) ([]models.Player, error) {
 	rows, err := d.DB.Query(`
-		SELECT user_id, room_id, display_name, cursor_x, cursor_y, is_spectator, is_connected, contribution, color, joined_at
+		SELECT user_id, room_id, display_name, cursor_x, cursor_y, is_spectator, is_connected, contribution, color, team_id, is_muted, joined_at
 		FROM players WHERE room_id = $1
 	`, roomID)
 	if err != nil {
@@ -725,7 +908,7 @@ func (d *Database) GetRoomPlayers(roomID string) ([]models.Player, error) {
 	for rows.Next() {
 		var player models.Player
 		err := rows.Scan(&player.UserID, &player.RoomID, &player.DisplayName, &player.CursorX, &player.CursorY,
-			&player.IsSpectator, &player.IsConnected, &player.Contribution, &player.Color, &player.JoinedAt)
+			&player.IsSpectator, &player.IsConnected, &player.Contribution, &player.Color, &player.TeamID, &player.IsMuted, &player.JoinedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -756,15 +939,460 @@ func (d *Database) UpdatePlayerContribution(userID, roomID string, contribution
 	return err
 }
 
+func (d *Database) UpdatePlayerTeam(userID, roomID, teamID string) error {
+	_, err := d.DB.Exec(`
+		UPDATE players SET team_id = $3 WHERE user_id = $1 AND room_id = $2
+	`, userID, roomID, teamID)
+	return err
+}
+
+func (d *Database) UpdatePlayerMuted(userID, roomID string, muted bool) error {
+	_, err := d.DB.Exec(`
+		UPDATE players SET is_muted = $3 WHERE user_id = $1 AND room_id = $2
+	`, userID, roomID, muted)
+	return err
+}
+
 func (d *Database) RemovePlayer(userID, roomID string) error {
 	_, err := d.DB.Exec(`DELETE FROM players WHERE user_id = $1 AND room_id = $2`, userID, roomID)
 	return err
 }
 
+// Ban operations
+func (d *Database) BanPlayer(roomID, userID, bannedBy string) error {
+	_, err := d.DB.Exec(`
+		INSERT INTO room_bans (room_id, user_id, banned_by, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (room_id, user_id) DO NOTHING
+	`, roomID, userID, bannedBy, time.Now())
+	return err
+}
+
+func (d *Database) IsBanned(roomID, userID string) (bool, error) {
+	var banned bool
+	err := d.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM room_bans WHERE room_id = $1 AND user_id = $2)
+	`, roomID, userID).Scan(&banned)
+	return banned, err
+}
+
+// Public room operations
+
+// publicRoomsQuery lists public rooms that are still in their lobby and not locked
+const publicRoomsQuery = `
+	SELECT * FROM (
+		SELECT r.id, r.code, r.mode, COALESCE(host.display_name, ''), p.title, p.difficulty,
+			(SELECT COUNT(*) FROM players pl WHERE pl.room_id = r.id AND NOT pl.is_spectator) AS player_count,
+			COALESCE((r.config->>'maxPlayers')::int, 0) AS max_players,
+			COALESCE((r.config->>'quickMatch')::boolean, FALSE),
+			COALESCE((r.config->>'skillRating')::int, 0),
+			r.created_at
+		FROM rooms r
+		JOIN puzzles p ON p.id = r.puzzle_id
+		LEFT JOIN players host ON host.room_id = r.id AND host.user_id = r.host_id
+		WHERE r.state = 'lobby'
+			AND COALESCE((r.config->>'isPublic')::boolean, FALSE)
+			AND NOT COALESCE((r.config->>'locked')::boolean, FALSE)
+	) listed`
+
+func scanPublicRoom(scan func(dest ...interface{}) error) (*models.PublicRoom, error) {
+	room := &models.PublicRoom{}
+	err := scan(&room.ID, &room.Code, &room.Mode, &room.HostName, &room.PuzzleTitle, &room.Difficulty,
+		&room.Players, &room.MaxPlayers, &room.QuickMatch, &room.SkillRating, &room.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	room.OpenSeats = room.MaxPlayers - room.Players
+	if room.OpenSeats < 0 {
+		room.OpenSeats = 0
+	}
+	return room, nil
+}
+
+// ListPublicRooms returns the public rooms matching filter, newest first
+func (d *Database) ListPublicRooms(filter models.PublicRoomFilter, limit int) ([]models.PublicRoom, error) {
+	rows, err := d.DB.Query(publicRoomsQuery+`
+		WHERE ($1 = '' OR mode = $1)
+			AND ($2 = '' OR difficulty = $2)
+			AND max_players - player_count >= $3
+		ORDER BY created_at DESC
+		LIMIT $4
+	`, string(filter.Mode), string(filter.Difficulty), filter.MinSeats, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rooms := []models.PublicRoom{}
+	for rows.Next() {
+		room, err := scanPublicRoom(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, *room)
+	}
+	return rooms, rows.Err()
+}
+
+// GetPublicRoom returns a room's lobby listing, or nil if the room isn't listed
+func (d *Database) GetPublicRoom(roomID string) (*models.PublicRoom, error) {
+	room, err := scanPublicRoom(d.DB.QueryRow(publicRoomsQuery+` WHERE id = $1`, roomID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return room, err
+}
+
+// Quick-match queue operations
+
+// QueueQuickMatch adds a user to the quick-match queue or refreshes their
+// entry, and fills in entry.RoomID if they have already been matched
+func (d *Database) QueueQuickMatch(entry *models.QuickMatchEntry) error {
+	var roomID sql.NullString
+	err := d.DB.QueryRow(`
+		INSERT INTO quick_match_queue (user_id, display_name, skill_rating, difficulty, queued_at, polled_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (user_id) DO UPDATE SET
+			display_name = EXCLUDED.display_name,
+			skill_rating = EXCLUDED.skill_rating,
+			difficulty = EXCLUDED.difficulty,
+			polled_at = EXCLUDED.polled_at
+		RETURNING room_id, queued_at
+	`, entry.UserID, entry.DisplayName, entry.SkillRating, string(entry.Difficulty), time.Now()).Scan(&roomID, &entry.QueuedAt)
+	entry.RoomID = roomID.String
+	return err
+}
+
+// ClaimQuickMatchPlayers assigns up to limit waiting users to roomID: the user
+// in entry first, then others who asked for the same difficulty, are within
+// skillRange of entry's rating and polled within maxIdle, longest waiting first.
+// Users being claimed by a concurrent match are skipped.
+func (d *Database) ClaimQuickMatchPlayers(roomID string, entry *models.QuickMatchEntry, skillRange, limit int, maxIdle time.Duration) ([]models.QuickMatchEntry, error) {
+	rows, err := d.DB.Query(`
+		UPDATE quick_match_queue SET room_id = $1
+		WHERE user_id IN (
+			SELECT user_id FROM quick_match_queue
+			WHERE room_id IS NULL
+				AND difficulty = $3
+				AND ABS(skill_rating - $4) <= $5
+				AND polled_at > $6
+			ORDER BY user_id = $2 DESC, queued_at
+			LIMIT $7
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING user_id, display_name, skill_rating, difficulty, queued_at
+	`, roomID, entry.UserID, string(entry.Difficulty), entry.SkillRating, skillRange, time.Now().Add(-maxIdle), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claimed []models.QuickMatchEntry
+	for rows.Next() {
+		e := models.QuickMatchEntry{RoomID: roomID}
+		if err := rows.Scan(&e.UserID, &e.DisplayName, &e.SkillRating, &e.Difficulty, &e.QueuedAt); err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, e)
+	}
+	return claimed, rows.Err()
+}
+
+// CountQuickMatchCandidates counts the users ClaimQuickMatchPlayers would
+// consider for entry, including entry's own user
+func (d *Database) CountQuickMatchCandidates(entry *models.QuickMatchEntry, skillRange int, maxIdle time.Duration) (int, error) {
+	var count int
+	err := d.DB.QueryRow(`
+		SELECT COUNT(*) FROM quick_match_queue
+		WHERE room_id IS NULL
+			AND difficulty = $1
+			AND ABS(skill_rating - $2) <= $3
+			AND polled_at > $4
+	`, string(entry.Difficulty), entry.SkillRating, skillRange, time.Now().Add(-maxIdle)).Scan(&count)
+	return count, err
+}
+
+// ReleaseQuickMatchPlayers puts users claimed for a room that wasn't created back in the queue
+func (d *Database) ReleaseQuickMatchPlayers(roomID string) error {
+	_, err := d.DB.Exec(`UPDATE quick_match_queue SET room_id = NULL WHERE room_id = $1`, roomID)
+	return err
+}
+
+func (d *Database) LeaveQuickMatch(userID string) error {
+	_, err := d.DB.Exec(`DELETE FROM quick_match_queue WHERE user_id = $1`, userID)
+	return err
+}
+
+// Tournament operations
+func (d *Database) CreateTournament(t *models.Tournament) error {
+	_, err := d.DB.Exec(`
+		INSERT INTO tournaments (id, name, format, status, heat_size, advance, rounds, round, difficulty, timer_seconds, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, t.ID, t.Name, t.Format, t.Status, t.HeatSize, t.Advance, t.Rounds, t.Round, t.Difficulty, t.TimerSeconds, t.CreatedAt)
+	return err
+}
+
+const tournamentColumns = `id, name, format, status, heat_size, advance, rounds, round, difficulty, timer_seconds, winner_id, created_at, started_at, ended_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTournament(row rowScanner) (*models.Tournament, error) {
+	t := &models.Tournament{}
+	err := row.Scan(&t.ID, &t.Name, &t.Format, &t.Status, &t.HeatSize, &t.Advance, &t.Rounds, &t.Round,
+		&t.Difficulty, &t.TimerSeconds, &t.WinnerID, &t.CreatedAt, &t.StartedAt, &t.EndedAt)
+	return t, err
+}
+
+func (d *Database) GetTournament(id string) (*models.Tournament, error) {
+	t, err := scanTournament(d.DB.QueryRow(`SELECT `+tournamentColumns+` FROM tournaments WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ListTournaments lists tournaments newest first, only those with status unless it is empty
+func (d *Database) ListTournaments(status models.TournamentStatus, limit int) ([]models.Tournament, error) {
+	rows, err := d.DB.Query(`
+		SELECT `+tournamentColumns+` FROM tournaments
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, string(status), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tournaments []models.Tournament
+	for rows.Next() {
+		t, err := scanTournament(rows)
+		if err != nil {
+			return nil, err
+		}
+		tournaments = append(tournaments, *t)
+	}
+	return tournaments, rows.Err()
+}
+
+// StartTournament closes registration and stores the players' seeds. It
+// reports false if the tournament had already started.
+func (d *Database) StartTournament(id string, players []models.TournamentPlayer) (bool, error) {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin tournament start: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE tournaments SET status = $2, started_at = $3
+		WHERE id = $1 AND status = $4
+	`, id, models.TournamentStatusRunning, time.Now(), models.TournamentStatusRegistration)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	for _, p := range players {
+		if _, err := tx.Exec(`
+			UPDATE tournament_players SET seed = $3 WHERE tournament_id = $1 AND user_id = $2
+		`, id, p.UserID, p.Seed); err != nil {
+			return false, fmt.Errorf("failed to seed %s: %w", p.UserID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit tournament start: %w", err)
+	}
+	return true, nil
+}
+
+// ClaimTournamentRound moves a running tournament from round from to round
+// to. It reports false if another caller already moved it on.
+func (d *Database) ClaimTournamentRound(id string, from, to int) (bool, error) {
+	result, err := d.DB.Exec(`
+		UPDATE tournaments SET round = $3 WHERE id = $1 AND round = $2 AND status = $4
+	`, id, from, to, models.TournamentStatusRunning)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// CompleteTournament ends a running tournament. It reports false if the
+// tournament was not running.
+func (d *Database) CompleteTournament(id, winnerID string) (bool, error) {
+	result, err := d.DB.Exec(`
+		UPDATE tournaments SET status = $2, winner_id = $3, ended_at = $4
+		WHERE id = $1 AND status = $5
+	`, id, models.TournamentStatusCompleted, winnerID, time.Now(), models.TournamentStatusRunning)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+func (d *Database) AddTournamentPlayer(p *models.TournamentPlayer) error {
+	_, err := d.DB.Exec(`
+		INSERT INTO tournament_players (tournament_id, user_id, display_name, rating, registered_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (tournament_id, user_id) DO NOTHING
+	`, p.TournamentID, p.UserID, p.DisplayName, p.Rating, p.RegisteredAt)
+	return err
+}
+
+func (d *Database) RemoveTournamentPlayer(tournamentID, userID string) error {
+	_, err := d.DB.Exec(`
+		DELETE FROM tournament_players WHERE tournament_id = $1 AND user_id = $2
+	`, tournamentID, userID)
+	return err
+}
+
+// GetTournamentPlayers returns a tournament's players in seed order, or in
+// registration order before seeding
+func (d *Database) GetTournamentPlayers(tournamentID string) ([]models.TournamentPlayer, error) {
+	rows, err := d.DB.Query(`
+		SELECT tournament_id, user_id, display_name, rating, seed, points, eliminated, registered_at
+		FROM tournament_players WHERE tournament_id = $1
+		ORDER BY seed, registered_at
+	`, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var players []models.TournamentPlayer
+	for rows.Next() {
+		var p models.TournamentPlayer
+		if err := rows.Scan(&p.TournamentID, &p.UserID, &p.DisplayName, &p.Rating, &p.Seed,
+			&p.Points, &p.Eliminated, &p.RegisteredAt); err != nil {
+			return nil, err
+		}
+		players = append(players, p)
+	}
+	return players, rows.Err()
+}
+
+func (d *Database) CreateTournamentHeat(heat *models.TournamentHeat) error {
+	playersJSON, _ := json.Marshal(heat.Players)
+
+	_, err := d.DB.Exec(`
+		INSERT INTO tournament_heats (id, tournament_id, round, number, room_id, room_code, players)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, heat.ID, heat.TournamentID, heat.Round, heat.Number, heat.RoomID, heat.RoomCode, playersJSON)
+	return err
+}
+
+const tournamentHeatColumns = `id, tournament_id, round, number, room_id, room_code, players, results, finished_at`
+
+func scanTournamentHeat(row rowScanner) (*models.TournamentHeat, error) {
+	heat := &models.TournamentHeat{}
+	var playersJSON, resultsJSON []byte
+	if err := row.Scan(&heat.ID, &heat.TournamentID, &heat.Round, &heat.Number, &heat.RoomID, &heat.RoomCode,
+		&playersJSON, &resultsJSON, &heat.FinishedAt); err != nil {
+		return nil, err
+	}
+	json.Unmarshal(playersJSON, &heat.Players)
+	json.Unmarshal(resultsJSON, &heat.Results)
+	return heat, nil
+}
+
+// GetTournamentHeats returns every heat of a tournament by round and number
+func (d *Database) GetTournamentHeats(tournamentID string) ([]models.TournamentHeat, error) {
+	rows, err := d.DB.Query(`
+		SELECT `+tournamentHeatColumns+` FROM tournament_heats
+		WHERE tournament_id = $1
+		ORDER BY round, number
+	`, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var heats []models.TournamentHeat
+	for rows.Next() {
+		heat, err := scanTournamentHeat(rows)
+		if err != nil {
+			return nil, err
+		}
+		heats = append(heats, *heat)
+	}
+	return heats, rows.Err()
+}
+
+// GetTournamentHeatByRoom returns the heat played in a room, or nil if the
+// room isn't a tournament heat
+func (d *Database) GetTournamentHeatByRoom(roomID string) (*models.TournamentHeat, error) {
+	heat, err := scanTournamentHeat(d.DB.QueryRow(`
+		SELECT `+tournamentHeatColumns+` FROM tournament_heats WHERE room_id = $1
+	`, roomID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return heat, nil
+}
+
+// FinishTournamentHeat records a heat's results and adds its points and
+// eliminations to the players, all at once. It reports false if the heat was
+// already finished.
+func (d *Database) FinishTournamentHeat(heat *models.TournamentHeat, points map[string]int, eliminated []string) (bool, error) {
+	resultsJSON, _ := json.Marshal(heat.Results)
+
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin heat results: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE tournament_heats SET results = $2, finished_at = $3
+		WHERE id = $1 AND finished_at IS NULL
+	`, heat.ID, resultsJSON, time.Now())
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	for userID, n := range points {
+		if _, err := tx.Exec(`
+			UPDATE tournament_players SET points = points + $3 WHERE tournament_id = $1 AND user_id = $2
+		`, heat.TournamentID, userID, n); err != nil {
+			return false, fmt.Errorf("failed to score %s: %w", userID, err)
+		}
+	}
+	for _, userID := range eliminated {
+		if _, err := tx.Exec(`
+			UPDATE tournament_players SET eliminated = TRUE WHERE tournament_id = $1 AND user_id = $2
+		`, heat.TournamentID, userID); err != nil {
+			return false, fmt.Errorf("failed to eliminate %s: %w", userID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit heat results: %w", err)
+	}
+	return true, nil
+}
+
 // Grid state operations
 func (d *Database) CreateGridState(gridState *models.GridState) error {
 	cellsJSON, _ := json.Marshal(gridState.Cells)
 	completedCluesJSON, _ := json.Marshal(gridState.CompletedClues)
+	clueNotesJSON := marshalClueNotes(gridState.ClueNotes)
 
 	userID := gridState.UserID
 	if userID == "" {
@@ -772,13 +1400,14 @@ func (d *Database) CreateGridState(gridState *models.GridState) error {
 	}
 
 	_, err := d.DB.Exec(`
-		INSERT INTO grid_states (room_id, user_id, cells, completed_clues, last_updated)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO grid_states (room_id, user_id, cells, completed_clues, clue_notes, last_updated)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		ON CONFLICT (room_id, user_id) DO UPDATE SET
 			cells = EXCLUDED.cells,
 			completed_clues = EXCLUDED.completed_clues,
+			clue_notes = EXCLUDED.clue_notes,
 			last_updated = EXCLUDED.last_updated
-	`, gridState.RoomID, userID, cellsJSON, completedCluesJSON, gridState.LastUpdated)
+	`, gridState.RoomID, userID, cellsJSON, completedCluesJSON, clueNotesJSON, gridState.LastUpdated)
 	return err
 }
 
@@ -787,15 +1416,16 @@ func (d *Database) GetGridState(roomID string) (*models.GridState, error) {
 	return d.GetPlayerGridState(roomID, "")
 }
 
-// GetPlayerGridState returns a player-specific grid state (for Race mode)
+// GetPlayerGridState returns a player-specific grid state (for Race mode), or
+// a team's grid state when userID is "team:<id>"
 func (d *Database) GetPlayerGridState(roomID, userID string) (*models.GridState, error) {
 	gridState := &models.GridState{}
-	var cellsJSON, completedCluesJSON []byte
+	var cellsJSON, completedCluesJSON, clueNotesJSON []byte
 
 	err := d.DB.QueryRow(`
-		SELECT room_id, user_id, cells, completed_clues, last_updated
+		SELECT room_id, user_id, cells, completed_clues, clue_notes, last_updated
 		FROM grid_states WHERE room_id = $1 AND user_id = $2
-	`, roomID, userID).Scan(&gridState.RoomID, &gridState.UserID, &cellsJSON, &completedCluesJSON, &gridState.LastUpdated)
+	`, roomID, userID).Scan(&gridState.RoomID, &gridState.UserID, &cellsJSON, &completedCluesJSON, &clueNotesJSON, &gridState.LastUpdated)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -806,6 +1436,7 @@ func (d *Database) GetPlayerGridState(roomID, userID string) (*models.GridState,
 
 	json.Unmarshal(cellsJSON, &gridState.Cells)
 	json.Unmarshal(completedCluesJSON, &gridState.CompletedClues)
+	json.Unmarshal(clueNotesJSON, &gridState.ClueNotes)
 
 	return gridState, nil
 }
@@ -813,8 +1444,8 @@ func (d *Database) GetPlayerGridState(roomID, userID string) (*models.GridState,
 // GetAllPlayerGridStates returns all player grid states for a room (Race mode leaderboard)
 func (d *Database) GetAllPlayerGridStates(roomID string) ([]*models.GridState, error) {
 	rows, err := d.DB.Query(`
-		SELECT room_id, user_id, cells, completed_clues, last_updated
-		FROM grid_states WHERE room_id = $1 AND user_id != ''
+		SELECT room_id, user_id, cells, completed_clues, clue_notes, last_updated
+		FROM grid_states WHERE room_id = $1 AND user_id != '' AND user_id NOT LIKE 'team:%'
 	`, roomID)
 	if err != nil {
 		return nil, err
@@ -824,13 +1455,14 @@ func (d *Database) GetAllPlayerGridStates(roomID string) ([]*models.GridState, e
 	var states []*models.GridState
 	for rows.Next() {
 		gridState := &models.GridState{}
-		var cellsJSON, completedCluesJSON []byte
-		err := rows.Scan(&gridState.RoomID, &gridState.UserID, &cellsJSON, &completedCluesJSON, &gridState.LastUpdated)
+		var cellsJSON, completedCluesJSON, clueNotesJSON []byte
+		err := rows.Scan(&gridState.RoomID, &gridState.UserID, &cellsJSON, &completedCluesJSON, &clueNotesJSON, &gridState.LastUpdated)
 		if err != nil {
 			return nil, err
 		}
 		json.Unmarshal(cellsJSON, &gridState.Cells)
 		json.Unmarshal(completedCluesJSON, &gridState.CompletedClues)
+		json.Unmarshal(clueNotesJSON, &gridState.ClueNotes)
 		states = append(states, gridState)
 	}
 
@@ -847,12 +1479,52 @@ func (d *Database) UpdateGridState(gridState *models.GridState) error {
 	}
 
 	_, err := d.DB.Exec(`
-		UPDATE grid_states SET cells = $3, completed_clues = $4, last_updated = $5
+		UPDATE grid_states SET cells = $3, completed_clues = $4, clue_notes = $5, last_updated = $6
 		WHERE room_id = $1 AND user_id = $2
-	`, gridState.RoomID, userID, cellsJSON, completedCluesJSON, gridState.LastUpdated)
+	`, gridState.RoomID, userID, cellsJSON, completedCluesJSON, marshalClueNotes(gridState.ClueNotes), gridState.LastUpdated)
 	return err
 }
 
+// UpdateGridStates writes several grid states in one transaction
+func (d *Database) UpdateGridStates(gridStates []*models.GridState) error {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin grid update: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		UPDATE grid_states SET cells = $3, completed_clues = $4, clue_notes = $5, last_updated = $6
+		WHERE room_id = $1 AND user_id = $2
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare grid update: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, gridState := range gridStates {
+		cellsJSON, _ := json.Marshal(gridState.Cells)
+		completedCluesJSON, _ := json.Marshal(gridState.CompletedClues)
+		if _, err := stmt.Exec(gridState.RoomID, gridState.UserID, cellsJSON, completedCluesJSON, marshalClueNotes(gridState.ClueNotes), gridState.LastUpdated); err != nil {
+			return fmt.Errorf("failed to update grid for room %s: %w", gridState.RoomID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit grid update: %w", err)
+	}
+	return nil
+}
+
+// marshalClueNotes encodes a grid's clue notes, writing {} rather than null for none
+func marshalClueNotes(notes map[string]models.ClueNote) []byte {
+	if notes == nil {
+		return []byte("{}")
+	}
+	data, _ := json.Marshal(notes)
+	return data
+}
+
 // Relay state operations
 func (d *Database) CreateRelayState(state *models.RelayState) error {
 	turnOrderJSON, _ := json.Marshal(state.TurnOrder)
@@ -903,12 +1575,22 @@ func (d *Database) CreateMessage(msg *models.Message) error {
 	return err
 }
 
+// GetRoomMessages returns the room's latest messages in chronological order
 func (d *Database) GetRoomMessages(roomID string, limit int) ([]models.Message, error) {
+	return d.GetRoomMessagesBefore(roomID, "", limit)
+}
+
+// GetRoomMessagesBefore returns, in chronological order, up to limit of the
+// room's messages sent before the message beforeID, or its latest messages
+// if beforeID is empty. Deleted messages are left out.
+func (d *Database) GetRoomMessagesBefore(roomID, beforeID string, limit int) ([]models.Message, error) {
 	rows, err := d.DB.Query(`
 		SELECT id, room_id, user_id, display_name, text, created_at
-		FROM messages WHERE room_id = $1
-		ORDER BY created_at DESC LIMIT $2
-	`, roomID, limit)
+		FROM messages
+		WHERE room_id = $1 AND deleted_at IS NULL
+			AND ($2 = '' OR (created_at, id) < (SELECT created_at, id FROM messages WHERE id = $2))
+		ORDER BY created_at DESC, id DESC LIMIT $3
+	`, roomID, beforeID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -932,6 +1614,85 @@ func (d *Database) GetRoomMessages(roomID string, limit int) ([]models.Message,
 	return messages, nil
 }
 
+// GetMessage returns a message that hasn't been deleted, or nil
+func (d *Database) GetMessage(id string) (*models.Message, error) {
+	var msg models.Message
+	err := d.DB.QueryRow(`
+		SELECT id, room_id, user_id, display_name, text, created_at
+		FROM messages WHERE id = $1 AND deleted_at IS NULL
+	`, id).Scan(&msg.ID, &msg.RoomID, &msg.UserID, &msg.DisplayName, &msg.Text, &msg.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// DeleteMessage hides a message from the room's history
+func (d *Database) DeleteMessage(id string) error {
+	_, err := d.DB.Exec(`UPDATE messages SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL`, id, time.Now())
+	return err
+}
+
+// Room event log operations
+
+// AppendRoomEvents adds recorded events to their rooms' event logs in one transaction
+func (d *Database) AppendRoomEvents(events []models.RoomEvent) error {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin event append: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO room_events (room_id, user_id, type, x, y, value, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare event append: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range events {
+		if _, err := stmt.Exec(e.RoomID, e.UserID, string(e.Type), e.X, e.Y, e.Value, e.CreatedAt); err != nil {
+			return fmt.Errorf("failed to append event for room %s: %w", e.RoomID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit event append: %w", err)
+	}
+	return nil
+}
+
+// GetRoomEvents returns a room's recorded events in the order they happened
+func (d *Database) GetRoomEvents(roomID string) ([]models.RoomEvent, error) {
+	rows, err := d.DB.Query(`
+		SELECT room_id, user_id, type, x, y, value, created_at
+		FROM room_events WHERE room_id = $1
+		ORDER BY created_at, id
+	`, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.RoomEvent{}
+	for rows.Next() {
+		var e models.RoomEvent
+		var eventType string
+		if err := rows.Scan(&e.RoomID, &e.UserID, &eventType, &e.X, &e.Y, &e.Value, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Type = models.RoomEventType(eventType)
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
 // Puzzle history operations
 func (d *Database) CreatePuzzleHistory(history *models.PuzzleHistory) error {
 	_, err := d.DB.Exec(`
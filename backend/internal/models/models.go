@@ -88,6 +88,7 @@ const (
 	RoomModeCollaborative RoomMode = "collaborative"
 	RoomModeRace          RoomMode = "race"
 	RoomModeRelay         RoomMode = "relay"
+	RoomModeTeam          RoomMode = "team" // teams race each other, each sharing a grid
 )
 
 // RoomState represents the state of a room
@@ -101,12 +102,27 @@ const (
 
 // RoomConfig holds room configuration options
 type RoomConfig struct {
-	MaxPlayers    int    `json:"maxPlayers"`
-	IsPublic      bool   `json:"isPublic"`
-	SpectatorMode bool   `json:"spectatorMode"`
-	TimerMode     string `json:"timerMode"` // "none", "countdown", "stopwatch"
-	TimerSeconds  int    `json:"timerSeconds,omitempty"`
-	HintsEnabled  bool   `json:"hintsEnabled"`
+	MaxPlayers     int    `json:"maxPlayers"`
+	IsPublic       bool   `json:"isPublic"`
+	SpectatorMode  bool   `json:"spectatorMode"`
+	TimerMode      string `json:"timerMode"` // "none", "countdown", "stopwatch"
+	TimerSeconds   int    `json:"timerSeconds,omitempty"`
+	HintsEnabled   bool   `json:"hintsEnabled"`
+	TeamCount      int    `json:"teamCount,omitempty"`      // team mode: number of teams, 2 to 4
+	Locked         bool   `json:"locked,omitempty"`         // host locked the room: no new players can join
+	ChatMuted      bool   `json:"chatMuted,omitempty"`      // host muted chat for everyone but themselves
+	SpectatorDelay int    `json:"spectatorDelay,omitempty"` // seconds spectators' view lags behind the game
+	QuickMatch     bool   `json:"quickMatch,omitempty"`     // created by quick-match
+	SkillRating    int    `json:"skillRating,omitempty"`    // quick-match: rating the players were matched around
+	Ranked         bool   `json:"ranked,omitempty"`         // competitive room: hints are off
+	MaxReveals     int    `json:"maxReveals,omitempty"`     // letter and word hints each player may use; 0 is unlimited
+	HintPenalty    int    `json:"hintPenalty,omitempty"`    // race: seconds added to a player's time per revealed letter
+	TurnSeconds    int    `json:"turnSeconds,omitempty"`    // relay: length of a turn; 0 is the default minute
+	WordsPerTurn   int    `json:"wordsPerTurn,omitempty"`   // relay: the turn passes once the player completes this many words; 0 is no limit
+	SkipVotes      bool   `json:"skipVotes,omitempty"`      // relay: a majority of the other players can vote to skip the current turn
+	CatchUp        bool   `json:"catchUp,omitempty"`        // relay: the next turn goes to the player who has contributed least
+	SeriesGames    int    `json:"seriesGames,omitempty"`    // games in a series scored across rematches; 0 is a single game
+	TournamentID   string `json:"tournamentId,omitempty"`   // the tournament this room is a heat of
 }
 
 // Room represents a multiplayer room
@@ -123,6 +139,110 @@ type Room struct {
 	EndedAt   *time.Time `json:"endedAt,omitempty"`
 }
 
+// PublicRoom is a public room as listed in the lobby: not locked and not started yet
+type PublicRoom struct {
+	ID          string     `json:"id"`
+	Code        string     `json:"code"`
+	Mode        RoomMode   `json:"mode"`
+	HostName    string     `json:"hostName"`
+	PuzzleTitle string     `json:"puzzleTitle"`
+	Difficulty  Difficulty `json:"difficulty"`
+	Players     int        `json:"players"` // spectators not included
+	MaxPlayers  int        `json:"maxPlayers"`
+	OpenSeats   int        `json:"openSeats"`
+	QuickMatch  bool       `json:"quickMatch,omitempty"`
+	SkillRating int        `json:"skillRating,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// PublicRoomFilter narrows the lobby listing; zero values match every room
+type PublicRoomFilter struct {
+	Mode       RoomMode
+	Difficulty Difficulty
+	MinSeats   int // open seats
+}
+
+// QuickMatchEntry is a user waiting in the quick-match queue
+type QuickMatchEntry struct {
+	UserID      string
+	DisplayName string
+	SkillRating int
+	Difficulty  Difficulty // "" when the user has no preference
+	RoomID      string     // set once the user has been matched into a room
+	QueuedAt    time.Time
+}
+
+// TournamentFormat is how a tournament's rounds are drawn
+type TournamentFormat string
+
+const (
+	TournamentFormatBracket TournamentFormat = "bracket" // the top finishers of each heat advance, the rest are out
+	TournamentFormatSwiss   TournamentFormat = "swiss"   // everyone plays every round, drawn against players on similar points
+)
+
+// TournamentStatus represents the state of a tournament
+type TournamentStatus string
+
+const (
+	TournamentStatusRegistration TournamentStatus = "registration"
+	TournamentStatusRunning      TournamentStatus = "running"
+	TournamentStatusCompleted    TournamentStatus = "completed"
+)
+
+// Tournament is a competition played over rounds of race rooms
+type Tournament struct {
+	ID           string           `json:"id"`
+	Name         string           `json:"name"`
+	Format       TournamentFormat `json:"format"`
+	Status       TournamentStatus `json:"status"`
+	HeatSize     int              `json:"heatSize"`         // most players in one race room
+	Advance      int              `json:"advance"`          // bracket: players who go through from each heat
+	Rounds       int              `json:"rounds,omitempty"` // swiss: rounds to play
+	Round        int              `json:"round"`            // current round, 0 before the start
+	Difficulty   Difficulty       `json:"difficulty"`
+	TimerSeconds int              `json:"timerSeconds,omitempty"` // heat time limit; 0 is untimed
+	WinnerID     *string          `json:"winnerId,omitempty"`
+	CreatedAt    time.Time        `json:"createdAt"`
+	StartedAt    *time.Time       `json:"startedAt,omitempty"`
+	EndedAt      *time.Time       `json:"endedAt,omitempty"`
+}
+
+// TournamentPlayer is a user registered for a tournament
+type TournamentPlayer struct {
+	TournamentID string    `json:"tournamentId"`
+	UserID       string    `json:"userId"`
+	DisplayName  string    `json:"displayName"`
+	Rating       int       `json:"rating"`
+	Seed         int       `json:"seed"` // 1 is the top seed; 0 before the tournament starts
+	Points       int       `json:"points"`
+	Eliminated   bool      `json:"eliminated"`
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+// TournamentHeat is one race room of a tournament round
+type TournamentHeat struct {
+	ID           string     `json:"id"`
+	TournamentID string     `json:"tournamentId"`
+	Round        int        `json:"round"`
+	Number       int        `json:"number"` // heat within the round, from 1
+	RoomID       string     `json:"roomId"`
+	RoomCode     string     `json:"roomCode"`
+	Players      []string   `json:"players"`           // user IDs in seed order
+	Results      []string   `json:"results,omitempty"` // user IDs in finishing order, once the heat is over
+	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
+}
+
+// TournamentStanding is a player's place in a tournament
+type TournamentStanding struct {
+	Rank        int    `json:"rank"`
+	UserID      string `json:"userId"`
+	DisplayName string `json:"displayName"`
+	Seed        int    `json:"seed"`
+	Points      int    `json:"points"`
+	Round       int    `json:"round"` // last round the player played
+	Eliminated  bool   `json:"eliminated"`
+}
+
 // Player represents a player in a room
 type Player struct {
 	UserID         string  `json:"userId"`
@@ -134,24 +254,38 @@ type Player struct {
 	IsConnected    bool    `json:"isConnected"`
 	Contribution   float64 `json:"contribution"` // % of correct cells
 	Color          string  `json:"color"`        // cursor/highlight color
+	TeamID         string  `json:"teamId,omitempty"` // team mode only
+	IsMuted        bool    `json:"isMuted,omitempty"` // muted in chat by the host
 	JoinedAt       time.Time `json:"joinedAt"`
 }
 
 // Cell represents the state of a single cell in a game
 type Cell struct {
-	Value        *string `json:"value"`
-	IsRevealed   bool    `json:"isRevealed"`
-	IsCorrect    *bool   `json:"isCorrect,omitempty"`
-	LastEditedBy *string `json:"lastEditedBy,omitempty"`
+	Value        *string  `json:"value"`
+	IsPencil     bool     `json:"isPencil,omitempty"`   // Value is a tentative entry and doesn't count as an answer
+	Candidates   []string `json:"candidates,omitempty"` // letters a solver is considering for the cell
+	IsRevealed   bool     `json:"isRevealed"`
+	IsCorrect    *bool    `json:"isCorrect,omitempty"`
+	LastEditedBy *string  `json:"lastEditedBy,omitempty"`
+	Version      int64    `json:"version,omitempty"` // incremented on every write to the cell
 }
 
 // GridState represents the current state of the puzzle grid in a room
 type GridState struct {
-	RoomID         string    `json:"roomId"`
-	UserID         string    `json:"userId,omitempty"` // For Race mode: player-specific grid
-	Cells          [][]Cell  `json:"cells"`
-	CompletedClues []string  `json:"completedClues"`
-	LastUpdated    time.Time `json:"lastUpdated"`
+	RoomID         string              `json:"roomId"`
+	UserID         string              `json:"userId,omitempty"` // For Race mode: player-specific grid
+	Cells          [][]Cell            `json:"cells"`
+	CompletedClues []string            `json:"completedClues"`
+	ClueNotes      map[string]ClueNote `json:"clueNotes,omitempty"` // keyed by clue ID, e.g. "across-1"
+	LastUpdated    time.Time           `json:"lastUpdated"`
+}
+
+// ClueNote is a sticky note a solver left on a clue for the others sharing the grid
+type ClueNote struct {
+	Text      string    `json:"text"`
+	UserID    string    `json:"userId"`
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 // RelayState tracks turn-based gameplay for Relay mode
@@ -166,12 +300,52 @@ type RelayState struct {
 
 // RaceProgress tracks individual player progress in Race mode
 type RaceProgress struct {
-	UserID       string    `json:"userId"`
-	DisplayName  string    `json:"displayName"`
-	Progress     float64   `json:"progress"`     // 0-100 percentage
-	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
-	SolveTime    *int      `json:"solveTime,omitempty"` // Seconds to complete
-	Rank         int       `json:"rank,omitempty"`
+	UserID      string     `json:"userId"`
+	DisplayName string     `json:"displayName"`
+	Progress    float64    `json:"progress"` // 0-100 percentage
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	SolveTime   *int       `json:"solveTime,omitempty"` // Seconds to complete, including the hint penalty
+	Rank        int        `json:"rank,omitempty"`
+	HintsUsed   int        `json:"hintsUsed,omitempty"`
+	Penalty     int        `json:"penalty,omitempty"` // seconds added for revealed letters
+}
+
+// Team is one side of a team mode room
+type Team struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// TeamProgress tracks a team's progress in team mode
+type TeamProgress struct {
+	TeamID     string     `json:"teamId"`
+	Name       string     `json:"name"`
+	Progress   float64    `json:"progress"` // 0-100 percentage
+	Players    []string   `json:"players"`  // user IDs
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	SolveTime  *int       `json:"solveTime,omitempty"` // Seconds to complete
+	Rank       int        `json:"rank,omitempty"`
+}
+
+// RoomEventType identifies what a recorded room event was
+type RoomEventType string
+
+const (
+	RoomEventCell   RoomEventType = "cell"   // accepted cell_update; Value is the letter ("" when cleared)
+	RoomEventHint   RoomEventType = "hint"   // hint request; Value is the hint and its scope, e.g. "check:word"
+	RoomEventCursor RoomEventType = "cursor" // cursor move
+)
+
+// RoomEvent is one entry in a room's recorded event log, used for replays
+type RoomEvent struct {
+	RoomID    string        `json:"-"`
+	Type      RoomEventType `json:"type"`
+	UserID    string        `json:"userId,omitempty"`
+	X         int           `json:"x"`
+	Y         int           `json:"y"`
+	Value     string        `json:"value,omitempty"`
+	CreatedAt time.Time     `json:"at"`
 }
 
 // Message represents a chat message in a room
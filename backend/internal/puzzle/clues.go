@@ -5,6 +5,8 @@ import (
 	"sort"
 	"strings"
 	"sync"
+
+	"github.com/crossplay/backend/pkg/clues"
 )
 
 // ClueGenerator generates crossword clues using dictionary definitions
@@ -317,40 +319,18 @@ func (cg *ClueGenerator) filterValidClues(clues []GeneratedClue, answer string)
 	return valid
 }
 
-// ValidateClue checks if a clue is valid for the given answer
+// ValidateClue checks if a clue is valid for the given answer using the shared
+// clue linter, plus a minimum length check for dictionary-derived clues
 func (cg *ClueGenerator) ValidateClue(clue string, answer string) []string {
 	var issues []string
-	answer = strings.ToUpper(answer)
-	clueUpper := strings.ToUpper(clue)
-
-	// Check if answer appears in clue
-	if strings.Contains(clueUpper, answer) {
-		issues = append(issues, fmt.Sprintf("answer '%s' appears in clue", answer))
-	}
-
-	// Check for partial matches (answer substrings)
-	if len(answer) >= 4 {
-		for i := 0; i <= len(answer)-4; i++ {
-			substr := answer[i : i+4]
-			if strings.Contains(clueUpper, substr) {
-				issues = append(issues, fmt.Sprintf("answer substring '%s' appears in clue", substr))
-				break
-			}
-		}
+	for _, issue := range clues.NewLinter().LintClue(answer, clue) {
+		issues = append(issues, issue.Message)
 	}
 
 	// Check clue length
-	if len(clue) < 5 {
+	if trimmed := strings.TrimSpace(clue); trimmed != "" && len(trimmed) < 5 {
 		issues = append(issues, "clue is too short")
 	}
-	if len(clue) > 100 {
-		issues = append(issues, "clue is too long")
-	}
-
-	// Check for empty or whitespace-only
-	if strings.TrimSpace(clue) == "" {
-		issues = append(issues, "clue is empty")
-	}
 
 	return issues
 }
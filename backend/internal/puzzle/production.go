@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/crossplay/backend/internal/models"
+	"github.com/crossplay/backend/internal/wordfilter"
 	"github.com/google/uuid"
 )
 
@@ -36,8 +37,10 @@ type PipelineConfig struct {
 	// Grid specifications by size
 	GridSpecs map[string]GridSizeSpec
 
-	// Content filtering
+	// Content filtering. Answers containing a word banned by WordFilter (the
+	// filter chat uses) or equal to one of CustomBannedWords are rejected.
 	FilterOffensive   bool
+	WordFilter        *wordfilter.Filter
 	CustomBannedWords []string
 }
 
@@ -409,24 +412,15 @@ func (pp *ProductionPipeline) filterContent(puzzle *models.Puzzle) []string {
 	var issues []string
 
 	// Check answers for offensive content
-	bannedPatterns := []string{
-		// Add patterns for offensive content
-		// This is a simplified check - production should use a proper content filter
-	}
-
 	for _, clue := range puzzle.CluesAcross {
-		for _, pattern := range bannedPatterns {
-			if strings.Contains(strings.ToUpper(clue.Answer), pattern) {
-				issues = append(issues, fmt.Sprintf("banned pattern in answer: %s", clue.Answer))
-			}
+		if word := pp.config.WordFilter.ContainedIn(clue.Answer); word != "" {
+			issues = append(issues, fmt.Sprintf("banned pattern in answer: %s", clue.Answer))
 		}
 	}
 
 	for _, clue := range puzzle.CluesDown {
-		for _, pattern := range bannedPatterns {
-			if strings.Contains(strings.ToUpper(clue.Answer), pattern) {
-				issues = append(issues, fmt.Sprintf("banned pattern in answer: %s", clue.Answer))
-			}
+		if word := pp.config.WordFilter.ContainedIn(clue.Answer); word != "" {
+			issues = append(issues, fmt.Sprintf("banned pattern in answer: %s", clue.Answer))
 		}
 	}
 
@@ -0,0 +1,487 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Broker is the subset of Redis the hub uses to coordinate with other server
+// instances: pub/sub for room broadcasts, hashes for shared room state and
+// presence, lists for replay buffers, and expiring keys for locks and
+// instance heartbeats.
+type Broker interface {
+	Publish(ctx context.Context, channel string, data []byte) error
+	// Subscribe delivers messages published on channel until ctx is cancelled
+	Subscribe(ctx context.Context, channel string) (<-chan []byte, error)
+
+	HSet(ctx context.Context, key, field, value string) error
+	HSetNX(ctx context.Context, key, field, value string) (bool, error)
+	// HDel returns how many of the fields existed, so deleting one can claim it
+	HDel(ctx context.Context, key string, fields ...string) (int64, error)
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	HIncrBy(ctx context.Context, key, field string, incr int64) (int64, error)
+
+	RPush(ctx context.Context, key, value string) error
+	// LTrim and LRange take inclusive indexes; negative indexes count from the end
+	LTrim(ctx context.Context, key string, start, stop int64) error
+	LRange(ctx context.Context, key string, start, stop int64) ([]string, error)
+
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+
+	// Pipeline sends the writes fn queues in one round trip. It is not a
+	// transaction: other clients' commands may run in between.
+	Pipeline(ctx context.Context, fn func(pipe Pipe)) error
+}
+
+// Pipe queues writes for Broker.Pipeline
+type Pipe interface {
+	RPush(key, value string)
+	LTrim(key string, start, stop int64)
+	Expire(key string, ttl time.Duration)
+	Publish(channel string, data []byte)
+}
+
+// RedisBroker implements Broker on top of a go-redis client
+type RedisBroker struct {
+	client *redis.Client
+}
+
+// NewRedisBroker creates a broker backed by the given Redis client
+func NewRedisBroker(client *redis.Client) *RedisBroker {
+	return &RedisBroker{client: client}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, channel string, data []byte) error {
+	return b.client.Publish(ctx, channel, data).Err()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	pubsub := b.client.Subscribe(ctx, channel)
+
+	// Wait for the subscription to be confirmed so no message published
+	// after Subscribe returns is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", channel, err)
+	}
+
+	out := make(chan []byte, 256)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+
+		msgs := pubsub.Channel()
+		for {
+			select {
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case out <- []byte(msg.Payload):
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (b *RedisBroker) HSet(ctx context.Context, key, field, value string) error {
+	return b.client.HSet(ctx, key, field, value).Err()
+}
+
+func (b *RedisBroker) HSetNX(ctx context.Context, key, field, value string) (bool, error) {
+	return b.client.HSetNX(ctx, key, field, value).Result()
+}
+
+func (b *RedisBroker) HDel(ctx context.Context, key string, fields ...string) (int64, error) {
+	return b.client.HDel(ctx, key, fields...).Result()
+}
+
+func (b *RedisBroker) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return b.client.HGetAll(ctx, key).Result()
+}
+
+func (b *RedisBroker) HIncrBy(ctx context.Context, key, field string, incr int64) (int64, error) {
+	return b.client.HIncrBy(ctx, key, field, incr).Result()
+}
+
+func (b *RedisBroker) RPush(ctx context.Context, key, value string) error {
+	return b.client.RPush(ctx, key, value).Err()
+}
+
+func (b *RedisBroker) LTrim(ctx context.Context, key string, start, stop int64) error {
+	return b.client.LTrim(ctx, key, start, stop).Err()
+}
+
+func (b *RedisBroker) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	return b.client.LRange(ctx, key, start, stop).Result()
+}
+
+func (b *RedisBroker) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return b.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (b *RedisBroker) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return b.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (b *RedisBroker) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := b.client.Exists(ctx, key).Result()
+	return n > 0, err
+}
+
+func (b *RedisBroker) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return b.client.Expire(ctx, key, ttl).Err()
+}
+
+func (b *RedisBroker) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return b.client.Del(ctx, keys...).Err()
+}
+
+func (b *RedisBroker) Pipeline(ctx context.Context, fn func(pipe Pipe)) error {
+	_, err := b.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		fn(redisPipe{ctx: ctx, pipe: p})
+		return nil
+	})
+	return err
+}
+
+// redisPipe queues writes on a go-redis pipeline
+type redisPipe struct {
+	ctx  context.Context
+	pipe redis.Pipeliner
+}
+
+func (p redisPipe) RPush(key, value string) { p.pipe.RPush(p.ctx, key, value) }
+
+func (p redisPipe) LTrim(key string, start, stop int64) { p.pipe.LTrim(p.ctx, key, start, stop) }
+
+func (p redisPipe) Expire(key string, ttl time.Duration) { p.pipe.Expire(p.ctx, key, ttl) }
+
+func (p redisPipe) Publish(channel string, data []byte) { p.pipe.Publish(p.ctx, channel, data) }
+
+// MemoryBroker is an in-process stand-in for Redis. It is used when the server
+// runs without Redis and lets tests run several hubs against shared state.
+type MemoryBroker struct {
+	mutex   sync.Mutex
+	values  map[string]string
+	hashes  map[string]map[string]string
+	lists   map[string][]string
+	expires map[string]time.Time
+	subs    map[string][]chan []byte
+}
+
+// NewMemoryBroker creates an empty in-process broker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		values:  make(map[string]string),
+		hashes:  make(map[string]map[string]string),
+		lists:   make(map[string][]string),
+		expires: make(map[string]time.Time),
+		subs:    make(map[string][]chan []byte),
+	}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, channel string, data []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, ch := range b.subs[channel] {
+		msg := append([]byte(nil), data...)
+		select {
+		case ch <- msg:
+		default:
+			// Slow subscriber, drop the message like Redis does
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	ch := make(chan []byte, 256)
+
+	b.mutex.Lock()
+	b.subs[channel] = append(b.subs[channel], ch)
+	b.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		subs := b.subs[channel]
+		for i, sub := range subs {
+			if sub == ch {
+				b.subs[channel] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+// expireLocked drops key if its TTL has passed. Callers must hold the mutex.
+func (b *MemoryBroker) expireLocked(key string) {
+	if deadline, ok := b.expires[key]; ok && time.Now().After(deadline) {
+		delete(b.values, key)
+		delete(b.hashes, key)
+		delete(b.lists, key)
+		delete(b.expires, key)
+	}
+}
+
+func (b *MemoryBroker) HSet(ctx context.Context, key, field, value string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.expireLocked(key)
+	if b.hashes[key] == nil {
+		b.hashes[key] = make(map[string]string)
+	}
+	b.hashes[key][field] = value
+	return nil
+}
+
+func (b *MemoryBroker) HSetNX(ctx context.Context, key, field, value string) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.expireLocked(key)
+	if _, exists := b.hashes[key][field]; exists {
+		return false, nil
+	}
+	if b.hashes[key] == nil {
+		b.hashes[key] = make(map[string]string)
+	}
+	b.hashes[key][field] = value
+	return true, nil
+}
+
+func (b *MemoryBroker) HDel(ctx context.Context, key string, fields ...string) (int64, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.expireLocked(key)
+	var deleted int64
+	for _, field := range fields {
+		if _, ok := b.hashes[key][field]; ok {
+			delete(b.hashes[key], field)
+			deleted++
+		}
+	}
+	if len(b.hashes[key]) == 0 {
+		delete(b.hashes, key)
+		delete(b.expires, key)
+	}
+	return deleted, nil
+}
+
+func (b *MemoryBroker) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.expireLocked(key)
+	result := make(map[string]string, len(b.hashes[key]))
+	for field, value := range b.hashes[key] {
+		result[field] = value
+	}
+	return result, nil
+}
+
+func (b *MemoryBroker) HIncrBy(ctx context.Context, key, field string, incr int64) (int64, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.expireLocked(key)
+	if b.hashes[key] == nil {
+		b.hashes[key] = make(map[string]string)
+	}
+	var current int64
+	if value, ok := b.hashes[key][field]; ok {
+		if _, err := fmt.Sscan(value, &current); err != nil {
+			return 0, fmt.Errorf("hash value is not an integer")
+		}
+	}
+	current += incr
+	b.hashes[key][field] = fmt.Sprint(current)
+	return current, nil
+}
+
+func (b *MemoryBroker) RPush(ctx context.Context, key, value string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.expireLocked(key)
+	b.lists[key] = append(b.lists[key], value)
+	return nil
+}
+
+func (b *MemoryBroker) LTrim(ctx context.Context, key string, start, stop int64) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.expireLocked(key)
+	list := b.lists[key]
+	from, to := listRange(len(list), start, stop)
+	if from >= to {
+		delete(b.lists, key)
+		delete(b.expires, key)
+		return nil
+	}
+	b.lists[key] = append([]string(nil), list[from:to]...)
+	return nil
+}
+
+func (b *MemoryBroker) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.expireLocked(key)
+	list := b.lists[key]
+	from, to := listRange(len(list), start, stop)
+	if from >= to {
+		return []string{}, nil
+	}
+	return append([]string(nil), list[from:to]...), nil
+}
+
+// listRange converts inclusive Redis list indexes into a slice range
+func listRange(length int, start, stop int64) (from, to int) {
+	n := int64(length)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return 0, 0
+	}
+	return int(start), int(stop + 1)
+}
+
+func (b *MemoryBroker) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.values[key] = value
+	b.setTTLLocked(key, ttl)
+	return nil
+}
+
+func (b *MemoryBroker) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.expireLocked(key)
+	if _, exists := b.values[key]; exists {
+		return false, nil
+	}
+	b.values[key] = value
+	b.setTTLLocked(key, ttl)
+	return true, nil
+}
+
+func (b *MemoryBroker) Exists(ctx context.Context, key string) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.existsLocked(key), nil
+}
+
+func (b *MemoryBroker) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.existsLocked(key) {
+		b.setTTLLocked(key, ttl)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Del(ctx context.Context, keys ...string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, key := range keys {
+		delete(b.values, key)
+		delete(b.hashes, key)
+		delete(b.lists, key)
+		delete(b.expires, key)
+	}
+	return nil
+}
+
+// Pipeline runs the queued writes one after another, there being no round trips to save
+func (b *MemoryBroker) Pipeline(ctx context.Context, fn func(pipe Pipe)) error {
+	pipe := &memoryPipe{ctx: ctx, broker: b}
+	fn(pipe)
+	return pipe.err
+}
+
+// memoryPipe applies writes to a MemoryBroker as they are queued, keeping the first error
+type memoryPipe struct {
+	ctx    context.Context
+	broker *MemoryBroker
+	err    error
+}
+
+func (p *memoryPipe) keep(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *memoryPipe) RPush(key, value string) { p.keep(p.broker.RPush(p.ctx, key, value)) }
+
+func (p *memoryPipe) LTrim(key string, start, stop int64) {
+	p.keep(p.broker.LTrim(p.ctx, key, start, stop))
+}
+
+func (p *memoryPipe) Expire(key string, ttl time.Duration) { p.keep(p.broker.Expire(p.ctx, key, ttl)) }
+
+func (p *memoryPipe) Publish(channel string, data []byte) {
+	p.keep(p.broker.Publish(p.ctx, channel, data))
+}
+
+// existsLocked reports whether key holds an unexpired value. Callers must hold the mutex.
+func (b *MemoryBroker) existsLocked(key string) bool {
+	b.expireLocked(key)
+	_, isValue := b.values[key]
+	_, isHash := b.hashes[key]
+	_, isList := b.lists[key]
+	return isValue || isHash || isList
+}
+
+// setTTLLocked records when key expires (ttl <= 0 means never). Callers must hold the mutex.
+func (b *MemoryBroker) setTTLLocked(key string, ttl time.Duration) {
+	if ttl > 0 {
+		b.expires[key] = time.Now().Add(ttl)
+	} else {
+		delete(b.expires, key)
+	}
+}
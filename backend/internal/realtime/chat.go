@@ -0,0 +1,166 @@
+package realtime
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+	"github.com/crossplay/backend/internal/wordfilter"
+)
+
+// Room chat is moderated as it is sent. Messages are trimmed and limited to
+// maxMessageLength characters, and banned words (the word filter the puzzle
+// pipeline uses) are starred out. Each user may send chatBurst messages at once
+// and one more every chatRefill after that, across their connections to this
+// instance. Senders can delete their own messages and the host anyone's;
+// deleted messages leave the room's history. While composing, players can
+// send typing, which the others get as player_typing.
+
+const (
+	maxMessageLength = 500
+	chatBurst        = 5
+	chatRefill       = time.Second
+)
+
+// tokenBucket allows bursts of up to capacity events, refilled at one per
+// refill interval
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take spends a token if there is one
+func (b *tokenBucket) take(now time.Time, capacity int, refill time.Duration) bool {
+	b.tokens += float64(now.Sub(b.last)) / float64(refill)
+	if b.tokens > float64(capacity) {
+		b.tokens = float64(capacity)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// chatLimiter holds each user's chat token bucket
+type chatLimiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket // userID -> bucket
+}
+
+// allow reports whether the user may send a message now
+func (l *chatLimiter) allow(userID string, now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	bucket, ok := l.buckets[userID]
+	if !ok {
+		bucket = &tokenBucket{tokens: chatBurst, last: now}
+		l.buckets[userID] = bucket
+	}
+	return bucket.take(now, chatBurst, chatRefill)
+}
+
+// forget drops a user's bucket once they have no connections left
+func (l *chatLimiter) forget(userID string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.buckets, userID)
+}
+
+// SetWordFilter sets the banned words starred out of chat messages
+func (h *Hub) SetWordFilter(filter *wordfilter.Filter) {
+	h.wordFilter = filter
+}
+
+// cleanMessage trims a chat message and stars out its banned words. problem
+// says why it can't be sent instead, if it is too long.
+func (h *Hub) cleanMessage(text string) (cleaned string, problem string) {
+	text = strings.TrimSpace(text)
+	if len([]rune(text)) > maxMessageLength {
+		return "", "message is too long"
+	}
+	cleaned, _ = h.wordFilter.Censor(text)
+	return cleaned, ""
+}
+
+func (h *Hub) handleDeleteMessage(client *Client, payload json.RawMessage) {
+	if client.RoomID == "" {
+		return
+	}
+
+	var p DeleteMessagePayload
+	if err := json.Unmarshal(payload, &p); err != nil || p.MessageID == "" {
+		h.sendError(client, "invalid payload")
+		return
+	}
+
+	msg, err := h.db.GetMessage(p.MessageID)
+	if err != nil || msg == nil || msg.RoomID != client.RoomID {
+		h.sendError(client, "message not found")
+		return
+	}
+	if msg.UserID != client.UserID {
+		room, _ := h.cachedRoomAndPuzzle(client.RoomID)
+		if room == nil || room.HostID != client.UserID {
+			h.sendError(client, "only the host can delete other players' messages")
+			return
+		}
+	}
+
+	if err := h.db.DeleteMessage(msg.ID); err != nil {
+		h.sendError(client, "failed to delete message")
+		return
+	}
+	h.broadcastToRoom(client.RoomID, "", MsgMessageDeleted, MessageDeletedPayload{
+		MessageID: msg.ID,
+		DeletedBy: client.UserID,
+	})
+}
+
+func (h *Hub) handleTyping(client *Client, payload json.RawMessage) {
+	if client.RoomID == "" {
+		return
+	}
+
+	var p TypingPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return
+	}
+
+	// Only players who could send the message are shown typing
+	players, _ := h.db.GetRoomPlayers(client.RoomID)
+	player := findPlayer(players, client.UserID)
+	if player == nil || player.IsMuted {
+		return
+	}
+	room, _ := h.cachedRoomAndPuzzle(client.RoomID)
+	if room == nil || (room.Config.ChatMuted && room.HostID != client.UserID) {
+		return
+	}
+	if player.IsSpectator && room.State == models.RoomStateActive {
+		return
+	}
+
+	typing := PlayerTypingPayload{
+		UserID:      client.UserID,
+		DisplayName: player.DisplayName,
+		Typing:      p.Typing,
+	}
+	if p.Team {
+		teamID, members := playerTeam(players, client.UserID)
+		if teamID == "" {
+			return
+		}
+		typing.TeamID = teamID
+		h.sendToUsers(client.RoomID, client.ConnectionID, members, MsgPlayerTyping, typing)
+		return
+	}
+	h.broadcastToRoom(client.RoomID, client.ConnectionID, MsgPlayerTyping, typing)
+}
@@ -0,0 +1,69 @@
+package realtime
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/wordfilter"
+)
+
+func TestChatLimiter(t *testing.T) {
+	var limits chatLimiter
+	now := time.Now()
+
+	for i := 0; i < chatBurst; i++ {
+		if !limits.allow("user-1", now) {
+			t.Fatalf("Message %d of a burst of %d should be allowed", i+1, chatBurst)
+		}
+	}
+	if limits.allow("user-1", now) {
+		t.Error("A message beyond the burst should be refused")
+	}
+	if !limits.allow("user-2", now) {
+		t.Error("Another user's messages shouldn't count against the limit")
+	}
+
+	now = now.Add(chatRefill)
+	if !limits.allow("user-1", now) {
+		t.Error("A message should be allowed once a token has refilled")
+	}
+	if limits.allow("user-1", now) {
+		t.Error("Only one token should have refilled")
+	}
+
+	// The bucket never holds more than a burst
+	now = now.Add(time.Hour)
+	sent := 0
+	for limits.allow("user-1", now) {
+		sent++
+	}
+	if sent != chatBurst {
+		t.Errorf("Sent %d messages after a long pause, want %d", sent, chatBurst)
+	}
+
+	limits.forget("user-1")
+	if !limits.allow("user-1", now) {
+		t.Error("A forgotten user should start with a full bucket")
+	}
+}
+
+func TestCleanMessage(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	hub.SetWordFilter(wordfilter.New([]string{"darn"}))
+
+	if got, problem := hub.cleanMessage("  well DARN it  "); got != "well **** it" || problem != "" {
+		t.Errorf("cleanMessage = (%q, %q), want the trimmed, censored text", got, problem)
+	}
+	if _, problem := hub.cleanMessage(strings.Repeat("é", maxMessageLength+1)); problem == "" {
+		t.Error("A message over the length limit should be refused")
+	}
+	if got, problem := hub.cleanMessage(strings.Repeat("é", maxMessageLength)); problem != "" || len([]rune(got)) != maxMessageLength {
+		t.Errorf("A message at the length limit should be sent, got problem %q", problem)
+	}
+
+	hub.SetWordFilter(nil)
+	if got, _ := hub.cleanMessage("darn"); got != "darn" {
+		t.Errorf("Without a filter the text should be left alone, got %q", got)
+	}
+}
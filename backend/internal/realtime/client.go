@@ -2,6 +2,7 @@ package realtime
 
 import (
 	"encoding/json"
+	"io"
 	"log"
 	"net/http"
 	"time"
@@ -30,6 +31,8 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true // Allow all origins for development
 	},
+	// Clients offering no subprotocol get JSON too; see protocol.go
+	Subprotocols: []string{protocolCompact, protocolJSON},
 }
 
 // Client represents a WebSocket client connection
@@ -37,10 +40,13 @@ type Client struct {
 	ConnectionID string // Unique per WebSocket connection
 	Hub         *Hub
 	Conn        *websocket.Conn
-	Send        chan []byte
+	Send        chan []byte // JSON messages, or compact frames for Compact clients; queue with trySend
 	UserID      string
 	DisplayName string
 	RoomID      string
+	IsSpectator bool // set when the client joins a room; see spectators.go
+	Compact     bool // speaks the compact binary protocol; see protocol.go
+	inbound     inboundLimiter // rate limit and strikes; see inbound.go
 }
 
 // NewClient creates a new WebSocket client
@@ -70,7 +76,7 @@ func (c *Client) ReadPump() {
 	})
 
 	for {
-		_, message, err := c.Conn.ReadMessage()
+		messageType, message, err := c.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
@@ -78,13 +84,59 @@ func (c *Client) ReadPump() {
 			break
 		}
 
+		if messageType == websocket.BinaryMessage {
+			if !c.Compact {
+				log.Printf("Ignoring binary message from JSON client %s", c.ConnectionID)
+				continue
+			}
+			msgs, err := decodeCompact(message)
+			if err != nil {
+				log.Printf("Failed to parse binary message: %v", err)
+				continue
+			}
+			for i := range msgs {
+				if !c.handle(&msgs[i]) {
+					return
+				}
+			}
+			continue
+		}
+
 		var msg Message
 		if err := json.Unmarshal(message, &msg); err != nil {
 			log.Printf("Failed to parse message: %v", err)
 			continue
 		}
 
-		c.Hub.HandleMessage(c, &msg)
+		if !c.handle(&msg) {
+			return
+		}
+	}
+}
+
+// handle passes a message the hub admits on to it, and reports false once the
+// connection has been closed for sending too many it wouldn't
+func (c *Client) handle(msg *Message) bool {
+	switch c.Hub.admit(c, msg, time.Now()) {
+	case verdictHandle:
+		c.Hub.HandleMessage(c, msg)
+	case verdictDisconnect:
+		log.Printf("Disconnecting %s (user %s): too many rejected messages", c.ConnectionID, c.UserID)
+		closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too many messages")
+		c.Conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(writeWait))
+		return false
+	}
+	return true
+}
+
+// trySend queues a message in the client's encoding without waiting, and
+// reports false if the send buffer is full
+func (c *Client) trySend(m *outgoing) bool {
+	select {
+	case c.Send <- m.encodedFor(c):
+		return true
+	default:
+		return false
 	}
 }
 
@@ -106,17 +158,20 @@ func (c *Client) WritePump() {
 				return
 			}
 
-			w, err := c.Conn.NextWriter(websocket.TextMessage)
+			messageType := websocket.TextMessage
+			if c.Compact {
+				messageType = websocket.BinaryMessage
+			}
+			w, err := c.Conn.NextWriter(messageType)
 			if err != nil {
 				return
 			}
-			w.Write(message)
+			c.writeMessage(w, message, true)
 
 			// Add queued messages to the current websocket message
 			n := len(c.Send)
 			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.Send)
+				c.writeMessage(w, <-c.Send, false)
 			}
 
 			if err := w.Close(); err != nil {
@@ -132,6 +187,19 @@ func (c *Client) WritePump() {
 	}
 }
 
+// writeMessage adds a message to the websocket message being written: after
+// a newline for JSON clients, or length-prefixed for compact ones
+func (c *Client) writeMessage(w io.Writer, message []byte, first bool) {
+	if c.Compact {
+		w.Write(appendFrame(nil, message))
+		return
+	}
+	if !first {
+		w.Write([]byte{'\n'})
+	}
+	w.Write(message)
+}
+
 // ServeWs handles WebSocket requests from the peer
 func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request, userID, displayName string) {
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -141,6 +209,7 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request, userID, displayNa
 	}
 
 	client := NewClient(hub, conn, userID, displayName)
+	client.Compact = conn.Subprotocol() == protocolCompact
 	hub.Register(client)
 
 	// Start the client pumps
@@ -0,0 +1,409 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+// The hub owns each room's game clock. Elapsed time is measured from the game
+// start minus time spent paused, and is what solve times are computed from.
+// Clock state lives in the room's shared state hash so every instance agrees;
+// ticks and expiry are claimed so only one instance broadcasts them.
+
+const (
+	// Values of models.RoomConfig.TimerMode
+	timerModeCountdown = "countdown"
+	timerModeStopwatch = "stopwatch"
+
+	clockTickInterval = time.Second
+	clockLockTTL      = time.Minute
+)
+
+// clockElapsed returns how long the game in a room has been running at now,
+// excluding pauses. It is zero before the game starts.
+func (h *Hub) clockElapsed(roomID string, now time.Time) time.Duration {
+	state, err := h.broker.HGetAll(context.Background(), roomStateKey(roomID))
+	if err != nil {
+		return 0
+	}
+	return elapsedFromState(state, now)
+}
+
+// elapsedFromState computes the running time from a room state hash
+func elapsedFromState(state map[string]string, now time.Time) time.Duration {
+	start, err := strconv.ParseInt(state["startTime"], 10, 64)
+	if err != nil {
+		return 0
+	}
+	end := now.UnixNano()
+	if paused, err := strconv.ParseInt(state["pausedAt"], 10, 64); err == nil {
+		end = paused
+	} else if ended, err := strconv.ParseInt(state["endedAt"], 10, 64); err == nil {
+		end = ended
+	}
+	pausedTotal, _ := strconv.ParseInt(state["pausedTotal"], 10, 64)
+
+	elapsed := time.Duration(end - start - pausedTotal)
+	if elapsed < 0 {
+		return 0
+	}
+	return elapsed
+}
+
+// clockPaused reports whether the game in a room is paused
+func (h *Hub) clockPaused(roomID string) bool {
+	state, err := h.broker.HGetAll(context.Background(), roomStateKey(roomID))
+	return err == nil && state["pausedAt"] != ""
+}
+
+// pauseClock stops a room's clock at now. It returns false if the clock is not
+// running (not started, already paused or ended).
+func (h *Hub) pauseClock(roomID string, now time.Time) bool {
+	ctx := context.Background()
+	state, err := h.broker.HGetAll(ctx, roomStateKey(roomID))
+	if err != nil || state["startTime"] == "" || state["endedAt"] != "" {
+		return false
+	}
+	paused, err := h.broker.HSetNX(ctx, roomStateKey(roomID), "pausedAt", strconv.FormatInt(now.UnixNano(), 10))
+	return err == nil && paused
+}
+
+// resumeClock restarts a paused clock and returns how long it was paused.
+// Deleting pausedAt claims the resume, so when two instances (or a double
+// resume) race only the one that deleted it adds the pause to the total.
+func (h *Hub) resumeClock(roomID string, now time.Time) (time.Duration, bool) {
+	ctx := context.Background()
+	state, err := h.broker.HGetAll(ctx, roomStateKey(roomID))
+	if err != nil {
+		return 0, false
+	}
+	pausedAt, err := strconv.ParseInt(state["pausedAt"], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	if deleted, err := h.broker.HDel(ctx, roomStateKey(roomID), "pausedAt"); err != nil || deleted != 1 {
+		return 0, false
+	}
+
+	pausedFor := time.Duration(now.UnixNano() - pausedAt)
+	if pausedFor < 0 {
+		pausedFor = 0
+	}
+	if _, err := h.broker.HIncrBy(ctx, roomStateKey(roomID), "pausedTotal", int64(pausedFor)); err != nil {
+		log.Printf("resumeClock: failed to record pause of room %s: %v", roomID, err)
+	}
+	return pausedFor, true
+}
+
+// stopClock records that the game in a room ended, freezing its elapsed time.
+// It reports whether this call ended the game: when the last edits arrive
+// together, on one instance or several, only one caller gets true and goes on
+// to record results.
+func (h *Hub) stopClock(roomID string, now time.Time) bool {
+	ended, err := h.broker.HSetNX(context.Background(), roomStateKey(roomID), "endedAt", strconv.FormatInt(now.UnixNano(), 10))
+	if err != nil {
+		log.Printf("stopClock: failed to end the game in room %s: %v", roomID, err)
+		return false
+	}
+	return ended
+}
+
+// solveSeconds returns the room's elapsed game time in whole seconds
+func (h *Hub) solveSeconds(roomID string) int {
+	return int(h.clockElapsed(roomID, time.Now()).Seconds())
+}
+
+// claimClockEvent reports whether this instance should broadcast a clock event
+func (h *Hub) claimClockEvent(roomID, event string) bool {
+	key := fmt.Sprintf("room:%s:clock:%s", roomID, event)
+	claimed, err := h.broker.SetNX(context.Background(), key, h.instanceID, clockLockTTL)
+	return err == nil && claimed
+}
+
+// runGameClocks drives the clocks of the timed rooms this instance has clients
+// in until ctx is cancelled
+func (h *Hub) runGameClocks(ctx context.Context) {
+	ticker := time.NewTicker(clockTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.tickGameClocks(now)
+		}
+	}
+}
+
+// tickGameClocks broadcasts a timer_tick for every running timed game and ends
+// countdown games whose time is up
+func (h *Hub) tickGameClocks(now time.Time) {
+	type timedRoom struct {
+		id           string
+		timerMode    string
+		timerSeconds int
+	}
+
+	h.mutex.RLock()
+	rooms := make([]timedRoom, 0, len(h.rooms))
+	for roomID, room := range h.rooms {
+		if room.TimerMode == timerModeCountdown || room.TimerMode == timerModeStopwatch {
+			rooms = append(rooms, timedRoom{roomID, room.TimerMode, room.TimerSeconds})
+		}
+	}
+	h.mutex.RUnlock()
+
+	for _, room := range rooms {
+		state, err := h.broker.HGetAll(context.Background(), roomStateKey(room.id))
+		if err != nil || state["startTime"] == "" || state["endedAt"] != "" {
+			continue
+		}
+
+		elapsed := elapsedFromState(state, now)
+		elapsedSeconds := int(elapsed.Seconds())
+		tick := TimerTickPayload{
+			Elapsed: elapsedSeconds,
+			Paused:  state["pausedAt"] != "",
+		}
+
+		expired := false
+		if room.timerMode == timerModeCountdown {
+			remaining := room.timerSeconds - elapsedSeconds
+			if remaining <= 0 {
+				remaining = 0
+				expired = true
+			}
+			tick.Remaining = &remaining
+		}
+
+		// Every instance serving the room ticks; one tick per second is broadcast
+		if h.claimClockEvent(room.id, "tick:"+strconv.FormatInt(now.Unix(), 10)) {
+			h.broadcastToRoom(room.id, "", MsgTimerTick, tick)
+		}
+
+		if expired && h.claimClockEvent(room.id, "expired:"+state["startTime"]) {
+			h.endGameOnTimeout(room.id, room.timerSeconds)
+		}
+	}
+}
+
+// endGameOnTimeout ends a countdown game whose time ran out. Players get their
+// progress as a result, but nobody is credited with solving the puzzle.
+func (h *Hub) endGameOnTimeout(roomID string, timerSeconds int) {
+	if !h.stopClock(roomID, time.Now()) {
+		return // the puzzle was completed first
+	}
+
+	room, _ := h.db.GetRoomByID(roomID)
+	if room == nil || room.State != models.RoomStateActive {
+		return
+	}
+	h.setRoomState(roomID, models.RoomStateCompleted)
+	h.flushRoomGrids(roomID)
+
+	h.broadcastToRoom(roomID, "", MsgTimeExpired, TimeExpiredPayload{
+		Elapsed: timerSeconds,
+	})
+
+	puzzle, _ := h.db.GetPuzzleByID(room.PuzzleID)
+	players, _ := h.db.GetRoomPlayers(roomID)
+
+	var results []PlayerResult
+	var teamResults []TeamResult
+	switch room.Mode {
+	case models.RoomModeRace:
+		results = h.timedOutRaceResults(roomID, puzzle, players)
+	case models.RoomModeTeam:
+		results, teamResults = h.teamResults(roomID, puzzle, players, teamsInPlay(roomTeams(room), players))
+	case models.RoomModeCollaborative:
+		results = h.timedOutCollaborativeResults(roomID, puzzle, players)
+	default:
+		for _, p := range players {
+			if !p.IsSpectator {
+				results = append(results, PlayerResult{
+					UserID:      p.UserID,
+					DisplayName: p.DisplayName,
+					Color:       p.Color,
+				})
+			}
+		}
+	}
+
+	h.completePuzzle(room, players, PuzzleCompletedPayload{
+		SolveTime:   timerSeconds,
+		Players:     results,
+		CompletedAt: time.Now(),
+		TimedOut:    true,
+		Teams:       teamResults,
+	})
+
+	log.Printf("endGameOnTimeout: room %s ran out of time after %ds", roomID, timerSeconds)
+}
+
+// timedOutCollaborativeResults credits each player with their share of the grid filled in
+func (h *Hub) timedOutCollaborativeResults(roomID string, puzzle *models.Puzzle, players []models.Player) []PlayerResult {
+	totalCells := 0
+	if puzzle != nil {
+		_, totalCells = countCorrectCells(puzzle, nil)
+	}
+	contributions := h.roomContributions(roomID)
+
+	var results []PlayerResult
+	for _, p := range players {
+		if p.IsSpectator {
+			continue
+		}
+		contribution := 0.0
+		if totalCells > 0 {
+			contribution = float64(contributions[p.UserID]) / float64(totalCells) * 100.0
+			h.db.UpdatePlayerContribution(p.UserID, roomID, contribution)
+		}
+		results = append(results, PlayerResult{
+			UserID:       p.UserID,
+			DisplayName:  p.DisplayName,
+			Contribution: contribution,
+			Color:        p.Color,
+		})
+	}
+	return results
+}
+
+// timedOutRaceResults ranks finished racers first, in finish order, then
+// everyone else by progress. Contribution is the percentage of the grid solved.
+func (h *Hub) timedOutRaceResults(roomID string, puzzle *models.Puzzle, players []models.Player) []PlayerResult {
+	finished := make(map[string]bool)
+	var results []PlayerResult
+	for _, uid := range h.finishOrder(roomID) {
+		finished[uid] = true
+		if player := findPlayer(players, uid); player != nil {
+			results = append(results, PlayerResult{
+				UserID:       uid,
+				DisplayName:  player.DisplayName,
+				Contribution: 100,
+				Color:        player.Color,
+			})
+		}
+	}
+
+	var unfinished []PlayerResult
+	for _, p := range players {
+		if p.IsSpectator || finished[p.UserID] {
+			continue
+		}
+		progress := 0.0
+		if puzzle != nil {
+			gridState := h.playerGridState(roomID, p.UserID)
+			if correct, total := countCorrectCells(puzzle, gridState); total > 0 {
+				progress = float64(correct) / float64(total) * 100
+			}
+		}
+		unfinished = append(unfinished, PlayerResult{
+			UserID:       p.UserID,
+			DisplayName:  p.DisplayName,
+			Contribution: progress,
+			Color:        p.Color,
+		})
+	}
+	sort.SliceStable(unfinished, func(i, j int) bool {
+		return unfinished[i].Contribution > unfinished[j].Contribution
+	})
+
+	return append(results, unfinished...)
+}
+
+// handlePauseGame lets the host pause a running game
+func (h *Hub) handlePauseGame(client *Client) {
+	room := h.hostActiveRoom(client)
+	if room == nil {
+		return
+	}
+
+	now := time.Now()
+	if !h.pauseClock(room.ID, now) {
+		h.sendError(client, "game is not running")
+		return
+	}
+
+	h.broadcastToRoom(room.ID, "", MsgGamePaused, GamePausedPayload{
+		UserID:  client.UserID,
+		Elapsed: int(h.clockElapsed(room.ID, now).Seconds()),
+	})
+}
+
+// handleResumeGame lets the host resume a paused game
+func (h *Hub) handleResumeGame(client *Client) {
+	room := h.hostActiveRoom(client)
+	if room == nil {
+		return
+	}
+
+	now := time.Now()
+	pausedFor, ok := h.resumeClock(room.ID, now)
+	if !ok {
+		h.sendError(client, "game is not paused")
+		return
+	}
+
+	// The relay turn in progress gets back the time it lost to the pause
+	if room.Mode == models.RoomModeRelay {
+		if relayState := h.relayState(room.ID); relayState != nil {
+			relayState.TurnStartedAt = relayState.TurnStartedAt.Add(pausedFor)
+			h.saveRelayState(relayState)
+			h.scheduleTurnTimeout(room.ID, turnDeadline(relayState))
+		}
+	}
+
+	h.broadcastToRoom(room.ID, "", MsgGameResumed, GamePausedPayload{
+		UserID:  client.UserID,
+		Elapsed: int(h.clockElapsed(room.ID, now).Seconds()),
+	})
+}
+
+// hostActiveRoom returns the client's room if the client is its host and the
+// game is active, sending an error otherwise
+func (h *Hub) hostActiveRoom(client *Client) *models.Room {
+	if client.RoomID == "" {
+		return nil
+	}
+	room, _ := h.db.GetRoomByID(client.RoomID)
+	if room == nil {
+		return nil
+	}
+	if room.HostID != client.UserID {
+		h.sendError(client, "only host can pause or resume the game")
+		return nil
+	}
+	if room.State != models.RoomStateActive {
+		h.sendError(client, "game is not running")
+		return nil
+	}
+	return room
+}
+
+// countCorrectCells counts the letter cells of a grid state that match the
+// puzzle, along with the total number of letter cells. gridState may be nil.
+func countCorrectCells(puzzle *models.Puzzle, gridState *models.GridState) (correct, total int) {
+	for y := range puzzle.Grid {
+		for x := range puzzle.Grid[y] {
+			expectedLetter := puzzle.Grid[y][x].Letter
+			if expectedLetter == nil {
+				continue
+			}
+			total++
+			if gridState != nil && y < len(gridState.Cells) && x < len(gridState.Cells[y]) {
+				currentValue := cellAnswer(gridState.Cells[y][x])
+				if currentValue != nil && *currentValue == *expectedLetter {
+					correct++
+				}
+			}
+		}
+	}
+	return correct, total
+}
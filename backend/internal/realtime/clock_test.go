@@ -0,0 +1,220 @@
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+func TestClockPauseResume(t *testing.T) {
+	hub := NewHub(nil)
+	t0 := time.Now()
+
+	if hub.clockElapsed("room-1", t0) != 0 {
+		t.Error("Clock should read zero before the game starts")
+	}
+	if hub.pauseClock("room-1", t0) {
+		t.Error("A game that hasn't started can't be paused")
+	}
+
+	hub.setRoomStartTime("room-1", t0)
+	if got := hub.clockElapsed("room-1", t0.Add(10*time.Second)); got != 10*time.Second {
+		t.Errorf("elapsed = %v, want 10s", got)
+	}
+
+	if !hub.pauseClock("room-1", t0.Add(10*time.Second)) {
+		t.Fatal("pauseClock failed")
+	}
+	if hub.pauseClock("room-1", t0.Add(11*time.Second)) {
+		t.Error("A paused game can't be paused again")
+	}
+	if !hub.clockPaused("room-1") {
+		t.Error("clockPaused = false after pausing")
+	}
+	if got := hub.clockElapsed("room-1", t0.Add(60*time.Second)); got != 10*time.Second {
+		t.Errorf("elapsed while paused = %v, want 10s", got)
+	}
+
+	pausedFor, ok := hub.resumeClock("room-1", t0.Add(40*time.Second))
+	if !ok || pausedFor != 30*time.Second {
+		t.Errorf("resumeClock = (%v, %v), want (30s, true)", pausedFor, ok)
+	}
+	if _, ok := hub.resumeClock("room-1", t0.Add(41*time.Second)); ok {
+		t.Error("A running game can't be resumed")
+	}
+	if got := hub.clockElapsed("room-1", t0.Add(50*time.Second)); got != 20*time.Second {
+		t.Errorf("elapsed after resume = %v, want 20s", got)
+	}
+
+	hub.stopClock("room-1", t0.Add(55*time.Second))
+	if got := hub.clockElapsed("room-1", t0.Add(100*time.Second)); got != 25*time.Second {
+		t.Errorf("elapsed after the game ended = %v, want 25s", got)
+	}
+	if hub.pauseClock("room-1", t0.Add(100*time.Second)) {
+		t.Error("An ended game can't be paused")
+	}
+}
+
+func TestClockResumeRace(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubs := []*Hub{NewHubWithBroker(nil, broker), NewHubWithBroker(nil, broker)}
+	t0 := time.Now()
+
+	hubs[0].setRoomStartTime("room-1", t0)
+	hubs[0].pauseClock("room-1", t0.Add(10*time.Second))
+
+	// Both instances resume at once; only one may add the pause
+	var wg sync.WaitGroup
+	resumed := make(chan bool, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(hub *Hub) {
+			defer wg.Done()
+			_, ok := hub.resumeClock("room-1", t0.Add(40*time.Second))
+			resumed <- ok
+		}(hubs[i%2])
+	}
+	wg.Wait()
+	close(resumed)
+
+	wins := 0
+	for ok := range resumed {
+		if ok {
+			wins++
+		}
+	}
+	if wins != 1 {
+		t.Errorf("%d resumes succeeded, want exactly 1", wins)
+	}
+	if got := hubs[1].clockElapsed("room-1", t0.Add(50*time.Second)); got != 20*time.Second {
+		t.Errorf("elapsed after resume = %v, want 20s (the pause counted once)", got)
+	}
+}
+
+func TestStopClockClaimsCompletionOnce(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubs := []*Hub{NewHubWithBroker(nil, broker), NewHubWithBroker(nil, broker)}
+	t0 := time.Now()
+	hubs[0].setRoomStartTime("room-1", t0)
+
+	// The last two edits complete the grid on both instances at once; only
+	// one of them may record the results
+	var wg sync.WaitGroup
+	ended := make(chan bool, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(hub *Hub, i int) {
+			defer wg.Done()
+			ended <- hub.stopClock("room-1", t0.Add(time.Duration(30+i)*time.Second))
+		}(hubs[i%2], i)
+	}
+	wg.Wait()
+	close(ended)
+
+	wins := 0
+	for ok := range ended {
+		if ok {
+			wins++
+		}
+	}
+	if wins != 1 {
+		t.Errorf("%d writers claimed the completion, want exactly 1", wins)
+	}
+
+	// A rematch starts a new game that can be completed again
+	hubs[1].resetRoomState("room-1")
+	if !hubs[1].stopClock("room-1", t0.Add(time.Minute)) {
+		t.Error("The next game should be claimable after a rematch")
+	}
+}
+
+func TestTickGameClocks(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := NewHubWithBroker(nil, broker)
+	hubB := NewHubWithBroker(nil, broker)
+
+	countdown := newTestClient("conn-1", "user-1")
+	stopwatch := newTestClient("conn-2", "user-2")
+	untimed := newTestClient("conn-3", "user-3")
+	addTestRoom(hubA, "countdown", countdown)
+	addTestRoom(hubA, "stopwatch", stopwatch)
+	addTestRoom(hubA, "untimed", untimed)
+	hubA.rooms["countdown"].TimerMode = timerModeCountdown
+	hubA.rooms["countdown"].TimerSeconds = 60
+	hubA.rooms["stopwatch"].TimerMode = timerModeStopwatch
+
+	// Same room served by a second instance
+	addTestRoom(hubB, "countdown", newTestClient("conn-4", "user-4"))
+	hubB.rooms["countdown"].TimerMode = timerModeCountdown
+	hubB.rooms["countdown"].TimerSeconds = 60
+
+	now := time.Now()
+	for _, roomID := range []string{"countdown", "stopwatch", "untimed"} {
+		hubA.setRoomStartTime(roomID, now.Add(-10*time.Second))
+	}
+
+	hubA.tickGameClocks(now)
+	hubB.tickGameClocks(now)
+
+	var tick TimerTickPayload
+	msg := receiveMessage(t, countdown, time.Second)
+	if msg == nil || msg.Type != MsgTimerTick {
+		t.Fatalf("Expected timer_tick, got %v", msg)
+	}
+	json.Unmarshal(msg.Payload, &tick)
+	if tick.Elapsed != 10 || tick.Remaining == nil || *tick.Remaining != 50 || tick.Paused {
+		t.Errorf("countdown tick = %+v, want 10s elapsed and 50s remaining", tick)
+	}
+	if msg := receiveMessage(t, countdown, 20*time.Millisecond); msg != nil {
+		t.Error("Only one instance should broadcast each tick")
+	}
+
+	msg = receiveMessage(t, stopwatch, time.Second)
+	if msg == nil || msg.Type != MsgTimerTick {
+		t.Fatalf("Expected timer_tick, got %v", msg)
+	}
+	tick = TimerTickPayload{}
+	json.Unmarshal(msg.Payload, &tick)
+	if tick.Remaining != nil {
+		t.Error("Stopwatch ticks should not carry a remaining time")
+	}
+
+	if msg := receiveMessage(t, untimed, 20*time.Millisecond); msg != nil {
+		t.Error("Rooms without a timer should not tick")
+	}
+
+	// A paused game keeps ticking with a frozen clock
+	hubA.pauseClock("countdown", now)
+	hubA.tickGameClocks(now.Add(5 * time.Second))
+	msg = receiveMessage(t, countdown, time.Second)
+	if msg == nil {
+		t.Fatal("Expected timer_tick while paused")
+	}
+	tick = TimerTickPayload{}
+	json.Unmarshal(msg.Payload, &tick)
+	if !tick.Paused || tick.Elapsed != 10 {
+		t.Errorf("paused tick = %+v, want paused at 10s", tick)
+	}
+}
+
+func TestCountCorrectCells(t *testing.T) {
+	a, b := "A", "B"
+	puzzle := &models.Puzzle{Grid: [][]models.GridCell{
+		{{Letter: &a}, {Letter: nil}},
+		{{Letter: &b}, {Letter: &a}},
+	}}
+	gridState := &models.GridState{Cells: [][]models.Cell{
+		{{Value: &a}, {}},
+		{{Value: &a}, {}},
+	}}
+
+	if correct, total := countCorrectCells(puzzle, gridState); correct != 1 || total != 3 {
+		t.Errorf("countCorrectCells = (%d, %d), want (1, 3)", correct, total)
+	}
+	if correct, total := countCorrectCells(puzzle, nil); correct != 0 || total != 3 {
+		t.Errorf("countCorrectCells(nil) = (%d, %d), want (0, 3)", correct, total)
+	}
+}
@@ -0,0 +1,380 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Several server instances can run behind a load balancer. Each hub only holds
+// its own WebSocket connections; room broadcasts, presence and game state that
+// must be seen by every instance go through the Broker (Redis in production).
+
+const (
+	// Channel every instance subscribes to for room broadcasts
+	broadcastChannel = "realtime:broadcast"
+
+	// Instances refresh a heartbeat key so presence entries left behind by a
+	// crashed instance can be ignored
+	instanceHeartbeatInterval = 5 * time.Second
+	instanceTTL               = 3 * instanceHeartbeatInterval
+
+	// Shared room state is dropped this long after the last join or game start
+	roomStateTTL = 24 * time.Hour
+
+	// Locks that make sure only one instance ends a relay turn or acts on a host disconnect
+	turnEndLockTTL    = time.Minute
+	hostChangeLockTTL = time.Minute
+)
+
+type envelopeKind string
+
+const (
+	envelopeBroadcast  envelopeKind = "broadcast"   // deliver Data to the room's local clients
+	envelopeRoomClosed envelopeKind = "room_closed" // room was deleted, detach local clients
+	envelopeKick       envelopeKind = "kick"        // detach the Users' local clients from the room
+	envelopeLobby      envelopeKind = "lobby"       // Data is the room's lobby listing, empty if it is no longer listed
+)
+
+// clusterEnvelope is published to other instances for each room event
+type clusterEnvelope struct {
+	Kind    envelopeKind    `json:"kind"`
+	Origin  string          `json:"origin"` // instance that published the event
+	RoomID  string          `json:"roomId"`
+	Exclude string          `json:"exclude,omitempty"` // connection ID that must not receive Data
+	Users   []string        `json:"users,omitempty"`   // when set, only these users' connections receive Data
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Broker keys
+func instanceKey(instanceID string) string    { return "realtime:instance:" + instanceID }
+func roomStateKey(roomID string) string       { return "room:" + roomID + ":state" }
+func roomContribKey(roomID string) string     { return "room:" + roomID + ":contributions" }
+func roomFinishKey(roomID string) string      { return "room:" + roomID + ":finish" }
+func roomConnectionsKey(roomID string) string { return "room:" + roomID + ":connections" }
+func userConnectionsKey(userID string) string { return "user:" + userID + ":connections" }
+
+// Presence entries are stored as "instanceID|value"
+func presenceEntry(instanceID, value string) string { return instanceID + "|" + value }
+
+func parsePresenceEntry(entry string) (instanceID, value string) {
+	instanceID, value, _ = strings.Cut(entry, "|")
+	return instanceID, value
+}
+
+// publish sends an envelope to every other instance
+func (h *Hub) publish(env clusterEnvelope) {
+	data, err := h.encodeEnvelope(env)
+	if err != nil {
+		return
+	}
+	if err := h.broker.Publish(context.Background(), broadcastChannel, data); err != nil {
+		log.Printf("publish: failed to publish %s for room %s: %v", env.Kind, env.RoomID, err)
+	}
+}
+
+// encodeEnvelope marks an envelope as coming from this instance and encodes it
+func (h *Hub) encodeEnvelope(env clusterEnvelope) ([]byte, error) {
+	env.Origin = h.instanceID
+	return json.Marshal(env)
+}
+
+// handleClusterMessages applies events published by other instances until ctx is cancelled
+func (h *Hub) handleClusterMessages(ctx context.Context, msgs <-chan []byte) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data, ok := <-msgs:
+			if !ok {
+				return
+			}
+			h.applyClusterMessage(data)
+		}
+	}
+}
+
+// applyClusterMessage applies one event published by another instance
+func (h *Hub) applyClusterMessage(data []byte) {
+	var env clusterEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		log.Printf("applyClusterMessage: invalid envelope: %v", err)
+		return
+	}
+	if env.Origin == h.instanceID {
+		return
+	}
+
+	switch env.Kind {
+	case envelopeBroadcast:
+		h.applyRemoteBroadcast(env.RoomID, env.Data)
+		if len(env.Users) > 0 {
+			h.deliverToUsers(env.RoomID, env.Exclude, env.Users, env.Data)
+		} else {
+			h.deliverToRoom(env.RoomID, env.Exclude, env.Data)
+		}
+	case envelopeRoomClosed:
+		h.closeLocalRoom(env.RoomID)
+	case envelopeKick:
+		for _, userID := range env.Users {
+			h.detachUser(env.RoomID, userID)
+		}
+	case envelopeLobby:
+		h.applyRemoteLobbyUpdate(env.RoomID, env.Data)
+	}
+}
+
+// applyRemoteBroadcast keeps this instance's copy of a room in step with a
+// message broadcast by another instance
+func (h *Hub) applyRemoteBroadcast(roomID string, msgData []byte) {
+	var msg Message
+	if err := json.Unmarshal(msgData, &msg); err != nil {
+		return
+	}
+
+	switch msg.Type {
+	case MsgCellUpdated:
+		h.mergeRemoteCellUpdate(roomID, &msg)
+	case MsgClueNoteUpdated:
+		h.mergeRemoteClueNote(roomID, &msg)
+	case MsgTurnChanged:
+		h.applyRemoteTurnChange(roomID, &msg)
+	case MsgGameResumed:
+		h.watchTurn(roomID)
+	case MsgRematchStarted:
+		h.resetLocalRoom(roomID)
+	case MsgGameStarted, MsgHostChanged, MsgRoomSettings:
+		h.invalidateRoom(roomID)
+	case MsgPlayerJoined, MsgPlayerLeft, MsgPlayerKicked, MsgTeamChanged:
+		h.invalidatePlayers(roomID)
+	case MsgPuzzleCompleted, MsgTimeExpired:
+		h.invalidateRoom(roomID)
+		h.flushRoomGrids(roomID)
+	}
+}
+
+// runHeartbeat keeps this instance's heartbeat key alive until ctx is cancelled
+func (h *Hub) runHeartbeat(ctx context.Context) {
+	ticker := time.NewTicker(instanceHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := h.broker.Set(context.Background(), instanceKey(h.instanceID), "1", instanceTTL); err != nil {
+			log.Printf("runHeartbeat: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// instanceAlive reports whether an instance has refreshed its heartbeat recently
+func (h *Hub) instanceAlive(instanceID string) bool {
+	if instanceID == h.instanceID {
+		return true
+	}
+	alive, err := h.broker.Exists(context.Background(), instanceKey(instanceID))
+	return err == nil && alive
+}
+
+// trackConnection publishes a connection and the room it is in ("" while waiting to join)
+func (h *Hub) trackConnection(client *Client) {
+	ctx := context.Background()
+	if err := h.broker.HSet(ctx, userConnectionsKey(client.UserID), client.ConnectionID, presenceEntry(h.instanceID, client.RoomID)); err != nil {
+		log.Printf("trackConnection: %v", err)
+	}
+	if client.RoomID != "" {
+		if err := h.broker.HSet(ctx, roomConnectionsKey(client.RoomID), client.ConnectionID, presenceEntry(h.instanceID, client.UserID)); err != nil {
+			log.Printf("trackConnection: %v", err)
+		}
+	}
+}
+
+// untrackRoomConnection removes a connection from a room's presence
+func (h *Hub) untrackRoomConnection(client *Client, roomID string) {
+	ctx := context.Background()
+	h.broker.HDel(ctx, roomConnectionsKey(roomID), client.ConnectionID)
+	h.broker.HSet(ctx, userConnectionsKey(client.UserID), client.ConnectionID, presenceEntry(h.instanceID, ""))
+}
+
+// untrackConnection removes a closed connection from the user's presence
+func (h *Hub) untrackConnection(client *Client) {
+	h.broker.HDel(context.Background(), userConnectionsKey(client.UserID), client.ConnectionID)
+}
+
+// clusterHasConnection reports whether the user has a connection on any live
+// instance, other than excludeConnectionID, that is in the room or not yet in any room
+func (h *Hub) clusterHasConnection(userID, roomID, excludeConnectionID string) (bool, error) {
+	conns, err := h.broker.HGetAll(context.Background(), userConnectionsKey(userID))
+	if err != nil {
+		return false, err
+	}
+
+	for connID, entry := range conns {
+		if connID == excludeConnectionID {
+			continue
+		}
+		instanceID, connRoomID := parsePresenceEntry(entry)
+		if connRoomID != roomID && connRoomID != "" {
+			continue
+		}
+		if h.instanceAlive(instanceID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// touchRoomState extends the lifetime of a room's shared state
+func (h *Hub) touchRoomState(roomID string) {
+	ctx := context.Background()
+	for _, key := range []string{roomStateKey(roomID), roomContribKey(roomID), roomFinishKey(roomID), roomConnectionsKey(roomID), roomReplayKey(roomID), roomCellVersionsKey(roomID), roomHintsKey(roomID), roomSeriesKey(roomID), roomRelayKey(roomID)} {
+		h.broker.Expire(ctx, key, roomStateTTL)
+	}
+}
+
+// clearRoomState removes all shared state of a deleted room
+func (h *Hub) clearRoomState(roomID string) {
+	h.broker.Del(context.Background(), roomStateKey(roomID), roomContribKey(roomID), roomFinishKey(roomID), roomConnectionsKey(roomID), roomReplayKey(roomID), roomCellVersionsKey(roomID), roomHintsKey(roomID), roomSeriesKey(roomID), roomRelayKey(roomID))
+}
+
+// resetRoomState clears the shared state of a room's last game for a rematch.
+// The message sequence stays, so clients can resume across the rematch.
+func (h *Hub) resetRoomState(roomID string) {
+	ctx := context.Background()
+	state, _ := h.broker.HGetAll(ctx, roomStateKey(roomID))
+	var fields []string
+	for field := range state {
+		if field != "seq" {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) > 0 {
+		h.broker.HDel(ctx, roomStateKey(roomID), fields...)
+	}
+	h.broker.Del(ctx, roomContribKey(roomID), roomFinishKey(roomID), roomCellVersionsKey(roomID), roomHintsKey(roomID), roomRelayKey(roomID))
+}
+
+// setRoomStartTime records when the game in a room started
+func (h *Hub) setRoomStartTime(roomID string, startTime time.Time) {
+	if err := h.broker.HSet(context.Background(), roomStateKey(roomID), "startTime", strconv.FormatInt(startTime.UnixNano(), 10)); err != nil {
+		log.Printf("setRoomStartTime: %v", err)
+	}
+	h.touchRoomState(roomID)
+}
+
+// roomStartTime returns when the game in a room started, or nil if it hasn't
+func (h *Hub) roomStartTime(roomID string) *time.Time {
+	state, err := h.broker.HGetAll(context.Background(), roomStateKey(roomID))
+	if err != nil || state["startTime"] == "" {
+		return nil
+	}
+	nanos, err := strconv.ParseInt(state["startTime"], 10, 64)
+	if err != nil {
+		return nil
+	}
+	startTime := time.Unix(0, nanos)
+	return &startTime
+}
+
+// addContribution credits a user with one newly correct cell
+func (h *Hub) addContribution(roomID, userID string) {
+	if _, err := h.broker.HIncrBy(context.Background(), roomContribKey(roomID), userID, 1); err != nil {
+		log.Printf("addContribution: %v", err)
+	}
+}
+
+// roomContributions returns the correct cell count of each contributing user
+func (h *Hub) roomContributions(roomID string) map[string]int {
+	counts := make(map[string]int)
+	values, err := h.broker.HGetAll(context.Background(), roomContribKey(roomID))
+	if err != nil {
+		return counts
+	}
+	for userID, value := range values {
+		if n, err := strconv.Atoi(value); err == nil {
+			counts[userID] = n
+		}
+	}
+	return counts
+}
+
+// recordFinish assigns the next race rank to a user. ok is false if the user
+// had already finished.
+func (h *Hub) recordFinish(roomID, userID string) (rank int, ok bool) {
+	ctx := context.Background()
+	added, err := h.broker.HSetNX(ctx, roomFinishKey(roomID), userID, "0")
+	if err != nil || !added {
+		return 0, false
+	}
+	n, err := h.broker.HIncrBy(ctx, roomStateKey(roomID), "finished", 1)
+	if err != nil {
+		return 0, false
+	}
+	h.broker.HSet(ctx, roomFinishKey(roomID), userID, strconv.FormatInt(n, 10))
+	return int(n), true
+}
+
+// finishOrder returns the user IDs of finished racers, first place first
+func (h *Hub) finishOrder(roomID string) []string {
+	values, err := h.broker.HGetAll(context.Background(), roomFinishKey(roomID))
+	if err != nil {
+		return nil
+	}
+
+	type finish struct {
+		userID string
+		rank   int
+	}
+	finishes := make([]finish, 0, len(values))
+	for userID, value := range values {
+		rank, _ := strconv.Atoi(value)
+		finishes = append(finishes, finish{userID, rank})
+	}
+	// Rank 0 is a finish still being recorded; order it last
+	sort.Slice(finishes, func(i, j int) bool {
+		ri, rj := finishes[i].rank, finishes[j].rank
+		if (ri == 0) != (rj == 0) {
+			return rj == 0
+		}
+		return ri < rj
+	})
+
+	order := make([]string, len(finishes))
+	for i, f := range finishes {
+		order[i] = f.userID
+	}
+	return order
+}
+
+// nextTurnNumber increments and returns a relay room's turn counter
+func (h *Hub) nextTurnNumber(roomID string) int {
+	n, err := h.broker.HIncrBy(context.Background(), roomStateKey(roomID), "turn", 1)
+	if err != nil {
+		return 1
+	}
+	return int(n)
+}
+
+// claimTurnEnd reports whether this instance should end a relay turn. A pass,
+// a timeout, a completed quota of words and a skip vote can all end the same
+// turn, on any instance; only the first to claim it advances.
+func (h *Hub) claimTurnEnd(roomID string, turnStartedAt time.Time) bool {
+	key := fmt.Sprintf("room:%s:turn-end:%d", roomID, turnStartedAt.UnixNano())
+	claimed, err := h.broker.SetNX(context.Background(), key, h.instanceID, turnEndLockTTL)
+	return err == nil && claimed
+}
+
+// claimHostChange reports whether this instance should pass on the host role
+// of a host that left, or delete the room if nobody is left
+func (h *Hub) claimHostChange(roomID, hostUserID string) bool {
+	claimed, err := h.broker.SetNX(context.Background(), "room:"+roomID+":host-change:"+hostUserID, h.instanceID, hostChangeLockTTL)
+	return err == nil && claimed
+}
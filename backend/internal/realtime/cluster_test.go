@@ -0,0 +1,285 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// addTestRoom creates a room on the hub containing the given clients
+func addTestRoom(hub *Hub, roomID string, clients ...*Client) {
+	room := &Room{
+		ID:      roomID,
+		Code:    "TEST",
+		Clients: make(map[string]*Client),
+	}
+	for _, c := range clients {
+		c.RoomID = roomID
+		room.Clients[c.ConnectionID] = c
+	}
+	hub.mutex.Lock()
+	hub.rooms[roomID] = room
+	hub.mutex.Unlock()
+}
+
+// runHub runs a hub until the test ends
+func runHub(t *testing.T, hub *Hub) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go hub.Run(ctx)
+}
+
+func newTestClient(connID, userID string) *Client {
+	return &Client{
+		ConnectionID: connID,
+		UserID:       userID,
+		DisplayName:  "Test User",
+		Send:         make(chan []byte, 256),
+	}
+}
+
+func receiveMessage(t *testing.T, client *Client, timeout time.Duration) *Message {
+	t.Helper()
+	select {
+	case data := <-client.Send:
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("invalid message: %v", err)
+		}
+		return &msg
+	case <-time.After(timeout):
+		return nil
+	}
+}
+
+func TestBroadcastAcrossInstances(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := NewHubWithBroker(nil, broker)
+	hubB := NewHubWithBroker(nil, broker)
+	runHub(t, hubA)
+	runHub(t, hubB)
+
+	clientA := newTestClient("conn-a", "user-a")
+	clientB1 := newTestClient("conn-b1", "user-b")
+	clientB2 := newTestClient("conn-b2", "user-c")
+	addTestRoom(hubA, "room-1", clientA)
+	addTestRoom(hubB, "room-1", clientB1, clientB2)
+
+	hubA.broadcastToRoom("room-1", clientB2.ConnectionID, MsgCellUpdated, map[string]string{"test": "data"})
+
+	if msg := receiveMessage(t, clientA, time.Second); msg == nil || msg.Type != MsgCellUpdated {
+		t.Fatalf("Local client should receive the broadcast, got %v", msg)
+	}
+	if msg := receiveMessage(t, clientB1, time.Second); msg == nil || msg.Type != MsgCellUpdated {
+		t.Fatalf("Client on the other instance should receive the broadcast, got %v", msg)
+	}
+	if msg := receiveMessage(t, clientB2, 50*time.Millisecond); msg != nil {
+		t.Error("Excluded connection on the other instance should not receive the broadcast")
+	}
+	if msg := receiveMessage(t, clientA, 50*time.Millisecond); msg != nil {
+		t.Error("Publishing instance should not deliver its own broadcast twice")
+	}
+}
+
+func TestPresenceAcrossInstances(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := NewHubWithBroker(nil, broker)
+	hubB := NewHubWithBroker(nil, broker)
+	runHub(t, hubB)
+
+	client := newTestClient("conn-1", "user-1")
+	hubB.Register(client)
+
+	// Wait for the connection to be tracked
+	for i := 0; i < 100 && !hubA.hasPotentialRoomConnection("user-1", "room-1", ""); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if !hubA.hasPotentialRoomConnection("user-1", "room-1", "") {
+		t.Fatal("Connection waiting to join on another instance should count as a potential room connection")
+	}
+
+	hubB.untrackConnection(client)
+	joined := newTestClient("conn-2", "user-1")
+	joined.RoomID = "room-2"
+	hubB.trackConnection(joined)
+	if hubA.hasPotentialRoomConnection("user-1", "room-1", "") {
+		t.Error("Connection in a different room should not count")
+	}
+	if !hubA.hasPotentialRoomConnection("user-1", "room-2", "") {
+		t.Error("Connection in the room on another instance should count")
+	}
+	if hubA.hasPotentialRoomConnection("user-1", "room-2", joined.ConnectionID) {
+		t.Error("Excluded connection should not count")
+	}
+
+	// Entries left behind by an instance without a heartbeat are ignored
+	hubC := NewHubWithBroker(nil, broker)
+	stale := newTestClient("conn-stale", "user-2")
+	hubC.trackConnection(stale)
+	if hubA.hasPotentialRoomConnection("user-2", "room-1", "") {
+		t.Error("Connection on a dead instance should not count")
+	}
+}
+
+func TestSharedRoomState(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := NewHubWithBroker(nil, broker)
+	hubB := NewHubWithBroker(nil, broker)
+
+	start := time.Now().Add(-time.Minute)
+	hubA.setRoomStartTime("room-1", start)
+	if got := hubB.roomStartTime("room-1"); got == nil || !got.Equal(time.Unix(0, start.UnixNano())) {
+		t.Errorf("roomStartTime = %v, want %v", got, start)
+	}
+
+	hubA.addContribution("room-1", "user-1")
+	hubB.addContribution("room-1", "user-1")
+	hubB.addContribution("room-1", "user-2")
+	contributions := hubA.roomContributions("room-1")
+	if contributions["user-1"] != 2 || contributions["user-2"] != 1 {
+		t.Errorf("roomContributions = %v", contributions)
+	}
+
+	if rank, ok := hubA.recordFinish("room-1", "user-2"); !ok || rank != 1 {
+		t.Errorf("First finish = (%d, %v), want (1, true)", rank, ok)
+	}
+	if rank, ok := hubB.recordFinish("room-1", "user-1"); !ok || rank != 2 {
+		t.Errorf("Second finish = (%d, %v), want (2, true)", rank, ok)
+	}
+	if _, ok := hubB.recordFinish("room-1", "user-2"); ok {
+		t.Error("A player should only finish once")
+	}
+	order := hubA.finishOrder("room-1")
+	if len(order) != 2 || order[0] != "user-2" || order[1] != "user-1" {
+		t.Errorf("finishOrder = %v, want [user-2 user-1]", order)
+	}
+
+	if n := hubA.nextTurnNumber("room-1"); n != 1 {
+		t.Errorf("nextTurnNumber = %d, want 1", n)
+	}
+	if n := hubB.nextTurnNumber("room-1"); n != 2 {
+		t.Errorf("nextTurnNumber = %d, want 2", n)
+	}
+
+	hubA.clearRoomState("room-1")
+	if hubB.roomStartTime("room-1") != nil || len(hubB.finishOrder("room-1")) != 0 {
+		t.Error("clearRoomState should remove shared state")
+	}
+}
+
+func TestClusterLocks(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := NewHubWithBroker(nil, broker)
+	hubB := NewHubWithBroker(nil, broker)
+
+	turnStarted := time.Now()
+	if !hubA.claimTurnEnd("room-1", turnStarted) {
+		t.Error("First instance should claim the turn end")
+	}
+	if hubB.claimTurnEnd("room-1", turnStarted) {
+		t.Error("Second instance should not claim the same turn end")
+	}
+	if !hubB.claimTurnEnd("room-1", turnStarted.Add(time.Second)) {
+		t.Error("A later turn should be claimable again")
+	}
+
+	if !hubB.claimHostChange("room-1", "host-1") {
+		t.Error("First instance should claim the host change")
+	}
+	if hubA.claimHostChange("room-1", "host-1") {
+		t.Error("Second instance should not claim the same host change")
+	}
+	if !hubA.claimHostChange("room-1", "host-2") {
+		t.Error("A later host leaving should be claimable again")
+	}
+}
+
+func TestRoomClosedAcrossInstances(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := NewHubWithBroker(nil, broker)
+	hubB := NewHubWithBroker(nil, broker)
+	runHub(t, hubB)
+
+	client := newTestClient("conn-1", "user-1")
+	addTestRoom(hubB, "room-1", client)
+
+	hubA.publish(clusterEnvelope{Kind: envelopeRoomClosed, RoomID: "room-1"})
+
+	for i := 0; i < 100; i++ {
+		hubB.mutex.RLock()
+		_, exists := hubB.rooms["room-1"]
+		hubB.mutex.RUnlock()
+		if !exists {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	hubB.mutex.RLock()
+	_, exists := hubB.rooms["room-1"]
+	hubB.mutex.RUnlock()
+	if exists {
+		t.Fatal("Room should be removed from the other instance")
+	}
+
+	hubA.broadcastToRoom("room-1", "", MsgCellUpdated, nil)
+	if msg := receiveMessage(t, client, 50*time.Millisecond); msg != nil {
+		t.Error("Client should no longer receive broadcasts for the deleted room")
+	}
+}
+
+func TestMemoryBrokerExpiry(t *testing.T) {
+	broker := NewMemoryBroker()
+	ctx := context.Background()
+
+	broker.Set(ctx, "lock", "1", 10*time.Millisecond)
+	if ok, _ := broker.SetNX(ctx, "lock", "2", time.Minute); ok {
+		t.Error("SetNX should fail while the key exists")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if exists, _ := broker.Exists(ctx, "lock"); exists {
+		t.Error("Key should have expired")
+	}
+	if ok, _ := broker.SetNX(ctx, "lock", "2", time.Minute); !ok {
+		t.Error("SetNX should succeed after expiry")
+	}
+
+	// Expire on a missing key is a no-op, as in Redis
+	broker.Expire(ctx, "missing", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	broker.HSet(ctx, "missing", "field", "value")
+	if values, _ := broker.HGetAll(ctx, "missing"); values["field"] != "value" {
+		t.Error("Expire on a missing key should not affect a key created later")
+	}
+}
+
+func TestRunEndsBroadcastSubscription(t *testing.T) {
+	broker := NewMemoryBroker()
+	hub := NewHubWithBroker(nil, broker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	// The subscription is dropped by the broker once its context is cancelled
+	deadline := time.Now().Add(time.Second)
+	for {
+		broker.mutex.Lock()
+		subscribers := len(broker.subs[broadcastChannel])
+		broker.mutex.Unlock()
+		if subscribers == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Run should end the broadcast subscription, %d still open", subscribers)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,72 @@
+package realtime
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cursor moves are the most frequent messages by far, so they aren't sent as
+// they come in. Each connection's latest move is kept, and once per
+// cursorTick the moves since the last tick are stored and shown to the room.
+// A player dragging across the grid costs the room one message per tick.
+
+const cursorTick = 50 * time.Millisecond
+
+// cursorMove is a cursor position a client sent
+type cursorMove struct {
+	client *Client
+	roomID string
+	x, y   int
+}
+
+// cursorBatch holds the latest cursor move of each connection since the last tick
+type cursorBatch struct {
+	mutex sync.Mutex
+	moves map[string]cursorMove // connectionID -> latest move
+}
+
+func (b *cursorBatch) add(move cursorMove) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.moves == nil {
+		b.moves = make(map[string]cursorMove)
+	}
+	b.moves[move.client.ConnectionID] = move
+}
+
+// drop forgets a connection's waiting move
+func (b *cursorBatch) drop(connectionID string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.moves, connectionID)
+}
+
+// take returns the waiting moves and starts a new batch
+func (b *cursorBatch) take() []cursorMove {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	moves := make([]cursorMove, 0, len(b.moves))
+	for _, move := range b.moves {
+		moves = append(moves, move)
+	}
+	b.moves = nil
+	return moves
+}
+
+// runCursorFlusher sends out the waiting cursor moves every cursorTick until ctx is cancelled
+func (h *Hub) runCursorFlusher(ctx context.Context) {
+	ticker := time.NewTicker(cursorTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, move := range h.cursors.take() {
+				h.moveCursor(move)
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package realtime
+
+import "testing"
+
+func TestCursorBatch(t *testing.T) {
+	var batch cursorBatch
+	alice := newTestClient("conn-1", "user-1")
+	bob := newTestClient("conn-2", "user-2")
+
+	batch.add(cursorMove{client: alice, roomID: "room-1", x: 0, y: 0})
+	batch.add(cursorMove{client: alice, roomID: "room-1", x: 1, y: 0})
+	batch.add(cursorMove{client: alice, roomID: "room-1", x: 2, y: 0})
+	batch.add(cursorMove{client: bob, roomID: "room-1", x: 5, y: 5})
+
+	moves := batch.take()
+	if len(moves) != 2 {
+		t.Fatalf("Got %d moves, want one per connection", len(moves))
+	}
+	for _, move := range moves {
+		if move.client == alice && move.x != 2 {
+			t.Errorf("Alice's move = (%d, %d), want only the latest", move.x, move.y)
+		}
+	}
+	if moves := batch.take(); len(moves) != 0 {
+		t.Errorf("Moves should only be sent once, got %d again", len(moves))
+	}
+
+	batch.add(cursorMove{client: alice, roomID: "room-1", x: 3, y: 3})
+	batch.drop(alice.ConnectionID)
+	if moves := batch.take(); len(moves) != 0 {
+		t.Errorf("A dropped connection's move shouldn't be sent, got %+v", moves)
+	}
+}
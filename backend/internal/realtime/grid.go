@@ -0,0 +1,620 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+// Grids being played are kept in memory and are authoritative for edits: the
+// shared grid of a collaborative or relay room, each player's own grid in race
+// mode and each team's grid in team mode (see teams.go). The database copy is
+// written behind them: edits only mark a grid dirty, and dirty grids are
+// flushed in one batch every gridFlushInterval, when a game ends, when the last
+// client on this instance leaves the room and when the server shuts down.
+//
+// Edits to a shared grid are merged cell by cell. Every write to a cell gets a
+// version from a per-cell counter shared by all instances, and a cell only
+// takes a write with a higher version than the one it holds. Simultaneous
+// edits to different cells therefore never overwrite each other, and when two
+// players type into the same cell every instance settles on the write that got
+// the later version. The cell_updated message for a write carries the whole
+// cell as the write left it (entry, pencil flag and candidates), so instances
+// that merge it end up with the same cell.
+
+// gridFlushInterval is how often dirty grids are written to the database
+const gridFlushInterval = time.Second
+
+// gridStore persists grid states; *db.Database implements it
+type gridStore interface {
+	UpdateGridStates(gridStates []*models.GridState) error
+}
+
+// sharedGrid is the in-memory copy of a grid
+type sharedGrid struct {
+	mutex      sync.Mutex
+	state      *models.GridState
+	dirtySince time.Time // zero when the database copy is up to date
+}
+
+// cellEdit is a versioned write to one cell
+type cellEdit struct {
+	Value      *string
+	IsPencil   bool
+	Candidates []string
+	EditedBy   string
+	Version    int64
+	IsRevealed bool
+	Part       cellPart
+	Expect     int64 // when set, the edit only applies while the cell holds this version
+}
+
+// cellPart is the part of a cell an edit writes
+type cellPart int
+
+const (
+	cellEntry      cellPart = iota // the value and pencil flag; revealing also drops the candidates
+	cellCandidates                 // only the candidate letters
+	cellWhole                      // everything, as another instance's edit left the cell
+)
+
+func roomCellVersionsKey(roomID string) string { return "room:" + roomID + ":cell-versions" }
+
+// gridKey identifies a grid in the hub: the room's shared grid has an empty
+// userID and team grids use teamGridOwner
+func gridKey(roomID, userID string) string { return roomID + "/" + userID }
+
+// markDirtyLocked records that the grid has changes not yet in the database
+func (g *sharedGrid) markDirtyLocked(now time.Time) {
+	g.state.LastUpdated = now
+	if g.dirtySince.IsZero() {
+		g.dirtySince = now
+	}
+}
+
+// applyEdit merges an edit into the cell at (x, y). It returns the cell's
+// previous answer (see cellAnswer) and whether the edit won.
+func (g *sharedGrid) applyEdit(x, y int, edit cellEdit) (prev *string, applied bool) {
+	before, _, applied := g.mergeEdit(x, y, edit)
+	return cellAnswer(before), applied
+}
+
+// mergeEdit is applyEdit returning the whole cell before and after the edit
+func (g *sharedGrid) mergeEdit(x, y int, edit cellEdit) (before, after models.Cell, applied bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if y < 0 || y >= len(g.state.Cells) || x < 0 || x >= len(g.state.Cells[y]) {
+		return models.Cell{}, models.Cell{}, false
+	}
+	cell := &g.state.Cells[y][x]
+	before = *cell
+	if edit.Version <= cell.Version || (edit.Expect != 0 && edit.Expect != cell.Version) {
+		return before, before, false
+	}
+
+	applyToCell(cell, edit)
+	cell.Version = edit.Version
+	g.markDirtyLocked(time.Now())
+	return before, *cell, true
+}
+
+// applyToCell writes the part of the cell an edit covers, leaving its version alone
+func applyToCell(cell *models.Cell, edit cellEdit) {
+	if edit.Part != cellEntry {
+		// Candidate slices are replaced, never changed in place, so snapshots can share them
+		cell.Candidates = edit.Candidates
+	}
+	if edit.Part == cellCandidates {
+		return
+	}
+
+	cell.Value = edit.Value
+	cell.IsPencil = edit.IsPencil && edit.Value != nil
+	cell.IsCorrect = nil
+	if edit.IsRevealed {
+		cell.IsRevealed = true
+		cell.IsPencil = false
+		cell.Candidates = nil
+	}
+	if edit.EditedBy != "" {
+		editedBy := edit.EditedBy
+		cell.LastEditedBy = &editedBy
+	}
+}
+
+// cellValue returns the cell's entry, "" when it is empty
+func cellValue(cell models.Cell) string {
+	if cell.Value == nil {
+		return ""
+	}
+	return *cell.Value
+}
+
+// cellAnswer returns the value that counts as the cell's answer: nil when the
+// cell is empty or only pencilled in
+func cellAnswer(cell models.Cell) *string {
+	if cell.IsPencil {
+		return nil
+	}
+	return cell.Value
+}
+
+// cellVersion returns the version held by the cell at (x, y)
+func (g *sharedGrid) cellVersion(x, y int) int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if y < 0 || y >= len(g.state.Cells) || x < 0 || x >= len(g.state.Cells[y]) {
+		return 0
+	}
+	return g.state.Cells[y][x].Version
+}
+
+// inBounds reports whether (x, y) is a cell of the grid
+func (g *sharedGrid) inBounds(x, y int) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return y >= 0 && y < len(g.state.Cells) && x >= 0 && x < len(g.state.Cells[y])
+}
+
+// update runs fn with exclusive access to the grid state and marks the grid dirty
+func (g *sharedGrid) update(fn func(state *models.GridState)) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	fn(g.state)
+	g.markDirtyLocked(time.Now())
+}
+
+// snapshot returns a deep copy of the grid state
+func (g *sharedGrid) snapshot() *models.GridState {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.snapshotLocked()
+}
+
+func (g *sharedGrid) snapshotLocked() *models.GridState {
+	copied := *g.state
+	copied.Cells = make([][]models.Cell, len(g.state.Cells))
+	for y, row := range g.state.Cells {
+		copied.Cells[y] = append([]models.Cell(nil), row...)
+	}
+	copied.CompletedClues = append([]string(nil), g.state.CompletedClues...)
+	if g.state.ClueNotes != nil {
+		copied.ClueNotes = make(map[string]models.ClueNote, len(g.state.ClueNotes))
+		for clueID, note := range g.state.ClueNotes {
+			copied.ClueNotes[clueID] = note
+		}
+	}
+	return &copied
+}
+
+// takeDirty returns a snapshot of the grid and how long it has been dirty, and
+// marks it clean. ok is false if the grid has no unsaved changes.
+func (g *sharedGrid) takeDirty(now time.Time) (state *models.GridState, lag time.Duration, ok bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.dirtySince.IsZero() {
+		return nil, 0, false
+	}
+	lag = now.Sub(g.dirtySince)
+	g.dirtySince = time.Time{}
+	return g.snapshotLocked(), lag, true
+}
+
+// restoreDirty marks the grid dirty again after a failed flush
+func (g *sharedGrid) restoreDirty(since time.Time) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.dirtySince.IsZero() || since.Before(g.dirtySince) {
+		g.dirtySince = since
+	}
+}
+
+// loadGrid returns a grid, loading it from the database on first use
+func (h *Hub) loadGrid(roomID, userID string) *sharedGrid {
+	h.gridsMutex.Lock()
+	defer h.gridsMutex.Unlock()
+
+	key := gridKey(roomID, userID)
+	if grid, ok := h.grids[key]; ok {
+		return grid
+	}
+	if h.db == nil {
+		return nil
+	}
+	gridState, err := h.db.GetPlayerGridState(roomID, userID)
+	if err != nil || gridState == nil {
+		return nil
+	}
+	grid := &sharedGrid{state: gridState}
+	h.grids[key] = grid
+	return grid
+}
+
+// roomGrid returns the room's shared grid
+func (h *Hub) roomGrid(roomID string) *sharedGrid {
+	return h.loadGrid(roomID, "")
+}
+
+// playerGrid returns a player's own grid in race mode, creating it if needed
+func (h *Hub) playerGrid(roomID, userID string, puzzle *models.Puzzle) *sharedGrid {
+	if grid := h.loadGrid(roomID, userID); grid != nil {
+		return grid
+	}
+	if puzzle == nil {
+		return nil
+	}
+
+	gridState := newGridState(roomID, userID, puzzle)
+	if h.db != nil {
+		if err := h.db.CreateGridState(gridState); err != nil {
+			log.Printf("playerGrid: failed to create grid for %s in room %s: %v", userID, roomID, err)
+			return nil
+		}
+	}
+
+	h.gridsMutex.Lock()
+	defer h.gridsMutex.Unlock()
+	key := gridKey(roomID, userID)
+	if grid, ok := h.grids[key]; ok {
+		return grid
+	}
+	grid := &sharedGrid{state: gridState}
+	h.grids[key] = grid
+	return grid
+}
+
+// playerGridState returns a snapshot of a player's race grid. Grids of players
+// connected to other instances are read from the database, so they can lag by
+// up to gridFlushInterval.
+func (h *Hub) playerGridState(roomID, userID string) *models.GridState {
+	if grid := h.loadGrid(roomID, userID); grid != nil {
+		return grid.snapshot()
+	}
+	return nil
+}
+
+// newGridState returns an empty grid for a puzzle
+func newGridState(roomID, userID string, puzzle *models.Puzzle) *models.GridState {
+	gridState := &models.GridState{
+		RoomID:         roomID,
+		UserID:         userID,
+		Cells:          make([][]models.Cell, len(puzzle.Grid)),
+		CompletedClues: []string{},
+		LastUpdated:    time.Now(),
+	}
+	for y := range puzzle.Grid {
+		gridState.Cells[y] = make([]models.Cell, len(puzzle.Grid[y]))
+	}
+	return gridState
+}
+
+// loadedRoomGrid returns the room's shared grid if this instance has it in memory
+func (h *Hub) loadedRoomGrid(roomID string) *sharedGrid {
+	return h.loadedGrid(roomID, "")
+}
+
+// loadedGrid returns a grid if this instance has it in memory
+func (h *Hub) loadedGrid(roomID, userID string) *sharedGrid {
+	h.gridsMutex.Lock()
+	defer h.gridsMutex.Unlock()
+	return h.grids[gridKey(roomID, userID)]
+}
+
+// setRoomGrid makes gridState the room's in-memory shared grid
+func (h *Hub) setRoomGrid(roomID string, gridState *models.GridState) *sharedGrid {
+	return h.setGrid(roomID, "", gridState)
+}
+
+// setGrid makes gridState an in-memory grid
+func (h *Hub) setGrid(roomID, userID string, gridState *models.GridState) *sharedGrid {
+	grid := &sharedGrid{state: gridState}
+	h.gridsMutex.Lock()
+	h.grids[gridKey(roomID, userID)] = grid
+	h.gridsMutex.Unlock()
+	return grid
+}
+
+// releaseRoomGrids writes the room's grids to the database and drops them from memory
+func (h *Hub) releaseRoomGrids(roomID string) {
+	h.flushRoomGrids(roomID)
+	h.dropRoomGrids(roomID)
+}
+
+// dropRoomGrids forgets the room's in-memory grids without saving them
+func (h *Hub) dropRoomGrids(roomID string) {
+	h.gridsMutex.Lock()
+	defer h.gridsMutex.Unlock()
+
+	prefix := gridKey(roomID, "")
+	for key := range h.grids {
+		if strings.HasPrefix(key, prefix) {
+			delete(h.grids, key)
+		}
+	}
+}
+
+// roomGridState returns a snapshot of the room's shared grid
+func (h *Hub) roomGridState(roomID string) *models.GridState {
+	if grid := h.roomGrid(roomID); grid != nil {
+		return grid.snapshot()
+	}
+	return nil
+}
+
+// nextCellVersion allocates a version for a write to the cell at (x, y). The
+// counter is shared by all instances; if it fell behind the version the grid
+// holds (for example after the shared state expired) it is moved past it.
+func (h *Hub) nextCellVersion(roomID string, x, y int, current int64) int64 {
+	return h.nextGridVersion(roomID, fmt.Sprintf("%d,%d", x, y), current)
+}
+
+// nextGridVersion allocates the next version of one field of the room's
+// shared version counters (a cell or a clue note)
+func (h *Hub) nextGridVersion(roomID, field string, current int64) int64 {
+	ctx := context.Background()
+	version, err := h.broker.HIncrBy(ctx, roomCellVersionsKey(roomID), field, 1)
+	if err != nil {
+		log.Printf("nextGridVersion: %v", err)
+		return current + 1
+	}
+	if version <= current {
+		version, err = h.broker.HIncrBy(ctx, roomCellVersionsKey(roomID), field, current-version+1)
+		if err != nil {
+			return current + 1
+		}
+	}
+	return version
+}
+
+// editCell writes a value to a cell of the room's shared grid. It returns the
+// version the write got, the cell's previous answer and whether the write won.
+func (h *Hub) editCell(grid *sharedGrid, roomID string, x, y int, value *string, editedBy string, revealed bool) (version int64, prev *string, applied bool) {
+	version, before, _, applied := h.writeCell(grid, roomID, x, y, cellEdit{
+		Value:      value,
+		EditedBy:   editedBy,
+		IsRevealed: revealed,
+	})
+	return version, cellAnswer(before), applied
+}
+
+// writeCell is editCell for any edit, returning the whole cell before and after it
+func (h *Hub) writeCell(grid *sharedGrid, roomID string, x, y int, edit cellEdit) (version int64, before, after models.Cell, applied bool) {
+	if !grid.inBounds(x, y) {
+		return 0, models.Cell{}, models.Cell{}, false
+	}
+	edit.Version = h.nextCellVersion(roomID, x, y, grid.cellVersion(x, y))
+	before, after, applied = grid.mergeEdit(x, y, edit)
+	return edit.Version, before, after, applied
+}
+
+// cellUpdated describes a cell as an edit left it, for the cell_updated message
+func cellUpdated(x, y int, cell models.Cell) CellUpdatedPayload {
+	return CellUpdatedPayload{
+		X:          x,
+		Y:          y,
+		Value:      cellValue(cell),
+		IsPencil:   cell.IsPencil,
+		Candidates: cell.Candidates,
+		Version:    cell.Version,
+	}
+}
+
+// raceCellUpdated is cellUpdated for a player's race grid. It leaves out the
+// version: other instances merge versioned updates into their shared grids.
+func raceCellUpdated(x, y int, cell models.Cell) CellUpdatedPayload {
+	payload := cellUpdated(x, y, cell)
+	payload.Version = 0
+	return payload
+}
+
+// mergeRemoteCellUpdate applies a cell edit broadcast by another instance to
+// this instance's copy of the grid
+func (h *Hub) mergeRemoteCellUpdate(roomID string, msg *Message) {
+	var p CellUpdatedPayload
+	if err := json.Unmarshal(msg.Payload, &p); err != nil || p.Version == 0 {
+		return
+	}
+
+	owner := ""
+	if p.TeamID != "" {
+		owner = teamGridOwner(p.TeamID)
+	}
+	grid := h.loadedGrid(roomID, owner)
+	if grid == nil {
+		return
+	}
+
+	var value *string
+	if p.Value != "" {
+		value = &p.Value
+	}
+	editedBy := p.PlayerID
+	if p.IsRevealed {
+		editedBy = ""
+	}
+	grid.applyEdit(p.X, p.Y, cellEdit{
+		Value:      value,
+		IsPencil:   p.IsPencil,
+		Candidates: p.Candidates,
+		EditedBy:   editedBy,
+		Version:    p.Version,
+		IsRevealed: p.IsRevealed,
+		Part:       cellWhole,
+	})
+}
+
+// runGridFlusher writes dirty grids and recorded events to the database every
+// gridFlushInterval until ctx is cancelled. Run makes the final flush.
+func (h *Hub) runGridFlusher(ctx context.Context) {
+	ticker := time.NewTicker(gridFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.Flush()
+		}
+	}
+}
+
+// flushRoomGrids writes the room's dirty grids and the recorded events to the database now
+func (h *Hub) flushRoomGrids(roomID string) {
+	prefix := gridKey(roomID, "")
+	h.flushGrids(func(key string) bool { return strings.HasPrefix(key, prefix) })
+	h.flushEvents()
+}
+
+// Flush writes every dirty grid and recorded event to the database. Call it before shutting down.
+func (h *Hub) Flush() {
+	h.flushGrids(func(string) bool { return true })
+	h.flushEvents()
+}
+
+// flushGrids writes the dirty grids whose key matches to the database in one batch
+func (h *Hub) flushGrids(match func(key string) bool) {
+	if h.gridStore == nil {
+		return
+	}
+
+	h.gridsMutex.Lock()
+	var candidates []*sharedGrid
+	for key, grid := range h.grids {
+		if match(key) {
+			candidates = append(candidates, grid)
+		}
+	}
+	h.gridsMutex.Unlock()
+
+	// Flushes can start concurrently (interval, game end, shutdown); holding the
+	// lock from snapshot to write keeps an older snapshot from landing last
+	h.flushMutex.Lock()
+	defer h.flushMutex.Unlock()
+
+	now := time.Now()
+	var batch []*models.GridState
+	var flushed []*sharedGrid
+	var lags []time.Duration
+	for _, grid := range candidates {
+		if state, lag, ok := grid.takeDirty(now); ok {
+			batch = append(batch, state)
+			flushed = append(flushed, grid)
+			lags = append(lags, lag)
+		}
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	if err := h.gridStore.UpdateGridStates(batch); err != nil {
+		log.Printf("flushGrids: failed to save %d grids: %v", len(batch), err)
+		for i, grid := range flushed {
+			grid.restoreDirty(now.Add(-lags[i]))
+		}
+		h.persistStats.recordFailure()
+		return
+	}
+	h.persistStats.recordFlush(len(batch), lags, time.Since(now))
+}
+
+// persistStats tracks how far the database lags behind the in-memory grids
+type persistStats struct {
+	mutex         sync.Mutex
+	flushes       int64
+	failures      int64
+	gridsFlushed  int64
+	totalLag      time.Duration
+	lastLag       time.Duration
+	maxLag        time.Duration
+	lastFlush     time.Time
+	lastFlushTime time.Duration
+}
+
+func (s *persistStats) recordFlush(grids int, lags []time.Duration, took time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.flushes++
+	s.gridsFlushed += int64(grids)
+	s.lastFlush = time.Now()
+	s.lastFlushTime = took
+	s.lastLag = 0
+	for _, lag := range lags {
+		s.totalLag += lag
+		if lag > s.lastLag {
+			s.lastLag = lag
+		}
+		if lag > s.maxLag {
+			s.maxLag = lag
+		}
+	}
+}
+
+func (s *persistStats) recordFailure() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures++
+}
+
+// PersistenceMetrics returns grid write-behind statistics for the metrics endpoint.
+// Flush lag is how long an edit waited in memory before reaching the database.
+func (h *Hub) PersistenceMetrics() map[string]interface{} {
+	h.gridsMutex.Lock()
+	cached := len(h.grids)
+	candidates := make([]*sharedGrid, 0, cached)
+	for _, grid := range h.grids {
+		candidates = append(candidates, grid)
+	}
+	h.gridsMutex.Unlock()
+
+	now := time.Now()
+	dirty := 0
+	var oldestDirty time.Duration
+	for _, grid := range candidates {
+		grid.mutex.Lock()
+		if !grid.dirtySince.IsZero() {
+			dirty++
+			if age := now.Sub(grid.dirtySince); age > oldestDirty {
+				oldestDirty = age
+			}
+		}
+		grid.mutex.Unlock()
+	}
+
+	s := &h.persistStats
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	avgLag := time.Duration(0)
+	if s.gridsFlushed > 0 {
+		avgLag = s.totalLag / time.Duration(s.gridsFlushed)
+	}
+	var lastFlush interface{}
+	if !s.lastFlush.IsZero() {
+		lastFlush = s.lastFlush.Unix()
+	}
+
+	return map[string]interface{}{
+		"cached_grids":           cached,
+		"dirty_grids":            dirty,
+		"oldest_dirty_ms":        oldestDirty.Milliseconds(),
+		"flushes":                s.flushes,
+		"flush_failures":         s.failures,
+		"grids_flushed":          s.gridsFlushed,
+		"avg_flush_lag_ms":       avgLag.Milliseconds(),
+		"last_flush_lag_ms":      s.lastLag.Milliseconds(),
+		"max_flush_lag_ms":       s.maxLag.Milliseconds(),
+		"last_flush_duration_ms": s.lastFlushTime.Milliseconds(),
+		"last_flush_at":          lastFlush,
+	}
+}
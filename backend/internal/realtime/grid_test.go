@@ -0,0 +1,314 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+func newTestGridState(roomID string, width, height int) *models.GridState {
+	gridState := &models.GridState{
+		RoomID:         roomID,
+		Cells:          make([][]models.Cell, height),
+		CompletedClues: []string{},
+		LastUpdated:    time.Now(),
+	}
+	for y := range gridState.Cells {
+		gridState.Cells[y] = make([]models.Cell, width)
+	}
+	return gridState
+}
+
+func TestConcurrentCellEditsAreNotLost(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	grid := hub.setRoomGrid("room-1", newTestGridState("room-1", 15, 15))
+
+	const writers = 8
+	const editsPerWriter = 25 // 200 cells, leaving (14, 14) for the contested writes
+
+	type contested struct {
+		version int64
+		value   string
+	}
+	var mu sync.Mutex
+	var contestedWrites []contested
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			userID := fmt.Sprintf("user-%d", w)
+			for i := 0; i < editsPerWriter; i++ {
+				// Every writer owns its own cells...
+				cell := w*editsPerWriter + i
+				value := fmt.Sprintf("%c", 'A'+w%26)
+				if _, _, applied := hub.editCell(grid, "room-1", cell%15, cell/15, &value, userID, false); !applied {
+					t.Errorf("Uncontested edit to (%d, %d) was rejected", cell%15, cell/15)
+				}
+
+				// ...and all of them fight over the last cell
+				contestedValue := fmt.Sprintf("%s-%d", userID, i)
+				version, _, _ := hub.editCell(grid, "room-1", 14, 14, &contestedValue, userID, false)
+				mu.Lock()
+				contestedWrites = append(contestedWrites, contested{version, contestedValue})
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	state := grid.snapshot()
+	for w := 0; w < writers; w++ {
+		want := fmt.Sprintf("%c", 'A'+w%26)
+		for i := 0; i < editsPerWriter; i++ {
+			cell := w*editsPerWriter + i
+			got := state.Cells[cell/15][cell%15].Value
+			if got == nil || *got != want {
+				t.Fatalf("Cell (%d, %d) = %v, want %q: update lost", cell%15, cell/15, got, want)
+			}
+		}
+	}
+
+	// The contested cell holds the write with the highest version, and every
+	// write to it got a distinct version
+	seen := make(map[int64]bool)
+	var latest contested
+	for _, write := range contestedWrites {
+		if seen[write.version] {
+			t.Fatalf("Version %d was allocated twice", write.version)
+		}
+		seen[write.version] = true
+		if write.version > latest.version {
+			latest = write
+		}
+	}
+	final := state.Cells[14][14]
+	if final.Value == nil || *final.Value != latest.value || final.Version != latest.version {
+		t.Errorf("Contested cell = %v (v%d), want %q (v%d)", final.Value, final.Version, latest.value, latest.version)
+	}
+}
+
+func TestStaleCellEditIsRejected(t *testing.T) {
+	grid := &sharedGrid{state: newTestGridState("room-1", 3, 3)}
+
+	newer := "B"
+	if _, applied := grid.applyEdit(1, 1, cellEdit{Value: &newer, Version: 2}); !applied {
+		t.Fatal("Edit with a newer version should apply")
+	}
+	older := "A"
+	prev, applied := grid.applyEdit(1, 1, cellEdit{Value: &older, Version: 1})
+	if applied {
+		t.Error("Edit with an older version should be rejected")
+	}
+	if prev == nil || *prev != "B" {
+		t.Errorf("Rejected edit should report the current value, got %v", prev)
+	}
+	if _, applied := grid.applyEdit(5, 5, cellEdit{Value: &newer, Version: 3}); applied {
+		t.Error("Edit outside the grid should be rejected")
+	}
+}
+
+func TestCellVersionCounterCatchesUp(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	gridState := newTestGridState("room-1", 3, 3)
+	gridState.Cells[0][0].Version = 7 // loaded from the database after the shared counter expired
+	grid := hub.setRoomGrid("room-1", gridState)
+
+	value := "X"
+	version, _, applied := hub.editCell(grid, "room-1", 0, 0, &value, "user-1", false)
+	if !applied || version != 8 {
+		t.Errorf("editCell = (v%d, %v), want (v8, true)", version, applied)
+	}
+}
+
+func TestGridsConvergeAcrossInstances(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := NewHubWithBroker(nil, broker)
+	hubB := NewHubWithBroker(nil, broker)
+	runHub(t, hubA)
+	runHub(t, hubB)
+
+	gridA := hubA.setRoomGrid("room-1", newTestGridState("room-1", 5, 5))
+	gridB := hubB.setRoomGrid("room-1", newTestGridState("room-1", 5, 5))
+
+	// Both instances edit the same cells at the same time and broadcast what won locally
+	var wg sync.WaitGroup
+	for _, edit := range []struct {
+		hub   *Hub
+		grid  *sharedGrid
+		value string
+	}{{hubA, gridA, "A"}, {hubB, gridB, "B"}} {
+		wg.Add(1)
+		go func(hub *Hub, grid *sharedGrid, value string) {
+			defer wg.Done()
+			for y := 0; y < 5; y++ {
+				for x := 0; x < 5; x++ {
+					v := value
+					version, _, applied := hub.editCell(grid, "room-1", x, y, &v, "user-"+value, false)
+					if applied {
+						hub.broadcastToRoom("room-1", "", MsgCellUpdated, CellUpdatedPayload{
+							X: x, Y: y, Value: v, PlayerID: "user-" + value, Version: version,
+						})
+					}
+				}
+			}
+		}(edit.hub, edit.grid, edit.value)
+	}
+	wg.Wait()
+
+	converged := func() bool {
+		a, b := gridA.snapshot(), gridB.snapshot()
+		for y := range a.Cells {
+			for x := range a.Cells[y] {
+				if a.Cells[y][x].Version != b.Cells[y][x].Version || *a.Cells[y][x].Value != *b.Cells[y][x].Value {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	for i := 0; i < 200 && !converged(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if !converged() {
+		t.Error("Grids on both instances should converge on the same cell values")
+	}
+}
+
+type fakeGridStore struct {
+	mutex   sync.Mutex
+	batches [][]*models.GridState
+	fail    bool
+}
+
+func (s *fakeGridStore) UpdateGridStates(gridStates []*models.GridState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.fail {
+		return fmt.Errorf("database unavailable")
+	}
+	s.batches = append(s.batches, gridStates)
+	return nil
+}
+
+func TestDirtyGridsAreFlushedInOneBatch(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	store := &fakeGridStore{}
+	hub.gridStore = store
+
+	gridA := hub.setRoomGrid("room-a", newTestGridState("room-a", 3, 3))
+	gridB := hub.setRoomGrid("room-b", newTestGridState("room-b", 3, 3))
+	hub.setRoomGrid("room-c", newTestGridState("room-c", 3, 3))
+
+	// Many keystrokes, no database writes yet
+	for i := 0; i < 5; i++ {
+		value := fmt.Sprintf("%c", 'A'+i)
+		hub.editCell(gridA, "room-a", i%3, 0, &value, "user-1", false)
+		hub.editCell(gridB, "room-b", i%3, 1, &value, "user-2", false)
+	}
+	if len(store.batches) != 0 {
+		t.Fatalf("Edits should not be written through, got %d writes", len(store.batches))
+	}
+
+	hub.Flush()
+	if len(store.batches) != 1 || len(store.batches[0]) != 2 {
+		t.Fatalf("Expected one batch with the two dirty grids, got %v", store.batches)
+	}
+
+	hub.Flush()
+	if len(store.batches) != 1 {
+		t.Error("Clean grids should not be written again")
+	}
+
+	metrics := hub.PersistenceMetrics()
+	if metrics["grids_flushed"] != int64(2) || metrics["dirty_grids"] != 0 {
+		t.Errorf("Unexpected metrics: %v", metrics)
+	}
+}
+
+func TestFailedFlushIsRetried(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	store := &fakeGridStore{fail: true}
+	hub.gridStore = store
+
+	grid := hub.setRoomGrid("room-1", newTestGridState("room-1", 3, 3))
+	value := "A"
+	hub.editCell(grid, "room-1", 0, 0, &value, "user-1", false)
+
+	hub.Flush()
+	if metrics := hub.PersistenceMetrics(); metrics["dirty_grids"] != 1 || metrics["flush_failures"] != int64(1) {
+		t.Fatalf("Grid should stay dirty after a failed flush: %v", metrics)
+	}
+
+	store.fail = false
+	hub.Flush()
+	if len(store.batches) != 1 {
+		t.Fatal("Grid should be written once the database is back")
+	}
+	if got := store.batches[0][0].Cells[0][0].Value; got == nil || *got != "A" {
+		t.Errorf("Flushed cell = %v, want A", got)
+	}
+}
+
+func TestRoomGridsFlushOnRelease(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	store := &fakeGridStore{}
+	hub.gridStore = store
+
+	puzzle := &models.Puzzle{Grid: make([][]models.GridCell, 3)}
+	for y := range puzzle.Grid {
+		puzzle.Grid[y] = make([]models.GridCell, 3)
+	}
+
+	// Race mode grids are cached per player alongside the shared grid
+	shared := hub.setRoomGrid("room-1", newTestGridState("room-1", 3, 3))
+	player := hub.playerGrid("room-1", "user-1", puzzle)
+	other := hub.setRoomGrid("room-2", newTestGridState("room-2", 3, 3))
+	value := "A"
+	hub.editCell(shared, "room-1", 0, 0, &value, "user-1", false)
+	hub.editCell(other, "room-2", 0, 0, &value, "user-1", false)
+	player.update(func(state *models.GridState) { state.Cells[1][1].Value = &value })
+
+	hub.releaseRoomGrids("room-1")
+	if len(store.batches) != 1 || len(store.batches[0]) != 2 {
+		t.Fatalf("Releasing a room should write its two grids, got %v", store.batches)
+	}
+	if hub.loadedRoomGrid("room-1") != nil {
+		t.Error("Released grid should be dropped from memory")
+	}
+	if hub.loadedRoomGrid("room-2") == nil {
+		t.Error("Other rooms should keep their grids")
+	}
+}
+
+func TestRunFlushesOnShutdown(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	store := &fakeGridStore{}
+	hub.gridStore = store
+
+	grid := hub.setRoomGrid("room-1", newTestGridState("room-1", 3, 3))
+	value := "A"
+	hub.editCell(grid, "room-1", 0, 0, &value, "user-1", false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run should return once its context is cancelled")
+	}
+	if len(store.batches) != 1 {
+		t.Errorf("Run should write the dirty grid on the way out, got %d writes", len(store.batches))
+	}
+}
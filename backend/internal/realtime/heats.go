@@ -0,0 +1,33 @@
+package realtime
+
+import "github.com/crossplay/backend/internal/models"
+
+// Race rooms created for a tournament round (config.tournamentId) are its
+// heats. When a heat's race ends the hub reports the finishing order, so the
+// tournament can score the heat and draw the next round.
+
+// HeatReporter is told the finishing order of a tournament heat once its race
+// ends (see api.Handlers.HeatFinished)
+type HeatReporter interface {
+	HeatFinished(roomID string, finishOrder []string)
+}
+
+// SetHeatReporter makes the hub report finished tournament heats to r
+func (h *Hub) SetHeatReporter(r HeatReporter) {
+	h.heats = r
+}
+
+// reportHeat passes on the results of a finished game if the room is a
+// tournament heat. Drawing the next round can take a while, so it doesn't hold
+// up the room.
+func (h *Hub) reportHeat(room *models.Room, results PuzzleCompletedPayload) {
+	if h.heats == nil || room.Config.TournamentID == "" {
+		return
+	}
+
+	finishOrder := make([]string, 0, len(results.Players))
+	for _, result := range results.Players {
+		finishOrder = append(finishOrder, result.UserID)
+	}
+	go h.heats.HeatFinished(room.ID, finishOrder)
+}
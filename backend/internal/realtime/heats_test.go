@@ -0,0 +1,41 @@
+package realtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+type heatRecorder chan []string
+
+func (r heatRecorder) HeatFinished(roomID string, finishOrder []string) {
+	r <- append([]string{roomID}, finishOrder...)
+}
+
+func TestReportHeat(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	reported := make(heatRecorder, 1)
+	hub.SetHeatReporter(reported)
+
+	results := PuzzleCompletedPayload{Players: []PlayerResult{{UserID: "b"}, {UserID: "a"}}}
+	hub.reportHeat(&models.Room{ID: "room-1"}, results)
+
+	heat := &models.Room{ID: "room-2", Config: models.RoomConfig{TournamentID: "cup"}}
+	hub.reportHeat(heat, results)
+
+	select {
+	case got := <-reported:
+		if want := []string{"room-2", "b", "a"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Reported %v, want the heat's room and finishing order %v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("A finished heat should be reported")
+	}
+	select {
+	case got := <-reported:
+		t.Errorf("Only tournament rooms should be reported, got %v", got)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
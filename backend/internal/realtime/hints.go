@@ -0,0 +1,297 @@
+package realtime
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+// A hint reveals or checks the cells of a scope: a cell, a word (by a cell in
+// it or by clue) or the whole puzzle. It applies to the grid the player is
+// solving, their own in race mode, whose check results only they see.
+//
+// Hints follow the room's policy: there are none unless the room enables them,
+// and never in ranked rooms. maxReveals caps the letter and word hints each
+// player may ask for, and in race mode every letter a player has revealed adds
+// hintPenalty seconds to their time. A racer who completes the grid with a
+// penalty only finishes once the clock has run on by it, so finish order and
+// ranks follow the penalized times.
+//
+// Each player's hints are counted in the broker, so every instance enforces the
+// same limits. When a player finishes, puzzle_history gets their reveals and
+// checks as hintsUsed, and as accuracy the share of the letters of the grid
+// they finished that weren't revealed.
+
+func roomHintsKey(roomID string) string { return "room:" + roomID + ":hints" }
+
+// hintUsage is what a player has used of the room's hints
+type hintUsage struct {
+	Reveals int // letter and word hints
+	Letters int // letters those hints revealed
+	Checks  int
+}
+
+// Counters of a player's hintUsage, stored as "userID:counter"
+const (
+	hintReveals = "reveals"
+	hintLetters = "letters"
+	hintChecks  = "checks"
+)
+
+// hintsAllowed reports whether the room's players may ask for hints
+func hintsAllowed(config models.RoomConfig) bool {
+	return config.HintsEnabled && !config.Ranked
+}
+
+// hintPenalty returns the seconds a player's hints add to their race time
+func hintPenalty(config models.RoomConfig, usage hintUsage) int {
+	return usage.Letters * config.HintPenalty
+}
+
+// claimReveal uses up one of the player's reveals. ok is false if they have
+// none left.
+func (h *Hub) claimReveal(roomID, userID string, maxReveals int) (ok bool) {
+	ctx := context.Background()
+	field := userID + ":" + hintReveals
+	n, err := h.broker.HIncrBy(ctx, roomHintsKey(roomID), field, 1)
+	if err != nil {
+		log.Printf("claimReveal: %v", err)
+		return false
+	}
+	if maxReveals > 0 && n > int64(maxReveals) {
+		h.broker.HIncrBy(ctx, roomHintsKey(roomID), field, -1)
+		return false
+	}
+	return true
+}
+
+// countHint adds n to one of the player's hint counters
+func (h *Hub) countHint(roomID, userID, counter string, n int) {
+	if n == 0 {
+		return
+	}
+	if _, err := h.broker.HIncrBy(context.Background(), roomHintsKey(roomID), userID+":"+counter, int64(n)); err != nil {
+		log.Printf("countHint: %v", err)
+	}
+}
+
+// roomHintUsage returns the hint usage of every player who has used a hint
+func (h *Hub) roomHintUsage(roomID string) map[string]hintUsage {
+	usage := make(map[string]hintUsage)
+	values, err := h.broker.HGetAll(context.Background(), roomHintsKey(roomID))
+	if err != nil {
+		return usage
+	}
+	for field, value := range values {
+		userID, counter, found := strings.Cut(field, ":")
+		n, err := strconv.Atoi(value)
+		if !found || err != nil {
+			continue
+		}
+		u := usage[userID]
+		switch counter {
+		case hintReveals:
+			u.Reveals = n
+		case hintLetters:
+			u.Letters = n
+		case hintChecks:
+			u.Checks = n
+		}
+		usage[userID] = u
+	}
+	return usage
+}
+
+// revealAccuracy returns the percentage of the puzzle's letters that weren't
+// revealed in the grid
+func revealAccuracy(puzzle *models.Puzzle, gridState *models.GridState) float64 {
+	if puzzle == nil || gridState == nil {
+		return 100.0
+	}
+	revealed, total := 0, 0
+	for y := range puzzle.Grid {
+		for x := range puzzle.Grid[y] {
+			if puzzle.Grid[y][x].Letter == nil {
+				continue
+			}
+			total++
+			if y < len(gridState.Cells) && x < len(gridState.Cells[y]) && gridState.Cells[y][x].IsRevealed {
+				revealed++
+			}
+		}
+	}
+	if total == 0 {
+		return 100.0
+	}
+	return float64(total-revealed) / float64(total) * 100
+}
+
+// Hint actions, and the scopes they apply to
+const (
+	hintReveal = "reveal"
+	hintCheck  = "check"
+
+	scopeCell   = "cell"
+	scopeWord   = "word" // the word through (x, y), in direction if given
+	scopeClue   = "clue" // the word of clueId
+	scopePuzzle = "puzzle"
+)
+
+// hintAction resolves a hint request to an action and scope. The original
+// hint types stand for one: "letter" reveals a cell, "word" reveals a word and
+// "check" without a scope checks the whole puzzle.
+func hintAction(p RequestHintPayload) (action, scope string) {
+	switch p.Type {
+	case "letter":
+		return hintReveal, scopeCell
+	case "word":
+		return hintReveal, scopeWord
+	case hintCheck:
+		if p.Scope == "" {
+			return hintCheck, scopePuzzle
+		}
+		return hintCheck, p.Scope
+	case hintReveal:
+		if p.Scope == "" {
+			return hintReveal, scopeCell
+		}
+		return hintReveal, p.Scope
+	}
+	return "", ""
+}
+
+// hintCells returns the letter cells a hint's scope covers, nil if it covers
+// none. A word through (x, y) is looked for across first unless a direction
+// is given.
+func hintCells(puzzle *models.Puzzle, scope string, p RequestHintPayload) [][2]int {
+	switch scope {
+	case scopeCell:
+		if hasLetter(puzzle, p.X, p.Y) {
+			return [][2]int{{p.X, p.Y}}
+		}
+
+	case scopeWord:
+		if !hasLetter(puzzle, p.X, p.Y) {
+			return nil
+		}
+		if p.Direction != "down" {
+			for i, clue := range puzzle.CluesAcross {
+				if clue.PositionY == p.Y && p.X >= clue.PositionX && p.X < clue.PositionX+clue.Length {
+					return wordCells(puzzle, &puzzle.CluesAcross[i], false)
+				}
+			}
+		}
+		if p.Direction != "across" {
+			for i, clue := range puzzle.CluesDown {
+				if clue.PositionX == p.X && p.Y >= clue.PositionY && p.Y < clue.PositionY+clue.Length {
+					return wordCells(puzzle, &puzzle.CluesDown[i], true)
+				}
+			}
+		}
+
+	case scopeClue:
+		if clue, down := clueByID(puzzle, p.ClueID); clue != nil {
+			return wordCells(puzzle, clue, down)
+		}
+
+	case scopePuzzle:
+		var cells [][2]int
+		for y := range puzzle.Grid {
+			for x := range puzzle.Grid[y] {
+				if puzzle.Grid[y][x].Letter != nil {
+					cells = append(cells, [2]int{x, y})
+				}
+			}
+		}
+		return cells
+	}
+	return nil
+}
+
+// wordCells returns the letter cells of a clue's word
+func wordCells(puzzle *models.Puzzle, clue *models.Clue, down bool) [][2]int {
+	var cells [][2]int
+	for i := 0; i < clue.Length; i++ {
+		x, y := clue.PositionX+i, clue.PositionY
+		if down {
+			x, y = clue.PositionX, clue.PositionY+i
+		}
+		if hasLetter(puzzle, x, y) {
+			cells = append(cells, [2]int{x, y})
+		}
+	}
+	return cells
+}
+
+// hasLetter reports whether (x, y) is a letter cell of the puzzle
+func hasLetter(puzzle *models.Puzzle, x, y int) bool {
+	return y >= 0 && y < len(puzzle.Grid) && x >= 0 && x < len(puzzle.Grid[y]) && puzzle.Grid[y][x].Letter != nil
+}
+
+// penaltyField marks in the room state when a racer completed their grid
+func penaltyField(userID string) string { return "completed:" + userID }
+
+// servePenalty holds back a racer who completed their grid until they have
+// served their hint penalty. It returns their solve time once they have, and
+// ok false while they are still serving it. The instance that first sees the
+// grid completed checks again when the penalty is up.
+func (h *Hub) servePenalty(roomID, userID string, penalty int) (solveTime int, ok bool) {
+	elapsed := h.solveSeconds(roomID)
+	if penalty <= 0 {
+		return elapsed, true
+	}
+
+	ctx := context.Background()
+	completedAt := elapsed
+	first, err := h.broker.HSetNX(ctx, roomStateKey(roomID), penaltyField(userID), strconv.Itoa(elapsed))
+	if err != nil {
+		return elapsed + penalty, true
+	}
+	if !first {
+		state, _ := h.broker.HGetAll(ctx, roomStateKey(roomID))
+		if n, err := strconv.Atoi(state[penaltyField(userID)]); err == nil {
+			completedAt = n
+		}
+	}
+
+	if remaining := completedAt + penalty - elapsed; remaining > 0 {
+		if first {
+			h.schedulePenaltyCheck(roomID, userID, time.Duration(remaining)*time.Second)
+		}
+		return 0, false
+	}
+	return completedAt + penalty, true
+}
+
+// abandonPenalty forgets that a racer completed their grid, after they broke
+// an entry while serving their penalty
+func (h *Hub) abandonPenalty(roomID, userID string) {
+	h.broker.HDel(context.Background(), roomStateKey(roomID), penaltyField(userID))
+}
+
+// schedulePenaltyCheck checks the race again after a racer's penalty should be
+// served, and keeps checking every second while it isn't (the game was paused)
+func (h *Hub) schedulePenaltyCheck(roomID, userID string, wait time.Duration) {
+	time.AfterFunc(wait, func() {
+		room, _ := h.cachedRoomAndPuzzle(roomID)
+		if room == nil || room.State != models.RoomStateActive {
+			return
+		}
+		h.checkRaceProgress(roomID, userID)
+
+		state, err := h.broker.HGetAll(context.Background(), roomStateKey(roomID))
+		if err != nil || state[penaltyField(userID)] == "" {
+			return
+		}
+		for _, finished := range h.finishOrder(roomID) {
+			if finished == userID {
+				return
+			}
+		}
+		h.schedulePenaltyCheck(roomID, userID, time.Second)
+	})
+}
@@ -0,0 +1,164 @@
+package realtime
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+func TestHintsAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		config models.RoomConfig
+		want   bool
+	}{
+		{models.RoomConfig{}, false},
+		{models.RoomConfig{HintsEnabled: true}, true},
+		{models.RoomConfig{HintsEnabled: true, Ranked: true}, false},
+	} {
+		if got := hintsAllowed(tc.config); got != tc.want {
+			t.Errorf("hintsAllowed(%+v) = %v, want %v", tc.config, got, tc.want)
+		}
+	}
+}
+
+func TestClaimRevealRespectsLimit(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+
+	for i := 0; i < 2; i++ {
+		if !hub.claimReveal("room-1", "user-1", 2) {
+			t.Fatalf("Reveal %d of 2 should be allowed", i+1)
+		}
+	}
+	if hub.claimReveal("room-1", "user-1", 2) {
+		t.Error("A third reveal should be refused")
+	}
+	if !hub.claimReveal("room-1", "user-2", 2) {
+		t.Error("Another player's reveals shouldn't count against the limit")
+	}
+	if got := hub.roomHintUsage("room-1")["user-1"].Reveals; got != 2 {
+		t.Errorf("Refused reveal was counted: %d reveals, want 2", got)
+	}
+
+	for i := 0; i < 5; i++ {
+		if !hub.claimReveal("room-2", "user-1", 0) {
+			t.Fatal("Reveals should be unlimited when maxReveals is 0")
+		}
+	}
+}
+
+func TestRoomHintUsage(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	hub.claimReveal("room-1", "user-1", 0)
+	hub.countHint("room-1", "user-1", hintLetters, 4)
+	hub.countHint("room-1", "user-1", hintChecks, 1)
+	hub.countHint("room-1", "user-2", hintChecks, 2)
+
+	want := map[string]hintUsage{
+		"user-1": {Reveals: 1, Letters: 4, Checks: 1},
+		"user-2": {Checks: 2},
+	}
+	if got := hub.roomHintUsage("room-1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("roomHintUsage = %+v, want %+v", got, want)
+	}
+
+	config := models.RoomConfig{HintPenalty: 15}
+	if got := hintPenalty(config, want["user-1"]); got != 60 {
+		t.Errorf("hintPenalty = %d, want 60 for 4 letters at 15s", got)
+	}
+}
+
+func TestRevealAccuracy(t *testing.T) {
+	a := "A"
+	puzzle := &models.Puzzle{Grid: [][]models.GridCell{{{Letter: &a}, {Letter: &a}}, {{Letter: &a}, {}}}}
+	gridState := newTestGridState("room-1", 2, 2)
+	if got := revealAccuracy(puzzle, gridState); got != 100 {
+		t.Errorf("revealAccuracy = %v with nothing revealed, want 100", got)
+	}
+
+	gridState.Cells[0][1].IsRevealed = true
+	if got := revealAccuracy(puzzle, gridState); got < 66.6 || got > 66.7 {
+		t.Errorf("revealAccuracy = %v with 1 of 3 letters revealed, want 66.7", got)
+	}
+}
+
+func TestHintAction(t *testing.T) {
+	for _, tc := range []struct {
+		payload       RequestHintPayload
+		action, scope string
+	}{
+		{RequestHintPayload{Type: "letter"}, hintReveal, scopeCell},
+		{RequestHintPayload{Type: "word"}, hintReveal, scopeWord},
+		{RequestHintPayload{Type: "check"}, hintCheck, scopePuzzle},
+		{RequestHintPayload{Type: "check", Scope: "word"}, hintCheck, scopeWord},
+		{RequestHintPayload{Type: "reveal"}, hintReveal, scopeCell},
+		{RequestHintPayload{Type: "reveal", Scope: "clue"}, hintReveal, scopeClue},
+		{RequestHintPayload{Type: "solve"}, "", ""},
+	} {
+		if action, scope := hintAction(tc.payload); action != tc.action || scope != tc.scope {
+			t.Errorf("hintAction(%+v) = (%q, %q), want (%q, %q)", tc.payload, action, scope, tc.action, tc.scope)
+		}
+	}
+}
+
+func TestHintCells(t *testing.T) {
+	a := "A"
+	// A 3x2 grid with 1-Across on the top row and 1-Down in the first column
+	puzzle := &models.Puzzle{
+		Grid:        [][]models.GridCell{{{Letter: &a}, {Letter: &a}, {Letter: &a}}, {{Letter: &a}, {}, {}}},
+		CluesAcross: []models.Clue{{Number: 1, PositionX: 0, PositionY: 0, Length: 3}},
+		CluesDown:   []models.Clue{{Number: 1, PositionX: 0, PositionY: 0, Length: 2}},
+	}
+	across := [][2]int{{0, 0}, {1, 0}, {2, 0}}
+	down := [][2]int{{0, 0}, {0, 1}}
+
+	for _, tc := range []struct {
+		scope   string
+		payload RequestHintPayload
+		want    [][2]int
+	}{
+		{scopeCell, RequestHintPayload{X: 2, Y: 0}, [][2]int{{2, 0}}},
+		{scopeCell, RequestHintPayload{X: 1, Y: 1}, nil}, // black square
+		{scopeWord, RequestHintPayload{X: 1, Y: 0}, across},
+		{scopeWord, RequestHintPayload{X: 0, Y: 0}, across},
+		{scopeWord, RequestHintPayload{X: 0, Y: 0, Direction: "down"}, down},
+		{scopeWord, RequestHintPayload{X: 0, Y: 1}, down},
+		{scopeWord, RequestHintPayload{X: 0, Y: 1, Direction: "across"}, nil},
+		{scopeWord, RequestHintPayload{X: 5, Y: 0}, nil},
+		{scopeClue, RequestHintPayload{ClueID: "down-1"}, down},
+		{scopeClue, RequestHintPayload{ClueID: "down-2"}, nil},
+		{scopePuzzle, RequestHintPayload{}, [][2]int{{0, 0}, {1, 0}, {2, 0}, {0, 1}}},
+		{"row", RequestHintPayload{}, nil},
+	} {
+		if got := hintCells(puzzle, tc.scope, tc.payload); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("hintCells(%s, %+v) = %v, want %v", tc.scope, tc.payload, got, tc.want)
+		}
+	}
+}
+
+func TestServePenalty(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	hub.setRoomStartTime("room-1", time.Now().Add(-100*time.Second))
+	// Both players completed their grids 60s into the game
+	ctx := context.Background()
+	hub.broker.HSet(ctx, roomStateKey("room-1"), penaltyField("user-1"), "60")
+	hub.broker.HSet(ctx, roomStateKey("room-1"), penaltyField("user-2"), "60")
+
+	if solveTime, ok := hub.servePenalty("room-1", "user-1", 30); !ok || solveTime != 90 {
+		t.Errorf("servePenalty = (%d, %v) for a served 30s penalty, want (90, true)", solveTime, ok)
+	}
+	if _, ok := hub.servePenalty("room-1", "user-2", 50); ok {
+		t.Error("A racer 10s short of their 50s penalty shouldn't finish yet")
+	}
+
+	hub.abandonPenalty("room-1", "user-2")
+	if state, _ := hub.broker.HGetAll(ctx, roomStateKey("room-1")); state[penaltyField("user-2")] != "" {
+		t.Error("abandonPenalty should forget when the racer completed")
+	}
+
+	if solveTime, ok := hub.servePenalty("room-1", "user-3", 0); !ok || solveTime < 99 || solveTime > 101 {
+		t.Errorf("servePenalty = (%d, %v) without a penalty, want the elapsed time", solveTime, ok)
+	}
+}
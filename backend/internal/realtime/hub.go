@@ -1,13 +1,16 @@
 package realtime
 
 import (
+	"context"
 	"encoding/json"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/crossplay/backend/internal/db"
 	"github.com/crossplay/backend/internal/models"
+	"github.com/crossplay/backend/internal/wordfilter"
 	"github.com/google/uuid"
 )
 
@@ -16,15 +19,41 @@ type MessageType string
 
 const (
 	// Client to Server
-	MsgJoinRoom     MessageType = "join_room"
-	MsgLeaveRoom    MessageType = "leave_room"
-	MsgCellUpdate   MessageType = "cell_update"
-	MsgCursorMove   MessageType = "cursor_move"
-	MsgSendMessage  MessageType = "send_message"
-	MsgRequestHint  MessageType = "request_hint"
-	MsgStartGame    MessageType = "start_game"
-	MsgReaction     MessageType = "reaction"
-	MsgPassTurn     MessageType = "pass_turn" // Relay mode: pass turn to next player
+	MsgJoinRoom      MessageType = "join_room"
+	MsgLeaveRoom     MessageType = "leave_room"
+	MsgCellUpdate    MessageType = "cell_update"
+	MsgCursorMove    MessageType = "cursor_move"
+	MsgSendMessage   MessageType = "send_message"
+	MsgRequestHint   MessageType = "request_hint"
+	MsgStartGame     MessageType = "start_game"
+	MsgReaction      MessageType = "reaction"
+	MsgPassTurn      MessageType = "pass_turn"      // Relay mode: pass turn to next player
+	MsgResume        MessageType = "resume"         // Rejoin a room after reconnecting, replaying missed messages
+	MsgPauseGame     MessageType = "pause_game"     // Host: freeze the game clock
+	MsgResumeGame    MessageType = "resume_game"    // Host: restart the game clock
+	MsgSetTeam       MessageType = "set_team"       // Team mode lobby: join a team, or (host) move a player
+	MsgSetClueNote   MessageType = "set_clue_note"  // Leave (or clear) a note on a clue of a shared grid
+	MsgUndo          MessageType = "undo"           // Take back the player's last edit
+	MsgRedo          MessageType = "redo"           // Put back the player's last undone edit
+	MsgDeleteMessage MessageType = "delete_message" // Delete one of the player's chat messages, or (host) anyone's
+	MsgTyping        MessageType = "typing"         // The player started or stopped writing a chat message
+	MsgVoteSkip      MessageType = "vote_skip"      // Relay mode: vote to skip the current player's turn
+	MsgRematch       MessageType = "rematch"        // Host: play again in the same room once the game is over
+
+	// Host moderation (see moderation.go)
+	MsgKickPlayer   MessageType = "kick_player"
+	MsgBanPlayer    MessageType = "ban_player"  // Kick and keep out of the room
+	MsgTransferHost MessageType = "transfer_host"
+	MsgLockRoom     MessageType = "lock_room"   // Stop (or allow) new players joining
+	MsgMuteChat     MessageType = "mute_chat"   // Mute one player, or everyone but the host
+
+	// Client to Server: replays of the room's recorded events (see recording.go)
+	MsgStartReplay   MessageType = "start_replay"
+	MsgReplayControl MessageType = "replay_control" // Pause, resume, stop or change the speed of a replay
+
+	// Client to Server: the lobby of public rooms (see lobby.go)
+	MsgWatchLobby   MessageType = "watch_lobby"
+	MsgUnwatchLobby MessageType = "unwatch_lobby"
 
 	// Server to Client
 	MsgRoomState        MessageType = "room_state"
@@ -41,14 +70,41 @@ const (
 	MsgPlayerFinished   MessageType = "player_finished"   // Race mode: player completed puzzle
 	MsgTurnChanged      MessageType = "turn_changed"      // Relay mode: turn passed
 	MsgRoomDeleted      MessageType = "room_deleted"      // Room was deleted (e.g., host left)
+	MsgResumed          MessageType = "resumed"           // Missed messages were replayed after a resume
+	MsgTimerTick        MessageType = "timer_tick"        // Game clock, every second in timed rooms
+	MsgTimeExpired      MessageType = "time_expired"      // Countdown ran out
+	MsgGamePaused       MessageType = "game_paused"
+	MsgGameResumed      MessageType = "game_resumed"
+	MsgReplayStarted    MessageType = "replay_started"
+	MsgReplayEvent      MessageType = "replay_event"      // One recorded event, sent at its (scaled) original time
+	MsgReplayEnded      MessageType = "replay_ended"
+	MsgTeamChanged      MessageType = "team_changed"      // Team mode: a player moved to another team
+	MsgTeamProgress     MessageType = "team_progress"     // Team mode: standings update
+	MsgTeamFinished     MessageType = "team_finished"     // Team mode: a team completed the puzzle
+	MsgPlayerKicked     MessageType = "player_kicked"     // Host removed a player (banned or not)
+	MsgHostChanged      MessageType = "host_changed"      // Host role moved to another player
+	MsgRoomSettings     MessageType = "room_settings"     // Host locked/unlocked the room or muted chat
+	MsgPlayerMuted      MessageType = "player_muted"
+	MsgLobbyRooms       MessageType = "lobby_rooms"        // Public rooms matching a watch_lobby filter
+	MsgLobbyRoom        MessageType = "lobby_room"         // Public room created or changed
+	MsgLobbyRoomRemoved MessageType = "lobby_room_removed" // Room no longer listed for the watcher
+	MsgClueNoteUpdated  MessageType = "clue_note_updated"  // A clue's note was set or cleared
+	MsgHintUsage        MessageType = "hint_usage"         // The player's hints used (and left) after a hint
+	MsgMessageDeleted   MessageType = "message_deleted"    // A chat message was deleted
+	MsgPlayerTyping     MessageType = "player_typing"      // Another player started or stopped writing a chat message
+	MsgSkipVote         MessageType = "skip_vote"          // Relay mode: a player voted to skip the current turn
+	MsgRematchStarted   MessageType = "rematch_started"    // The room is back in the lobby with a new puzzle
+	MsgSlowDown         MessageType = "slow_down"          // The connection is over its message rate limit (see inbound.go)
 )
 
 const hostDisconnectGracePeriod = 2 * time.Second
 
-// Message represents a WebSocket message
+// Message represents a WebSocket message. Seq is set on messages broadcast to a
+// room and increases by one per room message (see replay.go).
 type Message struct {
 	Type    MessageType     `json:"type"`
 	Payload json.RawMessage `json:"payload"`
+	Seq     int64           `json:"seq,omitempty"`
 }
 
 // Payload types
@@ -58,10 +114,20 @@ type JoinRoomPayload struct {
 	IsSpectator bool   `json:"isSpectator"`
 }
 
+type ResumePayload struct {
+	RoomCode string `json:"roomCode"`
+	LastSeq  int64  `json:"lastSeq"` // sequence number of the last room message received
+}
+
+// CellUpdatePayload writes a cell's entry, pencilled in or not. When
+// Candidates is present (even empty) it only replaces the cell's candidate
+// letters and leaves the entry alone.
 type CellUpdatePayload struct {
-	X     int     `json:"x"`
-	Y     int     `json:"y"`
-	Value *string `json:"value"`
+	X          int      `json:"x"`
+	Y          int      `json:"y"`
+	Value      *string  `json:"value"`
+	Pencil     bool     `json:"pencil,omitempty"`
+	Candidates []string `json:"candidates,omitempty"`
 }
 
 type CursorMovePayload struct {
@@ -71,12 +137,47 @@ type CursorMovePayload struct {
 
 type SendMessagePayload struct {
 	Text string `json:"text"`
+	Team bool   `json:"team,omitempty"` // Team mode: only send to the sender's team
+}
+
+type DeleteMessagePayload struct {
+	MessageID string `json:"messageId"`
+}
+
+type TypingPayload struct {
+	Typing bool `json:"typing"`
+	Team   bool `json:"team,omitempty"` // Team mode: writing to the team
 }
 
 type RequestHintPayload struct {
-	Type string `json:"type"` // "letter", "word", "check"
-	X    int    `json:"x"`
-	Y    int    `json:"y"`
+	Type      string `json:"type"`                // "reveal" or "check"; "letter" and "word" reveal a cell and a word
+	Scope     string `json:"scope,omitempty"`     // "cell", "word", "clue" or "puzzle"
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	Direction string `json:"direction,omitempty"` // word scope: "across" or "down"
+	ClueID    string `json:"clueId,omitempty"`    // clue scope: "across-1", "down-5"
+}
+
+// HintUsagePayload tells a player what they have used of the room's hints
+type HintUsagePayload struct {
+	Reveals     int  `json:"reveals"` // letter and word hints
+	Checks      int  `json:"checks"`
+	RevealsLeft *int `json:"revealsLeft,omitempty"` // set when the room limits reveals
+	Penalty     int  `json:"penalty,omitempty"`     // race: seconds added to the player's time
+}
+
+type SetClueNotePayload struct {
+	ClueID string `json:"clueId"` // format: "across-1" or "down-5"
+	Text   string `json:"text"`   // empty clears the note
+}
+
+type ClueNoteUpdatedPayload struct {
+	ClueID    string    `json:"clueId"`
+	Text      string    `json:"text"`
+	PlayerID  string    `json:"playerId"`
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	TeamID    string    `json:"teamId,omitempty"` // team mode: the team grid the note is on
 }
 
 type ReactionPayload struct {
@@ -84,14 +185,87 @@ type ReactionPayload struct {
 	Emoji  string `json:"emoji"`
 }
 
+type StartReplayPayload struct {
+	Speed float64 `json:"speed,omitempty"` // 1 = real time; defaults to 1
+}
+
+type ReplayControlPayload struct {
+	Action string  `json:"action"` // "pause", "resume", "stop" or "speed"
+	Speed  float64 `json:"speed,omitempty"`
+}
+
+type ModeratePlayerPayload struct {
+	UserID string `json:"userId"`
+}
+
+type LockRoomPayload struct {
+	Locked bool `json:"locked"`
+}
+
+type MuteChatPayload struct {
+	UserID string `json:"userId,omitempty"` // empty to mute everyone but the host
+	Muted  bool   `json:"muted"`
+}
+
+type WatchLobbyPayload struct {
+	Mode       models.RoomMode   `json:"mode,omitempty"`
+	Difficulty models.Difficulty `json:"difficulty,omitempty"`
+	Seats      *int              `json:"seats,omitempty"` // minimum open seats; defaults to 1
+}
+
+type SetTeamPayload struct {
+	TeamID string `json:"teamId"`
+	UserID string `json:"userId,omitempty"` // host only; defaults to the sender
+}
+
 // Response payloads
 type RoomStatePayload struct {
-	Room      *models.Room      `json:"room"`
-	Players   []models.Player   `json:"players"`
-	GridState *models.GridState `json:"gridState"`
-	Puzzle    *models.Puzzle    `json:"puzzle"`
-	Messages  []models.Message  `json:"messages"`
-	Reactions []models.Reaction `json:"reactions"`
+	Room      *models.Room                 `json:"room"`
+	Players   []models.Player              `json:"players"`
+	GridState *models.GridState            `json:"gridState"`
+	Puzzle    *models.Puzzle               `json:"puzzle"`
+	Messages  []models.Message             `json:"messages"`
+	Reactions []models.Reaction            `json:"reactions"`
+	Seq       int64                        `json:"seq"`             // last room message reflected in this snapshot
+	Teams     []models.Team                `json:"teams,omitempty"` // team mode; gridState is the player's team grid
+	Grids     map[string]*models.GridState `json:"grids,omitempty"` // spectators in race and team mode: every player's or team's grid
+}
+
+// TimerTickPayload is broadcast every second while a timed game runs
+type TimerTickPayload struct {
+	Elapsed   int  `json:"elapsed"`             // seconds played, excluding pauses
+	Remaining *int `json:"remaining,omitempty"` // countdown mode only
+	Paused    bool `json:"paused"`
+}
+
+type TimeExpiredPayload struct {
+	Elapsed int `json:"elapsed"`
+}
+
+type GamePausedPayload struct {
+	UserID  string `json:"userId"`
+	Elapsed int    `json:"elapsed"`
+}
+
+type ReplayStartedPayload struct {
+	Events     int     `json:"events"`
+	DurationMs int64   `json:"durationMs"`
+	Speed      float64 `json:"speed"`
+}
+
+type ReplayEventPayload struct {
+	models.RoomEvent
+	OffsetMs int64 `json:"offsetMs"` // time since the first recorded event
+}
+
+type ReplayEndedPayload struct {
+	Events int `json:"events"`
+}
+
+type ResumedPayload struct {
+	FromSeq  int64 `json:"fromSeq"`
+	ToSeq    int64 `json:"toSeq"`
+	Replayed int   `json:"replayed"`
 }
 
 type PlayerJoinedPayload struct {
@@ -104,13 +278,18 @@ type PlayerLeftPayload struct {
 }
 
 type CellUpdatedPayload struct {
-	X          int    `json:"x"`
-	Y          int    `json:"y"`
-	Value      string `json:"value"`
-	PlayerID   string `json:"playerId"`
-	Color      string `json:"color"`
-	IsRevealed bool   `json:"isRevealed,omitempty"`
-	IsCorrect  *bool  `json:"isCorrect,omitempty"`
+	X          int      `json:"x"`
+	Y          int      `json:"y"`
+	Value      string   `json:"value"`
+	IsPencil   bool     `json:"isPencil,omitempty"`
+	Candidates []string `json:"candidates,omitempty"`
+	PlayerID   string   `json:"playerId"`
+	Color      string   `json:"color"`
+	IsRevealed bool     `json:"isRevealed,omitempty"`
+	IsCorrect  *bool    `json:"isCorrect,omitempty"`
+	Version    int64    `json:"version,omitempty"` // per-cell version of a shared grid write
+	TeamID     string   `json:"teamId,omitempty"`  // team mode: the team grid that was written
+	Origin     string   `json:"origin,omitempty"`  // "undo" or "redo" when the write took back or put back an edit
 }
 
 type CursorMovedPayload struct {
@@ -127,12 +306,28 @@ type NewMessagePayload struct {
 	DisplayName string    `json:"displayName"`
 	Text        string    `json:"text"`
 	CreatedAt   time.Time `json:"createdAt"`
+	TeamID      string    `json:"teamId,omitempty"` // set on team chat messages
+}
+
+type MessageDeletedPayload struct {
+	MessageID string `json:"messageId"`
+	DeletedBy string `json:"deletedBy"`
+}
+
+type PlayerTypingPayload struct {
+	UserID      string `json:"userId"`
+	DisplayName string `json:"displayName"`
+	Typing      bool   `json:"typing"`
+	TeamID      string `json:"teamId,omitempty"` // set when writing to the team
 }
 
 type PuzzleCompletedPayload struct {
 	SolveTime    int              `json:"solveTime"`
 	Players      []PlayerResult   `json:"players"`
 	CompletedAt  time.Time        `json:"completedAt"`
+	TimedOut     bool             `json:"timedOut,omitempty"` // countdown ran out before the puzzle was solved
+	Teams        []TeamResult     `json:"teams,omitempty"`    // team mode standings, first place first
+	Series       *SeriesStandings `json:"series,omitempty"`   // rooms playing a series: the standings after this game
 }
 
 type PlayerResult struct {
@@ -140,6 +335,45 @@ type PlayerResult struct {
 	DisplayName  string  `json:"displayName"`
 	Contribution float64 `json:"contribution"`
 	Color        string  `json:"color"`
+	TeamID       string  `json:"teamId,omitempty"`
+}
+
+type TeamResult struct {
+	TeamID    string   `json:"teamId"`
+	Name      string   `json:"name"`
+	Color     string   `json:"color"`
+	Rank      int      `json:"rank"`
+	Progress  float64  `json:"progress"`            // 0-100 percentage
+	SolveTime *int     `json:"solveTime,omitempty"` // finished teams only
+	Players   []string `json:"players"`
+}
+
+// Rematch and series payloads (see series.go)
+type RematchPayload struct {
+	Puzzle   string `json:"puzzle,omitempty"`   // "next" (default), "random" or "choose"
+	PuzzleID string `json:"puzzleId,omitempty"` // with "choose"
+}
+
+type RematchStartedPayload struct {
+	Room    *models.Room     `json:"room"`
+	Puzzle  *models.Puzzle   `json:"puzzle"`
+	Players []models.Player  `json:"players"`
+	Series  *SeriesStandings `json:"series,omitempty"`
+}
+
+type SeriesStandings struct {
+	Game      int              `json:"game"`  // games played so far
+	Games     int              `json:"games"` // games in the series
+	Finished  bool             `json:"finished"`
+	Standings []SeriesStanding `json:"standings"` // most points first
+}
+
+type SeriesStanding struct {
+	UserID      string `json:"userId"`
+	DisplayName string `json:"displayName"`
+	Points      int    `json:"points"`
+	GamePoints  int    `json:"gamePoints"` // scored in the game just finished
+	Rank        int    `json:"rank"`
 }
 
 type ErrorPayload struct {
@@ -154,65 +388,222 @@ type RaceProgressPayload struct {
 type PlayerFinishedPayload struct {
 	UserID      string `json:"userId"`
 	DisplayName string `json:"displayName"`
-	SolveTime   int    `json:"solveTime"`
+	SolveTime   int    `json:"solveTime"`         // includes the penalty
 	Rank        int    `json:"rank"`
+	Penalty     int    `json:"penalty,omitempty"` // seconds added for revealed letters
+}
+
+// Team mode payloads
+type TeamChangedPayload struct {
+	UserID string `json:"userId"`
+	TeamID string `json:"teamId"`
+}
+
+type TeamProgressPayload struct {
+	Teams []models.TeamProgress `json:"teams"`
+}
+
+type TeamFinishedPayload struct {
+	TeamID    string `json:"teamId"`
+	Name      string `json:"name"`
+	SolveTime int    `json:"solveTime"`
+	Rank      int    `json:"rank"`
 }
 
 // Relay mode payloads
 type TurnChangedPayload struct {
-	CurrentPlayerID   string `json:"currentPlayerId"`
-	CurrentPlayerName string `json:"currentPlayerName"`
-	TurnNumber        int    `json:"turnNumber"`
+	CurrentPlayerID   string     `json:"currentPlayerId"`
+	CurrentPlayerName string     `json:"currentPlayerName"`
+	TurnNumber        int        `json:"turnNumber"`
+	Reason            string     `json:"reason,omitempty"` // why the last turn ended: "passed", "timeout", "words" or "skipped"
+	PreviousPlayerID  string     `json:"previousPlayerId,omitempty"`
+	TurnEndsAt        *time.Time `json:"turnEndsAt,omitempty"` // when the turn times out, unless the game is paused
+}
+
+type SkipVotePayload struct {
+	PlayerID string `json:"playerId"` // player whose turn would be skipped
+	VoterID  string `json:"voterId"`
+	Votes    int    `json:"votes"`
+	Needed   int    `json:"needed"`
+}
+
+// Moderation payloads
+type PlayerKickedPayload struct {
+	UserID      string `json:"userId"`
+	DisplayName string `json:"displayName"`
+	Banned      bool   `json:"banned"`
+}
+
+type HostChangedPayload struct {
+	HostID      string `json:"hostId"`
+	DisplayName string `json:"displayName"`
+	Reason      string `json:"reason"` // "transferred" or "host_left"
+}
+
+type RoomSettingsPayload struct {
+	Locked    bool `json:"locked"`
+	ChatMuted bool `json:"chatMuted"`
+}
+
+type PlayerMutedPayload struct {
+	UserID string `json:"userId"`
+	Muted  bool   `json:"muted"`
+}
+
+type LobbyRoomsPayload struct {
+	Rooms []models.PublicRoom `json:"rooms"`
+}
+
+type LobbyRoomPayload struct {
+	Room models.PublicRoom `json:"room"`
+}
+
+type LobbyRoomRemovedPayload struct {
+	RoomID string `json:"roomId"`
 }
 
 type RoomDeletedPayload struct {
 	Reason string `json:"reason"`
 }
 
-// Hub manages all WebSocket connections and rooms
+// Hub manages the WebSocket connections of this server instance and the rooms
+// they are in. State shared with other instances lives in the broker (see cluster.go).
 type Hub struct {
 	db              *db.Database
-	clients         map[string]*Client   // connectionID -> client
-	userConnections map[string][]string  // userID -> []connectionID
-	rooms           map[string]*Room     // roomID -> room
+	broker          Broker
+	instanceID      string
+	clusterMessages <-chan []byte
+	unsubscribe     context.CancelFunc     // ends the broadcast subscription when Run stops
+	clients         map[string]*Client     // connectionID -> client
+	userConnections map[string][]string    // userID -> []connectionID
+	rooms           map[string]*Room       // roomID -> room
+	grids           map[string]*sharedGrid // roomID/userID -> in-memory grid (see grid.go)
+	gridsMutex      sync.Mutex
+	gridStore       gridStore
+	flushMutex      sync.Mutex
+	persistStats    persistStats
+	roomCache       roomCache
+	eventStore      eventStore
+	accessStore     accessStore
+	relayStore      relayStore
+	recorder        eventRecorder
+	replays         map[string]*replaySession // connectionID -> replay being watched
+	replaysMutex    sync.Mutex
+	lobbyWatchers   map[string]*lobbyWatcher // connectionID -> lobby filter (see lobby.go)
+	lobbyMutex      sync.Mutex
+	wordFilter      *wordfilter.Filter // banned words starred out of chat (see chat.go)
+	chatLimits      chatLimiter
+	turnTimers      map[string]*time.Timer // roomID -> end of the current relay turn (see relay.go)
+	turnTimersMutex sync.Mutex
+	heats           HeatReporter // told when a tournament heat's race ends (see heats.go)
+	cursors         cursorBatch  // cursor moves waiting for the next tick (see cursors.go)
+	inboundStats    inboundStats // what became of client messages (see inbound.go)
 	register        chan *Client
 	unregister      chan *Client
 	mutex           sync.RWMutex
 }
 
-// Room represents a multiplayer room with connected clients
+// Room represents a multiplayer room with the clients connected to this instance.
+// Start time, contributions, finish order and turn number are kept in the broker.
 type Room struct {
-	ID          string
-	Code        string
-	Mode        models.RoomMode
-	Clients     map[string]*Client // connectionID -> client
-	StartTime   *time.Time
-	mutex       sync.RWMutex
-	// Race mode tracking
-	FinishOrder []string           // UserIDs in order of completion
-	// Relay mode tracking
-	TurnNumber  int
-	// Contribution tracking (collaborative mode)
-	ContributionMap map[string]int // userID -> correct cell count
+	ID           string
+	Code         string
+	Mode         models.RoomMode
+	TimerMode    string // "none", "countdown" or "stopwatch"
+	TimerSeconds int
+	Clients      map[string]*Client      // connectionID -> client
+	feed         *spectatorFeed          // delays messages to spectators; nil unless the room has a spectator delay
+	histories    map[string]*editHistory // userID -> undo/redo history (see undo.go)
+	mutex        sync.RWMutex
 }
 
+// NewHub creates a hub that coordinates with other instances through the
+// database's Redis client, or an in-process broker when there is none
 func NewHub(database *db.Database) *Hub {
-	return &Hub{
+	var broker Broker
+	if database != nil && database.Redis != nil {
+		broker = NewRedisBroker(database.Redis)
+	} else {
+		broker = NewMemoryBroker()
+	}
+	return NewHubWithBroker(database, broker)
+}
+
+// NewHubWithBroker creates a hub that coordinates with other instances through broker
+func NewHubWithBroker(database *db.Database, broker Broker) *Hub {
+	h := &Hub{
 		db:              database,
+		broker:          broker,
+		instanceID:      uuid.New().String(),
 		clients:         make(map[string]*Client),
 		userConnections: make(map[string][]string),
 		rooms:           make(map[string]*Room),
+		grids:           make(map[string]*sharedGrid),
+		roomCache:       roomCache{entries: make(map[string]*cachedRoom)},
+		replays:         make(map[string]*replaySession),
+		lobbyWatchers:   make(map[string]*lobbyWatcher),
+		turnTimers:      make(map[string]*time.Timer),
 		register:        make(chan *Client),
 		unregister:      make(chan *Client),
 	}
+
+	// Subscribe up front so broadcasts published before Run starts are not lost
+	subCtx, unsubscribe := context.WithCancel(context.Background())
+	msgs, err := broker.Subscribe(subCtx, broadcastChannel)
+	if err != nil {
+		log.Printf("NewHub: failed to subscribe to room broadcasts: %v", err)
+	}
+	h.clusterMessages = msgs
+	h.unsubscribe = unsubscribe
+
+	if database != nil {
+		h.gridStore = database
+		h.eventStore = database
+		h.accessStore = database
+		h.relayStore = database
+	}
+
+	return h
 }
 
-func (h *Hub) Run() {
-	// Start relay turn timeout checker
-	go h.checkRelayTurnTimeouts()
+// Run handles client registrations and runs the hub's background loops until
+// ctx is cancelled. Before returning it stops the loops and flushes the grids
+// and events still waiting to be written.
+func (h *Hub) Run(ctx context.Context) {
+	var background sync.WaitGroup
+	start := func(loop func(ctx context.Context)) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			loop(ctx)
+		}()
+	}
+
+	// Start game clocks
+	start(h.runGameClocks)
+
+	// Write edited grids behind to the database
+	start(h.runGridFlusher)
+
+	// Send out cursor moves once per tick
+	start(h.runCursorFlusher)
+
+	// Coordinate with other instances
+	start(h.runHeartbeat)
+	if h.clusterMessages != nil {
+		start(func(ctx context.Context) { h.handleClusterMessages(ctx, h.clusterMessages) })
+	}
 
 	for {
 		select {
+		case <-ctx.Done():
+			// Stop the background loops and the broadcast subscription, then
+			// write what the flusher hadn't yet
+			background.Wait()
+			h.unsubscribe()
+			h.Flush()
+			return
+
 		case client := <-h.register:
 			h.mutex.Lock()
 			// Store client by connectionID
@@ -220,9 +611,14 @@ func (h *Hub) Run() {
 			// Track user's connections
 			h.userConnections[client.UserID] = append(h.userConnections[client.UserID], client.ConnectionID)
 			h.mutex.Unlock()
+			h.trackConnection(client)
 			log.Printf("Client registered: connectionID=%s, userID=%s", client.ConnectionID, client.UserID)
 
 		case client := <-h.unregister:
+			// Stop any replay or lobby updates before its send channel is closed
+			h.stopReplay(client)
+			h.unwatchLobby(client)
+
 			h.mutex.Lock()
 			if _, ok := h.clients[client.ConnectionID]; ok {
 				delete(h.clients, client.ConnectionID)
@@ -240,6 +636,7 @@ func (h *Hub) Run() {
 						h.userConnections[client.UserID] = newConns
 					} else {
 						delete(h.userConnections, client.UserID)
+						h.chatLimits.forget(client.UserID)
 					}
 				}
 			}
@@ -249,6 +646,7 @@ func (h *Hub) Run() {
 			if client.RoomID != "" {
 				h.removeClientFromRoom(client)
 			}
+			h.untrackConnection(client)
 			log.Printf("Client unregistered: connectionID=%s, userID=%s", client.ConnectionID, client.UserID)
 		}
 	}
@@ -264,6 +662,10 @@ func (h *Hub) Unregister(client *Client) {
 
 func (h *Hub) HandleMessage(client *Client, msg *Message) {
 	log.Printf("HandleMessage: Received %s from user %s", msg.Type, client.UserID)
+	if client.IsSpectator && spectatorBlocked[msg.Type] {
+		h.sendError(client, "spectators can only watch")
+		return
+	}
 	switch msg.Type {
 	case MsgJoinRoom:
 		h.handleJoinRoom(client, msg.Payload)
@@ -275,6 +677,10 @@ func (h *Hub) HandleMessage(client *Client, msg *Message) {
 		h.handleCursorMove(client, msg.Payload)
 	case MsgSendMessage:
 		h.handleSendMessage(client, msg.Payload)
+	case MsgDeleteMessage:
+		h.handleDeleteMessage(client, msg.Payload)
+	case MsgTyping:
+		h.handleTyping(client, msg.Payload)
 	case MsgRequestHint:
 		h.handleRequestHint(client, msg.Payload)
 	case MsgStartGame:
@@ -283,6 +689,42 @@ func (h *Hub) HandleMessage(client *Client, msg *Message) {
 		h.handleReaction(client, msg.Payload)
 	case MsgPassTurn:
 		h.handlePassTurn(client)
+	case MsgVoteSkip:
+		h.handleVoteSkip(client)
+	case MsgRematch:
+		h.handleRematch(client, msg.Payload)
+	case MsgResume:
+		h.handleResume(client, msg.Payload)
+	case MsgPauseGame:
+		h.handlePauseGame(client)
+	case MsgResumeGame:
+		h.handleResumeGame(client)
+	case MsgStartReplay:
+		h.handleStartReplay(client, msg.Payload)
+	case MsgReplayControl:
+		h.handleReplayControl(client, msg.Payload)
+	case MsgSetTeam:
+		h.handleSetTeam(client, msg.Payload)
+	case MsgSetClueNote:
+		h.handleSetClueNote(client, msg.Payload)
+	case MsgUndo:
+		h.handleUndo(client, false)
+	case MsgRedo:
+		h.handleUndo(client, true)
+	case MsgKickPlayer:
+		h.handleKickPlayer(client, msg.Payload)
+	case MsgBanPlayer:
+		h.handleBanPlayer(client, msg.Payload)
+	case MsgTransferHost:
+		h.handleTransferHost(client, msg.Payload)
+	case MsgLockRoom:
+		h.handleLockRoom(client, msg.Payload)
+	case MsgMuteChat:
+		h.handleMuteChat(client, msg.Payload)
+	case MsgWatchLobby:
+		h.handleWatchLobby(client, msg.Payload)
+	case MsgUnwatchLobby:
+		h.unwatchLobby(client)
 	default:
 		log.Printf("Unknown message type: %s", msg.Type)
 	}
@@ -299,7 +741,7 @@ func (h *Hub) handleJoinRoom(client *Client, payload json.RawMessage) {
 	log.Printf("handleJoinRoom: User %s joining room %s", client.UserID, p.RoomCode)
 
 	// Get room from database
-	room, err := h.db.GetRoomByCode(p.RoomCode)
+	room, err := h.accessStore.GetRoomByCode(p.RoomCode)
 	if err != nil || room == nil {
 		log.Printf("handleJoinRoom: Room not found - code: %s, err: %v", p.RoomCode, err)
 		h.sendError(client, "room not found")
@@ -308,39 +750,147 @@ func (h *Hub) handleJoinRoom(client *Client, payload json.RawMessage) {
 
 	log.Printf("handleJoinRoom: Found room %s (ID: %s)", room.Code, room.ID)
 
+	if !h.attachToRoom(client, room) {
+		return
+	}
+	h.assignTeamOnJoin(room, client.UserID)
+	players := h.sendRoomState(client, room)
+	h.announceJoin(client, room.ID, players)
+}
+
+// handleResume rejoins a room after a reconnect. Messages broadcast since
+// LastSeq are replayed from the room's buffer; if the gap can't be filled the
+// client gets a fresh room_state snapshot instead.
+func (h *Hub) handleResume(client *Client, payload json.RawMessage) {
+	var p ResumePayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		h.sendError(client, "invalid payload")
+		return
+	}
+
+	room, err := h.accessStore.GetRoomByCode(p.RoomCode)
+	if err != nil || room == nil {
+		h.sendError(client, "room not found")
+		return
+	}
+
+	if !h.attachToRoom(client, room) {
+		return
+	}
+
+	// Team mode messages are sent to one team only and aren't in the replay
+	// buffer, so team players always get a fresh snapshot. So do delayed
+	// spectators, as the buffer is ahead of their view.
+	messages, ok := h.replaySince(room.ID, p.LastSeq)
+	if !ok || room.Mode == models.RoomModeTeam || h.delayedFeed(client) != nil {
+		log.Printf("handleResume: cannot replay room %s from seq %d, sending snapshot", room.ID, p.LastSeq)
+		players := h.sendRoomState(client, room)
+		h.announceJoin(client, room.ID, players)
+		return
+	}
+
+	// Messages broadcast while attaching may arrive both live and replayed;
+	// clients drop anything with a seq they have already seen. A replay that
+	// doesn't fit in the send buffer would leave a gap, so the client gets a
+	// snapshot after whatever was queued.
+	queued := queueReplay(client, messages)
+	if queued < len(messages) {
+		log.Printf("handleResume: send buffer full after %d of %d replayed messages, sending snapshot", queued, len(messages))
+		players := h.sendRoomState(client, room)
+		h.announceJoin(client, room.ID, players)
+		return
+	}
+	h.sendToClient(client, MsgResumed, ResumedPayload{
+		FromSeq:  p.LastSeq + 1,
+		ToSeq:    p.LastSeq + int64(queued),
+		Replayed: queued,
+	})
+
+	players, _ := h.db.GetRoomPlayers(room.ID)
+	h.announceJoin(client, room.ID, players)
+}
+
+// attachToRoom adds a client to the hub room, creating it if needed, and marks the player connected.
+// Every way into a room comes through here, so it turns away banned users (returning false).
+func (h *Hub) attachToRoom(client *Client, room *models.Room) bool {
+	// Like the HTTP join, refuse when the ban list can't be read
+	banned, err := h.accessStore.IsBanned(room.ID, client.UserID)
+	if err != nil {
+		log.Printf("attachToRoom: failed to check bans of room %s: %v", room.ID, err)
+		h.sendError(client, "failed to join room")
+		return false
+	}
+	if banned {
+		h.sendError(client, "you are banned from this room")
+		return false
+	}
+
 	// Get or create room in hub
 	h.mutex.Lock()
 	hubRoom, exists := h.rooms[room.ID]
 	if !exists {
 		hubRoom = &Room{
-			ID:              room.ID,
-			Code:            room.Code,
-			Mode:            room.Mode,
-			Clients:         make(map[string]*Client),
-			FinishOrder:     []string{},
-			ContributionMap: make(map[string]int),
+			ID:           room.ID,
+			Code:         room.Code,
+			Mode:         room.Mode,
+			TimerMode:    room.Config.TimerMode,
+			TimerSeconds: room.Config.TimerSeconds,
+			Clients:      make(map[string]*Client),
 		}
+		hubRoom.startSpectatorFeed(room)
 		h.rooms[room.ID] = hubRoom
 	}
 	h.mutex.Unlock()
 
+	// The first client here of a relay game in progress starts timing its turns
+	if !exists && room.Mode == models.RoomModeRelay && room.State == models.RoomStateActive {
+		h.watchTurn(room.ID)
+	}
+
+	// The player was just added or changed by the join
+	h.invalidatePlayers(room.ID)
+	players, _ := h.db.GetRoomPlayers(room.ID)
+	player := findPlayer(players, client.UserID)
+
 	// Add client to room
 	hubRoom.mutex.Lock()
+	client.IsSpectator = player != nil && player.IsSpectator
 	hubRoom.Clients[client.ConnectionID] = client
 	hubRoom.mutex.Unlock()
 
 	client.RoomID = room.ID
+	h.trackConnection(client)
+	h.touchRoomState(room.ID)
 
 	// Update player connection status
 	h.db.UpdatePlayerConnection(client.UserID, room.ID, true)
+	return true
+}
+
+// sendRoomState sends a full snapshot of the room to a client and returns the room's players
+func (h *Hub) sendRoomState(client *Client, room *models.Room) []models.Player {
+	// Read the sequence first: anything broadcast while the snapshot is built
+	// is then either in the snapshot or delivered afterwards
+	seq := h.currentSeq(room.ID)
 
 	// Get room state
 	players, _ := h.db.GetRoomPlayers(room.ID)
-	gridState, _ := h.db.GetGridState(room.ID)
 	puzzle, _ := h.db.GetPuzzleByID(room.PuzzleID)
 	messages, _ := h.db.GetRoomMessages(room.ID, 50)
 	reactions, _ := h.db.GetRoomReactions(room.ID)
 
+	// In team mode players see their own team's grid
+	var gridState *models.GridState
+	var teams []models.Team
+	if room.Mode == models.RoomModeTeam {
+		teams = roomTeams(room)
+		if teamID, _ := playerTeam(players, client.UserID); teamID != "" {
+			gridState = h.teamGridState(room.ID, teamID)
+		}
+	} else {
+		gridState = h.roomGridState(room.ID)
+	}
+
 	// Send room state to joining client
 	roomState := RoomStatePayload{
 		Room:      room,
@@ -349,13 +899,24 @@ func (h *Hub) handleJoinRoom(client *Client, payload json.RawMessage) {
 		Puzzle:    sanitizePuzzleForClient(puzzle),
 		Messages:  messages,
 		Reactions: reactions,
+		Seq:       seq,
+		Teams:     teams,
+	}
+	if player := findPlayer(players, client.UserID); player != nil && player.IsSpectator {
+		roomState.Grids = h.spectatorGrids(room, players)
 	}
-	h.sendToClient(client, MsgRoomState, roomState)
 
-	// Notify other clients
+	// Delayed spectators get the snapshot once the messages queued before it are out
+	h.sendDelayed(client, MsgRoomState, roomState)
+
+	return players
+}
+
+// announceJoin notifies the other clients in the room that a player (re)joined
+func (h *Hub) announceJoin(client *Client, roomID string, players []models.Player) {
 	player := findPlayer(players, client.UserID)
 	if player != nil {
-		h.broadcastToRoom(room.ID, client.ConnectionID, MsgPlayerJoined, PlayerJoinedPayload{
+		h.broadcastToRoom(roomID, client.ConnectionID, MsgPlayerJoined, PlayerJoinedPayload{
 			Player: *player,
 		})
 	}
@@ -365,6 +926,7 @@ func (h *Hub) handleLeaveRoom(client *Client) {
 	if client.RoomID == "" {
 		return
 	}
+	h.stopReplay(client)
 	h.removeClientFromRoom(client)
 }
 
@@ -382,208 +944,171 @@ func (h *Hub) handleCellUpdate(client *Client, payload json.RawMessage) {
 		return
 	}
 	log.Printf("handleCellUpdate: x=%d, y=%d, value=%v", p.X, p.Y, p.Value)
+	if p.Candidates != nil {
+		candidates, ok := normalizeCandidates(p.Candidates)
+		if !ok {
+			h.sendError(client, "invalid candidates")
+			return
+		}
+		p.Candidates = candidates
+	}
 
-	// Get room
-	room, _ := h.db.GetRoomByID(client.RoomID)
-	if room == nil || room.State != models.RoomStateActive {
+	// Get room and puzzle
+	room, puzzle := h.cachedRoomAndPuzzle(client.RoomID)
+	if room == nil || puzzle == nil || room.State != models.RoomStateActive {
+		return
+	}
+	if h.clockPaused(room.ID) {
+		h.sendError(client, "game is paused")
 		return
 	}
 
 	// Handle based on game mode
 	switch room.Mode {
 	case models.RoomModeRace:
-		h.handleRaceCellUpdate(client, room, &p)
+		h.handleRaceCellUpdate(client, puzzle, &p)
 	case models.RoomModeRelay:
-		h.handleRelayCellUpdate(client, room, &p)
+		h.handleRelayCellUpdate(client, puzzle, &p)
+	case models.RoomModeTeam:
+		h.handleTeamCellUpdate(client, puzzle, &p)
 	default: // Collaborative
-		h.handleCollaborativeCellUpdate(client, room, &p)
+		h.handleCollaborativeCellUpdate(client, puzzle, &p)
 	}
 }
 
-func (h *Hub) handleCollaborativeCellUpdate(client *Client, room *models.Room, p *CellUpdatePayload) {
-	gridState, _ := h.db.GetGridState(client.RoomID)
-	if gridState == nil {
+func (h *Hub) handleCollaborativeCellUpdate(client *Client, puzzle *models.Puzzle, p *CellUpdatePayload) {
+	grid := h.roomGrid(client.RoomID)
+	if grid == nil {
 		return
 	}
 
-	// Get puzzle to validate answer
-	puzzle, _ := h.db.GetPuzzleByID(room.PuzzleID)
-	if puzzle == nil {
+	// Update cell. Edits are merged per cell, so simultaneous edits to other
+	// cells are never lost; a losing edit to the same cell is not broadcast.
+	_, before, cell, applied := h.writeCell(grid, client.RoomID, p.X, p.Y, playerCellEdit(p, client.UserID))
+	if !applied {
 		return
 	}
+	h.rememberEdit(client, "", p.X, p.Y, before, cell)
 
-	// Update cell
-	if p.Y >= 0 && p.Y < len(gridState.Cells) && p.X >= 0 && p.X < len(gridState.Cells[p.Y]) {
-		// Check if this cell was previously incorrect or empty, and is now correct
-		isNewCorrectAnswer := false
-		if p.Value != nil && puzzle.Grid[p.Y][p.X].Letter != nil {
-			prevValue := gridState.Cells[p.Y][p.X].Value
-			newValue := *p.Value
-			expectedValue := *puzzle.Grid[p.Y][p.X].Letter
-
-			// Only track contribution if this is a new correct answer
-			if newValue == expectedValue && (prevValue == nil || *prevValue != expectedValue) {
-				isNewCorrectAnswer = true
-			}
-		}
-
-		gridState.Cells[p.Y][p.X].Value = p.Value
-		gridState.Cells[p.Y][p.X].LastEditedBy = &client.UserID
-		gridState.LastUpdated = time.Now()
+	// Track contribution if this is a new correct answer
+	if isNewlyCorrect(puzzle, p, cellAnswer(before)) {
+		h.addContribution(client.RoomID, client.UserID)
+	}
 
-		h.db.UpdateGridState(gridState)
+	// Get player for color
+	player := findPlayer(h.cachedPlayers(client.RoomID), client.UserID)
+	color := "#888888"
+	if player != nil {
+		color = player.Color
+	}
 
-		// Track contribution if this is a new correct answer
-		if isNewCorrectAnswer {
-			h.mutex.Lock()
-			hubRoom := h.rooms[client.RoomID]
-			if hubRoom != nil {
-				if hubRoom.ContributionMap == nil {
-					hubRoom.ContributionMap = make(map[string]int)
-				}
-				hubRoom.ContributionMap[client.UserID]++
-			}
-			h.mutex.Unlock()
-		}
+	cellUpdate := cellUpdated(p.X, p.Y, cell)
+	cellUpdate.PlayerID = client.UserID
+	cellUpdate.Color = color
+	if p.Candidates == nil {
+		h.recordEvent(client.RoomID, models.RoomEventCell, client.UserID, p.X, p.Y, cellUpdate.Value)
+	}
 
-		// Get player for color
-		players, _ := h.db.GetRoomPlayers(client.RoomID)
-		player := findPlayer(players, client.UserID)
-		color := "#888888"
-		if player != nil {
-			color = player.Color
-		}
+	// Broadcast to room
+	h.broadcastToRoom(client.RoomID, "", MsgCellUpdated, cellUpdate)
 
-		value := ""
-		if p.Value != nil {
-			value = *p.Value
-		}
+	// Check for puzzle completion
+	h.checkCollaborativeCompletion(client.RoomID)
+}
 
-		// Broadcast to room
-		h.broadcastToRoom(client.RoomID, "", MsgCellUpdated, CellUpdatedPayload{
-			X:        p.X,
-			Y:        p.Y,
-			Value:    value,
-			PlayerID: client.UserID,
-			Color:    color,
-		})
+// playerCellEdit turns a player's cell update into an edit of their grid
+func playerCellEdit(p *CellUpdatePayload, userID string) cellEdit {
+	if p.Candidates != nil {
+		return cellEdit{Candidates: p.Candidates, EditedBy: userID, Part: cellCandidates}
+	}
+	return cellEdit{Value: p.Value, IsPencil: p.Pencil, EditedBy: userID}
+}
 
-		// Check for puzzle completion
-		h.checkCollaborativeCompletion(client.RoomID)
+// isNewlyCorrect reports whether an edit made a cell correct that wasn't
+// before. Pencilled entries and candidates don't count.
+func isNewlyCorrect(puzzle *models.Puzzle, p *CellUpdatePayload, prevValue *string) bool {
+	if p.Value == nil || p.Pencil || p.Candidates != nil || p.Y >= len(puzzle.Grid) || p.X >= len(puzzle.Grid[p.Y]) || puzzle.Grid[p.Y][p.X].Letter == nil {
+		return false
 	}
+	expectedValue := *puzzle.Grid[p.Y][p.X].Letter
+	return *p.Value == expectedValue && (prevValue == nil || *prevValue != expectedValue)
 }
 
-func (h *Hub) handleRaceCellUpdate(client *Client, room *models.Room, p *CellUpdatePayload) {
+func (h *Hub) handleRaceCellUpdate(client *Client, puzzle *models.Puzzle, p *CellUpdatePayload) {
 	// In Race mode, each player has their own grid
-	gridState, _ := h.db.GetPlayerGridState(client.RoomID, client.UserID)
-	if gridState == nil {
-		// Create player's grid if it doesn't exist
-		puzzle, _ := h.db.GetPuzzleByID(room.PuzzleID)
-		if puzzle == nil {
-			return
-		}
-		gridState = &models.GridState{
-			RoomID:         client.RoomID,
-			UserID:         client.UserID,
-			Cells:          make([][]models.Cell, len(puzzle.Grid)),
-			CompletedClues: []string{},
-			LastUpdated:    time.Now(),
-		}
-		for y := range puzzle.Grid {
-			gridState.Cells[y] = make([]models.Cell, len(puzzle.Grid[y]))
-		}
-		h.db.CreateGridState(gridState)
+	grid := h.playerGrid(client.RoomID, client.UserID, puzzle)
+	if grid == nil {
+		return
 	}
 
-	// Update cell
-	if p.Y >= 0 && p.Y < len(gridState.Cells) && p.X >= 0 && p.X < len(gridState.Cells[p.Y]) {
-		gridState.Cells[p.Y][p.X].Value = p.Value
-		gridState.Cells[p.Y][p.X].LastEditedBy = &client.UserID
-		gridState.LastUpdated = time.Now()
+	// Update cell. Race cells are versioned too, for undo (see undo.go).
+	if _, before, cell, applied := h.writeCell(grid, client.RoomID, p.X, p.Y, playerCellEdit(p, client.UserID)); applied {
+		h.rememberEdit(client, client.UserID, p.X, p.Y, before, cell)
 
-		h.db.UpdateGridState(gridState)
+		cellUpdate := raceCellUpdated(p.X, p.Y, cell)
+		cellUpdate.PlayerID = client.UserID
+		cellUpdate.Color = "#4ECDC4"
+		if p.Candidates == nil {
+			h.recordEvent(client.RoomID, models.RoomEventCell, client.UserID, p.X, p.Y, cellUpdate.Value)
+		}
 
 		// In Race mode, only send update back to the player (not broadcast)
-		value := ""
-		if p.Value != nil {
-			value = *p.Value
-		}
-		h.sendToClient(client, MsgCellUpdated, CellUpdatedPayload{
-			X:        p.X,
-			Y:        p.Y,
-			Value:    value,
-			PlayerID: client.UserID,
-			Color:    "#4ECDC4",
-		})
+		// and to the spectators, who watch every player's grid
+		h.sendToClient(client, MsgCellUpdated, cellUpdate)
+		h.sendToUsers(client.RoomID, "", spectatorIDs(h.cachedPlayers(client.RoomID)), MsgCellUpdated, cellUpdate)
 
 		// Check if this player completed and broadcast progress
 		h.checkRaceProgress(client.RoomID, client.UserID)
 	}
 }
 
-func (h *Hub) handleRelayCellUpdate(client *Client, room *models.Room, p *CellUpdatePayload) {
+func (h *Hub) handleRelayCellUpdate(client *Client, puzzle *models.Puzzle, p *CellUpdatePayload) {
 	// Check if it's this player's turn
-	relayState, _ := h.db.GetRelayState(client.RoomID)
+	relayState := h.relayState(client.RoomID)
 	if relayState == nil || relayState.CurrentPlayerID != client.UserID {
 		h.sendError(client, "not your turn")
 		return
 	}
 
-	gridState, _ := h.db.GetGridState(client.RoomID)
-	if gridState == nil {
+	grid := h.roomGrid(client.RoomID)
+	if grid == nil {
 		return
 	}
 
-	puzzle, _ := h.db.GetPuzzleByID(room.PuzzleID)
-	if puzzle == nil {
+	// Update cell
+	_, before, cell, applied := h.writeCell(grid, client.RoomID, p.X, p.Y, playerCellEdit(p, client.UserID))
+	if !applied {
 		return
 	}
+	h.rememberEdit(client, "", p.X, p.Y, before, cell)
+	turnDone := h.countRelayWords(grid, puzzle, relayState, h.relayWordsPerTurn(client.RoomID))
 
-	// Get previously completed clues count
-	prevCompletedCount := len(gridState.CompletedClues)
-
-	// Update cell
-	if p.Y >= 0 && p.Y < len(gridState.Cells) && p.X >= 0 && p.X < len(gridState.Cells[p.Y]) {
-		gridState.Cells[p.Y][p.X].Value = p.Value
-		gridState.Cells[p.Y][p.X].LastEditedBy = &client.UserID
-		gridState.LastUpdated = time.Now()
-
-		// Check for newly completed clues
-		gridState.CompletedClues = h.getCompletedClues(puzzle, gridState)
-		h.db.UpdateGridState(gridState)
-
-		// Track newly completed words this turn
-		newCompletedCount := len(gridState.CompletedClues)
-		if newCompletedCount > prevCompletedCount {
-			wordsCompleted := newCompletedCount - prevCompletedCount
-			relayState.WordsThisTurn += wordsCompleted
-			h.db.UpdateRelayState(relayState)
-		}
+	// Track contribution if this is a new correct answer
+	if isNewlyCorrect(puzzle, p, cellAnswer(before)) {
+		h.addContribution(client.RoomID, client.UserID)
+	}
 
-		// Get player for color
-		players, _ := h.db.GetRoomPlayers(client.RoomID)
-		player := findPlayer(players, client.UserID)
-		color := "#888888"
-		if player != nil {
-			color = player.Color
-		}
+	// Get player for color
+	player := findPlayer(h.cachedPlayers(client.RoomID), client.UserID)
+	color := "#888888"
+	if player != nil {
+		color = player.Color
+	}
 
-		value := ""
-		if p.Value != nil {
-			value = *p.Value
-		}
+	cellUpdate := cellUpdated(p.X, p.Y, cell)
+	cellUpdate.PlayerID = client.UserID
+	cellUpdate.Color = color
+	if p.Candidates == nil {
+		h.recordEvent(client.RoomID, models.RoomEventCell, client.UserID, p.X, p.Y, cellUpdate.Value)
+	}
 
-		// Broadcast to room
-		h.broadcastToRoom(client.RoomID, "", MsgCellUpdated, CellUpdatedPayload{
-			X:        p.X,
-			Y:        p.Y,
-			Value:    value,
-			PlayerID: client.UserID,
-			Color:    color,
-		})
+	// Broadcast to room
+	h.broadcastToRoom(client.RoomID, "", MsgCellUpdated, cellUpdate)
 
-		// Check for puzzle completion
-		h.checkCollaborativeCompletion(client.RoomID)
+	// Check for puzzle completion, then whether the player has used up their turn
+	h.checkCollaborativeCompletion(client.RoomID)
+	if turnDone {
+		h.endTurn(client.RoomID, relayState, turnWords)
 	}
 }
 
@@ -597,24 +1122,41 @@ func (h *Hub) handleCursorMove(client *Client, payload json.RawMessage) {
 		return
 	}
 
+	// Only the latest move per tick goes out (see cursors.go)
+	h.cursors.add(cursorMove{client: client, roomID: client.RoomID, x: p.X, y: p.Y})
+}
+
+// moveCursor stores a player's cursor position and shows it to the room
+func (h *Hub) moveCursor(move cursorMove) {
+	client, roomID := move.client, move.roomID
+
 	// Update cursor in database
-	h.db.UpdatePlayerCursor(client.UserID, client.RoomID, p.X, p.Y)
+	h.db.UpdatePlayerCursor(client.UserID, roomID, move.x, move.y)
+	h.recordEvent(roomID, models.RoomEventCursor, client.UserID, move.x, move.y, "")
 
 	// Get player for color and name
-	players, _ := h.db.GetRoomPlayers(client.RoomID)
+	players, _ := h.db.GetRoomPlayers(roomID)
 	player := findPlayer(players, client.UserID)
 	if player == nil {
 		return
 	}
 
-	// Broadcast to other players
-	h.broadcastToRoom(client.RoomID, client.ConnectionID, MsgCursorMoved, CursorMovedPayload{
+	cursor := CursorMovedPayload{
 		PlayerID:    client.UserID,
 		DisplayName: player.DisplayName,
-		X:           p.X,
-		Y:           p.Y,
+		X:           move.x,
+		Y:           move.y,
 		Color:       player.Color,
-	})
+	}
+
+	// In team mode cursors are only shown to teammates and spectators
+	if teamID, members := playerTeam(players, client.UserID); teamID != "" {
+		h.sendToUsers(roomID, client.ConnectionID, withSpectators(members, players), MsgCursorMoved, cursor)
+		return
+	}
+
+	// Broadcast to other players
+	h.broadcastToRoom(roomID, client.ConnectionID, MsgCursorMoved, cursor)
 }
 
 func (h *Hub) handleSendMessage(client *Client, payload json.RawMessage) {
@@ -628,7 +1170,12 @@ func (h *Hub) handleSendMessage(client *Client, payload json.RawMessage) {
 		return
 	}
 
-	if p.Text == "" {
+	if strings.TrimSpace(p.Text) == "" {
+		return
+	}
+	text, problem := h.cleanMessage(p.Text)
+	if problem != "" {
+		h.sendError(client, problem)
 		return
 	}
 
@@ -639,13 +1186,53 @@ func (h *Hub) handleSendMessage(client *Client, payload json.RawMessage) {
 		return
 	}
 
+	// The host can mute single players or everyone but themselves
+	if player.IsMuted {
+		h.sendError(client, "you are muted")
+		return
+	}
+	room, _ := h.cachedRoomAndPuzzle(client.RoomID)
+	if room != nil && room.Config.ChatMuted && room.HostID != client.UserID {
+		h.sendError(client, "chat is muted")
+		return
+	}
+
+	// Spectators can't talk to the players while they play
+	if player.IsSpectator && room != nil && room.State == models.RoomStateActive {
+		h.sendError(client, "spectators can't chat during the game")
+		return
+	}
+
+	if !h.chatLimits.allow(client.UserID, time.Now()) {
+		h.sendError(client, "you are sending messages too fast")
+		return
+	}
+
+	// Team chat goes to the sender's team only and isn't kept in the room's history
+	if p.Team {
+		teamID, members := playerTeam(players, client.UserID)
+		if teamID == "" {
+			h.sendError(client, "you are not on a team")
+			return
+		}
+		h.sendToUsers(client.RoomID, "", members, MsgNewMessage, NewMessagePayload{
+			ID:          uuid.New().String(),
+			UserID:      client.UserID,
+			DisplayName: player.DisplayName,
+			Text:        text,
+			CreatedAt:   time.Now(),
+			TeamID:      teamID,
+		})
+		return
+	}
+
 	// Create message
 	msg := &models.Message{
 		ID:          uuid.New().String(),
 		RoomID:      client.RoomID,
 		UserID:      client.UserID,
 		DisplayName: player.DisplayName,
-		Text:        p.Text,
+		Text:        text,
 		CreatedAt:   time.Now(),
 	}
 
@@ -672,114 +1259,118 @@ func (h *Hub) handleRequestHint(client *Client, payload json.RawMessage) {
 		return
 	}
 
-	room, _ := h.db.GetRoomByID(client.RoomID)
-	if room == nil || !room.Config.HintsEnabled {
+	room, puzzle := h.cachedRoomAndPuzzle(client.RoomID)
+	if room == nil || !hintsAllowed(room.Config) {
 		h.sendError(client, "hints are disabled")
 		return
 	}
+	if puzzle == nil || room.State != models.RoomStateActive {
+		return
+	}
+	if h.clockPaused(room.ID) {
+		h.sendError(client, "game is paused")
+		return
+	}
 
-	// Get puzzle and grid state
-	puzzle, _ := h.db.GetPuzzleByID(room.PuzzleID)
-	gridState, _ := h.db.GetGridState(client.RoomID)
-	if puzzle == nil || gridState == nil {
+	// Find the grid the hint applies to, as handleCellUpdate does: the
+	// player's own in race mode, their team's in team mode
+	var players []models.Player
+	owner, teamID := "", ""
+	var members []string
+	switch room.Mode {
+	case models.RoomModeRace:
+		players, _ = h.db.GetRoomPlayers(client.RoomID)
+		owner = client.UserID
+	case models.RoomModeTeam:
+		players, _ = h.db.GetRoomPlayers(client.RoomID)
+		if teamID, members = playerTeam(players, client.UserID); teamID == "" {
+			return
+		}
+		members = withSpectators(members, players)
+		owner = teamGridOwner(teamID)
+	}
+	grid := h.loadGrid(client.RoomID, owner)
+	if grid == nil {
 		return
 	}
 
-	switch p.Type {
-	case "letter":
-		// Reveal single letter
-		if p.Y >= 0 && p.Y < len(puzzle.Grid) && p.X >= 0 && p.X < len(puzzle.Grid[p.Y]) {
-			letter := puzzle.Grid[p.Y][p.X].Letter
-			if letter != nil {
-				gridState.Cells[p.Y][p.X].Value = letter
-				gridState.Cells[p.Y][p.X].IsRevealed = true
-				gridState.LastUpdated = time.Now()
-				h.db.UpdateGridState(gridState)
-
-				h.broadcastToRoom(client.RoomID, "", MsgCellUpdated, CellUpdatedPayload{
-					X:          p.X,
-					Y:          p.Y,
-					Value:      *letter,
-					PlayerID:   "system",
-					Color:      "#888888",
-					IsRevealed: true,
-				})
-			}
+	send := func(update CellUpdatedPayload) {
+		if room.Mode == models.RoomModeRace {
+			update.Version = 0 // race grids aren't merged across instances
+			h.sendToClient(client, MsgCellUpdated, update)
+			h.sendToUsers(client.RoomID, "", spectatorIDs(players), MsgCellUpdated, update)
+			return
 		}
+		h.broadcastCellUpdate(client.RoomID, teamID, members, update)
+	}
 
-	case "word":
-		// Reveal entire word
-		if p.Y >= 0 && p.Y < len(puzzle.Grid) && p.X >= 0 && p.X < len(puzzle.Grid[p.Y]) {
-			// Find which clue this cell belongs to
-			var targetClue *models.Clue
-			var direction string
-
-			// Check across clues
-			for _, clue := range puzzle.CluesAcross {
-				if clue.PositionY == p.Y && p.X >= clue.PositionX && p.X < clue.PositionX+clue.Length {
-					targetClue = &clue
-					direction = "across"
-					break
-				}
-			}
+	action, scope := hintAction(p)
+	if action == "" {
+		h.sendError(client, "unknown hint type")
+		return
+	}
+	cells := hintCells(puzzle, scope, p)
+	if len(cells) == 0 {
+		h.sendError(client, "nothing to "+action)
+		return
+	}
 
-			// Check down clues if not found
-			if targetClue == nil {
-				for _, clue := range puzzle.CluesDown {
-					if clue.PositionX == p.X && p.Y >= clue.PositionY && p.Y < clue.PositionY+clue.Length {
-						targetClue = &clue
-						direction = "down"
-						break
-					}
-				}
-			}
+	switch action {
+	case hintReveal:
+		if !h.claimReveal(client.RoomID, client.UserID, room.Config.MaxReveals) {
+			h.sendError(client, "no reveals left")
+			return
+		}
 
-			// Reveal all cells in the word
-			if targetClue != nil {
-				for i := 0; i < targetClue.Length; i++ {
-					var cellX, cellY int
-					if direction == "across" {
-						cellX = targetClue.PositionX + i
-						cellY = targetClue.PositionY
-					} else {
-						cellX = targetClue.PositionX
-						cellY = targetClue.PositionY + i
-					}
+		// Revealed letters are recorded as cell events without a user after the hint
+		h.recordEvent(client.RoomID, models.RoomEventHint, client.UserID, p.X, p.Y, action+":"+scope)
 
-					letter := puzzle.Grid[cellY][cellX].Letter
-					if letter != nil {
-						gridState.Cells[cellY][cellX].Value = letter
-						gridState.Cells[cellY][cellX].IsRevealed = true
-
-						h.broadcastToRoom(client.RoomID, "", MsgCellUpdated, CellUpdatedPayload{
-							X:          cellX,
-							Y:          cellY,
-							Value:      *letter,
-							PlayerID:   "system",
-							Color:      "#888888",
-							IsRevealed: true,
-						})
-					}
-				}
-				gridState.LastUpdated = time.Now()
-				h.db.UpdateGridState(gridState)
+		revealed := 0
+		for _, cell := range cells {
+			x, y := cell[0], cell[1]
+			letter := puzzle.Grid[y][x].Letter
+			version, before, _, applied := h.writeCell(grid, client.RoomID, x, y, cellEdit{Value: letter, IsRevealed: true})
+			if !applied {
+				continue
+			}
+			// Letters the player already had right cost nothing
+			if answer := cellAnswer(before); !before.IsRevealed && (answer == nil || *answer != *letter) {
+				revealed++
 			}
+			h.recordEvent(client.RoomID, models.RoomEventCell, "", x, y, *letter)
+
+			send(CellUpdatedPayload{
+				X:          x,
+				Y:          y,
+				Value:      *letter,
+				PlayerID:   "system",
+				Color:      "#888888",
+				IsRevealed: true,
+				Version:    version,
+			})
 		}
-
-	case "check":
-		// Check all cells and highlight incorrect ones
-		for y := 0; y < len(puzzle.Grid); y++ {
-			for x := 0; x < len(puzzle.Grid[y]); x++ {
+		h.countHint(client.RoomID, client.UserID, hintLetters, revealed)
+
+	case hintCheck:
+		h.recordEvent(client.RoomID, models.RoomEventHint, client.UserID, p.X, p.Y, action+":"+scope)
+		h.countHint(client.RoomID, client.UserID, hintChecks, 1)
+
+		// Check the scope's cells and highlight incorrect ones
+		var checked []CellUpdatedPayload
+		grid.update(func(gridState *models.GridState) {
+			for _, cell := range cells {
+				x, y := cell[0], cell[1]
+				if y >= len(gridState.Cells) || x >= len(gridState.Cells[y]) {
+					continue
+				}
 				letter := puzzle.Grid[y][x].Letter
 				currentValue := gridState.Cells[y][x].Value
 
-				// Only check cells that have user input and aren't black squares
-				if letter != nil && currentValue != nil && *currentValue != "" {
+				// Only check cells that have user input
+				if currentValue != nil && *currentValue != "" {
 					isCorrect := *letter == *currentValue
 					gridState.Cells[y][x].IsCorrect = &isCorrect
-
-					// Broadcast the validation result
-					h.broadcastToRoom(client.RoomID, "", MsgCellUpdated, CellUpdatedPayload{
+					checked = append(checked, CellUpdatedPayload{
 						X:         x,
 						Y:         y,
 						Value:     *currentValue,
@@ -789,9 +1380,40 @@ func (h *Hub) handleRequestHint(client *Client, payload json.RawMessage) {
 					})
 				}
 			}
+		})
+
+		// Send the validation results; a racer's checks are theirs alone
+		for _, result := range checked {
+			if room.Mode == models.RoomModeRace {
+				h.sendToClient(client, MsgCellUpdated, result)
+				continue
+			}
+			send(result)
 		}
-		gridState.LastUpdated = time.Now()
-		h.db.UpdateGridState(gridState)
+	}
+
+	usage := h.roomHintUsage(client.RoomID)[client.UserID]
+	hintUsed := HintUsagePayload{
+		Reveals: usage.Reveals,
+		Checks:  usage.Checks,
+	}
+	if room.Config.MaxReveals > 0 {
+		left := max(room.Config.MaxReveals-usage.Reveals, 0)
+		hintUsed.RevealsLeft = &left
+	}
+	if room.Mode == models.RoomModeRace {
+		hintUsed.Penalty = hintPenalty(room.Config, usage)
+	}
+	h.sendToClient(client, MsgHintUsage, hintUsed)
+
+	// Revealed letters count towards completion
+	switch room.Mode {
+	case models.RoomModeRace:
+		h.checkRaceProgress(client.RoomID, client.UserID)
+	case models.RoomModeTeam:
+		h.checkTeamProgress(client.RoomID)
+	default:
+		h.checkCollaborativeCompletion(client.RoomID)
 	}
 }
 
@@ -826,23 +1448,24 @@ func (h *Hub) handleStartGame(client *Client) {
 		return
 	}
 
+	// Team mode needs players on at least two teams; players without a team
+	// are put on the smallest one
+	if room.Mode == models.RoomModeTeam {
+		players, _ := h.db.GetRoomPlayers(room.ID)
+		players = h.balanceTeams(room, players)
+		if len(teamsInPlay(roomTeams(room), players)) < 2 {
+			h.sendError(client, "team mode needs players on at least two teams")
+			return
+		}
+	}
+
 	log.Printf("handleStartGame: updating room state to active")
 	// Update room state
-	h.db.UpdateRoomState(room.ID, models.RoomStateActive)
+	h.setRoomState(room.ID, models.RoomStateActive)
+	h.NotifyRoomChanged(room.ID)
 
-	// Set start time and initialize contribution tracking for collaborative mode
-	h.mutex.Lock()
-	if hubRoom, exists := h.rooms[room.ID]; exists {
-		now := time.Now()
-		hubRoom.StartTime = &now
-		// Initialize contribution map for collaborative mode
-		if room.Mode == models.RoomModeCollaborative {
-			if hubRoom.ContributionMap == nil {
-				hubRoom.ContributionMap = make(map[string]int)
-			}
-		}
-	}
-	h.mutex.Unlock()
+	// Set start time, shared with the other instances serving this room
+	h.setRoomStartTime(room.ID, time.Now())
 
 	// Initialize mode-specific state
 	players, _ := h.db.GetRoomPlayers(room.ID)
@@ -853,20 +1476,16 @@ func (h *Hub) handleStartGame(client *Client) {
 		// Create individual grid state for each player
 		for _, player := range players {
 			if !player.IsSpectator {
-				gridState := &models.GridState{
-					RoomID:         room.ID,
-					UserID:         player.UserID,
-					Cells:          make([][]models.Cell, len(puzzle.Grid)),
-					CompletedClues: []string{},
-					LastUpdated:    time.Now(),
-				}
-				for y := range puzzle.Grid {
-					gridState.Cells[y] = make([]models.Cell, len(puzzle.Grid[y]))
-				}
-				h.db.CreateGridState(gridState)
+				h.db.CreateGridState(newGridState(room.ID, player.UserID, puzzle))
 			}
 		}
 
+	case models.RoomModeTeam:
+		// Create a shared grid for each team
+		for _, team := range teamsInPlay(roomTeams(room), players) {
+			h.db.CreateGridState(newGridState(room.ID, teamGridOwner(team.ID), puzzle))
+		}
+
 	case models.RoomModeRelay:
 		// Set up turn order
 		var turnOrder []string
@@ -881,24 +1500,14 @@ func (h *Hub) handleStartGame(client *Client) {
 				CurrentPlayerID: turnOrder[0],
 				TurnOrder:       turnOrder,
 				TurnStartedAt:   time.Now(),
-				TurnTimeLimit:   60, // 60 seconds per turn
+				TurnTimeLimit:   turnSeconds(room.Config),
 				WordsThisTurn:   0,
 			}
-			h.db.CreateRelayState(relayState)
-
-			// Get first player name
-			firstPlayer := findPlayer(players, turnOrder[0])
-			playerName := "Unknown"
-			if firstPlayer != nil {
-				playerName = firstPlayer.DisplayName
-			}
+			h.createRelayState(relayState)
 
-			// Broadcast turn info
-			h.broadcastToRoom(client.RoomID, "", MsgTurnChanged, TurnChangedPayload{
-				CurrentPlayerID:   turnOrder[0],
-				CurrentPlayerName: playerName,
-				TurnNumber:        1,
-			})
+			// Broadcast turn info and time the turn
+			h.broadcastToRoom(client.RoomID, "", MsgTurnChanged, turnChanged(relayState, players, h.nextTurnNumber(room.ID), "", ""))
+			h.scheduleTurnTimeout(room.ID, turnDeadline(relayState))
 		}
 	}
 
@@ -990,13 +1599,12 @@ func (h *Hub) handleReaction(client *Client, payload json.RawMessage) {
 }
 
 func (h *Hub) checkCollaborativeCompletion(roomID string) {
-	room, _ := h.db.GetRoomByID(roomID)
-	if room == nil {
+	room, puzzle := h.cachedRoomAndPuzzle(roomID)
+	if room == nil || room.State != models.RoomStateActive {
 		return
 	}
 
-	puzzle, _ := h.db.GetPuzzleByID(room.PuzzleID)
-	gridState, _ := h.db.GetGridState(roomID)
+	gridState := h.roomGridState(roomID)
 	if puzzle == nil || gridState == nil {
 		return
 	}
@@ -1009,7 +1617,7 @@ func (h *Hub) checkCollaborativeCompletion(roomID string) {
 			if expectedLetter == nil {
 				continue // black square
 			}
-			currentValue := gridState.Cells[y][x].Value
+			currentValue := cellAnswer(gridState.Cells[y][x])
 			if currentValue == nil || *currentValue != *expectedLetter {
 				complete = false
 				break
@@ -1020,16 +1628,11 @@ func (h *Hub) checkCollaborativeCompletion(roomID string) {
 		}
 	}
 
-	if complete {
+	// Stopping the clock claims the completion, so stats are recorded and
+	// puzzle_completed is sent once however many last edits raced here
+	if complete && h.stopClock(roomID, time.Now()) {
 		// Calculate solve time
-		h.mutex.RLock()
-		hubRoom := h.rooms[roomID]
-		h.mutex.RUnlock()
-
-		solveTime := 0
-		if hubRoom != nil && hubRoom.StartTime != nil {
-			solveTime = int(time.Since(*hubRoom.StartTime).Seconds())
-		}
+		solveTime := h.solveSeconds(roomID)
 
 		// Calculate total cells in puzzle
 		totalCells := 0
@@ -1042,10 +1645,9 @@ func (h *Hub) checkCollaborativeCompletion(roomID string) {
 		}
 
 		// Calculate contribution percentages
-		h.mutex.Lock()
 		contributionMap := make(map[string]float64)
-		if hubRoom != nil && hubRoom.ContributionMap != nil && totalCells > 0 {
-			for userID, cellCount := range hubRoom.ContributionMap {
+		if totalCells > 0 {
+			for userID, cellCount := range h.roomContributions(roomID) {
 				contribution := float64(cellCount) / float64(totalCells) * 100.0
 				contributionMap[userID] = contribution
 
@@ -1053,7 +1655,6 @@ func (h *Hub) checkCollaborativeCompletion(roomID string) {
 				h.db.UpdatePlayerContribution(userID, roomID, contribution)
 			}
 		}
-		h.mutex.Unlock()
 
 		// Get player results with updated contributions
 		players, _ := h.db.GetRoomPlayers(roomID)
@@ -1070,15 +1671,16 @@ func (h *Hub) checkCollaborativeCompletion(roomID string) {
 
 				// Update user stats and create puzzle history
 				// For collaborative mode, everyone "wins" (rank 0 = no rank for collaborative)
-				h.updateUserStatsAfterCompletion(p.UserID, room.PuzzleID, &roomID, solveTime, 0)
+				h.updateUserStatsAfterCompletion(p.UserID, room.PuzzleID, &roomID, solveTime, 0, gridState)
 			}
 		}
 
-		// Update room state
-		h.db.UpdateRoomState(roomID, models.RoomStateCompleted)
+		// Update room state and save the final grid
+		h.setRoomState(roomID, models.RoomStateCompleted)
+		h.flushRoomGrids(roomID)
 
 		// Broadcast completion
-		h.broadcastToRoom(roomID, "", MsgPuzzleCompleted, PuzzleCompletedPayload{
+		h.completePuzzle(room, players, PuzzleCompletedPayload{
 			SolveTime:   solveTime,
 			Players:     results,
 			CompletedAt: time.Now(),
@@ -1087,37 +1689,14 @@ func (h *Hub) checkCollaborativeCompletion(roomID string) {
 }
 
 func (h *Hub) checkRaceProgress(roomID string, userID string) {
-	room, _ := h.db.GetRoomByID(roomID)
-	if room == nil {
+	room, puzzle := h.cachedRoomAndPuzzle(roomID)
+	if room == nil || puzzle == nil {
 		return
 	}
 
-	puzzle, _ := h.db.GetPuzzleByID(room.PuzzleID)
-	if puzzle == nil {
-		return
-	}
-
-	// Get the hub room for tracking finish order
-	h.mutex.RLock()
-	hubRoom := h.rooms[roomID]
-	h.mutex.RUnlock()
-
-	if hubRoom == nil {
-		return
-	}
-
-	// Count total cells in puzzle
-	totalCells := 0
-	for y := range puzzle.Grid {
-		for x := range puzzle.Grid[y] {
-			if puzzle.Grid[y][x].Letter != nil {
-				totalCells++
-			}
-		}
-	}
-
 	// Build leaderboard from all players
-	players, _ := h.db.GetRoomPlayers(roomID)
+	players := h.cachedPlayers(roomID)
+	hintUsage := h.roomHintUsage(roomID)
 	var leaderboard []models.RaceProgress
 
 	allFinished := true
@@ -1129,7 +1708,7 @@ func (h *Hub) checkRaceProgress(roomID string, userID string) {
 		}
 		activePlayers++
 
-		gridState, _ := h.db.GetPlayerGridState(roomID, player.UserID)
+		gridState := h.playerGridState(roomID, player.UserID)
 		if gridState == nil {
 			leaderboard = append(leaderboard, models.RaceProgress{
 				UserID:      player.UserID,
@@ -1141,55 +1720,34 @@ func (h *Hub) checkRaceProgress(roomID string, userID string) {
 		}
 
 		// Calculate progress
-		correctCells := 0
-		complete := true
-		for y := range puzzle.Grid {
-			for x := range puzzle.Grid[y] {
-				expectedLetter := puzzle.Grid[y][x].Letter
-				if expectedLetter == nil {
-					continue
-				}
-				if y < len(gridState.Cells) && x < len(gridState.Cells[y]) {
-					currentValue := gridState.Cells[y][x].Value
-					if currentValue != nil && *currentValue == *expectedLetter {
-						correctCells++
-					} else {
-						complete = false
-					}
-				} else {
-					complete = false
-				}
-			}
-		}
+		correctCells, totalCells := countCorrectCells(puzzle, gridState)
+		complete := correctCells == totalCells
 
 		progress := float64(correctCells) / float64(totalCells) * 100
 
+		usage := hintUsage[player.UserID]
 		rp := models.RaceProgress{
 			UserID:      player.UserID,
 			DisplayName: player.DisplayName,
 			Progress:    progress,
+			HintsUsed:   usage.Reveals + usage.Checks,
+			Penalty:     hintPenalty(room.Config, usage),
 		}
 
-		// Check if player just finished
+		// Check if player just finished; a player with a hint penalty finishes once they have served it
+		solveTime, served := 0, false
 		if complete {
-			hubRoom.mutex.Lock()
-			alreadyFinished := false
-			for _, uid := range hubRoom.FinishOrder {
-				if uid == player.UserID {
-					alreadyFinished = true
-					break
-				}
-			}
-			if !alreadyFinished {
-				hubRoom.FinishOrder = append(hubRoom.FinishOrder, player.UserID)
+			solveTime, served = h.servePenalty(roomID, player.UserID, rp.Penalty)
+		} else if rp.Penalty > 0 {
+			h.abandonPenalty(roomID, player.UserID)
+		}
+		if served {
+			// The finish order is shared, so a player finishing on another instance can't take the same rank
+			if rank, ok := h.recordFinish(roomID, player.UserID); ok {
 				now := time.Now()
 				rp.FinishedAt = &now
-				solveTime := 0
-				if hubRoom.StartTime != nil {
-					solveTime = int(time.Since(*hubRoom.StartTime).Seconds())
-				}
 				rp.SolveTime = &solveTime
-				rp.Rank = len(hubRoom.FinishOrder)
+				rp.Rank = rank
 
 				// Broadcast that player finished
 				h.broadcastToRoom(roomID, "", MsgPlayerFinished, PlayerFinishedPayload{
@@ -1197,13 +1755,13 @@ func (h *Hub) checkRaceProgress(roomID string, userID string) {
 					DisplayName: player.DisplayName,
 					SolveTime:   solveTime,
 					Rank:        rp.Rank,
+					Penalty:     rp.Penalty,
 				})
 
 				// Update user stats and create puzzle history
 				// For race mode, track multiplayer wins (rank 1 = winner)
-				h.updateUserStatsAfterCompletion(player.UserID, room.PuzzleID, &roomID, solveTime, rp.Rank)
+				h.updateUserStatsAfterCompletion(player.UserID, room.PuzzleID, &roomID, solveTime, rp.Rank, gridState)
 			}
-			hubRoom.mutex.Unlock()
 		} else {
 			allFinished = false
 		}
@@ -1217,24 +1775,26 @@ func (h *Hub) checkRaceProgress(roomID string, userID string) {
 	})
 
 	// Check if race is complete
-	if allFinished && activePlayers > 0 {
-		h.db.UpdateRoomState(roomID, models.RoomStateCompleted)
+	if allFinished && activePlayers > 0 && h.stopClock(roomID, time.Now()) {
+		h.setRoomState(roomID, models.RoomStateCompleted)
+		h.flushRoomGrids(roomID)
 
 		// Build final results
+		finishOrder := h.finishOrder(roomID)
 		var results []PlayerResult
-		for i, uid := range hubRoom.FinishOrder {
+		for i, uid := range finishOrder {
 			player := findPlayer(players, uid)
 			if player != nil {
 				results = append(results, PlayerResult{
 					UserID:       uid,
 					DisplayName:  player.DisplayName,
-					Contribution: float64(len(hubRoom.FinishOrder) - i), // Higher rank = more contribution
+					Contribution: float64(len(finishOrder) - i), // Higher rank = more contribution
 					Color:        player.Color,
 				})
 			}
 		}
 
-		h.broadcastToRoom(roomID, "", MsgPuzzleCompleted, PuzzleCompletedPayload{
+		h.completePuzzle(room, players, PuzzleCompletedPayload{
 			SolveTime:   0, // Race mode doesn't have a single solve time
 			Players:     results,
 			CompletedAt: time.Now(),
@@ -1242,163 +1802,10 @@ func (h *Hub) checkRaceProgress(roomID string, userID string) {
 	}
 }
 
-func (h *Hub) handlePassTurn(client *Client) {
-	if client.RoomID == "" {
-		return
-	}
-
-	room, _ := h.db.GetRoomByID(client.RoomID)
-	if room == nil || room.Mode != models.RoomModeRelay {
-		return
-	}
-
-	relayState, _ := h.db.GetRelayState(client.RoomID)
-	if relayState == nil || relayState.CurrentPlayerID != client.UserID {
-		h.sendError(client, "not your turn")
-		return
-	}
-
-	// Find next player
-	currentIndex := -1
-	for i, uid := range relayState.TurnOrder {
-		if uid == client.UserID {
-			currentIndex = i
-			break
-		}
-	}
-
-	if currentIndex == -1 {
-		return
-	}
-
-	nextIndex := (currentIndex + 1) % len(relayState.TurnOrder)
-	nextPlayerID := relayState.TurnOrder[nextIndex]
-
-	// Update relay state
-	relayState.CurrentPlayerID = nextPlayerID
-	relayState.TurnStartedAt = time.Now()
-	relayState.WordsThisTurn = 0
-	h.db.UpdateRelayState(relayState)
-
-	// Increment turn number
-	h.mutex.Lock()
-	hubRoom := h.rooms[client.RoomID]
-	if hubRoom != nil {
-		hubRoom.TurnNumber++
-	}
-	turnNum := 1
-	if hubRoom != nil {
-		turnNum = hubRoom.TurnNumber
-	}
-	h.mutex.Unlock()
-
-	// Get next player name
-	players, _ := h.db.GetRoomPlayers(client.RoomID)
-	nextPlayer := findPlayer(players, nextPlayerID)
-	playerName := "Unknown"
-	if nextPlayer != nil {
-		playerName = nextPlayer.DisplayName
-	}
-
-	// Broadcast turn change
-	h.broadcastToRoom(client.RoomID, "", MsgTurnChanged, TurnChangedPayload{
-		CurrentPlayerID:   nextPlayerID,
-		CurrentPlayerName: playerName,
-		TurnNumber:        turnNum,
-	})
-}
-
-func (h *Hub) checkRelayTurnTimeouts() {
-	ticker := time.NewTicker(5 * time.Second) // Check every 5 seconds
-	defer ticker.Stop()
-
-	for range ticker.C {
-		// Get all active relay rooms
-		h.mutex.RLock()
-		roomIDs := make([]string, 0, len(h.rooms))
-		for roomID, room := range h.rooms {
-			if room.Mode == models.RoomModeRelay {
-				roomIDs = append(roomIDs, roomID)
-			}
-		}
-		h.mutex.RUnlock()
-
-		// Check each relay room for turn timeout
-		for _, roomID := range roomIDs {
-			relayState, err := h.db.GetRelayState(roomID)
-			if err != nil || relayState == nil {
-				continue
-			}
-
-			// Skip if no time limit
-			if relayState.TurnTimeLimit == 0 {
-				continue
-			}
-
-			// Check if turn has timed out
-			elapsed := time.Since(relayState.TurnStartedAt)
-			if elapsed.Seconds() >= float64(relayState.TurnTimeLimit) {
-				// Auto-advance to next turn
-				h.advanceTurn(roomID, relayState)
-			}
-		}
-	}
-}
-
-func (h *Hub) advanceTurn(roomID string, relayState *models.RelayState) {
-	// Find next player
-	currentIndex := -1
-	for i, uid := range relayState.TurnOrder {
-		if uid == relayState.CurrentPlayerID {
-			currentIndex = i
-			break
-		}
-	}
-
-	if currentIndex == -1 {
-		return
-	}
-
-	nextIndex := (currentIndex + 1) % len(relayState.TurnOrder)
-	nextPlayerID := relayState.TurnOrder[nextIndex]
-
-	// Update relay state
-	relayState.CurrentPlayerID = nextPlayerID
-	relayState.TurnStartedAt = time.Now()
-	relayState.WordsThisTurn = 0
-	h.db.UpdateRelayState(relayState)
-
-	// Increment turn number
-	h.mutex.Lock()
-	hubRoom := h.rooms[roomID]
-	if hubRoom != nil {
-		hubRoom.TurnNumber++
-	}
-	turnNum := 1
-	if hubRoom != nil {
-		turnNum = hubRoom.TurnNumber
-	}
-	h.mutex.Unlock()
-
-	// Get next player name
-	players, _ := h.db.GetRoomPlayers(roomID)
-	nextPlayer := findPlayer(players, nextPlayerID)
-	playerName := "Unknown"
-	if nextPlayer != nil {
-		playerName = nextPlayer.DisplayName
-	}
-
-	// Broadcast turn change
-	h.broadcastToRoom(roomID, "", MsgTurnChanged, TurnChangedPayload{
-		CurrentPlayerID:   nextPlayerID,
-		CurrentPlayerName: playerName,
-		TurnNumber:        turnNum,
-	})
-
-	log.Printf("Relay turn timeout: room %s advanced to player %s (turn %d)", roomID, nextPlayerID, turnNum)
-}
-
 func (h *Hub) removeClientFromRoom(client *Client) {
+	// A cursor move still waiting for the tick is never shown
+	h.cursors.drop(client.ConnectionID)
+
 	h.mutex.Lock()
 	hubRoom, exists := h.rooms[client.RoomID]
 	h.mutex.Unlock()
@@ -1420,6 +1827,7 @@ func (h *Hub) removeClientFromRoom(client *Client) {
 	delete(hubRoom.Clients, client.ConnectionID)
 	isEmpty := len(hubRoom.Clients) == 0
 	hubRoom.mutex.Unlock()
+	h.untrackRoomConnection(client, roomID)
 
 	// Check if user has any other active connections in this room.
 	hasOtherConnections := false
@@ -1441,6 +1849,7 @@ func (h *Hub) removeClientFromRoom(client *Client) {
 	// Only update connection status if no other tabs are connected
 	if !hasOtherConnections {
 		h.db.UpdatePlayerConnection(client.UserID, roomID, false)
+		h.invalidatePlayers(roomID)
 
 		// Get player for notification
 		players, _ := h.db.GetRoomPlayers(roomID)
@@ -1453,30 +1862,33 @@ func (h *Hub) removeClientFromRoom(client *Client) {
 		// Check if the leaving user is the host
 		isHost := room != nil && room.HostID == client.UserID
 
-		if isHost {
-			// Host websocket disconnected. Delay deletion briefly to allow reconnect/rejoin.
-			log.Printf("removeClientFromRoom: host disconnected, scheduling deletion check for room %s", roomID)
-			go h.deleteRoomIfHostStillDisconnected(roomID, client.UserID)
-		} else {
-			// Regular player leaving - just notify others
-			h.broadcastToRoom(roomID, client.ConnectionID, MsgPlayerLeft, PlayerLeftPayload{
-				UserID:      client.UserID,
-				DisplayName: displayName,
-			})
+		h.broadcastToRoom(roomID, client.ConnectionID, MsgPlayerLeft, PlayerLeftPayload{
+			UserID:      client.UserID,
+			DisplayName: displayName,
+		})
 
-			// Clean up empty room
-			if isEmpty {
-				h.mutex.Lock()
-				delete(h.rooms, roomID)
-				h.mutex.Unlock()
-			}
+		if isHost {
+			// Host websocket disconnected. Give them a moment to reconnect before handing the room on.
+			log.Printf("removeClientFromRoom: host disconnected, scheduling host migration check for room %s", roomID)
+			go h.migrateHostIfStillDisconnected(roomID, client.UserID)
 		}
 	}
 
+	// Clean up the room once this instance has no clients in it, even if the
+	// player may still be connected elsewhere: a returning connection recreates it
+	if isEmpty {
+		h.releaseLocalRoom(roomID)
+	}
+
 	client.RoomID = ""
 }
 
 func (h *Hub) hasPotentialRoomConnection(userID, roomID, excludeConnectionID string) bool {
+	// The replacement connection may be on another instance
+	if connected, err := h.clusterHasConnection(userID, roomID, excludeConnectionID); err == nil {
+		return connected
+	}
+
 	h.mutex.RLock()
 	connIDs := append([]string(nil), h.userConnections[userID]...)
 	h.mutex.RUnlock()
@@ -1502,7 +1914,10 @@ func (h *Hub) hasPotentialRoomConnection(userID, roomID, excludeConnectionID str
 	return false
 }
 
-func (h *Hub) deleteRoomIfHostStillDisconnected(roomID, hostUserID string) {
+// migrateHostIfStillDisconnected hands the host role to the player who has
+// been in the room longest once the host has been gone for
+// hostDisconnectGracePeriod. The room is only deleted if no other player is connected.
+func (h *Hub) migrateHostIfStillDisconnected(roomID, hostUserID string) {
 	time.Sleep(hostDisconnectGracePeriod)
 
 	if h.hasPotentialRoomConnection(hostUserID, roomID, "") {
@@ -1518,30 +1933,65 @@ func (h *Hub) deleteRoomIfHostStillDisconnected(roomID, hostUserID string) {
 	}
 
 	players, err := h.db.GetRoomPlayers(roomID)
-	if err == nil {
-		for _, p := range players {
-			if p.UserID == hostUserID && p.IsConnected {
-				return
-			}
+	if err != nil {
+		return
+	}
+	for _, p := range players {
+		if p.UserID == hostUserID && p.IsConnected {
+			return
 		}
 	}
 
-	log.Printf("deleteRoomIfHostStillDisconnected: deleting room %s after host disconnect grace period", roomID)
+	// Every instance with clients in the room runs this check; only one acts on it
+	if !h.claimHostChange(roomID, hostUserID) {
+		return
+	}
+
+	if next := nextHost(players, hostUserID); next != nil {
+		log.Printf("migrateHostIfStillDisconnected: host of room %s left, handing it to %s", roomID, next.UserID)
+		h.changeHost(roomID, next, "host_left")
+		return
+	}
+
+	log.Printf("migrateHostIfStillDisconnected: deleting room %s, no players left after host disconnect grace period", roomID)
 
 	h.broadcastToRoom(roomID, "", MsgRoomDeleted, RoomDeletedPayload{
 		Reason: "host_left",
 	})
 
 	if err := h.db.DeleteRoom(roomID); err != nil {
-		log.Printf("deleteRoomIfHostStillDisconnected: failed to delete room: %v", err)
+		log.Printf("migrateHostIfStillDisconnected: failed to delete room: %v", err)
 	}
+	h.NotifyRoomChanged(roomID)
+
+	h.publish(clusterEnvelope{Kind: envelopeRoomClosed, RoomID: roomID})
+	h.clearRoomState(roomID)
+	h.closeLocalRoom(roomID)
+}
 
+// releaseLocalRoom forgets a room that no longer has clients on this
+// instance, saving its grids first
+func (h *Hub) releaseLocalRoom(roomID string) {
+	h.mutex.Lock()
+	hubRoom := h.rooms[roomID]
+	delete(h.rooms, roomID)
+	h.mutex.Unlock()
+	hubRoom.stopSpectatorFeed()
+	h.releaseRoomGrids(roomID)
+	h.forgetRoom(roomID)
+}
+
+// closeLocalRoom forgets a deleted room and detaches this instance's clients from it
+func (h *Hub) closeLocalRoom(roomID string) {
 	h.mutex.Lock()
 	hubRoom, exists := h.rooms[roomID]
 	if exists {
 		delete(h.rooms, roomID)
 	}
 	h.mutex.Unlock()
+	hubRoom.stopSpectatorFeed()
+	h.dropRoomGrids(roomID)
+	h.forgetRoom(roomID)
 
 	if exists {
 		hubRoom.mutex.RLock()
@@ -1568,24 +2018,13 @@ func (h *Hub) sendToClient(client *Client, msgType MessageType, payload interfac
 		return
 	}
 
-	select {
-	case client.Send <- msgData:
-	default:
-		// Channel full, skip message
-	}
+	client.trySend(newOutgoing(msgData)) // skipped if the channel is full
 }
 
+// broadcastToRoom sends a message to the room's clients on this instance and
+// publishes it to the other instances
 func (h *Hub) broadcastToRoom(roomID string, excludeConnectionID string, msgType MessageType, payload interface{}) {
 	log.Printf("broadcastToRoom: type=%s, roomID=%s, excludeConnectionID=%s", msgType, roomID, excludeConnectionID)
-	h.mutex.RLock()
-	hubRoom, exists := h.rooms[roomID]
-	h.mutex.RUnlock()
-
-	if !exists {
-		log.Printf("broadcastToRoom: room %s does not exist", roomID)
-		return
-	}
-
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return
@@ -1594,6 +2033,7 @@ func (h *Hub) broadcastToRoom(roomID string, excludeConnectionID string, msgType
 	msg := Message{
 		Type:    msgType,
 		Payload: data,
+		Seq:     h.nextSeq(roomID),
 	}
 
 	msgData, err := json.Marshal(msg)
@@ -1601,16 +2041,39 @@ func (h *Hub) broadcastToRoom(roomID string, excludeConnectionID string, msgType
 		return
 	}
 
+	h.bufferAndPublish(roomID, msgData, clusterEnvelope{
+		Kind:    envelopeBroadcast,
+		RoomID:  roomID,
+		Exclude: excludeConnectionID,
+		Data:    msgData,
+	})
+	h.deliverToRoom(roomID, excludeConnectionID, msgData)
+}
+
+// deliverToRoom sends an encoded message to the room's clients on this instance
+func (h *Hub) deliverToRoom(roomID string, excludeConnectionID string, msgData []byte) {
+	h.mutex.RLock()
+	hubRoom, exists := h.rooms[roomID]
+	h.mutex.RUnlock()
+
+	if !exists {
+		log.Printf("broadcastToRoom: room %s has no clients on this instance", roomID)
+		return
+	}
+
 	hubRoom.mutex.RLock()
 	clientCount := len(hubRoom.Clients)
 	log.Printf("broadcastToRoom: sending to %d clients in room", clientCount)
+	msg := newOutgoing(msgData) // encoded once for all compact clients
+	delayed := false
 	for connectionID, client := range hubRoom.Clients {
-		if connectionID != excludeConnectionID {
+		if connectionID != excludeConnectionID && hubRoom.delaysClient(client) {
+			delayed = true
+		} else if connectionID != excludeConnectionID {
 			log.Printf("broadcastToRoom: sending to client connectionID=%s, userID=%s", connectionID, client.UserID)
-			select {
-			case client.Send <- msgData:
+			if client.trySend(msg) {
 				log.Printf("broadcastToRoom: sent to client connectionID=%s", connectionID)
-			default:
+			} else {
 				log.Printf("broadcastToRoom: channel full for client connectionID=%s", connectionID)
 			}
 		} else {
@@ -1618,9 +2081,72 @@ func (h *Hub) broadcastToRoom(roomID string, excludeConnectionID string, msgType
 		}
 	}
 	hubRoom.mutex.RUnlock()
+	if delayed {
+		hubRoom.feed.push(spectatorMessage{exclude: excludeConnectionID, data: msgData})
+	}
 	log.Printf("broadcastToRoom: complete")
 }
 
+// sendToUsers sends a message to the connections of some of a room's users on
+// every instance. It is used for messages only part of the room may see, so
+// the message gets no seq and is not buffered for resume.
+func (h *Hub) sendToUsers(roomID string, excludeConnectionID string, userIDs []string, msgType MessageType, payload interface{}) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	msgData, err := json.Marshal(Message{Type: msgType, Payload: data})
+	if err != nil {
+		return
+	}
+
+	h.publish(clusterEnvelope{
+		Kind:    envelopeBroadcast,
+		RoomID:  roomID,
+		Exclude: excludeConnectionID,
+		Users:   userIDs,
+		Data:    msgData,
+	})
+	h.deliverToUsers(roomID, excludeConnectionID, userIDs, msgData)
+}
+
+// deliverToUsers sends an encoded message to the given users' clients in the room on this instance
+func (h *Hub) deliverToUsers(roomID string, excludeConnectionID string, userIDs []string, msgData []byte) {
+	h.mutex.RLock()
+	hubRoom, exists := h.rooms[roomID]
+	h.mutex.RUnlock()
+	if !exists {
+		return
+	}
+
+	recipients := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		recipients[userID] = true
+	}
+
+	hubRoom.mutex.RLock()
+	defer hubRoom.mutex.RUnlock()
+	msg := newOutgoing(msgData)
+	delayed := false
+	for connectionID, client := range hubRoom.Clients {
+		if connectionID == excludeConnectionID || !recipients[client.UserID] {
+			continue
+		}
+		if hubRoom.delaysClient(client) {
+			delayed = true
+			continue
+		}
+		client.trySend(msg) // skipped if the channel is full
+	}
+	if delayed {
+		hubRoom.feed.push(spectatorMessage{exclude: excludeConnectionID, users: recipients, data: msgData})
+	}
+}
+
 func (h *Hub) sendError(client *Client, message string) {
 	h.sendToClient(client, MsgError, ErrorPayload{Message: message})
 }
@@ -1672,15 +2198,15 @@ func (h *Hub) isClueComplete(clue models.Clue, gridState *models.GridState) bool
 			return false
 		}
 
-		cell := gridState.Cells[y][x]
-		if cell.Value == nil {
+		answer := cellAnswer(gridState.Cells[y][x])
+		if answer == nil {
 			return false
 		}
 
 		// Compare with expected answer (case insensitive)
 		if i < len(clue.Answer) {
 			expected := string(clue.Answer[i])
-			actual := *cell.Value
+			actual := *answer
 			if len(actual) > 0 && len(expected) > 0 {
 				if actual[0] != expected[0] && actual[0] != expected[0]+32 && actual[0] != expected[0]-32 {
 					return false
@@ -1727,7 +2253,8 @@ func sanitizePuzzleForClient(puzzle *models.Puzzle) *models.Puzzle {
 
 // updateUserStatsAfterCompletion updates user stats and creates puzzle history after completing a puzzle
 // rank: 0 = collaborative/relay (no ranking), 1 = first place (winner), 2+ = other ranks
-func (h *Hub) updateUserStatsAfterCompletion(userID string, puzzleID string, roomID *string, solveTime int, rank int) {
+// gridState is the grid the user finished, for the accuracy
+func (h *Hub) updateUserStatsAfterCompletion(userID string, puzzleID string, roomID *string, solveTime int, rank int, gridState *models.GridState) {
 	// Get current user stats
 	stats, err := h.db.GetUserStats(userID)
 	if err != nil {
@@ -1790,6 +2317,13 @@ func (h *Hub) updateUserStatsAfterCompletion(userID string, puzzleID string, roo
 	now := time.Now()
 	stats.LastPlayedAt = &now
 
+	// Hints count against the record: hintsUsed is the user's own reveals and
+	// checks, accuracy the share of the grid's letters nobody revealed
+	var usage hintUsage
+	if roomID != nil {
+		usage = h.roomHintUsage(*roomID)[userID]
+	}
+
 	// Save updated stats
 	if err := h.db.UpdateUserStats(stats); err != nil {
 		log.Printf("Error updating user stats for %s: %v", userID, err)
@@ -1803,8 +2337,8 @@ func (h *Hub) updateUserStatsAfterCompletion(userID string, puzzleID string, roo
 		RoomID:      roomID,
 		SolveTime:   solveTime,
 		Completed:   true,
-		Accuracy:    100.0,
-		HintsUsed:   0,
+		Accuracy:    revealAccuracy(puzzle, gridState),
+		HintsUsed:   usage.Reveals + usage.Checks,
 		CompletedAt: &now,
 		CreatedAt:   now,
 	}
@@ -26,7 +26,7 @@ func TestMultiTabSupport(t *testing.T) {
 	}
 
 	// Register both clients in goroutine (since Register is blocking)
-	go hub.Run()
+	runHub(t, hub)
 
 	hub.Register(client1)
 	hub.Register(client2)
@@ -47,6 +47,7 @@ func TestMultiTabSupport(t *testing.T) {
 		if c1Exists && c2Exists {
 			break
 		}
+		time.Sleep(1 * time.Millisecond)
 	}
 
 	// Verify both clients are stored
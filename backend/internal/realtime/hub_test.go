@@ -11,10 +11,14 @@ func TestMessageTypes(t *testing.T) {
 	// Verify all message types are distinct
 	types := []MessageType{
 		MsgJoinRoom, MsgLeaveRoom, MsgCellUpdate, MsgCursorMove,
-		MsgSendMessage, MsgRequestHint, MsgStartGame, MsgReaction, MsgPassTurn,
+		MsgSendMessage, MsgRequestHint, MsgStartGame, MsgReaction, MsgPassTurn, MsgResume,
 		MsgRoomState, MsgPlayerJoined, MsgPlayerLeft, MsgCellUpdated,
 		MsgCursorMoved, MsgNewMessage, MsgGameStarted, MsgPuzzleCompleted,
 		MsgError, MsgReactionAdded, MsgRaceProgress, MsgPlayerFinished, MsgTurnChanged,
+		MsgRoomDeleted, MsgResumed, MsgStartReplay, MsgReplayControl, MsgReplayStarted,
+		MsgReplayEvent, MsgReplayEnded, MsgSetTeam, MsgTeamChanged, MsgTeamProgress, MsgTeamFinished,
+		MsgKickPlayer, MsgBanPlayer, MsgTransferHost, MsgLockRoom, MsgMuteChat, MsgPlayerKicked,
+		MsgHostChanged, MsgRoomSettings, MsgPlayerMuted,
 	}
 
 	seen := make(map[MessageType]bool)
@@ -0,0 +1,298 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Every message a connection sends is checked before it is handled. Each
+// connection may send inboundBurst messages at once and one more every
+// inboundRefill after that (each frame of a compact message counts). Messages
+// over the limit are dropped, and the first dropped in a strike window earns
+// the client a slow_down. Messages that fail their type's validator are
+// dropped with an error. Both count as strikes: a connection that collects
+// disconnectStrikes within one window is closed.
+
+const (
+	inboundBurst      = 60
+	inboundRefill     = 50 * time.Millisecond
+	strikeWindow      = 10 * time.Second
+	disconnectStrikes = 100
+
+	maxPayloadSize    = 1024
+	maxGridPosition   = 64
+	maxRoomCodeLength = 16
+)
+
+// payloadLimits raises maxPayloadSize for messages that carry text
+var payloadLimits = map[MessageType]int{
+	MsgSendMessage: 4096,
+}
+
+// validators check the payload of each message type a client may send. A nil
+// validator means the type has nothing to check beyond its size.
+var validators = map[MessageType]func(payload json.RawMessage) string{
+	MsgJoinRoom:      validateJoinRoom,
+	MsgLeaveRoom:     nil,
+	MsgCellUpdate:    validateCellUpdate,
+	MsgCursorMove:    validateCursorMove,
+	MsgSendMessage:   nil,
+	MsgDeleteMessage: nil,
+	MsgTyping:        nil,
+	MsgRequestHint:   validateRequestHint,
+	MsgStartGame:     nil,
+	MsgReaction:      nil,
+	MsgPassTurn:      nil,
+	MsgVoteSkip:      nil,
+	MsgRematch:       nil,
+	MsgResume:        validateResume,
+	MsgPauseGame:     nil,
+	MsgResumeGame:    nil,
+	MsgStartReplay:   nil,
+	MsgReplayControl: nil,
+	MsgSetTeam:       nil,
+	MsgSetClueNote:   nil,
+	MsgUndo:          nil,
+	MsgRedo:          nil,
+	MsgKickPlayer:    nil,
+	MsgBanPlayer:     nil,
+	MsgTransferHost:  nil,
+	MsgLockRoom:      nil,
+	MsgMuteChat:      nil,
+	MsgWatchLobby:    nil,
+	MsgUnwatchLobby:  nil,
+}
+
+type SlowDownPayload struct {
+	RetryAfterMs int64 `json:"retryAfterMs"` // time until the connection may send again
+}
+
+// verdict is what becomes of a message from a client
+type verdict int
+
+const (
+	verdictHandle verdict = iota
+	verdictDrop
+	verdictDisconnect
+)
+
+// inboundLimiter is a connection's rate limit and strike count. Only the
+// connection's read pump uses it.
+type inboundLimiter struct {
+	bucket      tokenBucket
+	strikes     int
+	warned      bool
+	windowStart time.Time
+}
+
+// strike counts a dropped message, starting a new window if the last one is over
+func (l *inboundLimiter) strike(now time.Time) int {
+	if now.Sub(l.windowStart) > strikeWindow {
+		l.windowStart = now
+		l.strikes = 0
+		l.warned = false
+	}
+	l.strikes++
+	return l.strikes
+}
+
+// inboundStats counts what became of client messages for the metrics endpoint
+type inboundStats struct {
+	mutex         sync.Mutex
+	received      int64
+	rateLimited   int64
+	invalid       int64
+	invalidByType map[MessageType]int64
+	warnings      int64
+	disconnects   int64
+}
+
+// admit checks a message against the connection's rate limit and its type's
+// validator, warning or disconnecting clients that keep sending bad ones
+func (h *Hub) admit(client *Client, msg *Message, now time.Time) verdict {
+	s := &h.inboundStats
+	limiter := &client.inbound
+	if limiter.bucket.last.IsZero() {
+		limiter.bucket = tokenBucket{tokens: inboundBurst, last: now}
+	}
+
+	if !limiter.bucket.take(now, inboundBurst, inboundRefill) {
+		strikes := limiter.strike(now)
+		warn := !limiter.warned && strikes < disconnectStrikes
+		limiter.warned = limiter.warned || warn
+
+		s.mutex.Lock()
+		s.received++
+		s.rateLimited++
+		if warn {
+			s.warnings++
+		}
+		s.mutex.Unlock()
+
+		if warn {
+			h.sendToClient(client, MsgSlowDown, SlowDownPayload{RetryAfterMs: inboundRefill.Milliseconds()})
+		}
+		return h.escalate(strikes)
+	}
+
+	problem := validateMessage(msg)
+
+	s.mutex.Lock()
+	s.received++
+	if problem != "" {
+		s.invalid++
+		if s.invalidByType == nil {
+			s.invalidByType = make(map[MessageType]int64)
+		}
+		if _, known := validators[msg.Type]; known {
+			s.invalidByType[msg.Type]++
+		} else {
+			s.invalidByType["unknown"]++ // not keyed by whatever the client sent
+		}
+	}
+	s.mutex.Unlock()
+
+	if problem == "" {
+		return verdictHandle
+	}
+	h.sendError(client, problem)
+	return h.escalate(limiter.strike(now))
+}
+
+// escalate closes connections that have collected too many strikes
+func (h *Hub) escalate(strikes int) verdict {
+	if strikes < disconnectStrikes {
+		return verdictDrop
+	}
+	h.inboundStats.mutex.Lock()
+	h.inboundStats.disconnects++
+	h.inboundStats.mutex.Unlock()
+	return verdictDisconnect
+}
+
+// validateMessage says what is wrong with a message from a client, if anything
+func validateMessage(msg *Message) string {
+	validate, known := validators[msg.Type]
+	if !known {
+		return fmt.Sprintf("unknown message type %q", msg.Type)
+	}
+	limit := maxPayloadSize
+	if l, ok := payloadLimits[msg.Type]; ok {
+		limit = l
+	}
+	if len(msg.Payload) > limit {
+		return "payload too large"
+	}
+	if validate == nil || len(msg.Payload) == 0 {
+		return ""
+	}
+	return validate(msg.Payload)
+}
+
+func validateJoinRoom(payload json.RawMessage) string {
+	var p JoinRoomPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return "invalid payload"
+	}
+	return validateRoomCode(p.RoomCode)
+}
+
+func validateResume(payload json.RawMessage) string {
+	var p ResumePayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return "invalid payload"
+	}
+	if p.LastSeq < 0 {
+		return "invalid sequence number"
+	}
+	return validateRoomCode(p.RoomCode)
+}
+
+func validateCellUpdate(payload json.RawMessage) string {
+	var p CellUpdatePayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return "invalid payload"
+	}
+	if problem := validatePosition(p.X, p.Y); problem != "" {
+		return problem
+	}
+	if p.Value != nil && !isCellValue(*p.Value) {
+		return "cell value must be a letter or a rebus"
+	}
+	if len(p.Candidates) > maxCandidates {
+		return "too many candidates"
+	}
+	return ""
+}
+
+func validateCursorMove(payload json.RawMessage) string {
+	var p CursorMovePayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return "invalid payload"
+	}
+	return validatePosition(p.X, p.Y)
+}
+
+func validateRequestHint(payload json.RawMessage) string {
+	var p RequestHintPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return "invalid payload"
+	}
+	return validatePosition(p.X, p.Y)
+}
+
+func validatePosition(x, y int) string {
+	if x < 0 || y < 0 || x >= maxGridPosition || y >= maxGridPosition {
+		return "position out of range"
+	}
+	return ""
+}
+
+// validateRoomCode allows short ASCII letters and digits. An empty code is
+// left for the handler to reject.
+func validateRoomCode(code string) string {
+	isCodeChar := func(r rune) bool { return r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) }
+	if len(code) > maxRoomCodeLength || strings.IndexFunc(code, func(r rune) bool { return !isCodeChar(r) }) >= 0 {
+		return "invalid room code"
+	}
+	return ""
+}
+
+// isCellValue reports whether value can be written in a cell: empty (clearing
+// it), a single letter, or a rebus of up to maxCandidateLength letters and
+// digits. Answers are stored as A-Z and 0-9, so anything else (lowercase,
+// accented letters) could never be correct.
+func isCellValue(value string) bool {
+	if value == "" {
+		return true
+	}
+	if len(value) > maxCandidateLength {
+		return false
+	}
+	return strings.IndexFunc(value, func(r rune) bool { return !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') }) < 0
+}
+
+// InboundMetrics returns counts of client messages handled, dropped for the
+// rate limit or failed validation, and of the warnings and disconnects that followed
+func (h *Hub) InboundMetrics() map[string]interface{} {
+	s := &h.inboundStats
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	invalidByType := make(map[string]int64, len(s.invalidByType))
+	for msgType, count := range s.invalidByType {
+		invalidByType[string(msgType)] = count
+	}
+	return map[string]interface{}{
+		"received":        s.received,
+		"rate_limited":    s.rateLimited,
+		"invalid":         s.invalid,
+		"invalid_by_type": invalidByType,
+		"warnings":        s.warnings,
+		"disconnects":     s.disconnects,
+	}
+}
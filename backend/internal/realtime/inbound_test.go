@@ -0,0 +1,125 @@
+package realtime
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		msgType MessageType
+		payload string
+		valid   bool
+	}{
+		{"letter", MsgCellUpdate, `{"x":1,"y":2,"value":"A"}`, true},
+		{"rebus", MsgCellUpdate, `{"x":1,"y":2,"value":"HEART"}`, true},
+		{"clear", MsgCellUpdate, `{"x":1,"y":2,"value":null}`, true},
+		{"candidates", MsgCellUpdate, `{"x":1,"y":2,"candidates":["A","B"]}`, true},
+		{"punctuation", MsgCellUpdate, `{"x":1,"y":2,"value":"<b>"}`, false},
+		{"lowercase letter", MsgCellUpdate, `{"x":1,"y":2,"value":"a"}`, false},
+		{"lowercase rebus", MsgCellUpdate, `{"x":1,"y":2,"value":"Heart"}`, false},
+		{"accented letter", MsgCellUpdate, `{"x":1,"y":2,"value":"é"}`, false},
+		{"sharp s", MsgCellUpdate, `{"x":1,"y":2,"value":"ß"}`, false},
+		{"digit", MsgCellUpdate, `{"x":1,"y":2,"value":"7"}`, true},
+		{"long rebus", MsgCellUpdate, `{"x":1,"y":2,"value":"ABCDEFGHIJ"}`, false},
+		{"negative cell", MsgCellUpdate, `{"x":-1,"y":2,"value":"A"}`, false},
+		{"not an object", MsgCellUpdate, `"A"`, false},
+		{"cursor", MsgCursorMove, `{"x":3,"y":4}`, true},
+		{"cursor off the grid", MsgCursorMove, `{"x":3,"y":4000}`, false},
+		{"hint", MsgRequestHint, `{"type":"reveal","x":0,"y":0}`, true},
+		{"negative hint", MsgRequestHint, `{"type":"reveal","x":0,"y":-5}`, false},
+		{"join", MsgJoinRoom, `{"roomCode":"ABC234"}`, true},
+		{"join with a bad code", MsgJoinRoom, `{"roomCode":"ABC 234"}`, false},
+		{"resume from before", MsgResume, `{"roomCode":"ABC234","lastSeq":-1}`, false},
+		{"no payload", MsgUndo, ``, true},
+		{"chat", MsgSendMessage, `{"text":"` + strings.Repeat("a", 2000) + `"}`, true},
+		{"oversized", MsgSetClueNote, `{"text":"` + strings.Repeat("a", 2000) + `"}`, false},
+		{"unknown type", "explode", `{}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := validateMessage(&Message{Type: tt.msgType, Payload: json.RawMessage(tt.payload)})
+			if (problem == "") != tt.valid {
+				t.Errorf("validateMessage(%s %s) = %q, want valid=%v", tt.msgType, tt.payload, problem, tt.valid)
+			}
+		})
+	}
+}
+
+func TestAdmitRateLimit(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	client := newTestClient("conn-1", "user-1")
+	move := &Message{Type: MsgCursorMove, Payload: json.RawMessage(`{"x":1,"y":1}`)}
+	now := time.Now()
+
+	for i := 0; i < inboundBurst; i++ {
+		if v := hub.admit(client, move, now); v != verdictHandle {
+			t.Fatalf("Message %d of a burst of %d got verdict %d", i+1, inboundBurst, v)
+		}
+	}
+
+	// Over the limit: dropped, with one warning
+	if v := hub.admit(client, move, now); v != verdictDrop {
+		t.Fatalf("A message beyond the burst got verdict %d, want it dropped", v)
+	}
+	if msg := receiveMessage(t, client, 100*time.Millisecond); msg == nil || msg.Type != MsgSlowDown {
+		t.Fatalf("Expected slow_down, got %+v", msg)
+	}
+	hub.admit(client, move, now)
+	if msg := receiveMessage(t, client, 10*time.Millisecond); msg != nil {
+		t.Errorf("Only the first dropped message should be warned about, got %s", msg.Type)
+	}
+
+	now = now.Add(inboundRefill)
+	if v := hub.admit(client, move, now); v != verdictHandle {
+		t.Errorf("A message should be handled once a token has refilled, got verdict %d", v)
+	}
+
+	// Keep flooding and the connection is closed
+	var v verdict
+	for i := 0; i < disconnectStrikes && v != verdictDisconnect; i++ {
+		v = hub.admit(client, move, now)
+	}
+	if v != verdictDisconnect {
+		t.Fatal("A client that keeps flooding should be disconnected")
+	}
+
+	metrics := hub.InboundMetrics()
+	if metrics["received"].(int64) != inboundBurst+1+disconnectStrikes || metrics["rate_limited"].(int64) != disconnectStrikes {
+		t.Errorf("Metrics = %v", metrics)
+	}
+	if metrics["warnings"].(int64) != 1 || metrics["disconnects"].(int64) != 1 {
+		t.Errorf("Want one warning and one disconnect, got %v", metrics)
+	}
+}
+
+func TestAdmitInvalid(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	client := newTestClient("conn-1", "user-1")
+	bad := &Message{Type: MsgCellUpdate, Payload: json.RawMessage(`{"x":0,"y":0,"value":"!!"}`)}
+	now := time.Now()
+
+	if v := hub.admit(client, bad, now); v != verdictDrop {
+		t.Fatalf("An invalid message got verdict %d, want it dropped", v)
+	}
+	if msg := receiveMessage(t, client, 100*time.Millisecond); msg == nil || msg.Type != MsgError {
+		t.Fatalf("Expected an error, got %+v", msg)
+	}
+	hub.admit(client, &Message{Type: "nonsense"}, now)
+
+	metrics := hub.InboundMetrics()
+	byType := metrics["invalid_by_type"].(map[string]int64)
+	if metrics["invalid"].(int64) != 2 || byType[string(MsgCellUpdate)] != 1 || byType["unknown"] != 1 {
+		t.Errorf("Metrics = %v", metrics)
+	}
+
+	// Strikes run out with the window
+	now = now.Add(strikeWindow + time.Second)
+	if strikes := client.inbound.strike(now); strikes != 1 {
+		t.Errorf("A new window should start counting again, got %d strikes", strikes)
+	}
+}
@@ -0,0 +1,119 @@
+package realtime
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+// Clients that aren't in a room can watch the lobby of public rooms. After
+// watch_lobby they get the current listing as lobby_rooms, then lobby_room
+// whenever a listed room is created or changes (players join, host changes)
+// and lobby_room_removed once it no longer matches their filter (it filled
+// up, started, was locked or closed). Room changes come from the REST
+// handlers and the hub through NotifyRoomChanged and reach the watchers on
+// every instance.
+
+const lobbyRoomsLimit = 50
+
+type lobbyWatcher struct {
+	client *Client
+	filter models.PublicRoomFilter
+}
+
+// NotifyRoomChanged sends a room's current lobby listing to every instance's
+// lobby watchers
+func (h *Hub) NotifyRoomChanged(roomID string) {
+	if h.db == nil {
+		return
+	}
+
+	room, err := h.db.GetPublicRoom(roomID)
+	if err != nil {
+		log.Printf("NotifyRoomChanged: failed to load room %s: %v", roomID, err)
+		return
+	}
+
+	var data []byte
+	if room != nil {
+		data, _ = json.Marshal(room)
+	}
+	h.publish(clusterEnvelope{Kind: envelopeLobby, RoomID: roomID, Data: data})
+	h.deliverLobbyUpdate(roomID, room)
+}
+
+func (h *Hub) handleWatchLobby(client *Client, payload json.RawMessage) {
+	var p WatchLobbyPayload
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			h.sendError(client, "invalid payload")
+			return
+		}
+	}
+
+	filter := models.PublicRoomFilter{Mode: p.Mode, Difficulty: p.Difficulty, MinSeats: 1}
+	if p.Seats != nil {
+		filter.MinSeats = *p.Seats
+	}
+
+	// Watch before listing: an update racing the listing is then either in
+	// the listing or delivered as well
+	h.lobbyMutex.Lock()
+	h.lobbyWatchers[client.ConnectionID] = &lobbyWatcher{client: client, filter: filter}
+	h.lobbyMutex.Unlock()
+
+	rooms, err := h.db.ListPublicRooms(filter, lobbyRoomsLimit)
+	if err != nil {
+		log.Printf("handleWatchLobby: failed to list rooms: %v", err)
+		h.sendError(client, "failed to load lobby")
+		return
+	}
+	h.sendToClient(client, MsgLobbyRooms, LobbyRoomsPayload{Rooms: rooms})
+}
+
+func (h *Hub) unwatchLobby(client *Client) {
+	h.lobbyMutex.Lock()
+	delete(h.lobbyWatchers, client.ConnectionID)
+	h.lobbyMutex.Unlock()
+}
+
+// applyRemoteLobbyUpdate delivers a listing published by another instance
+func (h *Hub) applyRemoteLobbyUpdate(roomID string, data []byte) {
+	var room *models.PublicRoom
+	if len(data) > 0 {
+		room = &models.PublicRoom{}
+		if err := json.Unmarshal(data, room); err != nil {
+			log.Printf("applyRemoteLobbyUpdate: invalid listing for room %s: %v", roomID, err)
+			return
+		}
+	}
+	h.deliverLobbyUpdate(roomID, room)
+}
+
+// deliverLobbyUpdate sends a room's listing, or its removal when room is nil,
+// to this instance's lobby watchers
+func (h *Hub) deliverLobbyUpdate(roomID string, room *models.PublicRoom) {
+	// Send under the lock so nothing reaches a client after unwatchLobby
+	h.lobbyMutex.Lock()
+	defer h.lobbyMutex.Unlock()
+	for _, w := range h.lobbyWatchers {
+		if room != nil && matchesLobbyFilter(room, w.filter) {
+			h.sendToClient(w.client, MsgLobbyRoom, LobbyRoomPayload{Room: *room})
+		} else {
+			h.sendToClient(w.client, MsgLobbyRoomRemoved, LobbyRoomRemovedPayload{RoomID: roomID})
+		}
+	}
+}
+
+// matchesLobbyFilter reports whether a listed room passes a watcher's filter,
+// as ListPublicRooms would
+func matchesLobbyFilter(room *models.PublicRoom, filter models.PublicRoomFilter) bool {
+	if filter.Mode != "" && room.Mode != filter.Mode {
+		return false
+	}
+	if filter.Difficulty != "" && room.Difficulty != filter.Difficulty {
+		return false
+	}
+	return room.OpenSeats >= filter.MinSeats
+}
@@ -0,0 +1,76 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+func TestMatchesLobbyFilter(t *testing.T) {
+	room := &models.PublicRoom{Mode: models.RoomModeRace, Difficulty: models.DifficultyEasy, OpenSeats: 2}
+
+	tests := []struct {
+		filter models.PublicRoomFilter
+		want   bool
+	}{
+		{models.PublicRoomFilter{}, true},
+		{models.PublicRoomFilter{Mode: models.RoomModeRace, Difficulty: models.DifficultyEasy, MinSeats: 2}, true},
+		{models.PublicRoomFilter{Mode: models.RoomModeRelay}, false},
+		{models.PublicRoomFilter{Difficulty: models.DifficultyHard}, false},
+		{models.PublicRoomFilter{MinSeats: 3}, false},
+	}
+	for _, tt := range tests {
+		if got := matchesLobbyFilter(room, tt.filter); got != tt.want {
+			t.Errorf("matchesLobbyFilter(%+v) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestLobbyUpdatesReachWatchersAcrossInstances(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := NewHubWithBroker(nil, broker)
+	hubB := NewHubWithBroker(nil, broker)
+	runHub(t, hubA)
+	runHub(t, hubB)
+
+	raceWatcher := newTestClient("conn-race", "user-1")
+	relayWatcher := newTestClient("conn-relay", "user-2")
+	hubB.lobbyWatchers[raceWatcher.ConnectionID] = &lobbyWatcher{
+		client: raceWatcher,
+		filter: models.PublicRoomFilter{Mode: models.RoomModeRace, MinSeats: 1},
+	}
+	hubB.lobbyWatchers[relayWatcher.ConnectionID] = &lobbyWatcher{
+		client: relayWatcher,
+		filter: models.PublicRoomFilter{Mode: models.RoomModeRelay, MinSeats: 1},
+	}
+
+	data, _ := json.Marshal(models.PublicRoom{ID: "room-1", Mode: models.RoomModeRace, Players: 1, MaxPlayers: 4, OpenSeats: 3})
+	hubA.publish(clusterEnvelope{Kind: envelopeLobby, RoomID: "room-1", Data: data})
+
+	msg := receiveMessage(t, raceWatcher, time.Second)
+	if msg == nil || msg.Type != MsgLobbyRoom {
+		t.Fatalf("Expected lobby_room, got %+v", msg)
+	}
+	var listed LobbyRoomPayload
+	json.Unmarshal(msg.Payload, &listed)
+	if listed.Room.ID != "room-1" || listed.Room.OpenSeats != 3 {
+		t.Errorf("Unexpected listing %+v", listed.Room)
+	}
+	if msg := receiveMessage(t, relayWatcher, time.Second); msg == nil || msg.Type != MsgLobbyRoomRemoved {
+		t.Errorf("Watcher filtering for relay rooms should get lobby_room_removed, got %+v", msg)
+	}
+
+	// The room started: it is no longer listed
+	hubA.publish(clusterEnvelope{Kind: envelopeLobby, RoomID: "room-1"})
+	msg = receiveMessage(t, raceWatcher, time.Second)
+	if msg == nil || msg.Type != MsgLobbyRoomRemoved {
+		t.Fatalf("Expected lobby_room_removed, got %+v", msg)
+	}
+	var removed LobbyRoomRemovedPayload
+	json.Unmarshal(msg.Payload, &removed)
+	if removed.RoomID != "room-1" {
+		t.Errorf("Removed room = %q, want room-1", removed.RoomID)
+	}
+}
@@ -0,0 +1,257 @@
+package realtime
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+// The host moderates the room: kicking or banning players, handing the host
+// role to another player, locking the room to new players and muting chat.
+// When the host disconnects the role passes to the player who has been in the
+// room longest (see migrateHostIfStillDisconnected).
+
+// accessStore finds the room a client asks to enter and says whether they are
+// banned from it; *db.Database implements it
+type accessStore interface {
+	GetRoomByCode(code string) (*models.Room, error)
+	IsBanned(roomID, userID string) (bool, error)
+}
+
+// hostRoom returns the client's room if the client is its host, and sends an
+// error otherwise
+func (h *Hub) hostRoom(client *Client, action string) *models.Room {
+	if client.RoomID == "" {
+		return nil
+	}
+
+	room, err := h.db.GetRoomByID(client.RoomID)
+	if err != nil || room == nil {
+		return nil
+	}
+	if room.HostID != client.UserID {
+		h.sendError(client, "only host can "+action)
+		return nil
+	}
+	return room
+}
+
+func (h *Hub) handleKickPlayer(client *Client, payload json.RawMessage) {
+	h.removePlayer(client, payload, false)
+}
+
+func (h *Hub) handleBanPlayer(client *Client, payload json.RawMessage) {
+	h.removePlayer(client, payload, true)
+}
+
+// removePlayer takes a player out of the room and disconnects their clients
+// from it on every instance. A banned player can't join again; a player who
+// isn't in the room can still be banned.
+func (h *Hub) removePlayer(client *Client, payload json.RawMessage, ban bool) {
+	var p ModeratePlayerPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		h.sendError(client, "invalid payload")
+		return
+	}
+
+	action := "kick players"
+	if ban {
+		action = "ban players"
+	}
+	room := h.hostRoom(client, action)
+	if room == nil {
+		return
+	}
+	if p.UserID == "" || p.UserID == client.UserID {
+		h.sendError(client, "invalid player")
+		return
+	}
+
+	players, _ := h.db.GetRoomPlayers(room.ID)
+	player := findPlayer(players, p.UserID)
+	if player == nil && !ban {
+		h.sendError(client, "player not found")
+		return
+	}
+
+	if ban {
+		if err := h.db.BanPlayer(room.ID, p.UserID, client.UserID); err != nil {
+			log.Printf("removePlayer: failed to ban %s from room %s: %v", p.UserID, room.ID, err)
+			h.sendError(client, "failed to ban player")
+			return
+		}
+	}
+	if player == nil {
+		return
+	}
+	if err := h.db.RemovePlayer(p.UserID, room.ID); err != nil {
+		log.Printf("removePlayer: failed to remove %s from room %s: %v", p.UserID, room.ID, err)
+		h.sendError(client, "failed to remove player")
+		return
+	}
+	h.invalidatePlayers(room.ID)
+	h.NotifyRoomChanged(room.ID)
+
+	// The kicked player gets this too, before being detached
+	h.broadcastToRoom(room.ID, "", MsgPlayerKicked, PlayerKickedPayload{
+		UserID:      p.UserID,
+		DisplayName: player.DisplayName,
+		Banned:      ban,
+	})
+
+	h.publish(clusterEnvelope{Kind: envelopeKick, RoomID: room.ID, Users: []string{p.UserID}})
+	h.detachUser(room.ID, p.UserID)
+}
+
+// detachUser takes a user's clients on this instance out of a room. The
+// connections stay open so the client can show why it was removed.
+func (h *Hub) detachUser(roomID, userID string) {
+	h.mutex.RLock()
+	hubRoom, exists := h.rooms[roomID]
+	h.mutex.RUnlock()
+	if !exists {
+		return
+	}
+
+	var detached []*Client
+	hubRoom.mutex.Lock()
+	for connectionID, c := range hubRoom.Clients {
+		if c.UserID == userID {
+			delete(hubRoom.Clients, connectionID)
+			detached = append(detached, c)
+		}
+	}
+	isEmpty := len(hubRoom.Clients) == 0
+	hubRoom.mutex.Unlock()
+
+	for _, c := range detached {
+		h.stopReplay(c)
+		h.untrackRoomConnection(c, roomID)
+		c.RoomID = ""
+	}
+	if len(detached) > 0 && isEmpty {
+		h.releaseLocalRoom(roomID)
+	}
+}
+
+func (h *Hub) handleTransferHost(client *Client, payload json.RawMessage) {
+	var p ModeratePlayerPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		h.sendError(client, "invalid payload")
+		return
+	}
+
+	room := h.hostRoom(client, "transfer host")
+	if room == nil {
+		return
+	}
+
+	players, _ := h.db.GetRoomPlayers(room.ID)
+	player := findPlayer(players, p.UserID)
+	if player == nil || player.UserID == client.UserID || player.IsSpectator {
+		h.sendError(client, "invalid player")
+		return
+	}
+
+	h.changeHost(room.ID, player, "transferred")
+}
+
+// changeHost makes a player the room's host and tells the room
+func (h *Hub) changeHost(roomID string, player *models.Player, reason string) {
+	if err := h.db.UpdateRoomHost(roomID, player.UserID); err != nil {
+		log.Printf("changeHost: failed to make %s host of room %s: %v", player.UserID, roomID, err)
+		return
+	}
+	h.invalidateRoom(roomID)
+	h.NotifyRoomChanged(roomID)
+
+	h.broadcastToRoom(roomID, "", MsgHostChanged, HostChangedPayload{
+		HostID:      player.UserID,
+		DisplayName: player.DisplayName,
+		Reason:      reason,
+	})
+}
+
+// nextHost returns the connected player who joined the room first, other than the old host
+func nextHost(players []models.Player, hostUserID string) *models.Player {
+	var next *models.Player
+	for i := range players {
+		p := &players[i]
+		if p.UserID == hostUserID || p.IsSpectator || !p.IsConnected {
+			continue
+		}
+		if next == nil || p.JoinedAt.Before(next.JoinedAt) {
+			next = p
+		}
+	}
+	return next
+}
+
+func (h *Hub) handleLockRoom(client *Client, payload json.RawMessage) {
+	var p LockRoomPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		h.sendError(client, "invalid payload")
+		return
+	}
+
+	room := h.hostRoom(client, "lock the room")
+	if room == nil {
+		return
+	}
+
+	room.Config.Locked = p.Locked
+	h.updateRoomConfig(client, room)
+}
+
+func (h *Hub) handleMuteChat(client *Client, payload json.RawMessage) {
+	var p MuteChatPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		h.sendError(client, "invalid payload")
+		return
+	}
+
+	room := h.hostRoom(client, "mute chat")
+	if room == nil {
+		return
+	}
+
+	if p.UserID == "" {
+		room.Config.ChatMuted = p.Muted
+		h.updateRoomConfig(client, room)
+		return
+	}
+
+	players, _ := h.db.GetRoomPlayers(room.ID)
+	player := findPlayer(players, p.UserID)
+	if player == nil || player.UserID == client.UserID {
+		h.sendError(client, "invalid player")
+		return
+	}
+	if err := h.db.UpdatePlayerMuted(p.UserID, room.ID, p.Muted); err != nil {
+		log.Printf("handleMuteChat: failed to mute %s in room %s: %v", p.UserID, room.ID, err)
+		h.sendError(client, "failed to mute player")
+		return
+	}
+
+	h.broadcastToRoom(room.ID, "", MsgPlayerMuted, PlayerMutedPayload{
+		UserID: p.UserID,
+		Muted:  p.Muted,
+	})
+}
+
+// updateRoomConfig saves a room's changed settings and tells the room
+func (h *Hub) updateRoomConfig(client *Client, room *models.Room) {
+	if err := h.db.UpdateRoomConfig(room.ID, room.Config); err != nil {
+		log.Printf("updateRoomConfig: failed to update room %s: %v", room.ID, err)
+		h.sendError(client, "failed to update room")
+		return
+	}
+	h.invalidateRoom(room.ID)
+	h.NotifyRoomChanged(room.ID)
+
+	h.broadcastToRoom(room.ID, "", MsgRoomSettings, RoomSettingsPayload{
+		Locked:    room.Config.Locked,
+		ChatMuted: room.Config.ChatMuted,
+	})
+}
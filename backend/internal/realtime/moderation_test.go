@@ -0,0 +1,138 @@
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+func TestNextHost(t *testing.T) {
+	now := time.Now()
+	players := []models.Player{
+		{UserID: "host", IsConnected: false, JoinedAt: now.Add(-time.Hour)},
+		{UserID: "early-spectator", IsSpectator: true, IsConnected: true, JoinedAt: now.Add(-50 * time.Minute)},
+		{UserID: "early-offline", IsConnected: false, JoinedAt: now.Add(-40 * time.Minute)},
+		{UserID: "late", IsConnected: true, JoinedAt: now.Add(-10 * time.Minute)},
+		{UserID: "longest", IsConnected: true, JoinedAt: now.Add(-30 * time.Minute)},
+	}
+
+	next := nextHost(players, "host")
+	if next == nil || next.UserID != "longest" {
+		t.Fatalf("nextHost = %v, want the connected player who joined first", next)
+	}
+
+	if next := nextHost(players[:3], "host"); next != nil {
+		t.Errorf("nextHost = %s, want nil when no other player is connected", next.UserID)
+	}
+}
+
+func TestKickDetachesClientsAcrossInstances(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := NewHubWithBroker(nil, broker)
+	hubB := NewHubWithBroker(nil, broker)
+	runHub(t, hubA)
+	runHub(t, hubB)
+
+	host := newTestClient("conn-host", "host")
+	kickedTab1 := newTestClient("conn-k1", "kicked")
+	kickedTab2 := newTestClient("conn-k2", "kicked")
+	other := newTestClient("conn-other", "other")
+	addTestRoom(hubA, "room-1", host, kickedTab1)
+	addTestRoom(hubB, "room-1", kickedTab2, other)
+
+	hubA.publish(clusterEnvelope{Kind: envelopeKick, RoomID: "room-1", Users: []string{"kicked"}})
+	hubA.detachUser("room-1", "kicked")
+
+	roomClients := func(hub *Hub) map[string]bool {
+		hub.mutex.RLock()
+		room := hub.rooms["room-1"]
+		hub.mutex.RUnlock()
+		ids := make(map[string]bool)
+		if room == nil {
+			return ids
+		}
+		room.mutex.RLock()
+		defer room.mutex.RUnlock()
+		for id := range room.Clients {
+			ids[id] = true
+		}
+		return ids
+	}
+
+	for i := 0; i < 200 && roomClients(hubB)["conn-k2"]; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if clients := roomClients(hubA); clients["conn-k1"] || !clients["conn-host"] {
+		t.Errorf("Instance A clients = %v, want only the host", clients)
+	}
+	if clients := roomClients(hubB); clients["conn-k2"] || !clients["conn-other"] {
+		t.Errorf("Instance B clients = %v, want only the other player", clients)
+	}
+
+	// Later room broadcasts no longer reach the kicked player
+	hubA.broadcastToRoom("room-1", "", MsgNewMessage, NewMessagePayload{Text: "bye"})
+	if msg := receiveMessage(t, other, time.Second); msg == nil {
+		t.Fatal("Remaining player should still get room messages")
+	}
+	for _, c := range []*Client{kickedTab1, kickedTab2} {
+		if msg := receiveMessage(t, c, 50*time.Millisecond); msg != nil {
+			t.Errorf("Kicked connection %s still got %s", c.ConnectionID, msg.Type)
+		}
+	}
+}
+
+// fakeAccessStore serves rooms by code and a fixed ban list
+type fakeAccessStore struct {
+	rooms  map[string]*models.Room
+	banned map[string]bool // userID -> banned from every room
+	err    error           // returned by IsBanned
+}
+
+func (s *fakeAccessStore) GetRoomByCode(code string) (*models.Room, error) {
+	return s.rooms[code], nil
+}
+
+func (s *fakeAccessStore) IsBanned(roomID, userID string) (bool, error) {
+	return s.banned[userID], s.err
+}
+
+func TestResumeRefusesBannedPlayer(t *testing.T) {
+	room := &models.Room{ID: "room-1", Code: "ABC234", Mode: models.RoomModeCollaborative, State: models.RoomStateActive}
+	tests := []struct {
+		name    string
+		store   *fakeAccessStore
+		wantErr string
+	}{
+		{"banned", &fakeAccessStore{banned: map[string]bool{"user-1": true}}, "you are banned from this room"},
+		{"ban list unreadable", &fakeAccessStore{err: errors.New("connection refused")}, "failed to join room"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHubWithBroker(nil, NewMemoryBroker())
+			tt.store.rooms = map[string]*models.Room{room.Code: room}
+			hub.accessStore = tt.store
+			client := newTestClient("conn-1", "user-1")
+
+			for _, msgType := range []MessageType{MsgResume, MsgJoinRoom} {
+				hub.HandleMessage(client, &Message{Type: msgType, Payload: json.RawMessage(`{"roomCode":"ABC234","lastSeq":3}`)})
+
+				msg := receiveMessage(t, client, time.Second)
+				var payload ErrorPayload
+				if msg != nil {
+					json.Unmarshal(msg.Payload, &payload)
+				}
+				if msg == nil || msg.Type != MsgError || payload.Message != tt.wantErr {
+					t.Errorf("%s: got %+v, want error %q", msgType, msg, tt.wantErr)
+				}
+				if client.RoomID != "" || len(hub.rooms) != 0 {
+					t.Errorf("%s: the client should not be attached to the room", msgType)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package realtime
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+// Solvers can mark up a grid without committing to answers. A cell_update with
+// pencil set writes a tentative entry, and one with candidates replaces the
+// letters a solver is considering for the cell; neither counts towards
+// completion or contribution until the letter is written in ink. Players
+// sharing a grid (the room in collaborative and relay mode, a team in team
+// mode) can also leave a sticky note on a clue with set_clue_note. Notes are
+// versioned like cell writes and saved with the grid.
+
+const (
+	maxCandidates      = 8
+	maxCandidateLength = 8 // rebus squares hold more than one letter
+	maxClueNoteLength  = 200
+)
+
+// normalizeCandidates upper-cases a cell's candidate letters and drops
+// duplicates. ok is false if there are too many or one isn't made of the
+// letters A-Z.
+func normalizeCandidates(candidates []string) (normalized []string, ok bool) {
+	if len(candidates) > maxCandidates {
+		return nil, false
+	}
+	normalized = make([]string, 0, len(candidates))
+	seen := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if c == "" || len(c) > maxCandidateLength || strings.IndexFunc(c, func(r rune) bool { return r < 'A' || r > 'Z' }) >= 0 {
+			return nil, false
+		}
+		if !seen[c] {
+			seen[c] = true
+			normalized = append(normalized, c)
+		}
+	}
+	return normalized, true
+}
+
+// hasClue reports whether clueID ("across-1", "down-5") names a clue of the puzzle
+func hasClue(puzzle *models.Puzzle, clueID string) bool {
+	clue, _ := clueByID(puzzle, clueID)
+	return clue != nil
+}
+
+// clueByID returns the clue clueID names and whether it runs down, or nil if
+// the puzzle has no such clue
+func clueByID(puzzle *models.Puzzle, clueID string) (clue *models.Clue, down bool) {
+	direction, number, found := strings.Cut(clueID, "-")
+	if !found {
+		return nil, false
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return nil, false
+	}
+
+	var clues []models.Clue
+	switch direction {
+	case "across":
+		clues = puzzle.CluesAcross
+	case "down":
+		clues = puzzle.CluesDown
+	}
+	for i := range clues {
+		if clues[i].Number == n {
+			return &clues[i], direction == "down"
+		}
+	}
+	return nil, false
+}
+
+// setClueNote merges a versioned note into the grid; an empty text clears the
+// note. It reports whether the write won.
+func (g *sharedGrid) setClueNote(clueID string, note models.ClueNote) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if current, ok := g.state.ClueNotes[clueID]; ok && note.Version <= current.Version {
+		return false
+	}
+	if note.Text == "" {
+		delete(g.state.ClueNotes, clueID)
+	} else {
+		if g.state.ClueNotes == nil {
+			g.state.ClueNotes = make(map[string]models.ClueNote)
+		}
+		g.state.ClueNotes[clueID] = note
+	}
+	g.markDirtyLocked(time.Now())
+	return true
+}
+
+// clueNoteVersion returns the version of the clue's note, 0 if there is none
+func (g *sharedGrid) clueNoteVersion(clueID string) int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.state.ClueNotes[clueID].Version
+}
+
+func (h *Hub) handleSetClueNote(client *Client, payload json.RawMessage) {
+	if client.RoomID == "" {
+		return
+	}
+
+	var p SetClueNotePayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		h.sendError(client, "invalid payload")
+		return
+	}
+	p.Text = strings.TrimSpace(p.Text)
+	if len([]rune(p.Text)) > maxClueNoteLength {
+		h.sendError(client, "note is too long")
+		return
+	}
+
+	room, puzzle := h.cachedRoomAndPuzzle(client.RoomID)
+	if room == nil || puzzle == nil || room.State != models.RoomStateActive {
+		return
+	}
+	if h.clockPaused(room.ID) {
+		h.sendError(client, "game is paused")
+		return
+	}
+	if !hasClue(puzzle, p.ClueID) {
+		h.sendError(client, "unknown clue")
+		return
+	}
+
+	// Notes are for the players sharing a grid: the room, or the player's team
+	var grid *sharedGrid
+	var teamID string
+	var members []string
+	var players []models.Player
+	switch room.Mode {
+	case models.RoomModeRace:
+		h.sendError(client, "clue notes need a shared grid")
+		return
+	case models.RoomModeTeam:
+		players, _ = h.db.GetRoomPlayers(client.RoomID)
+		teamID, members = playerTeam(players, client.UserID)
+		if teamID == "" {
+			h.sendError(client, "you are not on a team")
+			return
+		}
+		grid = h.teamGrid(client.RoomID, teamID)
+	default:
+		grid = h.roomGrid(client.RoomID)
+	}
+	if grid == nil {
+		return
+	}
+
+	field := "note:" + p.ClueID
+	if teamID != "" {
+		field = "note:" + teamID + ":" + p.ClueID
+	}
+	note := models.ClueNote{
+		Text:      p.Text,
+		UserID:    client.UserID,
+		Version:   h.nextGridVersion(client.RoomID, field, grid.clueNoteVersion(p.ClueID)),
+		UpdatedAt: time.Now(),
+	}
+	if !grid.setClueNote(p.ClueID, note) {
+		return
+	}
+
+	update := ClueNoteUpdatedPayload{
+		ClueID:    p.ClueID,
+		Text:      note.Text,
+		PlayerID:  note.UserID,
+		Version:   note.Version,
+		UpdatedAt: note.UpdatedAt,
+		TeamID:    teamID,
+	}
+	if teamID == "" {
+		h.broadcastToRoom(client.RoomID, "", MsgClueNoteUpdated, update)
+		return
+	}
+	h.sendToUsers(client.RoomID, "", withSpectators(members, players), MsgClueNoteUpdated, update)
+}
+
+// mergeRemoteClueNote applies a clue note set on another instance to this
+// instance's copy of the grid
+func (h *Hub) mergeRemoteClueNote(roomID string, msg *Message) {
+	var p ClueNoteUpdatedPayload
+	if err := json.Unmarshal(msg.Payload, &p); err != nil || p.Version == 0 {
+		return
+	}
+
+	owner := ""
+	if p.TeamID != "" {
+		owner = teamGridOwner(p.TeamID)
+	}
+	grid := h.loadedGrid(roomID, owner)
+	if grid == nil {
+		return
+	}
+	grid.setClueNote(p.ClueID, models.ClueNote{
+		Text:      p.Text,
+		UserID:    p.PlayerID,
+		Version:   p.Version,
+		UpdatedAt: p.UpdatedAt,
+	})
+}
@@ -0,0 +1,174 @@
+package realtime
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+func TestPencilEntriesDontCount(t *testing.T) {
+	a := "A"
+	puzzle := &models.Puzzle{Grid: [][]models.GridCell{{{Letter: &a}}}}
+	grid := &sharedGrid{state: newTestGridState("room-1", 1, 1)}
+
+	pencil := &CellUpdatePayload{Value: &a, Pencil: true}
+	prev, _ := grid.applyEdit(0, 0, playerCellEdit(pencil, "user-1"))
+	if isNewlyCorrect(puzzle, pencil, prev) {
+		t.Error("A pencilled letter shouldn't count as a contribution")
+	}
+	if correct, _ := countCorrectCells(puzzle, grid.snapshot()); correct != 0 {
+		t.Errorf("countCorrectCells = %d with only a pencilled letter, want 0", correct)
+	}
+
+	ink := &CellUpdatePayload{Value: &a}
+	edit := playerCellEdit(ink, "user-1")
+	edit.Version = 2
+	prev, _ = grid.applyEdit(0, 0, edit)
+	if !isNewlyCorrect(puzzle, ink, prev) {
+		t.Error("Inking over a pencilled letter should count as a contribution")
+	}
+	if correct, _ := countCorrectCells(puzzle, grid.snapshot()); correct != 1 {
+		t.Errorf("countCorrectCells = %d after inking, want 1", correct)
+	}
+}
+
+func TestCandidatesLeaveEntryAlone(t *testing.T) {
+	grid := &sharedGrid{state: newTestGridState("room-1", 1, 1)}
+	b := "B"
+
+	grid.applyEdit(0, 0, cellEdit{Value: &b, IsPencil: true, Version: 1})
+	grid.applyEdit(0, 0, cellEdit{Candidates: []string{"B", "D"}, Part: cellCandidates, Version: 2})
+	cell := grid.snapshot().Cells[0][0]
+	if cell.Value == nil || *cell.Value != "B" || !cell.IsPencil || !reflect.DeepEqual(cell.Candidates, []string{"B", "D"}) {
+		t.Errorf("After setting candidates the cell is %+v, want pencilled B with candidates [B D]", cell)
+	}
+
+	grid.applyEdit(0, 0, cellEdit{Value: &b, Version: 3})
+	if cell := grid.snapshot().Cells[0][0]; cell.IsPencil || len(cell.Candidates) != 2 {
+		t.Errorf("Inking should keep the candidates, got %+v", cell)
+	}
+
+	grid.applyEdit(0, 0, cellEdit{Value: &b, IsRevealed: true, Version: 4})
+	if cell := grid.snapshot().Cells[0][0]; cell.Candidates != nil {
+		t.Errorf("Revealing should drop the candidates, got %v", cell.Candidates)
+	}
+}
+
+func TestCandidatesConvergeAcrossInstances(t *testing.T) {
+	hubA := NewHubWithBroker(nil, NewMemoryBroker())
+	hubB := NewHubWithBroker(nil, NewMemoryBroker())
+	gridA := hubA.setRoomGrid("room-1", newTestGridState("room-1", 2, 2))
+	gridB := hubB.setRoomGrid("room-1", newTestGridState("room-1", 2, 2))
+
+	c := "C"
+	for _, p := range []*CellUpdatePayload{
+		{X: 1, Y: 0, Value: &c, Pencil: true},
+		{X: 1, Y: 0, Candidates: []string{"C", "K"}},
+	} {
+		_, _, cell, applied := hubA.writeCell(gridA, "room-1", p.X, p.Y, playerCellEdit(p, "user-1"))
+		if !applied {
+			t.Fatalf("Edit %+v should apply", p)
+		}
+		payload, _ := json.Marshal(cellUpdated(p.X, p.Y, cell))
+		hubB.mergeRemoteCellUpdate("room-1", &Message{Type: MsgCellUpdated, Payload: payload})
+	}
+
+	a, b := gridA.snapshot().Cells[0][1], gridB.snapshot().Cells[0][1]
+	if b.Value == nil || *b.Value != *a.Value || b.IsPencil != a.IsPencil || !reflect.DeepEqual(b.Candidates, a.Candidates) || b.Version != a.Version {
+		t.Errorf("Merged cell %+v doesn't match the original %+v", b, a)
+	}
+}
+
+func TestNormalizeCandidates(t *testing.T) {
+	got, ok := normalizeCandidates([]string{"a", " E ", "A", "ing"})
+	if !ok || !reflect.DeepEqual(got, []string{"A", "E", "ING"}) {
+		t.Errorf("normalizeCandidates = (%v, %v), want ([A E ING], true)", got, ok)
+	}
+	if got, ok := normalizeCandidates([]string{}); !ok || got == nil || len(got) != 0 {
+		t.Errorf("Empty candidates should stay an empty (clearing) list, got (%v, %v)", got, ok)
+	}
+	for _, bad := range [][]string{{"1"}, {""}, {"A B"}, {"é"}, {"ß"}, {"A", "B", "C", "D", "E", "F", "G", "H", "I"}} {
+		if _, ok := normalizeCandidates(bad); ok {
+			t.Errorf("normalizeCandidates(%q) should fail", bad)
+		}
+	}
+}
+
+func TestHasClue(t *testing.T) {
+	puzzle := &models.Puzzle{
+		CluesAcross: []models.Clue{{Number: 1}, {Number: 12}},
+		CluesDown:   []models.Clue{{Number: 2}},
+	}
+	for clueID, want := range map[string]bool{
+		"across-1": true, "across-12": true, "down-2": true,
+		"down-1": false, "across-3": false, "across": false, "sideways-1": false,
+	} {
+		if got := hasClue(puzzle, clueID); got != want {
+			t.Errorf("hasClue(%q) = %v, want %v", clueID, got, want)
+		}
+	}
+}
+
+func TestClueNotesMergeByVersion(t *testing.T) {
+	grid := &sharedGrid{state: newTestGridState("room-1", 1, 1)}
+
+	if !grid.setClueNote("across-1", models.ClueNote{Text: "maybe a river?", UserID: "user-1", Version: 2}) {
+		t.Fatal("First note should apply")
+	}
+	snapshot := grid.snapshot()
+	if grid.setClueNote("across-1", models.ClueNote{Text: "older", UserID: "user-2", Version: 1}) {
+		t.Error("Note with an older version should be rejected")
+	}
+	if grid.clueNoteVersion("across-1") != 2 {
+		t.Errorf("clueNoteVersion = %d, want 2", grid.clueNoteVersion("across-1"))
+	}
+
+	if !grid.setClueNote("across-1", models.ClueNote{UserID: "user-2", Version: 3}) {
+		t.Fatal("Clearing the note should apply")
+	}
+	if _, ok := grid.snapshot().ClueNotes["across-1"]; ok {
+		t.Error("An empty note should clear the clue's note")
+	}
+	if snapshot.ClueNotes["across-1"].Text != "maybe a river?" {
+		t.Error("Snapshots should not change when the grid's notes do")
+	}
+}
+
+func TestSetClueNote(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	client := newTestClient("conn-1", "user-1")
+	addTestRoom(hub, "room-1", client)
+	hub.setRoomGrid("room-1", newTestGridState("room-1", 3, 3))
+
+	room := &models.Room{ID: "room-1", Mode: models.RoomModeCollaborative, State: models.RoomStateActive}
+	puzzle := &models.Puzzle{CluesAcross: []models.Clue{{Number: 1}}}
+	hub.roomCache.entries["room-1"] = &cachedRoom{room: room, puzzle: puzzle, fetchedAt: time.Now()}
+
+	hub.HandleMessage(client, &Message{Type: MsgSetClueNote, Payload: json.RawMessage(`{"clueId":"across-1","text":" not TIBER "}`)})
+	msg := receiveMessage(t, client, time.Second)
+	if msg == nil || msg.Type != MsgClueNoteUpdated {
+		t.Fatalf("Expected clue_note_updated, got %+v", msg)
+	}
+	var update ClueNoteUpdatedPayload
+	json.Unmarshal(msg.Payload, &update)
+	if update.ClueID != "across-1" || update.Text != "not TIBER" || update.PlayerID != "user-1" || update.Version == 0 {
+		t.Errorf("Unexpected update %+v", update)
+	}
+	if note := hub.roomGridState("room-1").ClueNotes["across-1"]; note.Text != "not TIBER" {
+		t.Errorf("Grid note = %+v, want the new note", note)
+	}
+
+	hub.HandleMessage(client, &Message{Type: MsgSetClueNote, Payload: json.RawMessage(`{"clueId":"down-4","text":"x"}`)})
+	if msg := receiveMessage(t, client, time.Second); msg == nil || msg.Type != MsgError {
+		t.Errorf("Expected an error for an unknown clue, got %+v", msg)
+	}
+
+	room.Mode = models.RoomModeRace
+	hub.HandleMessage(client, &Message{Type: MsgSetClueNote, Payload: json.RawMessage(`{"clueId":"across-1","text":"x"}`)})
+	if msg := receiveMessage(t, client, time.Second); msg == nil || msg.Type != MsgError {
+		t.Errorf("Expected an error for a note in a race room, got %+v", msg)
+	}
+}
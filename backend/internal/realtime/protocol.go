@@ -0,0 +1,217 @@
+package realtime
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Clients speak JSON unless they ask otherwise: every WebSocket message is a
+// text message of one or more newline-separated Messages. A client that offers
+// the compact subprotocol in Sec-WebSocket-Protocol gets binary messages
+// instead, and may send them. A binary message holds one or more frames, each
+// prefixed with its length as a uvarint. A frame starts with its kind:
+//
+//	0 JSON    any Message as JSON; used for every message without a frame of its own
+//	1 cursor  cursor_move:  x, y
+//	          cursor_moved: seq, x, y, playerId, displayName, color
+//	2 cell    cell_update:  x, y, flags, value
+//	          cell_updated: seq, x, y, flags, value, playerId, color, version, teamId, origin
+//
+// Numbers are signed varints (as encoding/binary's AppendVarint) and strings
+// a uvarint length followed by UTF-8. Cell flags are 1 pencil, 2 revealed,
+// 4 checked and 8 correct (only with checked). An empty cell_update value
+// clears the cell. Cells with candidates are sent as JSON.
+const (
+	protocolJSON    = "crossplay.json.v1"
+	protocolCompact = "crossplay.compact.v1"
+)
+
+// Frame kinds of the compact protocol
+const (
+	frameJSON   byte = 0
+	frameCursor byte = 1
+	frameCell   byte = 2
+)
+
+// Cell frame flags
+const (
+	cellFlagPencil   = 1 << 0
+	cellFlagRevealed = 1 << 1
+	cellFlagChecked  = 1 << 2
+	cellFlagCorrect  = 1 << 3
+)
+
+var errBadFrame = errors.New("malformed frame")
+
+// Messages are marshaled with their type first (see Message), so the hot
+// message types can be picked out without decoding every message
+var (
+	cursorMovedPrefix = []byte(`{"type":"` + string(MsgCursorMoved) + `"`)
+	cellUpdatedPrefix = []byte(`{"type":"` + string(MsgCellUpdated) + `"`)
+)
+
+// outgoing is a JSON-encoded Message on its way to clients. Its compact frame
+// is built the first time a compact client needs it and shared by the rest,
+// so a broadcast is converted once however many compact clients it reaches.
+type outgoing struct {
+	data  []byte
+	once  sync.Once
+	frame []byte
+}
+
+func newOutgoing(data []byte) *outgoing {
+	return &outgoing{data: data}
+}
+
+// encodedFor returns the message as the client expects it on its Send channel
+func (m *outgoing) encodedFor(client *Client) []byte {
+	if !client.Compact {
+		return m.data
+	}
+	m.once.Do(func() { m.frame = compactFrame(m.data) })
+	return m.frame
+}
+
+// compactFrame turns a JSON-encoded Message into a compact frame
+func compactFrame(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, cursorMovedPrefix):
+		var msg Message
+		var p CursorMovedPayload
+		if json.Unmarshal(data, &msg) == nil && json.Unmarshal(msg.Payload, &p) == nil {
+			frame := []byte{frameCursor}
+			frame = binary.AppendVarint(frame, msg.Seq)
+			frame = binary.AppendVarint(frame, int64(p.X))
+			frame = binary.AppendVarint(frame, int64(p.Y))
+			frame = appendString(frame, p.PlayerID)
+			frame = appendString(frame, p.DisplayName)
+			return appendString(frame, p.Color)
+		}
+	case bytes.HasPrefix(data, cellUpdatedPrefix):
+		var msg Message
+		var p CellUpdatedPayload
+		if json.Unmarshal(data, &msg) == nil && json.Unmarshal(msg.Payload, &p) == nil && len(p.Candidates) == 0 {
+			var flags int64
+			if p.IsPencil {
+				flags |= cellFlagPencil
+			}
+			if p.IsRevealed {
+				flags |= cellFlagRevealed
+			}
+			if p.IsCorrect != nil {
+				flags |= cellFlagChecked
+				if *p.IsCorrect {
+					flags |= cellFlagCorrect
+				}
+			}
+			frame := []byte{frameCell}
+			frame = binary.AppendVarint(frame, msg.Seq)
+			frame = binary.AppendVarint(frame, int64(p.X))
+			frame = binary.AppendVarint(frame, int64(p.Y))
+			frame = binary.AppendVarint(frame, flags)
+			frame = appendString(frame, p.Value)
+			frame = appendString(frame, p.PlayerID)
+			frame = appendString(frame, p.Color)
+			frame = binary.AppendVarint(frame, p.Version)
+			frame = appendString(frame, p.TeamID)
+			return appendString(frame, p.Origin)
+		}
+	}
+	return append([]byte{frameJSON}, data...)
+}
+
+// appendFrame adds a length-prefixed frame to a compact message
+func appendFrame(b, frame []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(frame)))
+	return append(b, frame...)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// decodeCompact reads the messages of a binary message from a compact client
+func decodeCompact(data []byte) ([]Message, error) {
+	var msgs []Message
+	for len(data) > 0 {
+		n, read := binary.Uvarint(data)
+		if read <= 0 || n == 0 || n > uint64(len(data)-read) {
+			return nil, errBadFrame
+		}
+		frame := data[read : read+int(n)]
+		data = data[read+int(n):]
+
+		msg, err := decodeFrame(frame)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// decodeFrame turns a frame from a client into the Message its JSON would be
+func decodeFrame(frame []byte) (Message, error) {
+	r := frameReader{data: frame[1:]}
+
+	var msg Message
+	var payload interface{}
+	switch frame[0] {
+	case frameJSON:
+		err := json.Unmarshal(r.data, &msg)
+		return msg, err
+	case frameCursor:
+		msg.Type = MsgCursorMove
+		payload = CursorMovePayload{X: r.int(), Y: r.int()}
+	case frameCell:
+		msg.Type = MsgCellUpdate
+		p := CellUpdatePayload{X: r.int(), Y: r.int()}
+		p.Pencil = r.int()&cellFlagPencil != 0
+		if value := r.string(); value != "" {
+			p.Value = &value
+		}
+		payload = p
+	default:
+		return msg, fmt.Errorf("unknown frame kind %d", frame[0])
+	}
+	if r.err != nil {
+		return msg, r.err
+	}
+
+	data, err := json.Marshal(payload)
+	msg.Payload = data
+	return msg, err
+}
+
+// frameReader reads the fields of a frame, remembering the first error
+type frameReader struct {
+	data []byte
+	err  error
+}
+
+func (r *frameReader) int() int {
+	v, n := binary.Varint(r.data)
+	if n <= 0 || v > math.MaxInt32 || v < math.MinInt32 {
+		r.err = errBadFrame
+		return 0
+	}
+	r.data = r.data[n:]
+	return int(v)
+}
+
+func (r *frameReader) string() string {
+	length, n := binary.Uvarint(r.data)
+	if n <= 0 || length > uint64(len(r.data)-n) {
+		r.err = errBadFrame
+		return ""
+	}
+	s := string(r.data[n : n+int(length)])
+	r.data = r.data[n+int(length):]
+	return s
+}
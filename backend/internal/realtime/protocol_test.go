@@ -0,0 +1,225 @@
+package realtime
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func encodeMessage(t *testing.T, msgType MessageType, seq int64, payload interface{}) []byte {
+	t.Helper()
+	data, _ := json.Marshal(payload)
+	msgData, err := json.Marshal(Message{Type: msgType, Payload: data, Seq: seq})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msgData
+}
+
+func TestCompactCursorFrame(t *testing.T) {
+	data := encodeMessage(t, MsgCursorMoved, 12, CursorMovedPayload{PlayerID: "user-1", DisplayName: "Ann", X: 3, Y: 14, Color: "#f00"})
+	frame := compactFrame(data)
+	if frame[0] != frameCursor {
+		t.Fatalf("cursor_moved should get a cursor frame, got kind %d", frame[0])
+	}
+	if len(frame) >= len(data)/2 {
+		t.Errorf("Cursor frame is %d bytes, JSON %d: want it much smaller", len(frame), len(data))
+	}
+
+	r := frameReader{data: frame[1:]}
+	seq, x, y := r.int(), r.int(), r.int()
+	playerID, name, color := r.string(), r.string(), r.string()
+	if r.err != nil || seq != 12 || x != 3 || y != 14 || playerID != "user-1" || name != "Ann" || color != "#f00" || len(r.data) != 0 {
+		t.Errorf("Decoded seq=%d x=%d y=%d player=%q name=%q color=%q err=%v", seq, x, y, playerID, name, color, r.err)
+	}
+}
+
+func TestCompactCellFrame(t *testing.T) {
+	correct := true
+	data := encodeMessage(t, MsgCellUpdated, 7, CellUpdatedPayload{X: 1, Y: 2, Value: "A", PlayerID: "user-1", Color: "#0f0", IsCorrect: &correct, IsPencil: true, Version: 5, TeamID: "red"})
+	frame := compactFrame(data)
+	if frame[0] != frameCell {
+		t.Fatalf("cell_updated should get a cell frame, got kind %d", frame[0])
+	}
+
+	r := frameReader{data: frame[1:]}
+	seq, x, y, flags := r.int(), r.int(), r.int(), r.int()
+	value, playerID, color := r.string(), r.string(), r.string()
+	version := r.int()
+	teamID, origin := r.string(), r.string()
+	if r.err != nil || seq != 7 || x != 1 || y != 2 || value != "A" || playerID != "user-1" || color != "#0f0" || version != 5 || teamID != "red" || origin != "" {
+		t.Errorf("Decoded seq=%d x=%d y=%d value=%q player=%q color=%q version=%d team=%q err=%v", seq, x, y, value, playerID, color, version, teamID, r.err)
+	}
+	if want := cellFlagPencil | cellFlagChecked | cellFlagCorrect; flags != want {
+		t.Errorf("Flags = %b, want %b", flags, want)
+	}
+
+	// Candidates have no place in a cell frame
+	data = encodeMessage(t, MsgCellUpdated, 8, CellUpdatedPayload{X: 1, Y: 2, Candidates: []string{"A", "B"}})
+	if frame := compactFrame(data); frame[0] != frameJSON || string(frame[1:]) != string(data) {
+		t.Errorf("A cell with candidates should be sent as JSON, got kind %d", frame[0])
+	}
+}
+
+func TestCompactJSONFrame(t *testing.T) {
+	data := encodeMessage(t, MsgNewMessage, 3, NewMessagePayload{ID: "m1", Text: "hi"})
+	if frame := compactFrame(data); frame[0] != frameJSON || string(frame[1:]) != string(data) {
+		t.Errorf("Other messages should be sent as JSON frames, got %q", frame)
+	}
+}
+
+func TestOutgoingEncodedOnce(t *testing.T) {
+	data := encodeMessage(t, MsgCursorMoved, 4, CursorMovedPayload{PlayerID: "user-1", X: 1, Y: 1})
+	msg := newOutgoing(data)
+
+	plain := newTestClient("conn-1", "user-1")
+	first, second := newTestClient("conn-2", "user-2"), newTestClient("conn-3", "user-3")
+	first.Compact, second.Compact = true, true
+
+	if got := msg.encodedFor(plain); string(got) != string(data) {
+		t.Errorf("A JSON client should get the JSON, got %q", got)
+	}
+	frame := msg.encodedFor(first)
+	if frame[0] != frameCursor {
+		t.Fatalf("A compact client should get a cursor frame, got kind %d", frame[0])
+	}
+	if again := msg.encodedFor(second); &again[0] != &frame[0] {
+		t.Error("Every compact client should share the frame built for the first")
+	}
+}
+
+func TestDecodeCompact(t *testing.T) {
+	cursor := []byte{frameCursor}
+	cursor = binary.AppendVarint(cursor, 4)
+	cursor = binary.AppendVarint(cursor, 9)
+
+	cell := []byte{frameCell}
+	cell = binary.AppendVarint(cell, 2)
+	cell = binary.AppendVarint(cell, 0)
+	cell = binary.AppendVarint(cell, cellFlagPencil)
+	cell = appendString(cell, "Q")
+
+	clear := []byte{frameCell}
+	clear = binary.AppendVarint(clear, 2)
+	clear = binary.AppendVarint(clear, 0)
+	clear = binary.AppendVarint(clear, 0)
+	clear = appendString(clear, "")
+
+	chat := append([]byte{frameJSON}, `{"type":"send_message","payload":{"text":"hi"}}`...)
+
+	var data []byte
+	for _, frame := range [][]byte{cursor, cell, clear, chat} {
+		data = appendFrame(data, frame)
+	}
+	msgs, err := decodeCompact(data)
+	if err != nil {
+		t.Fatalf("decodeCompact: %v", err)
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("Decoded %d messages, want 4", len(msgs))
+	}
+
+	var move CursorMovePayload
+	json.Unmarshal(msgs[0].Payload, &move)
+	if msgs[0].Type != MsgCursorMove || move.X != 4 || move.Y != 9 {
+		t.Errorf("Cursor frame decoded to %s %+v", msgs[0].Type, move)
+	}
+
+	var update CellUpdatePayload
+	json.Unmarshal(msgs[1].Payload, &update)
+	if msgs[1].Type != MsgCellUpdate || update.X != 2 || update.Value == nil || *update.Value != "Q" || !update.Pencil {
+		t.Errorf("Cell frame decoded to %s %+v", msgs[1].Type, update)
+	}
+
+	update = CellUpdatePayload{}
+	json.Unmarshal(msgs[2].Payload, &update)
+	if update.Value != nil {
+		t.Errorf("An empty value should clear the cell, got %q", *update.Value)
+	}
+
+	if msgs[3].Type != MsgSendMessage {
+		t.Errorf("JSON frame decoded to %s", msgs[3].Type)
+	}
+
+	for _, bad := range [][]byte{
+		{5, frameCursor, 2},                 // shorter than its length
+		appendFrame(nil, []byte{frameCell}), // missing fields
+		appendFrame(nil, []byte{42}),        // unknown kind
+		{0},                                 // empty frame
+	} {
+		if _, err := decodeCompact(bad); err == nil {
+			t.Errorf("decodeCompact(%v) should fail", bad)
+		}
+	}
+}
+
+func TestNegotiateProtocol(t *testing.T) {
+	clients := make(chan *Client, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := &Client{Conn: conn, Send: make(chan []byte, 8), Compact: conn.Subprotocol() == protocolCompact}
+		go client.WritePump()
+		clients <- client
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	cursor := encodeMessage(t, MsgCursorMoved, 1, CursorMovedPayload{PlayerID: "user-1", X: 1, Y: 1})
+	chat := encodeMessage(t, MsgNewMessage, 2, NewMessagePayload{Text: "hi"})
+
+	// No subprotocol: JSON as before
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	client := <-clients
+	client.trySend(newOutgoing(cursor))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	messageType, data, err := conn.ReadMessage()
+	if err != nil || messageType != websocket.TextMessage || string(data) != string(cursor) {
+		t.Errorf("JSON client got type %d %q (%v)", messageType, data, err)
+	}
+	close(client.Send)
+	conn.Close()
+
+	// Compact: binary frames
+	dialer := websocket.Dialer{Subprotocols: []string{protocolCompact, protocolJSON}}
+	conn, _, err = dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != protocolCompact {
+		t.Fatalf("Negotiated %q, want %q", conn.Subprotocol(), protocolCompact)
+	}
+	client = <-clients
+	client.trySend(newOutgoing(cursor))
+	client.trySend(newOutgoing(chat))
+	defer close(client.Send)
+
+	var frames [][]byte
+	for len(frames) < 2 {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		messageType, data, err = conn.ReadMessage()
+		if err != nil || messageType != websocket.BinaryMessage {
+			t.Fatalf("Compact client got type %d (%v)", messageType, err)
+		}
+		for len(data) > 0 {
+			n, read := binary.Uvarint(data)
+			frames = append(frames, data[read:read+int(n)])
+			data = data[read+int(n):]
+		}
+	}
+	if frames[0][0] != frameCursor || frames[1][0] != frameJSON {
+		t.Errorf("Compact client got frame kinds %d and %d, want cursor then JSON", frames[0][0], frames[1][0])
+	}
+}
@@ -0,0 +1,350 @@
+package realtime
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+// Every accepted cell edit, hint and cursor move is appended to the room's
+// event log. Events are buffered and written behind together with the grids
+// (see grid.go). A client can ask for the log of its room to be played back to
+// it with start_replay; players may only watch once the game is over, while
+// spectators can watch at any time.
+
+const (
+	// maxPendingEvents caps the events kept in memory while the database is unreachable
+	maxPendingEvents = 10000
+
+	// replayMaxGap is the longest pause between two replayed events, so idle
+	// stretches of a game don't stall the replay
+	replayMaxGap = 3 * time.Second
+
+	defaultReplaySpeed = 1.0
+	minReplaySpeed     = 0.25
+	maxReplaySpeed     = 16.0
+)
+
+// eventStore persists room event logs; *db.Database implements it
+type eventStore interface {
+	AppendRoomEvents(events []models.RoomEvent) error
+	GetRoomEvents(roomID string) ([]models.RoomEvent, error)
+}
+
+// eventRecorder buffers events until the next flush
+type eventRecorder struct {
+	mutex   sync.Mutex
+	pending []models.RoomEvent
+}
+
+// recordEvent appends an event to a room's event log
+func (h *Hub) recordEvent(roomID string, eventType models.RoomEventType, userID string, x, y int, value string) {
+	if h.eventStore == nil {
+		return
+	}
+
+	h.recorder.mutex.Lock()
+	defer h.recorder.mutex.Unlock()
+	if len(h.recorder.pending) >= maxPendingEvents {
+		return
+	}
+	h.recorder.pending = append(h.recorder.pending, models.RoomEvent{
+		RoomID:    roomID,
+		Type:      eventType,
+		UserID:    userID,
+		X:         x,
+		Y:         y,
+		Value:     value,
+		CreatedAt: time.Now(),
+	})
+}
+
+// flushEvents writes buffered events to the database
+func (h *Hub) flushEvents() {
+	if h.eventStore == nil {
+		return
+	}
+
+	h.recorder.mutex.Lock()
+	batch := h.recorder.pending
+	h.recorder.pending = nil
+	h.recorder.mutex.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	if err := h.eventStore.AppendRoomEvents(batch); err != nil {
+		log.Printf("flushEvents: failed to save %d events: %v", len(batch), err)
+
+		// Put them back in front of anything recorded meanwhile
+		h.recorder.mutex.Lock()
+		h.recorder.pending = append(batch, h.recorder.pending...)
+		if len(h.recorder.pending) > maxPendingEvents {
+			h.recorder.pending = h.recorder.pending[:maxPendingEvents]
+		}
+		h.recorder.mutex.Unlock()
+	}
+}
+
+// replaySession is a replay being played to one client
+type replaySession struct {
+	control chan ReplayControlPayload
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// handleStartReplay plays the event log of the client's room to the client
+func (h *Hub) handleStartReplay(client *Client, payload json.RawMessage) {
+	if client.RoomID == "" || h.eventStore == nil {
+		return
+	}
+
+	var p StartReplayPayload
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			h.sendError(client, "invalid payload")
+			return
+		}
+	}
+
+	// Players can't watch a game they are still playing
+	room, _ := h.cachedRoomAndPuzzle(client.RoomID)
+	if room == nil {
+		return
+	}
+	if room.State != models.RoomStateCompleted {
+		players, _ := h.db.GetRoomPlayers(client.RoomID)
+		player := findPlayer(players, client.UserID)
+		if player == nil || !player.IsSpectator {
+			h.sendError(client, "replay is available to spectators or after the game")
+			return
+		}
+	}
+
+	// Include events this instance hasn't written yet
+	h.flushEvents()
+	events, err := h.eventStore.GetRoomEvents(client.RoomID)
+	if err != nil {
+		log.Printf("handleStartReplay: failed to load events for room %s: %v", client.RoomID, err)
+		h.sendError(client, "failed to load replay")
+		return
+	}
+
+	// Spectators of a running game only see as far as their delayed view
+	if room.State != models.RoomStateCompleted && room.Config.SpectatorDelay > 0 {
+		cutoff := time.Now().Add(-time.Duration(room.Config.SpectatorDelay) * time.Second)
+		n := 0
+		for n < len(events) && !events[n].CreatedAt.After(cutoff) {
+			n++
+		}
+		events = events[:n]
+	}
+
+	h.startReplay(client, events, clampReplaySpeed(p.Speed))
+}
+
+// handleReplayControl pauses, resumes, stops or changes the speed of the client's replay
+func (h *Hub) handleReplayControl(client *Client, payload json.RawMessage) {
+	var p ReplayControlPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		h.sendError(client, "invalid payload")
+		return
+	}
+
+	if p.Action == "stop" {
+		h.stopReplay(client)
+		return
+	}
+
+	h.replaysMutex.Lock()
+	session := h.replays[client.ConnectionID]
+	h.replaysMutex.Unlock()
+	if session == nil {
+		return
+	}
+
+	select {
+	case session.control <- p:
+	case <-session.done:
+	}
+}
+
+// startReplay plays events to a client, replacing any replay it is watching
+func (h *Hub) startReplay(client *Client, events []models.RoomEvent, speed float64) {
+	h.stopReplay(client)
+
+	session := &replaySession{
+		control: make(chan ReplayControlPayload),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	h.replaysMutex.Lock()
+	h.replays[client.ConnectionID] = session
+	h.replaysMutex.Unlock()
+
+	go func() {
+		defer close(session.done)
+		h.playReplay(client, session, events, speed)
+
+		h.replaysMutex.Lock()
+		if h.replays[client.ConnectionID] == session {
+			delete(h.replays, client.ConnectionID)
+		}
+		h.replaysMutex.Unlock()
+	}()
+}
+
+// stopReplay ends the client's replay, if any, and waits for it to stop sending
+func (h *Hub) stopReplay(client *Client) {
+	h.replaysMutex.Lock()
+	session := h.replays[client.ConnectionID]
+	delete(h.replays, client.ConnectionID)
+	h.replaysMutex.Unlock()
+
+	if session != nil {
+		close(session.stop)
+		<-session.done
+	}
+}
+
+// replayPlayback is the speed and pause state of a replay
+type replayPlayback struct {
+	speed  float64
+	paused bool
+}
+
+// playReplay sends events to the client with their original spacing divided by the speed
+func (h *Hub) playReplay(client *Client, session *replaySession, events []models.RoomEvent, speed float64) {
+	playback := &replayPlayback{speed: speed}
+
+	var duration time.Duration
+	if len(events) > 0 {
+		duration = events[len(events)-1].CreatedAt.Sub(events[0].CreatedAt)
+	}
+	if !h.sendReplay(client, session, MsgReplayStarted, ReplayStartedPayload{
+		Events:     len(events),
+		DurationMs: duration.Milliseconds(),
+		Speed:      speed,
+	}) {
+		return
+	}
+
+	for i, event := range events {
+		if i > 0 {
+			gap := event.CreatedAt.Sub(events[i-1].CreatedAt)
+			if !h.waitReplay(session, gap, playback) {
+				return
+			}
+		}
+
+		if !h.sendReplay(client, session, MsgReplayEvent, ReplayEventPayload{
+			RoomEvent: event,
+			OffsetMs:  event.CreatedAt.Sub(events[0].CreatedAt).Milliseconds(),
+		}) {
+			return
+		}
+	}
+
+	h.sendReplay(client, session, MsgReplayEnded, ReplayEndedPayload{Events: len(events)})
+}
+
+// sendReplay queues a replay message for the client. Unlike sendToClient it
+// waits for room in the send buffer rather than dropping the message, since a
+// fast replay can outpace the connection. It returns false if the replay was stopped.
+func (h *Hub) sendReplay(client *Client, session *replaySession, msgType MessageType, payload interface{}) bool {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return true
+	}
+	msgData, err := json.Marshal(Message{Type: msgType, Payload: data})
+	if err != nil {
+		return true
+	}
+
+	select {
+	case client.Send <- newOutgoing(msgData).encodedFor(client):
+		return true
+	case <-session.stop:
+		return false
+	}
+}
+
+// waitReplay waits out the gap between two events at the current speed,
+// applying control messages meanwhile. It returns false if the replay was stopped.
+func (h *Hub) waitReplay(session *replaySession, gap time.Duration, playback *replayPlayback) bool {
+	wait := time.Duration(float64(gap) / playback.speed)
+	if wait > replayMaxGap {
+		wait = replayMaxGap
+	}
+	deadline := time.Now().Add(wait)
+	remaining := wait
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		// A nil channel never fires, so the timer is ignored while paused
+		timeout := timer.C
+		if playback.paused {
+			timeout = nil
+		}
+
+		select {
+		case <-timeout:
+			return true
+		case <-session.stop:
+			return false
+		case ctrl := <-session.control:
+			switch ctrl.Action {
+			case "pause":
+				if !playback.paused {
+					playback.paused = true
+					remaining = time.Until(deadline)
+				}
+			case "resume":
+				if playback.paused {
+					playback.paused = false
+					deadline = time.Now().Add(remaining)
+					resetTimer(timer, remaining)
+				}
+			case "speed":
+				newSpeed := clampReplaySpeed(ctrl.Speed)
+				if playback.paused {
+					remaining = time.Duration(float64(remaining) * playback.speed / newSpeed)
+				} else {
+					left := time.Duration(float64(time.Until(deadline)) * playback.speed / newSpeed)
+					deadline = time.Now().Add(left)
+					resetTimer(timer, left)
+				}
+				playback.speed = newSpeed
+			}
+		}
+	}
+}
+
+// resetTimer restarts a timer that may or may not have fired
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
+func clampReplaySpeed(speed float64) float64 {
+	if speed <= 0 {
+		return defaultReplaySpeed
+	}
+	if speed < minReplaySpeed {
+		return minReplaySpeed
+	}
+	if speed > maxReplaySpeed {
+		return maxReplaySpeed
+	}
+	return speed
+}
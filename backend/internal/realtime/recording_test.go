@@ -0,0 +1,159 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+type fakeEventStore struct {
+	mutex  sync.Mutex
+	events []models.RoomEvent
+	fail   bool
+}
+
+func (s *fakeEventStore) AppendRoomEvents(events []models.RoomEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.fail {
+		return fmt.Errorf("database unavailable")
+	}
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *fakeEventStore) GetRoomEvents(roomID string) ([]models.RoomEvent, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var events []models.RoomEvent
+	for _, e := range s.events {
+		if e.RoomID == roomID {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func readMessage(t *testing.T, client *Client) Message {
+	t.Helper()
+	select {
+	case data := <-client.Send:
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("invalid message: %v", err)
+		}
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a message")
+		return Message{}
+	}
+}
+
+func TestRecordedEventsAreFlushedInOrder(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	store := &fakeEventStore{fail: true}
+	hub.eventStore = store
+
+	hub.recordEvent("room-1", models.RoomEventCell, "user-1", 0, 0, "A")
+	hub.recordEvent("room-1", models.RoomEventCursor, "user-1", 1, 0, "")
+	hub.Flush()
+
+	// A failed write keeps the events for the next flush, ahead of newer ones
+	store.fail = false
+	hub.recordEvent("room-1", models.RoomEventHint, "user-1", 1, 0, "letter")
+	hub.Flush()
+
+	events, _ := store.GetRoomEvents("room-1")
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	for i, want := range []models.RoomEventType{models.RoomEventCell, models.RoomEventCursor, models.RoomEventHint} {
+		if events[i].Type != want {
+			t.Errorf("Event %d is %q, want %q", i, events[i].Type, want)
+		}
+	}
+}
+
+func TestReplayPlaysEventsAtSpeed(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	client := newTestClient("conn-1", "user-1")
+
+	start := time.Now()
+	events := []models.RoomEvent{
+		{Type: models.RoomEventCell, UserID: "user-1", Value: "A", CreatedAt: start},
+		{Type: models.RoomEventCell, UserID: "user-2", X: 1, Value: "B", CreatedAt: start.Add(400 * time.Millisecond)},
+		{Type: models.RoomEventCursor, UserID: "user-1", X: 2, CreatedAt: start.Add(800 * time.Millisecond)},
+	}
+
+	began := time.Now()
+	hub.startReplay(client, events, 4)
+
+	msg := readMessage(t, client)
+	var started ReplayStartedPayload
+	json.Unmarshal(msg.Payload, &started)
+	if msg.Type != MsgReplayStarted || started.Events != 3 || started.DurationMs != 800 {
+		t.Fatalf("Expected replay_started for 3 events over 800ms, got %s %+v", msg.Type, started)
+	}
+
+	for i, want := range events {
+		msg := readMessage(t, client)
+		var event ReplayEventPayload
+		json.Unmarshal(msg.Payload, &event)
+		if msg.Type != MsgReplayEvent || event.X != want.X || event.Value != want.Value {
+			t.Fatalf("Event %d = %s %+v, want %+v", i, msg.Type, event, want)
+		}
+		if wantOffset := want.CreatedAt.Sub(start).Milliseconds(); event.OffsetMs != wantOffset {
+			t.Errorf("Event %d offset = %dms, want %dms", i, event.OffsetMs, wantOffset)
+		}
+	}
+	if msg := readMessage(t, client); msg.Type != MsgReplayEnded {
+		t.Errorf("Expected replay_ended, got %s", msg.Type)
+	}
+
+	// 800ms of play at 4x takes about 200ms
+	if elapsed := time.Since(began); elapsed < 150*time.Millisecond || elapsed > 600*time.Millisecond {
+		t.Errorf("Replay at 4x took %v, want about 200ms", elapsed)
+	}
+}
+
+func TestReplayPauseAndStop(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	client := newTestClient("conn-1", "user-1")
+
+	start := time.Now()
+	events := []models.RoomEvent{
+		{Type: models.RoomEventCell, CreatedAt: start},
+		{Type: models.RoomEventCell, CreatedAt: start.Add(100 * time.Millisecond)},
+	}
+	hub.startReplay(client, events, 1)
+	readMessage(t, client) // replay_started
+	readMessage(t, client) // first event
+
+	pause, _ := json.Marshal(ReplayControlPayload{Action: "pause"})
+	hub.handleReplayControl(client, pause)
+
+	select {
+	case data := <-client.Send:
+		t.Fatalf("Paused replay sent %s", data)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	stop, _ := json.Marshal(ReplayControlPayload{Action: "stop"})
+	hub.handleReplayControl(client, stop)
+
+	hub.replaysMutex.Lock()
+	remaining := len(hub.replays)
+	hub.replaysMutex.Unlock()
+	if remaining != 0 {
+		t.Error("Stopped replay should be removed")
+	}
+	select {
+	case data := <-client.Send:
+		t.Errorf("Stopped replay sent %s", data)
+	default:
+	}
+}
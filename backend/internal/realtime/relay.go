@@ -0,0 +1,423 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+// Relay rooms solve one grid taking turns. A turn ends when the player passes,
+// when its time runs out, once the player has completed the room's
+// WordsPerTurn words, or when a majority of the other players vote to skip it
+// (SkipVotes). The next turn goes to the next player in turn order or, with
+// CatchUp, to the player with the fewest correct cells so far.
+//
+// Each instance with clients in the room keeps a timer for the end of the
+// current turn, set from turn_changed and game_resumed. Whatever ends a turn
+// first claims it (see claimTurnEnd), so a turn only ever advances once.
+//
+// Every letter a relay player types checks whose turn it is, so the relay
+// state is kept in the broker with the rest of the room's shared state. The
+// database copy is written when a turn starts and only read when the broker
+// has lost the state. Words completed during a turn are counted in the
+// broker alone, per turn (see addRelayWords).
+
+const defaultTurnSeconds = 60
+
+// Why a turn ended, sent in turn_changed
+const (
+	turnPassed   = "passed"
+	turnTimedOut = "timeout"
+	turnWords    = "words"
+	turnSkipped  = "skipped"
+)
+
+func roomRelayKey(roomID string) string { return "room:" + roomID + ":relay" }
+
+// Skip votes are kept per turn, so they lapse when the turn ends
+func skipVotesKey(roomID string, turnStartedAt time.Time) string {
+	return fmt.Sprintf("room:%s:skip-votes:%d", roomID, turnStartedAt.UnixNano())
+}
+
+// turnSeconds returns the length of a turn in a relay room
+func turnSeconds(config models.RoomConfig) int {
+	if config.TurnSeconds > 0 {
+		return config.TurnSeconds
+	}
+	return defaultTurnSeconds
+}
+
+// turnDeadline returns when the current turn times out, or the zero time if
+// turns aren't timed
+func turnDeadline(relayState *models.RelayState) time.Time {
+	if relayState.TurnTimeLimit <= 0 {
+		return time.Time{}
+	}
+	return relayState.TurnStartedAt.Add(time.Duration(relayState.TurnTimeLimit) * time.Second)
+}
+
+// nextRelayPlayer returns who plays after current. Turns go round in order;
+// with contributions, the player who has contributed least goes next, ties
+// going to whoever comes first in the rotation. Nobody plays twice in a row
+// unless they are alone.
+func nextRelayPlayer(turnOrder []string, current string, contributions map[string]int) string {
+	start, candidates := 0, len(turnOrder)
+	for i, userID := range turnOrder {
+		if userID == current {
+			start, candidates = i+1, len(turnOrder)-1
+			break
+		}
+	}
+	if candidates == 0 {
+		return current
+	}
+
+	next := turnOrder[start%len(turnOrder)]
+	if contributions == nil {
+		return next
+	}
+	for i := 1; i < candidates; i++ {
+		userID := turnOrder[(start+i)%len(turnOrder)]
+		if contributions[userID] < contributions[next] {
+			next = userID
+		}
+	}
+	return next
+}
+
+// skipVotesNeeded returns how many of the other players must vote to skip a
+// turn: more than half of them
+func skipVotesNeeded(players int) int {
+	return (players-1)/2 + 1
+}
+
+// turnChanged builds the turn_changed message for the turn in relayState
+func turnChanged(relayState *models.RelayState, players []models.Player, turnNumber int, reason, previousPlayerID string) TurnChangedPayload {
+	payload := TurnChangedPayload{
+		CurrentPlayerID:   relayState.CurrentPlayerID,
+		CurrentPlayerName: "Unknown",
+		TurnNumber:        turnNumber,
+		Reason:            reason,
+		PreviousPlayerID:  previousPlayerID,
+	}
+	if player := findPlayer(players, relayState.CurrentPlayerID); player != nil {
+		payload.CurrentPlayerName = player.DisplayName
+	}
+	if deadline := turnDeadline(relayState); !deadline.IsZero() {
+		payload.TurnEndsAt = &deadline
+	}
+	return payload
+}
+
+// endTurn passes the current turn on to the next player, unless something
+// else already ended it
+func (h *Hub) endTurn(roomID string, relayState *models.RelayState, reason string) {
+	if !h.claimTurnEnd(roomID, relayState.TurnStartedAt) {
+		return
+	}
+
+	var contributions map[string]int
+	if room, _ := h.cachedRoomAndPuzzle(roomID); room != nil && room.Config.CatchUp {
+		contributions = h.roomContributions(roomID)
+	}
+	previousPlayerID := relayState.CurrentPlayerID
+
+	relayState.CurrentPlayerID = nextRelayPlayer(relayState.TurnOrder, previousPlayerID, contributions)
+	relayState.TurnStartedAt = time.Now()
+	relayState.WordsThisTurn = 0
+	h.saveRelayState(relayState)
+
+	turnNum := h.nextTurnNumber(roomID)
+	players := h.cachedPlayers(roomID)
+	h.broadcastToRoom(roomID, "", MsgTurnChanged, turnChanged(relayState, players, turnNum, reason, previousPlayerID))
+	h.scheduleTurnTimeout(roomID, turnDeadline(relayState))
+
+	log.Printf("endTurn: room %s turn %d to player %s (%s)", roomID, turnNum, relayState.CurrentPlayerID, reason)
+}
+
+func (h *Hub) handlePassTurn(client *Client) {
+	if client.RoomID == "" {
+		return
+	}
+
+	room, _ := h.cachedRoomAndPuzzle(client.RoomID)
+	if room == nil || room.Mode != models.RoomModeRelay || room.State != models.RoomStateActive {
+		return
+	}
+
+	relayState := h.relayState(client.RoomID)
+	if relayState == nil || relayState.CurrentPlayerID != client.UserID {
+		h.sendError(client, "not your turn")
+		return
+	}
+	h.endTurn(client.RoomID, relayState, turnPassed)
+}
+
+func (h *Hub) handleVoteSkip(client *Client) {
+	if client.RoomID == "" {
+		return
+	}
+
+	room, _ := h.cachedRoomAndPuzzle(client.RoomID)
+	if room == nil || room.Mode != models.RoomModeRelay || room.State != models.RoomStateActive {
+		h.sendError(client, "no relay game in progress")
+		return
+	}
+	if !room.Config.SkipVotes {
+		h.sendError(client, "skip votes are off in this room")
+		return
+	}
+	if h.clockPaused(client.RoomID) {
+		h.sendError(client, "game is paused")
+		return
+	}
+
+	relayState := h.relayState(client.RoomID)
+	if relayState == nil {
+		return
+	}
+	if relayState.CurrentPlayerID == client.UserID {
+		h.sendError(client, "you can't vote to skip your own turn")
+		return
+	}
+	inTurnOrder := false
+	for _, userID := range relayState.TurnOrder {
+		inTurnOrder = inTurnOrder || userID == client.UserID
+	}
+	if !inTurnOrder {
+		h.sendError(client, "only players can vote to skip")
+		return
+	}
+
+	ctx := context.Background()
+	key := skipVotesKey(client.RoomID, relayState.TurnStartedAt)
+	if err := h.broker.HSet(ctx, key, client.UserID, "1"); err != nil {
+		h.sendError(client, "failed to record vote")
+		return
+	}
+	h.broker.Expire(ctx, key, turnEndLockTTL+time.Duration(relayState.TurnTimeLimit)*time.Second)
+	votes, _ := h.broker.HGetAll(ctx, key)
+
+	needed := skipVotesNeeded(len(relayState.TurnOrder))
+	h.broadcastToRoom(client.RoomID, "", MsgSkipVote, SkipVotePayload{
+		PlayerID: relayState.CurrentPlayerID,
+		VoterID:  client.UserID,
+		Votes:    len(votes),
+		Needed:   needed,
+	})
+	if len(votes) >= needed {
+		h.endTurn(client.RoomID, relayState, turnSkipped)
+	}
+}
+
+// countRelayWords updates the grid's completed clues after an edit and adds
+// the newly completed words to the current turn. It reports whether the
+// player has now completed the room's words per turn.
+func (h *Hub) countRelayWords(grid *sharedGrid, puzzle *models.Puzzle, relayState *models.RelayState, wordsPerTurn int) (turnDone bool) {
+	wordsCompleted := 0
+	grid.update(func(gridState *models.GridState) {
+		prevCompletedCount := len(gridState.CompletedClues)
+		gridState.CompletedClues = h.getCompletedClues(puzzle, gridState)
+		wordsCompleted = len(gridState.CompletedClues) - prevCompletedCount
+	})
+
+	// Track newly completed words this turn
+	if wordsCompleted > 0 {
+		relayState.WordsThisTurn = h.addRelayWords(relayState.RoomID, relayState.TurnStartedAt, wordsCompleted)
+	}
+	return wordsCompleted > 0 && wordsPerTurn > 0 && relayState.WordsThisTurn >= wordsPerTurn
+}
+
+// relayStore persists relay turns; *db.Database implements it
+type relayStore interface {
+	CreateRelayState(state *models.RelayState) error
+	GetRelayState(roomID string) (*models.RelayState, error)
+	UpdateRelayState(state *models.RelayState) error
+}
+
+// relayWordsField counts the words completed in the turn that started at
+// turnStartedAt. Counting per turn keeps a word completed as the turn ends
+// from being counted in the next one.
+func relayWordsField(turnStartedAt time.Time) string {
+	return "words:" + strconv.FormatInt(turnStartedAt.UnixNano(), 10)
+}
+
+// relayState returns a room's relay state, or nil if it has none
+func (h *Hub) relayState(roomID string) *models.RelayState {
+	fields, err := h.broker.HGetAll(context.Background(), roomRelayKey(roomID))
+	if err == nil && fields["state"] != "" {
+		var relayState models.RelayState
+		if err := json.Unmarshal([]byte(fields["state"]), &relayState); err == nil {
+			relayState.WordsThisTurn, _ = strconv.Atoi(fields[relayWordsField(relayState.TurnStartedAt)])
+			return &relayState
+		}
+	}
+
+	if h.relayStore == nil {
+		return nil
+	}
+	relayState, err := h.relayStore.GetRelayState(roomID)
+	if err != nil || relayState == nil {
+		return nil
+	}
+	h.shareRelayState(relayState)
+	return relayState
+}
+
+// createRelayState stores the first turn of a relay game
+func (h *Hub) createRelayState(relayState *models.RelayState) {
+	if h.relayStore != nil {
+		if err := h.relayStore.CreateRelayState(relayState); err != nil {
+			log.Printf("createRelayState: room %s: %v", relayState.RoomID, err)
+		}
+	}
+	h.shareRelayState(relayState)
+}
+
+// saveRelayState stores a changed turn
+func (h *Hub) saveRelayState(relayState *models.RelayState) {
+	if h.relayStore != nil {
+		if err := h.relayStore.UpdateRelayState(relayState); err != nil {
+			log.Printf("saveRelayState: room %s: %v", relayState.RoomID, err)
+		}
+	}
+	h.shareRelayState(relayState)
+}
+
+// shareRelayState puts a room's relay state in the broker, dropping the word
+// counts of earlier turns
+func (h *Hub) shareRelayState(relayState *models.RelayState) {
+	data, err := json.Marshal(relayState)
+	if err != nil {
+		return
+	}
+	ctx := context.Background()
+	key := roomRelayKey(relayState.RoomID)
+	wordsField := relayWordsField(relayState.TurnStartedAt)
+
+	fields, _ := h.broker.HGetAll(ctx, key)
+	var stale []string
+	for field := range fields {
+		if strings.HasPrefix(field, "words:") && field != wordsField {
+			stale = append(stale, field)
+		}
+	}
+	if len(stale) > 0 {
+		h.broker.HDel(ctx, key, stale...)
+	}
+
+	if err := h.broker.HSet(ctx, key, "state", string(data)); err != nil {
+		log.Printf("shareRelayState: room %s: %v", relayState.RoomID, err)
+		return
+	}
+	h.broker.HSet(ctx, key, wordsField, strconv.Itoa(relayState.WordsThisTurn))
+	h.broker.Expire(ctx, key, roomStateTTL)
+}
+
+// addRelayWords counts words completed in the turn that started at
+// turnStartedAt and returns the turn's total. Only the count is written, so
+// a turn that has already ended keeps its successor intact.
+func (h *Hub) addRelayWords(roomID string, turnStartedAt time.Time, words int) int {
+	total, err := h.broker.HIncrBy(context.Background(), roomRelayKey(roomID), relayWordsField(turnStartedAt), int64(words))
+	if err != nil {
+		log.Printf("addRelayWords: room %s: %v", roomID, err)
+		return 0
+	}
+	return int(total)
+}
+
+// relayWordsPerTurn returns how many words end a turn in a room, 0 if turns
+// don't end on words
+func (h *Hub) relayWordsPerTurn(roomID string) int {
+	room, _ := h.cachedRoomAndPuzzle(roomID)
+	if room == nil {
+		return 0
+	}
+	return room.Config.WordsPerTurn
+}
+
+// scheduleTurnTimeout sets the room's turn timer to go off at deadline,
+// replacing any timer already set. A zero deadline just stops the timer.
+func (h *Hub) scheduleTurnTimeout(roomID string, deadline time.Time) {
+	h.turnTimersMutex.Lock()
+	defer h.turnTimersMutex.Unlock()
+
+	if timer := h.turnTimers[roomID]; timer != nil {
+		timer.Stop()
+		delete(h.turnTimers, roomID)
+	}
+	if deadline.IsZero() {
+		return
+	}
+	h.turnTimers[roomID] = time.AfterFunc(time.Until(deadline), func() {
+		h.turnTimedOut(roomID)
+	})
+}
+
+// watchTurn sets a relay room's turn timer from its stored relay state, if
+// this instance has clients in the room
+func (h *Hub) watchTurn(roomID string) {
+	h.mutex.RLock()
+	hubRoom := h.rooms[roomID]
+	h.mutex.RUnlock()
+	if hubRoom == nil || hubRoom.Mode != models.RoomModeRelay {
+		return
+	}
+
+	relayState := h.relayState(roomID)
+	if relayState == nil || h.clockPaused(roomID) {
+		return
+	}
+	h.scheduleTurnTimeout(roomID, turnDeadline(relayState))
+}
+
+// turnTimedOut runs when a turn timer goes off. The turn may have moved on or
+// been given back time since the timer was set, so it is checked again.
+func (h *Hub) turnTimedOut(roomID string) {
+	h.mutex.RLock()
+	_, local := h.rooms[roomID]
+	h.mutex.RUnlock()
+
+	room, _ := h.cachedRoomAndPuzzle(roomID)
+	if !local || room == nil || room.State != models.RoomStateActive || h.clockPaused(roomID) {
+		// Resuming the game or another client joining sets the timer again
+		h.scheduleTurnTimeout(roomID, time.Time{})
+		return
+	}
+
+	relayState := h.relayState(roomID)
+	if relayState == nil {
+		return
+	}
+	if deadline := turnDeadline(relayState); deadline.IsZero() || time.Now().Before(deadline) {
+		h.scheduleTurnTimeout(roomID, deadline)
+		return
+	}
+	h.endTurn(roomID, relayState, turnTimedOut)
+}
+
+// applyRemoteTurnChange times a turn started by another instance
+func (h *Hub) applyRemoteTurnChange(roomID string, msg *Message) {
+	var p TurnChangedPayload
+	if err := json.Unmarshal(msg.Payload, &p); err != nil {
+		return
+	}
+	h.mutex.RLock()
+	_, local := h.rooms[roomID]
+	h.mutex.RUnlock()
+	if !local {
+		return
+	}
+
+	deadline := time.Time{}
+	if p.TurnEndsAt != nil {
+		deadline = *p.TurnEndsAt
+	}
+	h.scheduleTurnTimeout(roomID, deadline)
+}
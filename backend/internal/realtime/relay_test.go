@@ -0,0 +1,211 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+func TestNextRelayPlayer(t *testing.T) {
+	order := []string{"a", "b", "c", "d"}
+
+	tests := []struct {
+		name          string
+		order         []string
+		current       string
+		contributions map[string]int
+		want          string
+	}{
+		{"in order", order, "b", nil, "c"},
+		{"wraps around", order, "d", nil, "a"},
+		{"current not in order", order, "x", nil, "a"},
+		{"alone", []string{"a"}, "a", nil, "a"},
+		{"catch-up picks the least contribution", order, "a", map[string]int{"a": 0, "b": 5, "c": 1, "d": 3}, "c"},
+		{"catch-up skips the current player", order, "a", map[string]int{"b": 2, "c": 2, "d": 2}, "b"},
+		{"catch-up ties go in rotation", order, "c", map[string]int{"a": 1, "b": 1, "d": 4}, "a"},
+		{"catch-up counts missing players as zero", order, "a", map[string]int{"b": 2, "c": 1}, "d"},
+	}
+	for _, tt := range tests {
+		if got := nextRelayPlayer(tt.order, tt.current, tt.contributions); got != tt.want {
+			t.Errorf("%s: nextRelayPlayer = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSkipVotesNeeded(t *testing.T) {
+	// More than half of the players other than the one being skipped
+	for players, want := range map[int]int{2: 1, 3: 2, 4: 2, 5: 3, 6: 3} {
+		if got := skipVotesNeeded(players); got != want {
+			t.Errorf("skipVotesNeeded(%d) = %d, want %d", players, got, want)
+		}
+	}
+}
+
+func TestTurnDeadline(t *testing.T) {
+	started := time.Now()
+	relayState := &models.RelayState{TurnStartedAt: started, TurnTimeLimit: 45}
+	if got := turnDeadline(relayState); !got.Equal(started.Add(45 * time.Second)) {
+		t.Errorf("turnDeadline = %v, want 45s after the start", got)
+	}
+	relayState.TurnTimeLimit = 0
+	if got := turnDeadline(relayState); !got.IsZero() {
+		t.Errorf("An untimed turn should have no deadline, got %v", got)
+	}
+
+	if got := turnSeconds(models.RoomConfig{}); got != defaultTurnSeconds {
+		t.Errorf("turnSeconds default = %d, want %d", got, defaultTurnSeconds)
+	}
+	if got := turnSeconds(models.RoomConfig{TurnSeconds: 30}); got != 30 {
+		t.Errorf("turnSeconds = %d, want 30", got)
+	}
+}
+
+func TestScheduleTurnTimeout(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+
+	hub.scheduleTurnTimeout("room-1", time.Now().Add(time.Hour))
+	first := hub.turnTimers["room-1"]
+	if first == nil {
+		t.Fatal("A timer should be set for the turn")
+	}
+
+	hub.scheduleTurnTimeout("room-1", time.Now().Add(2*time.Hour))
+	if hub.turnTimers["room-1"] == first {
+		t.Error("Rescheduling should replace the timer")
+	}
+	if first.Stop() {
+		t.Error("The replaced timer should have been stopped")
+	}
+
+	hub.scheduleTurnTimeout("room-1", time.Time{})
+	if _, ok := hub.turnTimers["room-1"]; ok {
+		t.Error("A zero deadline should clear the timer")
+	}
+}
+
+func TestApplyRemoteTurnChange(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	endsAt := time.Now().Add(time.Hour)
+	payload, _ := json.Marshal(TurnChangedPayload{CurrentPlayerID: "user-2", TurnEndsAt: &endsAt})
+	msg := &Message{Type: MsgTurnChanged, Payload: payload}
+
+	// Rooms without clients here are timed by other instances
+	hub.applyRemoteTurnChange("room-1", msg)
+	if hub.turnTimers["room-1"] != nil {
+		t.Error("A room with no local clients shouldn't be timed")
+	}
+
+	addTestRoom(hub, "room-1")
+	hub.applyRemoteTurnChange("room-1", msg)
+	if hub.turnTimers["room-1"] == nil {
+		t.Error("A turn started elsewhere should be timed here too")
+	}
+	hub.scheduleTurnTimeout("room-1", time.Time{})
+}
+
+func TestRelayStateSharedThroughBroker(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := NewHubWithBroker(nil, broker)
+	hubB := NewHubWithBroker(nil, broker)
+
+	started := time.Now().Truncate(time.Second)
+	hubA.shareRelayState(&models.RelayState{
+		RoomID:          "room-1",
+		CurrentPlayerID: "user-1",
+		TurnOrder:       []string{"user-1", "user-2"},
+		TurnStartedAt:   started,
+		TurnTimeLimit:   60,
+	})
+
+	relayState := hubB.relayState("room-1")
+	if relayState == nil || relayState.CurrentPlayerID != "user-1" || !relayState.TurnStartedAt.Equal(started) || len(relayState.TurnOrder) != 2 {
+		t.Fatalf("relayState on the other instance = %+v", relayState)
+	}
+
+	hubA.addRelayWords("room-1", started, 1)
+	if total := hubB.addRelayWords("room-1", started, 2); total != 3 {
+		t.Errorf("Words this turn = %d, want 3", total)
+	}
+	if got := hubA.relayState("room-1").WordsThisTurn; got != 3 {
+		t.Errorf("relayState words = %d, want 3", got)
+	}
+
+	// A new turn starts counting again, and words completed in the old one
+	// don't count towards it
+	relayState.CurrentPlayerID = "user-2"
+	relayState.TurnStartedAt = started.Add(time.Minute)
+	relayState.WordsThisTurn = 0
+	hubB.shareRelayState(relayState)
+	hubA.addRelayWords("room-1", started, 1)
+	if got := hubA.relayState("room-1"); got.CurrentPlayerID != "user-2" || got.WordsThisTurn != 0 {
+		t.Errorf("relayState after the turn changed = %+v", got)
+	}
+
+	if hubA.relayState("room-2") != nil {
+		t.Error("A room without relay state should have none")
+	}
+}
+
+func TestRelayWordRacingTurnEnd(t *testing.T) {
+	// One across word, A-B-C: the player fills in its last letter as their
+	// turn times out
+	puzzle := &models.Puzzle{CluesAcross: []models.Clue{{Number: 1, Direction: "across", Length: 3, Answer: "ABC"}}}
+
+	for _, order := range []string{"timeout first", "word first", "together"} {
+		for i := 0; i < 20; i++ {
+			broker := NewMemoryBroker()
+			hubs := []*Hub{NewHubWithBroker(nil, broker), NewHubWithBroker(nil, broker)}
+			roomID := fmt.Sprintf("room-%d", i)
+			hubs[0].shareRelayState(&models.RelayState{
+				RoomID:          roomID,
+				CurrentPlayerID: "user-1",
+				TurnOrder:       []string{"user-1", "user-2", "user-3"},
+				TurnStartedAt:   time.Now().Add(-time.Minute),
+			})
+			grid := hubs[1].setRoomGrid(roomID, newTestGridState(roomID, 3, 1))
+			for x, letter := range []string{"A", "B", "C"} {
+				letter := letter
+				grid.state.Cells[0][x].Value = &letter
+			}
+
+			// Both read the turn before either acts on it
+			timedOut, stale := hubs[0].relayState(roomID), hubs[1].relayState(roomID)
+			timeout := func() { hubs[0].endTurn(roomID, timedOut, turnTimedOut) }
+			word := func() {
+				if hubs[1].countRelayWords(grid, puzzle, stale, 1) {
+					hubs[1].endTurn(roomID, stale, turnWords)
+				}
+			}
+
+			switch order {
+			case "timeout first":
+				timeout()
+				word()
+			case "word first":
+				word()
+				timeout()
+			default:
+				var wg sync.WaitGroup
+				wg.Add(2)
+				go func() { defer wg.Done(); timeout() }()
+				go func() { defer wg.Done(); word() }()
+				wg.Wait()
+			}
+
+			relayState := hubs[1].relayState(roomID)
+			if relayState.CurrentPlayerID != "user-2" || relayState.WordsThisTurn != 0 {
+				t.Fatalf("%s: the turn is %+v, want user-2's with no words", order, relayState)
+			}
+
+			// The turn isn't stuck: user-2 can still pass it on
+			hubs[1].endTurn(roomID, relayState, turnPassed)
+			if next := hubs[0].relayState(roomID); next.CurrentPlayerID != "user-3" {
+				t.Fatalf("%s: a pass after the race went to %s, want user-3", order, next.CurrentPlayerID)
+			}
+		}
+	}
+}
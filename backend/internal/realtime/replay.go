@@ -0,0 +1,124 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+)
+
+// Every message broadcast to a room carries a sequence number that increases
+// by one per message, shared by all instances. The most recent messages are
+// kept in a per-room replay buffer so a client that lost its connection can
+// send a resume message with the last sequence number it saw and receive just
+// the messages it missed. Messages sent to a single client (errors, race mode
+// cell echoes) carry no sequence number and are not replayed.
+
+// replayBufferSize is how many recent messages are kept per room. It stays well
+// below the client send buffer so a full replay can be queued at once.
+const replayBufferSize = 128
+
+func roomReplayKey(roomID string) string { return "room:" + roomID + ":replay" }
+
+// nextSeq allocates the next sequence number for a room message
+func (h *Hub) nextSeq(roomID string) int64 {
+	seq, err := h.broker.HIncrBy(context.Background(), roomStateKey(roomID), "seq", 1)
+	if err != nil {
+		log.Printf("nextSeq: %v", err)
+		return 0
+	}
+	return seq
+}
+
+// currentSeq returns the sequence number of the last message broadcast to a room
+func (h *Hub) currentSeq(roomID string) int64 {
+	state, err := h.broker.HGetAll(context.Background(), roomStateKey(roomID))
+	if err != nil {
+		return 0
+	}
+	seq, _ := strconv.ParseInt(state["seq"], 10, 64)
+	return seq
+}
+
+// bufferAndPublish appends an encoded room message to the replay buffer and
+// publishes its envelope to the other instances, in one round trip
+func (h *Hub) bufferAndPublish(roomID string, msgData []byte, env clusterEnvelope) {
+	envData, err := h.encodeEnvelope(env)
+	if err != nil {
+		return
+	}
+	key := roomReplayKey(roomID)
+	err = h.broker.Pipeline(context.Background(), func(pipe Pipe) {
+		pipe.RPush(key, string(msgData))
+		pipe.LTrim(key, -replayBufferSize, -1)
+		pipe.Expire(key, roomStateTTL)
+		pipe.Publish(broadcastChannel, envData)
+	})
+	if err != nil {
+		log.Printf("bufferAndPublish: room %s: %v", roomID, err)
+	}
+}
+
+// replaySince returns the buffered messages of a room with a sequence number
+// after lastSeq, in order. ok is false when the buffer no longer holds every
+// missed message (or lastSeq is ahead of the room), in which case the client
+// needs a fresh snapshot instead.
+func (h *Hub) replaySince(roomID string, lastSeq int64) (messages [][]byte, ok bool) {
+	current := h.currentSeq(roomID)
+	if lastSeq > current || lastSeq < 0 {
+		return nil, false
+	}
+	if lastSeq == current {
+		return [][]byte{}, true
+	}
+
+	entries, err := h.broker.LRange(context.Background(), roomReplayKey(roomID), 0, -1)
+	if err != nil {
+		return nil, false
+	}
+
+	type buffered struct {
+		seq  int64
+		data []byte
+	}
+	var missed []buffered
+	for _, entry := range entries {
+		var msg Message
+		if err := json.Unmarshal([]byte(entry), &msg); err != nil {
+			continue
+		}
+		if msg.Seq > lastSeq {
+			missed = append(missed, buffered{msg.Seq, []byte(entry)})
+		}
+	}
+
+	// Broadcasts from different instances can be buffered slightly out of order
+	sort.Slice(missed, func(i, j int) bool { return missed[i].seq < missed[j].seq })
+
+	// The missed messages must follow on from lastSeq without holes. Messages
+	// whose sequence was allocated but that are not buffered yet are still in
+	// flight and will be delivered live.
+	if len(missed) == 0 || missed[0].seq != lastSeq+1 {
+		return nil, false
+	}
+	messages = make([][]byte, 0, len(missed))
+	for i, m := range missed {
+		if i > 0 && m.seq != missed[i-1].seq+1 {
+			return nil, false
+		}
+		messages = append(messages, m.data)
+	}
+	return messages, true
+}
+
+// queueReplay queues replayed messages on the client's send buffer in order,
+// stopping at the first that doesn't fit, and returns how many were queued
+func queueReplay(client *Client, messages [][]byte) int {
+	for i, data := range messages {
+		if !client.trySend(newOutgoing(data)) {
+			return i
+		}
+	}
+	return len(messages)
+}
@@ -0,0 +1,99 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func messageSeq(t *testing.T, data []byte) int64 {
+	t.Helper()
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("invalid message: %v", err)
+	}
+	return msg.Seq
+}
+
+func TestBroadcastSequenceNumbers(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := NewHubWithBroker(nil, broker)
+	hubB := NewHubWithBroker(nil, broker)
+
+	client := newTestClient("conn-1", "user-1")
+	addTestRoom(hubA, "room-1", client)
+
+	hubA.broadcastToRoom("room-1", "", MsgCellUpdated, nil)
+	hubB.broadcastToRoom("room-1", "", MsgCellUpdated, nil)
+	hubA.broadcastToRoom("room-1", "", MsgCellUpdated, nil)
+	hubA.broadcastToRoom("room-2", "", MsgCellUpdated, nil)
+
+	if seq := messageSeq(t, <-client.Send); seq != 1 {
+		t.Errorf("first seq = %d, want 1", seq)
+	}
+	if seq := messageSeq(t, <-client.Send); seq != 3 {
+		t.Errorf("second local seq = %d, want 3 (seq 2 was broadcast by the other instance)", seq)
+	}
+	if seq := hubB.currentSeq("room-1"); seq != 3 {
+		t.Errorf("currentSeq(room-1) = %d, want 3", seq)
+	}
+	if seq := hubB.currentSeq("room-2"); seq != 1 {
+		t.Errorf("currentSeq(room-2) = %d, want 1, sequences are per room", seq)
+	}
+}
+
+func TestReplaySince(t *testing.T) {
+	hub := NewHub(nil)
+	for i := 0; i < 5; i++ {
+		hub.broadcastToRoom("room-1", "", MsgCellUpdated, map[string]int{"i": i})
+	}
+
+	messages, ok := hub.replaySince("room-1", 2)
+	if !ok || len(messages) != 3 {
+		t.Fatalf("replaySince(2) = %d messages, ok=%v; want 3, true", len(messages), ok)
+	}
+	for i, data := range messages {
+		if seq := messageSeq(t, data); seq != int64(i+3) {
+			t.Errorf("replayed message %d has seq %d, want %d", i, seq, i+3)
+		}
+	}
+
+	if messages, ok := hub.replaySince("room-1", 5); !ok || len(messages) != 0 {
+		t.Errorf("replaySince(current) = %d messages, ok=%v; want 0, true", len(messages), ok)
+	}
+	if _, ok := hub.replaySince("room-1", 6); ok {
+		t.Error("replaySince ahead of the room should require a snapshot")
+	}
+	if _, ok := hub.replaySince("room-unknown", 0); !ok {
+		t.Error("replaySince(0) for a room without messages should succeed")
+	}
+}
+
+func TestReplayGapTooLarge(t *testing.T) {
+	hub := NewHub(nil)
+	total := replayBufferSize + 10
+	for i := 0; i < total; i++ {
+		hub.broadcastToRoom("room-1", "", MsgCellUpdated, nil)
+	}
+
+	if _, ok := hub.replaySince("room-1", 1); ok {
+		t.Error("Gap older than the replay buffer should require a snapshot")
+	}
+
+	messages, ok := hub.replaySince("room-1", int64(total-replayBufferSize))
+	if !ok || len(messages) != replayBufferSize {
+		t.Errorf("replaySince(oldest buffered - 1) = %d messages, ok=%v; want %d, true", len(messages), ok, replayBufferSize)
+	}
+}
+
+func TestQueueReplayStopsWhenFull(t *testing.T) {
+	client := newTestClient("conn-1", "user-1")
+	client.Send = make(chan []byte, 2)
+
+	messages := [][]byte{[]byte("1"), []byte("2"), []byte("3")}
+	if queued := queueReplay(client, messages); queued != 2 {
+		t.Fatalf("queueReplay = %d, want the 2 that fit", queued)
+	}
+	if first := <-client.Send; string(first) != "1" {
+		t.Errorf("First queued message = %q, want the replay in order", first)
+	}
+}
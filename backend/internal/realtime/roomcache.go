@@ -0,0 +1,140 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+// Cell updates need the room (state, mode, puzzle), the puzzle answers and the
+// room's players (colors, teams, spectators). All are cached per room instead
+// of being read from the database on every keystroke. Puzzles don't change, so
+// a room's puzzle is fetched once; the room itself is refetched after
+// roomCacheTTL, and immediately whenever this instance changes its state or
+// sees another instance start or end the game. Players are refetched after
+// roomCacheTTL too, and immediately after anyone joins, leaves, is kicked or
+// changes team on any instance.
+
+// roomCacheTTL bounds how stale a cached room can be after a change made outside the hub
+const roomCacheTTL = 2 * time.Second
+
+type cachedRoom struct {
+	room      *models.Room
+	puzzle    *models.Puzzle
+	fetchedAt time.Time
+
+	players          []models.Player
+	playersFetchedAt time.Time
+}
+
+type roomCache struct {
+	mutex   sync.Mutex
+	entries map[string]*cachedRoom // roomID -> cached room
+}
+
+// cachedRoomAndPuzzle returns a room and its puzzle, from the cache when fresh
+func (h *Hub) cachedRoomAndPuzzle(roomID string) (*models.Room, *models.Puzzle) {
+	h.roomCache.mutex.Lock()
+	var cached cachedRoom
+	entry, ok := h.roomCache.entries[roomID]
+	if ok {
+		cached = *entry
+	}
+	h.roomCache.mutex.Unlock()
+	if ok && cached.room != nil && time.Since(cached.fetchedAt) < roomCacheTTL {
+		return cached.room, cached.puzzle
+	}
+
+	if h.db == nil {
+		return nil, nil
+	}
+	room, err := h.db.GetRoomByID(roomID)
+	if err != nil || room == nil {
+		return nil, nil
+	}
+
+	var puzzle *models.Puzzle
+	if ok && cached.puzzle != nil && cached.room != nil && cached.room.PuzzleID == room.PuzzleID {
+		puzzle = cached.puzzle
+	} else {
+		puzzle, _ = h.db.GetPuzzleByID(room.PuzzleID)
+	}
+
+	h.roomCache.mutex.Lock()
+	entry = h.roomCache.entry(roomID)
+	entry.room, entry.puzzle, entry.fetchedAt = room, puzzle, time.Now()
+	h.roomCache.mutex.Unlock()
+	return room, puzzle
+}
+
+// cachedPlayers returns a room's players, from the cache when fresh. The
+// slice is shared by every caller and must not be modified.
+func (h *Hub) cachedPlayers(roomID string) []models.Player {
+	h.roomCache.mutex.Lock()
+	if entry, ok := h.roomCache.entries[roomID]; ok && !entry.playersFetchedAt.IsZero() && time.Since(entry.playersFetchedAt) < roomCacheTTL {
+		players := entry.players
+		h.roomCache.mutex.Unlock()
+		return players
+	}
+	h.roomCache.mutex.Unlock()
+
+	if h.db == nil {
+		return nil
+	}
+	players, err := h.db.GetRoomPlayers(roomID)
+	if err != nil {
+		return nil
+	}
+
+	h.roomCache.mutex.Lock()
+	entry := h.roomCache.entry(roomID)
+	entry.players, entry.playersFetchedAt = players, time.Now()
+	h.roomCache.mutex.Unlock()
+	return players
+}
+
+// entry returns the cache entry for a room, adding an empty one if there is
+// none. Callers must hold the mutex.
+func (c *roomCache) entry(roomID string) *cachedRoom {
+	entry, ok := c.entries[roomID]
+	if !ok {
+		entry = &cachedRoom{}
+		c.entries[roomID] = entry
+	}
+	return entry
+}
+
+// setRoomState updates a room's state in the database and the cache
+func (h *Hub) setRoomState(roomID string, state models.RoomState) error {
+	err := h.db.UpdateRoomState(roomID, state)
+	h.invalidateRoom(roomID)
+	return err
+}
+
+// invalidateRoom makes the next lookup of a room read it from the database,
+// keeping its puzzle
+func (h *Hub) invalidateRoom(roomID string) {
+	h.roomCache.mutex.Lock()
+	defer h.roomCache.mutex.Unlock()
+	if entry, ok := h.roomCache.entries[roomID]; ok {
+		entry.fetchedAt = time.Time{}
+	}
+}
+
+// invalidatePlayers makes the next lookup of a room's players read them from
+// the database
+func (h *Hub) invalidatePlayers(roomID string) {
+	h.roomCache.mutex.Lock()
+	defer h.roomCache.mutex.Unlock()
+	if entry, ok := h.roomCache.entries[roomID]; ok {
+		entry.playersFetchedAt = time.Time{}
+	}
+}
+
+// forgetRoom drops a room from the cache
+func (h *Hub) forgetRoom(roomID string) {
+	h.roomCache.mutex.Lock()
+	defer h.roomCache.mutex.Unlock()
+	delete(h.roomCache.entries, roomID)
+}
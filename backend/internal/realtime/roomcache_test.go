@@ -0,0 +1,45 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+func TestCachedPlayers(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	room := &models.Room{ID: "room-1", Mode: models.RoomModeCollaborative, State: models.RoomStateActive}
+	players := []models.Player{{UserID: "user-1", Color: "#FF6B6B"}}
+	hub.roomCache.entries["room-1"] = &cachedRoom{
+		room:             room,
+		fetchedAt:        time.Now(),
+		players:          players,
+		playersFetchedAt: time.Now(),
+	}
+
+	if got := hub.cachedPlayers("room-1"); len(got) != 1 || got[0].Color != "#FF6B6B" {
+		t.Fatalf("cachedPlayers = %+v, want the cached players", got)
+	}
+
+	// Another instance announcing a join drops the cached players but not the room
+	data, _ := json.Marshal(Message{Type: MsgPlayerJoined, Payload: json.RawMessage(`{}`)})
+	hub.applyRemoteBroadcast("room-1", data)
+	if got := hub.cachedPlayers("room-1"); got != nil {
+		t.Errorf("cachedPlayers after a remote join = %+v, want them refetched", got)
+	}
+	if cached, _ := hub.cachedRoomAndPuzzle("room-1"); cached != room {
+		t.Error("A join should leave the cached room alone")
+	}
+}
+
+func TestCachedRoomWithOnlyPlayers(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	hub.roomCache.entries["room-1"] = &cachedRoom{players: []models.Player{{UserID: "user-1"}}, playersFetchedAt: time.Now()}
+
+	// An entry holding only players has no room to return yet
+	if room, puzzle := hub.cachedRoomAndPuzzle("room-1"); room != nil || puzzle != nil {
+		t.Errorf("cachedRoomAndPuzzle = %v, %v, want nothing without a database", room, puzzle)
+	}
+}
@@ -0,0 +1,291 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+// Once a game is over the host can send rematch to play again in the same
+// room: same code, players and teams, with the next puzzle in the archive, a
+// random one at the same difficulty, or one of their choosing. The room goes
+// back to the lobby and everything about the last game is reset, except chat
+// and the message sequence (so clients can still resume). Everyone gets
+// rematch_started with the room and its new puzzle.
+//
+// Rooms with config.seriesGames play a series of that many games, scored
+// across rematches. In race mode a racer scores one point per place from the
+// bottom (last place scores 1), in team mode every member of a team one point
+// per team placed from the bottom, and in collaborative and relay mode a
+// player one point per correct cell. puzzle_completed carries the series
+// standings; a rematch after the last game starts a new series.
+
+// Where the puzzle of a rematch comes from
+const (
+	rematchNext   = "next"   // the puzzle after this one in the archive (the default)
+	rematchRandom = "random" // a random published puzzle at the same difficulty
+	rematchChosen = "choose" // the puzzle the host gives
+)
+
+// rematchRandomTries bounds the draws for a random puzzle other than the current one
+const rematchRandomTries = 5
+
+// rematchLockTTL bounds how long a rematch claim is held
+const rematchLockTTL = time.Minute
+
+// Series scores are a hash of "points:<userID>" totals, the number of
+// "games" played and a "scored:<startTime>" marker per game
+func roomSeriesKey(roomID string) string { return "room:" + roomID + ":series" }
+
+const seriesGamesField = "games"
+
+func seriesPointsField(userID string) string { return "points:" + userID }
+
+// seriesPoints returns what each player scored in a finished game
+func seriesPoints(mode models.RoomMode, results PuzzleCompletedPayload, contributions map[string]int) map[string]int {
+	points := make(map[string]int)
+	switch mode {
+	case models.RoomModeRace:
+		// Results are in finishing order
+		for i, result := range results.Players {
+			points[result.UserID] = len(results.Players) - i
+		}
+	case models.RoomModeTeam:
+		for _, team := range results.Teams {
+			for _, userID := range team.Players {
+				points[userID] = len(results.Teams) - team.Rank + 1
+			}
+		}
+	default:
+		for _, result := range results.Players {
+			points[result.UserID] = contributions[result.UserID]
+		}
+	}
+	return points
+}
+
+// rankSeries orders the series totals, most points first, and numbers them.
+// Tied players share a rank. Players who left keep their points but have no name.
+func rankSeries(totals map[string]int, players []models.Player) []SeriesStanding {
+	standings := make([]SeriesStanding, 0, len(totals))
+	seen := make(map[string]bool)
+	for _, p := range players {
+		if p.IsSpectator {
+			continue
+		}
+		seen[p.UserID] = true
+		standings = append(standings, SeriesStanding{UserID: p.UserID, DisplayName: p.DisplayName, Points: totals[p.UserID]})
+	}
+	for userID, points := range totals {
+		if !seen[userID] {
+			standings = append(standings, SeriesStanding{UserID: userID, Points: points})
+		}
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].Points != standings[j].Points {
+			return standings[i].Points > standings[j].Points
+		}
+		return standings[i].DisplayName < standings[j].DisplayName
+	})
+	for i := range standings {
+		standings[i].Rank = i + 1
+		if i > 0 && standings[i].Points == standings[i-1].Points {
+			standings[i].Rank = standings[i-1].Rank
+		}
+	}
+	return standings
+}
+
+// seriesStandings returns the room's series so far, or nil if the room doesn't play series
+func (h *Hub) seriesStandings(room *models.Room, players []models.Player) *SeriesStandings {
+	if room.Config.SeriesGames < 2 {
+		return nil
+	}
+
+	values, _ := h.broker.HGetAll(context.Background(), roomSeriesKey(room.ID))
+	games, _ := strconv.Atoi(values[seriesGamesField])
+	totals := make(map[string]int)
+	for field, value := range values {
+		if userID, ok := strings.CutPrefix(field, seriesPointsField("")); ok {
+			totals[userID], _ = strconv.Atoi(value)
+		}
+	}
+
+	return &SeriesStandings{
+		Game:      games,
+		Games:     room.Config.SeriesGames,
+		Finished:  games >= room.Config.SeriesGames,
+		Standings: rankSeries(totals, players),
+	}
+}
+
+// scoreSeries adds a finished game to the room's series and returns the
+// standings with what each player scored this game, or nil if the room doesn't
+// play series
+func (h *Hub) scoreSeries(room *models.Room, results PuzzleCompletedPayload, players []models.Player) *SeriesStandings {
+	if room.Config.SeriesGames < 2 {
+		return nil
+	}
+	points := seriesPoints(room.Mode, results, h.roomContributions(room.ID))
+
+	// Every instance that sees the game end may get here; the game is scored once
+	ctx := context.Background()
+	key := roomSeriesKey(room.ID)
+	game := "0"
+	if startTime := h.roomStartTime(room.ID); startTime != nil {
+		game = strconv.FormatInt(startTime.UnixNano(), 10)
+	}
+	if first, err := h.broker.HSetNX(ctx, key, "scored:"+game, "1"); err == nil && first {
+		h.broker.HIncrBy(ctx, key, seriesGamesField, 1)
+		for userID, n := range points {
+			h.broker.HIncrBy(ctx, key, seriesPointsField(userID), int64(n))
+		}
+		h.broker.Expire(ctx, key, roomStateTTL)
+	}
+
+	standings := h.seriesStandings(room, players)
+	for i := range standings.Standings {
+		standings.Standings[i].GamePoints = points[standings.Standings[i].UserID]
+	}
+	return standings
+}
+
+// completePuzzle broadcasts the results of a finished game, scoring it for the series
+func (h *Hub) completePuzzle(room *models.Room, players []models.Player, results PuzzleCompletedPayload) {
+	results.Series = h.scoreSeries(room, results, players)
+	h.broadcastToRoom(room.ID, "", MsgPuzzleCompleted, results)
+	h.reportHeat(room, results)
+}
+
+// rematchPuzzle picks the puzzle for a rematch. problem says why there is none.
+func (h *Hub) rematchPuzzle(room *models.Room, p RematchPayload) (puzzle *models.Puzzle, problem string) {
+	switch p.Puzzle {
+	case "", rematchNext:
+		puzzle, _ = h.db.GetNextArchivePuzzle(room.PuzzleID)
+	case rematchRandom:
+		current, _ := h.db.GetPuzzleByID(room.PuzzleID)
+		if current == nil {
+			return nil, "puzzle not found"
+		}
+		for i := 0; i < rematchRandomTries; i++ {
+			if puzzle, _ = h.db.GetRandomPuzzle(string(current.Difficulty)); puzzle == nil || puzzle.ID != room.PuzzleID {
+				break
+			}
+		}
+		if puzzle != nil && puzzle.ID == room.PuzzleID {
+			puzzle = nil
+		}
+	case rematchChosen:
+		if p.PuzzleID == "" {
+			return nil, "choose a puzzle for the rematch"
+		}
+		puzzle, _ = h.db.GetPuzzleByID(p.PuzzleID)
+	default:
+		return nil, "unknown rematch puzzle"
+	}
+	if puzzle == nil {
+		return nil, "no puzzle available for a rematch"
+	}
+	return puzzle, ""
+}
+
+// claimRematch reports whether this instance should start the rematch of
+// the game that started at startTime; the host may ask more than once
+func (h *Hub) claimRematch(roomID string, startTime *time.Time) bool {
+	game := "0"
+	if startTime != nil {
+		game = strconv.FormatInt(startTime.UnixNano(), 10)
+	}
+	claimed, err := h.broker.SetNX(context.Background(), "room:"+roomID+":rematch:"+game, h.instanceID, rematchLockTTL)
+	return err == nil && claimed
+}
+
+func (h *Hub) handleRematch(client *Client, payload json.RawMessage) {
+	if client.RoomID == "" {
+		return
+	}
+
+	var p RematchPayload
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			h.sendError(client, "invalid payload")
+			return
+		}
+	}
+
+	room, err := h.db.GetRoomByID(client.RoomID)
+	if err != nil || room == nil {
+		return
+	}
+	if room.HostID != client.UserID {
+		h.sendError(client, "only host can start a rematch")
+		return
+	}
+	if room.State != models.RoomStateCompleted {
+		h.sendError(client, "the game isn't over yet")
+		return
+	}
+	if room.Config.TournamentID != "" {
+		h.sendError(client, "tournament heats can't be rematched")
+		return
+	}
+
+	puzzle, problem := h.rematchPuzzle(room, p)
+	if problem != "" {
+		h.sendError(client, problem)
+		return
+	}
+	if !h.claimRematch(room.ID, h.roomStartTime(room.ID)) {
+		return
+	}
+
+	// A finished series makes way for a new one
+	players, _ := h.db.GetRoomPlayers(room.ID)
+	if series := h.seriesStandings(room, players); series != nil && series.Finished {
+		h.broker.Del(context.Background(), roomSeriesKey(room.ID))
+	}
+
+	// Write out the last game's events first, so none land after the reset
+	h.flushEvents()
+	if err := h.db.ResetRoomForRematch(room.ID, puzzle.ID); err != nil {
+		log.Printf("handleRematch: %v", err)
+		h.sendError(client, "failed to start rematch")
+		return
+	}
+	h.resetRoomState(room.ID)
+	h.resetLocalRoom(room.ID)
+	h.NotifyRoomChanged(room.ID)
+
+	room, _ = h.cachedRoomAndPuzzle(room.ID)
+	if room == nil {
+		return
+	}
+	players, _ = h.db.GetRoomPlayers(room.ID)
+	h.broadcastToRoom(room.ID, "", MsgRematchStarted, RematchStartedPayload{
+		Room:    room,
+		Puzzle:  sanitizePuzzleForClient(puzzle),
+		Players: players,
+		Series:  h.seriesStandings(room, players),
+	})
+
+	log.Printf("handleRematch: room %s reset with puzzle %s", room.ID, puzzle.ID)
+}
+
+// resetLocalRoom forgets this instance's copy of a room's last game
+func (h *Hub) resetLocalRoom(roomID string) {
+	h.dropRoomGrids(roomID)
+	h.forgetRoom(roomID)
+	h.scheduleTurnTimeout(roomID, time.Time{})
+	if hubRoom := h.localRoom(roomID); hubRoom != nil {
+		hubRoom.mutex.Lock()
+		hubRoom.histories = nil
+		hubRoom.mutex.Unlock()
+	}
+}
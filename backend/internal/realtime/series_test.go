@@ -0,0 +1,136 @@
+package realtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+func TestSeriesPoints(t *testing.T) {
+	race := PuzzleCompletedPayload{Players: []PlayerResult{{UserID: "a"}, {UserID: "b"}, {UserID: "c"}}}
+	points := seriesPoints(models.RoomModeRace, race, nil)
+	if points["a"] != 3 || points["b"] != 2 || points["c"] != 1 {
+		t.Errorf("Race points = %v, want 3, 2 and 1 in finishing order", points)
+	}
+
+	team := PuzzleCompletedPayload{Teams: []TeamResult{
+		{TeamID: "red", Rank: 1, Players: []string{"a", "b"}},
+		{TeamID: "blue", Rank: 2, Players: []string{"c"}},
+	}}
+	points = seriesPoints(models.RoomModeTeam, team, nil)
+	if points["a"] != 2 || points["b"] != 2 || points["c"] != 1 {
+		t.Errorf("Team points = %v, want every member scoring their team's place", points)
+	}
+
+	collaborative := PuzzleCompletedPayload{Players: []PlayerResult{{UserID: "a"}, {UserID: "b"}}}
+	points = seriesPoints(models.RoomModeCollaborative, collaborative, map[string]int{"a": 12, "c": 4})
+	if points["a"] != 12 || points["b"] != 0 || points["c"] != 0 {
+		t.Errorf("Collaborative points = %v, want the players' correct cells", points)
+	}
+}
+
+func TestRankSeries(t *testing.T) {
+	players := []models.Player{
+		{UserID: "a", DisplayName: "Ann"},
+		{UserID: "b", DisplayName: "Bea"},
+		{UserID: "s", DisplayName: "Sam", IsSpectator: true},
+		{UserID: "c", DisplayName: "Cal"},
+	}
+	standings := rankSeries(map[string]int{"a": 4, "b": 6, "c": 4, "gone": 1}, players)
+
+	want := []SeriesStanding{
+		{UserID: "b", DisplayName: "Bea", Points: 6, Rank: 1},
+		{UserID: "a", DisplayName: "Ann", Points: 4, Rank: 2},
+		{UserID: "c", DisplayName: "Cal", Points: 4, Rank: 2},
+		{UserID: "gone", Points: 1, Rank: 4},
+	}
+	if len(standings) != len(want) {
+		t.Fatalf("Got %d standings, want %d: %+v", len(standings), len(want), standings)
+	}
+	for i := range want {
+		if standings[i] != want[i] {
+			t.Errorf("Standing %d = %+v, want %+v", i, standings[i], want[i])
+		}
+	}
+}
+
+func TestScoreSeries(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	room := &models.Room{ID: "room-1", Mode: models.RoomModeRace, Config: models.RoomConfig{SeriesGames: 2}}
+	players := []models.Player{{UserID: "a", DisplayName: "Ann"}, {UserID: "b", DisplayName: "Bea"}}
+
+	hub.setRoomStartTime(room.ID, time.Now())
+	first := PuzzleCompletedPayload{Players: []PlayerResult{{UserID: "a"}, {UserID: "b"}}}
+	series := hub.scoreSeries(room, first, players)
+	if series == nil || series.Game != 1 || series.Finished {
+		t.Fatalf("After one game series = %+v, want game 1 of 2", series)
+	}
+
+	// Another instance seeing the same game end doesn't score it again
+	series = hub.scoreSeries(room, first, players)
+	if series.Game != 1 || series.Standings[0].Points != 2 {
+		t.Errorf("A game should only be scored once, got %+v", series)
+	}
+
+	hub.setRoomStartTime(room.ID, time.Now().Add(time.Minute))
+	second := PuzzleCompletedPayload{Players: []PlayerResult{{UserID: "b"}, {UserID: "a"}}}
+	series = hub.scoreSeries(room, second, players)
+	if series.Game != 2 || !series.Finished {
+		t.Errorf("After two games series = %+v, want it finished", series)
+	}
+	for _, standing := range series.Standings {
+		if standing.Points != 3 || standing.Rank != 1 {
+			t.Errorf("Standing %+v, want both players tied on 3", standing)
+		}
+	}
+	if series.Standings[0].GamePoints+series.Standings[1].GamePoints != 3 {
+		t.Errorf("Game points should be this game's, got %+v", series.Standings)
+	}
+
+	room.Config.SeriesGames = 0
+	if hub.scoreSeries(room, first, players) != nil {
+		t.Error("A room without a series shouldn't be scored")
+	}
+}
+
+func TestResetRoomState(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	ctx := context.Background()
+
+	hub.setRoomStartTime("room-1", time.Now())
+	hub.nextSeq("room-1")
+	hub.addContribution("room-1", "a")
+	hub.recordFinish("room-1", "a")
+
+	hub.resetRoomState("room-1")
+
+	if hub.roomStartTime("room-1") != nil {
+		t.Error("The last game's start time should be cleared")
+	}
+	if len(hub.roomContributions("room-1")) != 0 || len(hub.finishOrder("room-1")) != 0 {
+		t.Error("Contributions and finish order should be cleared")
+	}
+	if state, _ := hub.broker.HGetAll(ctx, roomStateKey("room-1")); state["seq"] != "1" {
+		t.Errorf("The message sequence should be kept, state = %v", state)
+	}
+}
+
+func TestClaimRematch(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := NewHubWithBroker(nil, broker)
+	hubB := NewHubWithBroker(nil, broker)
+
+	started := time.Now()
+	if !hubA.claimRematch("room-1", &started) {
+		t.Error("The first rematch request should be claimed")
+	}
+	if hubB.claimRematch("room-1", &started) {
+		t.Error("A second request for the same game should not start another rematch")
+	}
+	next := started.Add(time.Minute)
+	if !hubB.claimRematch("room-1", &next) {
+		t.Error("The next game should be able to rematch")
+	}
+}
@@ -0,0 +1,217 @@
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+// Spectators watch a room without taking part: they can't edit a grid, move a
+// cursor, use hints or run the game, chat only outside play and don't count
+// towards the room's player limit. In race and team mode they see every grid
+// side by side, so edits to a player's or team's grid are sent to them too.
+//
+// A room can delay its spectators' view by Config.SpectatorDelay seconds so
+// nobody watching can coach the players. Room messages for spectators on this
+// instance then go through the room's spectator feed, which delivers them in
+// order once the delay has passed.
+
+// spectatorFeedSize is how many delayed messages a room holds before dropping
+// new ones, like a full client send channel
+const spectatorFeedSize = 4096
+
+// spectatorBlocked lists the messages spectators may not send
+var spectatorBlocked = map[MessageType]bool{
+	MsgCellUpdate:  true,
+	MsgCursorMove:  true,
+	MsgRequestHint: true,
+	MsgStartGame:   true,
+	MsgPassTurn:    true,
+	MsgVoteSkip:    true,
+	MsgPauseGame:   true,
+	MsgResumeGame:  true,
+	MsgSetTeam:     true,
+	MsgSetClueNote: true,
+	MsgUndo:        true,
+	MsgRedo:        true,
+}
+
+// spectatorMessage is a message waiting out the spectator delay. It goes to
+// every spectator in the room, or only to users or one connection when set.
+type spectatorMessage struct {
+	due        time.Time
+	exclude    string
+	users      map[string]bool
+	connection string
+	data       []byte
+}
+
+type spectatorFeed struct {
+	delay    time.Duration
+	messages chan spectatorMessage
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newSpectatorFeed(delay time.Duration) *spectatorFeed {
+	return &spectatorFeed{
+		delay:    delay,
+		messages: make(chan spectatorMessage, spectatorFeedSize),
+		done:     make(chan struct{}),
+	}
+}
+
+// push queues a message to be delivered after the delay
+func (f *spectatorFeed) push(m spectatorMessage) {
+	m.due = time.Now().Add(f.delay)
+	select {
+	case f.messages <- m:
+	default:
+		// Feed full, skip message
+	}
+}
+
+func (f *spectatorFeed) stop() {
+	f.stopOnce.Do(func() { close(f.done) })
+}
+
+// startSpectatorFeed gives a new hub room its spectator feed if the room delays spectators
+func (r *Room) startSpectatorFeed(room *models.Room) {
+	if room.Config.SpectatorDelay <= 0 {
+		return
+	}
+	r.feed = newSpectatorFeed(time.Duration(room.Config.SpectatorDelay) * time.Second)
+	go r.runSpectatorFeed(r.feed)
+}
+
+func (r *Room) stopSpectatorFeed() {
+	if r != nil && r.feed != nil {
+		r.feed.stop()
+	}
+}
+
+// delaysClient reports whether the room holds back messages for the client.
+// The caller holds r.mutex.
+func (r *Room) delaysClient(client *Client) bool {
+	return r.feed != nil && client.IsSpectator
+}
+
+func (r *Room) runSpectatorFeed(feed *spectatorFeed) {
+	for {
+		select {
+		case <-feed.done:
+			return
+		case m := <-feed.messages:
+			if wait := time.Until(m.due); wait > 0 {
+				select {
+				case <-feed.done:
+					return
+				case <-time.After(wait):
+				}
+			}
+			r.deliverToSpectators(m)
+		}
+	}
+}
+
+// deliverToSpectators sends a delayed message to the room's spectators on this instance
+func (r *Room) deliverToSpectators(m spectatorMessage) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	msg := newOutgoing(m.data)
+	for connectionID, client := range r.Clients {
+		if !client.IsSpectator || connectionID == m.exclude {
+			continue
+		}
+		if m.connection != "" && connectionID != m.connection {
+			continue
+		}
+		if m.users != nil && !m.users[client.UserID] {
+			continue
+		}
+		client.trySend(msg) // skipped if the channel is full
+	}
+}
+
+// delayedFeed returns the feed the client's messages go through, or nil if
+// they aren't delayed
+func (h *Hub) delayedFeed(client *Client) *spectatorFeed {
+	h.mutex.RLock()
+	hubRoom, exists := h.rooms[client.RoomID]
+	h.mutex.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	hubRoom.mutex.RLock()
+	defer hubRoom.mutex.RUnlock()
+	if _, ok := hubRoom.Clients[client.ConnectionID]; !ok || !hubRoom.delaysClient(client) {
+		return nil
+	}
+	return hubRoom.feed
+}
+
+// sendDelayed sends a message to a client, behind the spectator delay if the
+// client is a delayed spectator
+func (h *Hub) sendDelayed(client *Client, msgType MessageType, payload interface{}) {
+	feed := h.delayedFeed(client)
+	if feed == nil {
+		h.sendToClient(client, msgType, payload)
+		return
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	msgData, err := json.Marshal(Message{Type: msgType, Payload: data})
+	if err != nil {
+		return
+	}
+	feed.push(spectatorMessage{connection: client.ConnectionID, data: msgData})
+}
+
+// spectatorIDs returns the user IDs of the room's spectators
+func spectatorIDs(players []models.Player) []string {
+	var ids []string
+	for _, p := range players {
+		if p.IsSpectator {
+			ids = append(ids, p.UserID)
+		}
+	}
+	return ids
+}
+
+// withSpectators adds the room's spectators to the recipients of a message
+// about one player's or team's grid
+func withSpectators(userIDs []string, players []models.Player) []string {
+	return append(append([]string(nil), userIDs...), spectatorIDs(players)...)
+}
+
+// spectatorGrids returns every player's grid in race mode and every team's
+// grid in team mode, keyed by user or team ID
+func (h *Hub) spectatorGrids(room *models.Room, players []models.Player) map[string]*models.GridState {
+	grids := make(map[string]*models.GridState)
+	switch room.Mode {
+	case models.RoomModeRace:
+		for _, p := range players {
+			if p.IsSpectator {
+				continue
+			}
+			if gridState := h.playerGridState(room.ID, p.UserID); gridState != nil {
+				grids[p.UserID] = gridState
+			}
+		}
+	case models.RoomModeTeam:
+		for _, team := range roomTeams(room) {
+			if gridState := h.teamGridState(room.ID, team.ID); gridState != nil {
+				grids[team.ID] = gridState
+			}
+		}
+	default:
+		return nil
+	}
+	return grids
+}
@@ -0,0 +1,84 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+func TestSpectatorsCannotWrite(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	spectator := newTestClient("conn-s", "user-s")
+	spectator.IsSpectator = true
+	addTestRoom(hub, "room-1", spectator)
+
+	for _, msgType := range []MessageType{MsgCellUpdate, MsgCursorMove, MsgRequestHint, MsgStartGame, MsgPassTurn, MsgSetTeam, MsgSetClueNote} {
+		hub.HandleMessage(spectator, &Message{Type: msgType, Payload: json.RawMessage(`{}`)})
+
+		msg := receiveMessage(t, spectator, time.Second)
+		if msg == nil || msg.Type != MsgError {
+			t.Fatalf("Expected an error for %s from a spectator, got %+v", msgType, msg)
+		}
+	}
+}
+
+func TestDelayedBroadcastsReachSpectatorsLate(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	player := newTestClient("conn-p", "user-p")
+	spectator := newTestClient("conn-s", "user-s")
+	spectator.IsSpectator = true
+	addTestRoom(hub, "room-1", player, spectator)
+
+	delay := 300 * time.Millisecond
+	hubRoom := hub.rooms["room-1"]
+	hubRoom.feed = newSpectatorFeed(delay)
+	go hubRoom.runSpectatorFeed(hubRoom.feed)
+	defer hubRoom.stopSpectatorFeed()
+
+	sent := time.Now()
+	hub.broadcastToRoom("room-1", "", MsgNewMessage, NewMessagePayload{Text: "first"})
+	hub.sendToUsers("room-1", "", []string{"user-p", "user-s"}, MsgCellUpdated, CellUpdatedPayload{X: 1, Value: "A"})
+
+	if msg := receiveMessage(t, player, time.Second); msg == nil || msg.Type != MsgNewMessage {
+		t.Fatalf("Player should get the broadcast right away, got %+v", msg)
+	}
+	if msg := receiveMessage(t, player, time.Second); msg == nil || msg.Type != MsgCellUpdated {
+		t.Fatalf("Player should get the cell update right away, got %+v", msg)
+	}
+	if msg := receiveMessage(t, spectator, delay/2); msg != nil {
+		t.Fatalf("Spectator got %s before the delay", msg.Type)
+	}
+
+	for _, want := range []MessageType{MsgNewMessage, MsgCellUpdated} {
+		msg := receiveMessage(t, spectator, time.Second)
+		if msg == nil || msg.Type != want {
+			t.Fatalf("Expected delayed %s, got %+v", want, msg)
+		}
+	}
+	if elapsed := time.Since(sent); elapsed < delay {
+		t.Errorf("Spectator got the messages after %v, want at least %v", elapsed, delay)
+	}
+}
+
+func TestSpectatorGrids(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	room := &models.Room{ID: "room-1", Mode: models.RoomModeRace}
+	players := []models.Player{
+		{UserID: "user-1"},
+		{UserID: "user-2"},
+		{UserID: "user-s", IsSpectator: true},
+	}
+	hub.setGrid("room-1", "user-1", newTestGridState("room-1", 2, 2))
+	hub.setGrid("room-1", "user-2", newTestGridState("room-1", 2, 2))
+
+	grids := hub.spectatorGrids(room, players)
+	if len(grids) != 2 || grids["user-1"] == nil || grids["user-2"] == nil {
+		t.Errorf("Expected both players' race grids, got %v", grids)
+	}
+
+	if ids := withSpectators([]string{"user-1"}, players); len(ids) != 2 || ids[1] != "user-s" {
+		t.Errorf("withSpectators = %v, want [user-1 user-s]", ids)
+	}
+}
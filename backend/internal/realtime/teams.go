@@ -0,0 +1,419 @@
+package realtime
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+// In team mode the players are split into teams that race each other. Players
+// pick a team in the lobby (the host can move anyone) and whoever hasn't picked
+// one is put on the smallest team when the game starts. Each team shares a
+// grid that is edited like a collaborative grid (see grid.go) and stored under
+// the owner "team:<id>". Cell updates, cursors and team chat only go to the
+// team's members and the room's spectators; standings go to the whole room
+// as team_progress, like race_progress in race mode.
+
+const (
+	defaultTeamCount = 2
+	maxTeamCount     = 4
+)
+
+var teamPalette = []models.Team{
+	{ID: "red", Name: "Red", Color: "#FF6B6B"},
+	{ID: "blue", Name: "Blue", Color: "#4D96FF"},
+	{ID: "green", Name: "Green", Color: "#6BCB77"},
+	{ID: "gold", Name: "Gold", Color: "#FFD93D"},
+}
+
+// teamGridOwner is the grid owner under which a team's grid is stored
+func teamGridOwner(teamID string) string { return "team:" + teamID }
+
+// roomTeams returns the teams of a team mode room
+func roomTeams(room *models.Room) []models.Team {
+	n := room.Config.TeamCount
+	if n < defaultTeamCount {
+		n = defaultTeamCount
+	}
+	if n > maxTeamCount {
+		n = maxTeamCount
+	}
+	return teamPalette[:n]
+}
+
+func findTeam(teams []models.Team, teamID string) *models.Team {
+	for i := range teams {
+		if teams[i].ID == teamID {
+			return &teams[i]
+		}
+	}
+	return nil
+}
+
+// teamMembers returns the user IDs of the players on a team
+func teamMembers(players []models.Player, teamID string) []string {
+	var members []string
+	for _, p := range players {
+		if !p.IsSpectator && p.TeamID == teamID {
+			members = append(members, p.UserID)
+		}
+	}
+	return members
+}
+
+// teamsInPlay returns the teams that have players
+func teamsInPlay(teams []models.Team, players []models.Player) []models.Team {
+	var playing []models.Team
+	for _, team := range teams {
+		if len(teamMembers(players, team.ID)) > 0 {
+			playing = append(playing, team)
+		}
+	}
+	return playing
+}
+
+// smallestTeam returns the team with the fewest players, the first one on a tie
+func smallestTeam(teams []models.Team, players []models.Player) string {
+	best, bestSize := "", -1
+	for _, team := range teams {
+		size := len(teamMembers(players, team.ID))
+		if bestSize < 0 || size < bestSize {
+			best, bestSize = team.ID, size
+		}
+	}
+	return best
+}
+
+// teamGrid returns a team's shared grid
+func (h *Hub) teamGrid(roomID, teamID string) *sharedGrid {
+	return h.loadGrid(roomID, teamGridOwner(teamID))
+}
+
+// teamGridState returns a snapshot of a team's grid
+func (h *Hub) teamGridState(roomID, teamID string) *models.GridState {
+	if grid := h.teamGrid(roomID, teamID); grid != nil {
+		return grid.snapshot()
+	}
+	return nil
+}
+
+// playerTeam returns the team a user plays on and its members; teamID is
+// empty outside team mode and for spectators
+func playerTeam(players []models.Player, userID string) (teamID string, members []string) {
+	player := findPlayer(players, userID)
+	if player == nil || player.IsSpectator || player.TeamID == "" {
+		return "", nil
+	}
+	return player.TeamID, teamMembers(players, player.TeamID)
+}
+
+// broadcastCellUpdate sends a cell update to the room, or only to members (the
+// team whose grid was written and the spectators) when teamID is set
+func (h *Hub) broadcastCellUpdate(roomID, teamID string, members []string, payload CellUpdatedPayload) {
+	if teamID == "" {
+		h.broadcastToRoom(roomID, "", MsgCellUpdated, payload)
+		return
+	}
+	payload.TeamID = teamID
+	h.sendToUsers(roomID, "", members, MsgCellUpdated, payload)
+}
+
+// handleSetTeam moves a player to another team in a team mode lobby. Players
+// move themselves; the host can move anyone.
+func (h *Hub) handleSetTeam(client *Client, payload json.RawMessage) {
+	if client.RoomID == "" {
+		return
+	}
+
+	var p SetTeamPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		h.sendError(client, "invalid payload")
+		return
+	}
+
+	room, err := h.db.GetRoomByID(client.RoomID)
+	if err != nil || room == nil {
+		return
+	}
+	if room.Mode != models.RoomModeTeam {
+		h.sendError(client, "room is not in team mode")
+		return
+	}
+	if room.State != models.RoomStateLobby {
+		h.sendError(client, "teams can only be changed in the lobby")
+		return
+	}
+
+	userID := client.UserID
+	if p.UserID != "" && p.UserID != client.UserID {
+		if room.HostID != client.UserID {
+			h.sendError(client, "only host can move other players")
+			return
+		}
+		userID = p.UserID
+	}
+	if findTeam(roomTeams(room), p.TeamID) == nil {
+		h.sendError(client, "unknown team")
+		return
+	}
+
+	players, _ := h.db.GetRoomPlayers(room.ID)
+	player := findPlayer(players, userID)
+	if player == nil || player.IsSpectator {
+		h.sendError(client, "player not found")
+		return
+	}
+	if player.TeamID == p.TeamID {
+		return
+	}
+
+	if err := h.db.UpdatePlayerTeam(userID, room.ID, p.TeamID); err != nil {
+		log.Printf("handleSetTeam: failed to move %s to team %s: %v", userID, p.TeamID, err)
+		h.sendError(client, "failed to change team")
+		return
+	}
+	h.invalidatePlayers(room.ID)
+
+	h.broadcastToRoom(room.ID, "", MsgTeamChanged, TeamChangedPayload{
+		UserID: userID,
+		TeamID: p.TeamID,
+	})
+}
+
+// assignTeamOnJoin puts a player joining a team mode lobby on the smallest team
+func (h *Hub) assignTeamOnJoin(room *models.Room, userID string) {
+	if room.Mode != models.RoomModeTeam || room.State != models.RoomStateLobby {
+		return
+	}
+
+	players, _ := h.db.GetRoomPlayers(room.ID)
+	player := findPlayer(players, userID)
+	if player == nil || player.IsSpectator || player.TeamID != "" {
+		return
+	}
+
+	if err := h.db.UpdatePlayerTeam(userID, room.ID, smallestTeam(roomTeams(room), players)); err != nil {
+		log.Printf("assignTeamOnJoin: failed to assign a team to %s: %v", userID, err)
+	}
+	h.invalidatePlayers(room.ID)
+}
+
+// balanceTeams puts every player without a team on the smallest team and
+// returns the players with their teams
+func (h *Hub) balanceTeams(room *models.Room, players []models.Player) []models.Player {
+	teams := roomTeams(room)
+	for i := range players {
+		player := &players[i]
+		if player.IsSpectator || findTeam(teams, player.TeamID) != nil {
+			continue
+		}
+
+		teamID := smallestTeam(teams, players)
+		if err := h.db.UpdatePlayerTeam(player.UserID, room.ID, teamID); err != nil {
+			log.Printf("balanceTeams: failed to assign a team to %s: %v", player.UserID, err)
+			continue
+		}
+		player.TeamID = teamID
+		h.invalidatePlayers(room.ID)
+
+		h.broadcastToRoom(room.ID, "", MsgTeamChanged, TeamChangedPayload{
+			UserID: player.UserID,
+			TeamID: teamID,
+		})
+	}
+	return players
+}
+
+func (h *Hub) handleTeamCellUpdate(client *Client, puzzle *models.Puzzle, p *CellUpdatePayload) {
+	players := h.cachedPlayers(client.RoomID)
+	teamID, members := playerTeam(players, client.UserID)
+	if teamID == "" {
+		h.sendError(client, "you are not on a team")
+		return
+	}
+
+	grid := h.teamGrid(client.RoomID, teamID)
+	if grid == nil {
+		return
+	}
+
+	// Teammates' edits are merged per cell, as in collaborative mode
+	_, before, cell, applied := h.writeCell(grid, client.RoomID, p.X, p.Y, playerCellEdit(p, client.UserID))
+	if !applied {
+		return
+	}
+	h.rememberEdit(client, teamGridOwner(teamID), p.X, p.Y, before, cell)
+	if isNewlyCorrect(puzzle, p, cellAnswer(before)) {
+		h.addContribution(client.RoomID, client.UserID)
+	}
+
+	color := "#888888"
+	if player := findPlayer(players, client.UserID); player != nil {
+		color = player.Color
+	}
+
+	cellUpdate := cellUpdated(p.X, p.Y, cell)
+	cellUpdate.PlayerID = client.UserID
+	cellUpdate.Color = color
+	cellUpdate.TeamID = teamID
+	if p.Candidates == nil {
+		h.recordEvent(client.RoomID, models.RoomEventCell, client.UserID, p.X, p.Y, cellUpdate.Value)
+	}
+
+	h.sendToUsers(client.RoomID, "", withSpectators(members, players), MsgCellUpdated, cellUpdate)
+
+	h.checkTeamProgress(client.RoomID)
+}
+
+// checkTeamProgress broadcasts the team standings, records teams that just
+// finished and ends the game once every team has finished
+func (h *Hub) checkTeamProgress(roomID string) {
+	room, puzzle := h.cachedRoomAndPuzzle(roomID)
+	if room == nil || puzzle == nil || room.State != models.RoomStateActive {
+		return
+	}
+
+	players := h.cachedPlayers(roomID)
+	teams := teamsInPlay(roomTeams(room), players)
+
+	var standings []models.TeamProgress
+	allFinished := true
+	for _, team := range teams {
+		members := teamMembers(players, team.ID)
+		tp := models.TeamProgress{
+			TeamID:  team.ID,
+			Name:    team.Name,
+			Players: members,
+		}
+
+		gridState := h.teamGridState(roomID, team.ID)
+		if gridState == nil {
+			standings = append(standings, tp)
+			allFinished = false
+			continue
+		}
+
+		correctCells, totalCells := countCorrectCells(puzzle, gridState)
+		if totalCells > 0 {
+			tp.Progress = float64(correctCells) / float64(totalCells) * 100
+		}
+
+		if correctCells == totalCells {
+			// The finish order is shared, so a team finishing on another instance can't take the same rank
+			if rank, ok := h.recordFinish(roomID, teamGridOwner(team.ID)); ok {
+				now := time.Now()
+				solveTime := h.solveSeconds(roomID)
+				tp.FinishedAt = &now
+				tp.SolveTime = &solveTime
+				tp.Rank = rank
+
+				h.broadcastToRoom(roomID, "", MsgTeamFinished, TeamFinishedPayload{
+					TeamID:    team.ID,
+					Name:      team.Name,
+					SolveTime: solveTime,
+					Rank:      rank,
+				})
+
+				// Every member shares the team's rank (rank 1 = win)
+				for _, userID := range members {
+					h.updateUserStatsAfterCompletion(userID, room.PuzzleID, &roomID, solveTime, rank, gridState)
+				}
+			}
+		} else {
+			allFinished = false
+		}
+
+		standings = append(standings, tp)
+	}
+
+	h.broadcastToRoom(roomID, "", MsgTeamProgress, TeamProgressPayload{
+		Teams: standings,
+	})
+
+	if allFinished && len(teams) > 0 && h.stopClock(roomID, time.Now()) {
+		h.setRoomState(roomID, models.RoomStateCompleted)
+		h.flushRoomGrids(roomID)
+
+		results, teamResults := h.teamResults(roomID, puzzle, players, teams)
+		h.completePuzzle(room, players, PuzzleCompletedPayload{
+			SolveTime:   h.solveSeconds(roomID),
+			Players:     results,
+			CompletedAt: time.Now(),
+			Teams:       teamResults,
+		})
+	}
+}
+
+// teamResults returns the players' results, crediting each with their share
+// of their team's grid, and the team standings: finished teams in finish
+// order, then the others by progress
+func (h *Hub) teamResults(roomID string, puzzle *models.Puzzle, players []models.Player, teams []models.Team) ([]PlayerResult, []TeamResult) {
+	totalCells := 0
+	if puzzle != nil {
+		_, totalCells = countCorrectCells(puzzle, nil)
+	}
+	contributions := h.roomContributions(roomID)
+
+	var results []PlayerResult
+	for _, p := range players {
+		if p.IsSpectator {
+			continue
+		}
+		contribution := 0.0
+		if totalCells > 0 {
+			contribution = float64(contributions[p.UserID]) / float64(totalCells) * 100.0
+			h.db.UpdatePlayerContribution(p.UserID, roomID, contribution)
+		}
+		results = append(results, PlayerResult{
+			UserID:       p.UserID,
+			DisplayName:  p.DisplayName,
+			Contribution: contribution,
+			Color:        p.Color,
+			TeamID:       p.TeamID,
+		})
+	}
+
+	finishRanks := make(map[string]int)
+	for i, owner := range h.finishOrder(roomID) {
+		finishRanks[owner] = i + 1
+	}
+
+	var teamResults []TeamResult
+	for _, team := range teams {
+		tr := TeamResult{
+			TeamID:  team.ID,
+			Name:    team.Name,
+			Color:   team.Color,
+			Players: teamMembers(players, team.ID),
+		}
+		if gridState := h.teamGridState(roomID, team.ID); gridState != nil && puzzle != nil && totalCells > 0 {
+			correctCells, _ := countCorrectCells(puzzle, gridState)
+			tr.Progress = float64(correctCells) / float64(totalCells) * 100
+		}
+		teamResults = append(teamResults, tr)
+	}
+	rankTeams(teamResults, finishRanks)
+
+	return results, teamResults
+}
+
+// rankTeams orders team results, finished teams first by finish rank and the
+// rest by progress, and numbers them
+func rankTeams(results []TeamResult, finishRanks map[string]int) {
+	sort.SliceStable(results, func(i, j int) bool {
+		ri, rj := finishRanks[teamGridOwner(results[i].TeamID)], finishRanks[teamGridOwner(results[j].TeamID)]
+		if (ri == 0) != (rj == 0) {
+			return rj == 0
+		}
+		if ri != rj {
+			return ri < rj
+		}
+		return results[i].Progress > results[j].Progress
+	})
+	for i := range results {
+		results[i].Rank = i + 1
+	}
+}
@@ -0,0 +1,115 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+func TestRoomTeams(t *testing.T) {
+	tests := []struct {
+		teamCount int
+		want      int
+	}{
+		{0, 2},
+		{1, 2},
+		{3, 3},
+		{9, 4},
+	}
+	for _, tt := range tests {
+		room := &models.Room{Mode: models.RoomModeTeam, Config: models.RoomConfig{TeamCount: tt.teamCount}}
+		if got := len(roomTeams(room)); got != tt.want {
+			t.Errorf("roomTeams(teamCount=%d) has %d teams, want %d", tt.teamCount, got, tt.want)
+		}
+	}
+}
+
+func TestSmallestTeam(t *testing.T) {
+	teams := teamPalette[:3]
+	players := []models.Player{
+		{UserID: "a", TeamID: "red"},
+		{UserID: "b", TeamID: "red"},
+		{UserID: "c", TeamID: "blue"},
+		{UserID: "d", TeamID: "green", IsSpectator: true},
+	}
+	if got := smallestTeam(teams, players); got != "green" {
+		t.Errorf("smallestTeam = %q, want green (spectators don't count)", got)
+	}
+
+	players = append(players, models.Player{UserID: "e", TeamID: "green"})
+	if got := smallestTeam(teams, players); got != "blue" {
+		t.Errorf("smallestTeam = %q, want blue (first of the tied teams)", got)
+	}
+
+	if playing := teamsInPlay(teams, players[:3]); len(playing) != 2 {
+		t.Errorf("teamsInPlay = %v, want red and blue", playing)
+	}
+}
+
+func TestTeamMessagesOnlyReachTeamAcrossInstances(t *testing.T) {
+	broker := NewMemoryBroker()
+	hubA := NewHubWithBroker(nil, broker)
+	hubB := NewHubWithBroker(nil, broker)
+	runHub(t, hubA)
+	runHub(t, hubB)
+
+	red1 := newTestClient("conn-1", "red-1")
+	blue1 := newTestClient("conn-2", "blue-1")
+	red2 := newTestClient("conn-3", "red-2")
+	blue2 := newTestClient("conn-4", "blue-2")
+	addTestRoom(hubA, "room-1", red1, blue1)
+	addTestRoom(hubB, "room-1", red2, blue2)
+
+	// The other instance keeps its copy of the red grid in step
+	gridB := hubB.setGrid("room-1", teamGridOwner("red"), newTestGridState("room-1", 3, 3))
+
+	hubA.broadcastCellUpdate("room-1", "red", []string{"red-1", "red-2"}, CellUpdatedPayload{
+		X: 1, Y: 2, Value: "Q", PlayerID: "red-1", Version: 1,
+	})
+
+	for _, client := range []*Client{red1, red2} {
+		msg := receiveMessage(t, client, time.Second)
+		if msg == nil || msg.Type != MsgCellUpdated {
+			t.Fatalf("%s should receive its team's cell update, got %v", client.UserID, msg)
+		}
+		var p CellUpdatedPayload
+		json.Unmarshal(msg.Payload, &p)
+		if p.TeamID != "red" || msg.Seq != 0 {
+			t.Errorf("Team cell update = %+v (seq %d), want teamId red and no seq", p, msg.Seq)
+		}
+	}
+	for _, client := range []*Client{blue1, blue2} {
+		if msg := receiveMessage(t, client, 100*time.Millisecond); msg != nil {
+			t.Errorf("%s should not see the other team's edits, got %s", client.UserID, msg.Type)
+		}
+	}
+
+	if got := gridB.snapshot().Cells[2][1].Value; got == nil || *got != "Q" {
+		t.Errorf("Remote team grid cell = %v, want Q", got)
+	}
+	if hubB.loadedRoomGrid("room-1") != nil {
+		t.Error("Team edits should not touch the room's shared grid")
+	}
+}
+
+func TestRankTeams(t *testing.T) {
+	results := []TeamResult{
+		{TeamID: "red", Progress: 40},
+		{TeamID: "blue", Progress: 100},
+		{TeamID: "green", Progress: 100},
+		{TeamID: "gold", Progress: 70},
+	}
+	rankTeams(results, map[string]int{
+		teamGridOwner("green"): 1,
+		teamGridOwner("blue"):  2,
+	})
+
+	want := []string{"green", "blue", "gold", "red"}
+	for i, id := range want {
+		if results[i].TeamID != id || results[i].Rank != i+1 {
+			t.Errorf("Rank %d = %s (rank %d), want %s", i+1, results[i].TeamID, results[i].Rank, id)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package realtime
+
+import (
+	"github.com/crossplay/backend/internal/models"
+)
+
+// Players can take back their own edits. The last maxUndoHistory cell_update
+// writes of each player are kept on the hub room of the instance they're
+// connected to, and go when the room has no clients left there. undo takes
+// back the player's latest edit whose cell nobody has written since; edits to
+// cells another player or a hint has overwritten are dropped instead, so undo
+// never clobbers someone else's letter. redo puts back what undo took back,
+// under the same rule, until the player makes a new edit. Both are written
+// like any edit and sent as cell_updated with origin "undo" or "redo"; they
+// don't count towards contribution.
+
+const maxUndoHistory = 100
+
+// cellChange is a write to a cell: the cell before and after it. The write
+// can be taken back while the cell still holds after.Version.
+type cellChange struct {
+	owner  string // owner of the grid written, see gridKey
+	x, y   int
+	before models.Cell
+	after  models.Cell
+}
+
+// editHistory is a player's undo and redo stacks, latest change last
+type editHistory struct {
+	undo []cellChange
+	redo []cellChange
+}
+
+// localRoom returns the room if it has clients on this instance
+func (h *Hub) localRoom(roomID string) *Room {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.rooms[roomID]
+}
+
+// pushChange adds a change to one of the player's stacks, dropping the oldest
+// beyond maxUndoHistory
+func (r *Room) pushChange(userID string, redo bool, change cellChange) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.histories == nil {
+		r.histories = make(map[string]*editHistory)
+	}
+	history, ok := r.histories[userID]
+	if !ok {
+		history = &editHistory{}
+		r.histories[userID] = history
+	}
+
+	stack := &history.undo
+	if redo {
+		stack = &history.redo
+	}
+	*stack = append(*stack, change)
+	if len(*stack) > maxUndoHistory {
+		*stack = (*stack)[len(*stack)-maxUndoHistory:]
+	}
+}
+
+// popChange takes the latest change off one of the player's stacks
+func (r *Room) popChange(userID string, redo bool) (cellChange, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	history, ok := r.histories[userID]
+	if !ok {
+		return cellChange{}, false
+	}
+	stack := &history.undo
+	if redo {
+		stack = &history.redo
+	}
+	if len(*stack) == 0 {
+		return cellChange{}, false
+	}
+	change := (*stack)[len(*stack)-1]
+	*stack = (*stack)[:len(*stack)-1]
+	return change, true
+}
+
+// clearRedo forgets what the player can redo
+func (r *Room) clearRedo(userID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if history, ok := r.histories[userID]; ok {
+		history.redo = nil
+	}
+}
+
+// restoredCell updates the room's histories after a cell was put back to an
+// earlier state: changes that left the cell at fromVersion can be taken back
+// again now that it holds the same content at toVersion
+func (r *Room) restoredCell(owner string, x, y int, fromVersion, toVersion int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, history := range r.histories {
+		for _, stack := range [][]cellChange{history.undo, history.redo} {
+			for i := range stack {
+				c := &stack[i]
+				if c.owner == owner && c.x == x && c.y == y && c.after.Version == fromVersion {
+					c.after.Version = toVersion
+				}
+			}
+		}
+	}
+}
+
+// rememberEdit records a player's edit so they can undo it
+func (h *Hub) rememberEdit(client *Client, owner string, x, y int, before, after models.Cell) {
+	hubRoom := h.localRoom(client.RoomID)
+	if hubRoom == nil {
+		return
+	}
+	hubRoom.pushChange(client.UserID, false, cellChange{owner: owner, x: x, y: y, before: before, after: after})
+	hubRoom.clearRedo(client.UserID)
+}
+
+// takeBack reverts the player's latest change (latest undone change for redo)
+// whose cell still holds it, dropping those that were overwritten since, and
+// keeps the revert for redo (undo). It returns the revert as a change.
+func (h *Hub) takeBack(client *Client, grid *sharedGrid, owner string, redo bool) (revert cellChange, ok bool) {
+	hubRoom := h.localRoom(client.RoomID)
+	if hubRoom == nil {
+		return cellChange{}, false
+	}
+
+	for {
+		change, ok := hubRoom.popChange(client.UserID, redo)
+		if !ok {
+			return cellChange{}, false
+		}
+		if change.owner != owner || grid.cellVersion(change.x, change.y) != change.after.Version {
+			continue
+		}
+
+		_, before, after, applied := h.writeCell(grid, client.RoomID, change.x, change.y, cellEdit{
+			Value:      change.before.Value,
+			IsPencil:   change.before.IsPencil,
+			Candidates: change.before.Candidates,
+			EditedBy:   client.UserID,
+			Part:       cellWhole,
+			Expect:     change.after.Version,
+		})
+		if !applied {
+			continue
+		}
+
+		revert = cellChange{owner: owner, x: change.x, y: change.y, before: before, after: after}
+		hubRoom.restoredCell(owner, change.x, change.y, change.before.Version, after.Version)
+		hubRoom.pushChange(client.UserID, !redo, revert)
+		return revert, true
+	}
+}
+
+func (h *Hub) handleUndo(client *Client, redo bool) {
+	if client.RoomID == "" {
+		return
+	}
+	origin, nothing := "undo", "nothing to undo"
+	if redo {
+		origin, nothing = "redo", "nothing to redo"
+	}
+
+	room, puzzle := h.cachedRoomAndPuzzle(client.RoomID)
+	if room == nil || puzzle == nil || room.State != models.RoomStateActive {
+		return
+	}
+	if h.clockPaused(room.ID) {
+		h.sendError(client, "game is paused")
+		return
+	}
+
+	// Find the grid the player's edits went to, as handleCellUpdate does
+	players, _ := h.db.GetRoomPlayers(client.RoomID)
+	var relayState *models.RelayState
+	owner, teamID := "", ""
+	switch room.Mode {
+	case models.RoomModeRace:
+		owner = client.UserID
+	case models.RoomModeRelay:
+		relayState = h.relayState(client.RoomID)
+		if relayState == nil || relayState.CurrentPlayerID != client.UserID {
+			h.sendError(client, "not your turn")
+			return
+		}
+	case models.RoomModeTeam:
+		if teamID, _ = playerTeam(players, client.UserID); teamID == "" {
+			h.sendError(client, "you are not on a team")
+			return
+		}
+		owner = teamGridOwner(teamID)
+	}
+	grid := h.loadGrid(client.RoomID, owner)
+	if grid == nil {
+		return
+	}
+
+	revert, ok := h.takeBack(client, grid, owner, redo)
+	if !ok {
+		h.sendError(client, nothing)
+		return
+	}
+
+	cellUpdate := cellUpdated(revert.x, revert.y, revert.after)
+	if room.Mode == models.RoomModeRace {
+		cellUpdate = raceCellUpdated(revert.x, revert.y, revert.after)
+	}
+	cellUpdate.PlayerID = client.UserID
+	cellUpdate.Origin = origin
+	cellUpdate.Color = "#888888"
+	if player := findPlayer(players, client.UserID); player != nil {
+		cellUpdate.Color = player.Color
+	}
+	if cellValue(revert.before) != cellValue(revert.after) {
+		h.recordEvent(client.RoomID, models.RoomEventCell, client.UserID, revert.x, revert.y, cellUpdate.Value)
+	}
+
+	switch room.Mode {
+	case models.RoomModeRace:
+		cellUpdate.Color = "#4ECDC4" // as handleRaceCellUpdate
+		h.sendToClient(client, MsgCellUpdated, cellUpdate)
+		h.sendToUsers(client.RoomID, "", spectatorIDs(players), MsgCellUpdated, cellUpdate)
+		h.checkRaceProgress(client.RoomID, client.UserID)
+	case models.RoomModeTeam:
+		cellUpdate.TeamID = teamID
+		h.sendToUsers(client.RoomID, "", withSpectators(teamMembers(players, teamID), players), MsgCellUpdated, cellUpdate)
+		h.checkTeamProgress(client.RoomID)
+	default:
+		turnDone := false
+		if relayState != nil {
+			turnDone = h.countRelayWords(grid, puzzle, relayState, room.Config.WordsPerTurn)
+		}
+		h.broadcastToRoom(client.RoomID, "", MsgCellUpdated, cellUpdate)
+		h.checkCollaborativeCompletion(client.RoomID)
+		if turnDone {
+			h.endTurn(client.RoomID, relayState, turnWords)
+		}
+	}
+}
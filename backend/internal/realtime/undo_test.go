@@ -0,0 +1,111 @@
+package realtime
+
+import (
+	"testing"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+// testEdit writes a value for a player the way handleCollaborativeCellUpdate does
+func testEdit(t *testing.T, hub *Hub, grid *sharedGrid, client *Client, x, y int, value string) {
+	t.Helper()
+	_, before, after, applied := hub.writeCell(grid, client.RoomID, x, y, cellEdit{Value: &value, EditedBy: client.UserID})
+	if !applied {
+		t.Fatalf("Edit of (%d, %d) should apply", x, y)
+	}
+	hub.rememberEdit(client, "", x, y, before, after)
+}
+
+func testCellValue(grid *sharedGrid, x, y int) string {
+	return cellValue(grid.snapshot().Cells[y][x])
+}
+
+func TestUndoRedo(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	client := newTestClient("conn-1", "user-1")
+	addTestRoom(hub, "room-1", client)
+	grid := hub.setRoomGrid("room-1", newTestGridState("room-1", 3, 3))
+
+	testEdit(t, hub, grid, client, 0, 0, "A")
+	testEdit(t, hub, grid, client, 0, 0, "B")
+
+	if revert, ok := hub.takeBack(client, grid, "", false); !ok || cellValue(revert.after) != "A" {
+		t.Fatalf("First undo should restore A, got %+v (%v)", revert.after, ok)
+	}
+	if revert, ok := hub.takeBack(client, grid, "", false); !ok || revert.after.Value != nil {
+		t.Fatalf("Second undo should empty the cell, got %+v (%v)", revert.after, ok)
+	}
+	if _, ok := hub.takeBack(client, grid, "", false); ok {
+		t.Error("Nothing should be left to undo")
+	}
+
+	if _, ok := hub.takeBack(client, grid, "", true); !ok || testCellValue(grid, 0, 0) != "A" {
+		t.Errorf("Redo should put A back, cell holds %q", testCellValue(grid, 0, 0))
+	}
+
+	// A new edit clears what can be redone
+	testEdit(t, hub, grid, client, 1, 0, "C")
+	if _, ok := hub.takeBack(client, grid, "", true); ok {
+		t.Error("Redo after a new edit should have nothing to redo")
+	}
+}
+
+func TestUndoSkipsOverwrittenCells(t *testing.T) {
+	hub := NewHubWithBroker(nil, NewMemoryBroker())
+	alice := newTestClient("conn-a", "user-a")
+	bob := newTestClient("conn-b", "user-b")
+	addTestRoom(hub, "room-1", alice, bob)
+	grid := hub.setRoomGrid("room-1", newTestGridState("room-1", 3, 3))
+
+	testEdit(t, hub, grid, alice, 0, 0, "A")
+	testEdit(t, hub, grid, alice, 1, 0, "B")
+	testEdit(t, hub, grid, bob, 1, 0, "X")
+
+	revert, ok := hub.takeBack(alice, grid, "", false)
+	if !ok || revert.x != 0 || revert.y != 0 {
+		t.Fatalf("Undo should skip the cell Bob overwrote and take back (0, 0), got %+v (%v)", revert, ok)
+	}
+	if got := testCellValue(grid, 1, 0); got != "X" {
+		t.Errorf("Bob's letter should survive Alice's undo, cell holds %q", got)
+	}
+
+	// Bob's undo still works after Alice's
+	if _, ok := hub.takeBack(bob, grid, "", false); !ok || testCellValue(grid, 1, 0) != "B" {
+		t.Errorf("Bob's undo should restore B, cell holds %q", testCellValue(grid, 1, 0))
+	}
+
+	// Redo is dropped once someone writes over the undone cell
+	testEdit(t, hub, grid, bob, 0, 0, "Y")
+	if _, ok := hub.takeBack(alice, grid, "", true); ok {
+		t.Error("Alice's redo should be dropped after Bob wrote the cell")
+	}
+}
+
+func TestUndoHistoryIsBounded(t *testing.T) {
+	room := &Room{ID: "room-1"}
+	for i := 0; i < maxUndoHistory+20; i++ {
+		room.pushChange("user-1", false, cellChange{x: i})
+	}
+
+	undone := 0
+	first := -1
+	for {
+		change, ok := room.popChange("user-1", false)
+		if !ok {
+			break
+		}
+		first = change.x
+		undone++
+	}
+	if undone != maxUndoHistory || first != 20 {
+		t.Errorf("History kept %d changes back to #%d, want %d back to #20", undone, first, maxUndoHistory)
+	}
+}
+
+func TestRaceCellUpdatesCarryNoVersion(t *testing.T) {
+	value := "A"
+	payload := raceCellUpdated(2, 1, models.Cell{Value: &value, Version: 9})
+	if payload.Version != 0 || payload.Value != "A" || payload.X != 2 || payload.Y != 1 {
+		t.Errorf("raceCellUpdated = %+v, want A at (2, 1) without a version", payload)
+	}
+}
@@ -0,0 +1,299 @@
+// Package tournament draws and scores tournaments played over rounds of race
+// rooms. It holds no state: callers load a tournament with its players and
+// heats, and store what these functions return.
+//
+// Players are seeded by rating when the tournament starts. In a bracket each
+// round's heats are drawn by snake seeding so the top seeds are spread out,
+// the top finishers of each heat go through and the rest are out; a round
+// with a single heat is the final. In Swiss every player plays every round,
+// in heats of players on similar points, for a set number of rounds. Either
+// way a player scores one point per place from the bottom of their heat.
+package tournament
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+const (
+	MinHeatSize     = 2
+	MaxHeatSize     = 8
+	MaxRounds       = 10
+	MinPlayers      = 2
+	defaultHeatSize = 4
+	defaultRounds   = 3
+)
+
+var (
+	ErrNotEnoughPlayers = errors.New("not enough players to start the tournament")
+	ErrRoundInProgress  = errors.New("the current round isn't finished")
+	ErrNotRegistering   = errors.New("registration is closed")
+	ErrOver             = errors.New("the tournament is over")
+)
+
+// Validate fills in the defaults of a new tournament and checks its settings
+func Validate(t *models.Tournament) error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("a tournament needs a name")
+	}
+	if t.Format == "" {
+		t.Format = models.TournamentFormatBracket
+	}
+	if t.HeatSize == 0 {
+		t.HeatSize = defaultHeatSize
+	}
+	if t.HeatSize < MinHeatSize || t.HeatSize > MaxHeatSize {
+		return fmt.Errorf("heat size must be between %d and %d", MinHeatSize, MaxHeatSize)
+	}
+	switch t.Difficulty {
+	case "", models.DifficultyEasy, models.DifficultyMedium, models.DifficultyHard:
+	default:
+		return fmt.Errorf("unknown difficulty %q", t.Difficulty)
+	}
+	if t.TimerSeconds < 0 {
+		return fmt.Errorf("timer can't be negative")
+	}
+
+	switch t.Format {
+	case models.TournamentFormatBracket:
+		if t.Advance == 0 {
+			t.Advance = t.HeatSize / 2
+		}
+		if t.Advance < 1 || t.Advance >= t.HeatSize {
+			return fmt.Errorf("between 1 and %d players can advance from a heat of %d", t.HeatSize-1, t.HeatSize)
+		}
+		t.Rounds = 0
+	case models.TournamentFormatSwiss:
+		if t.Rounds == 0 {
+			t.Rounds = defaultRounds
+		}
+		if t.Rounds < 1 || t.Rounds > MaxRounds {
+			return fmt.Errorf("rounds must be between 1 and %d", MaxRounds)
+		}
+		t.Advance = 0
+	default:
+		return fmt.Errorf("unknown format %q", t.Format)
+	}
+	return nil
+}
+
+// Seed orders players by rating, earliest registration first on a tie, and
+// numbers them from 1
+func Seed(players []models.TournamentPlayer) ([]models.TournamentPlayer, error) {
+	if len(players) < MinPlayers {
+		return nil, ErrNotEnoughPlayers
+	}
+
+	seeded := append([]models.TournamentPlayer(nil), players...)
+	sort.SliceStable(seeded, func(i, j int) bool {
+		if seeded[i].Rating != seeded[j].Rating {
+			return seeded[i].Rating > seeded[j].Rating
+		}
+		return seeded[i].RegisteredAt.Before(seeded[j].RegisteredAt)
+	})
+	for i := range seeded {
+		seeded[i].Seed = i + 1
+	}
+	return seeded, nil
+}
+
+// HeatSizes splits n players into as few heats of at most heatSize as
+// possible, as evenly as possible, larger heats first
+func HeatSizes(n, heatSize int) []int {
+	if n <= 0 {
+		return nil
+	}
+	heats := (n + heatSize - 1) / heatSize
+	sizes := make([]int, heats)
+	for i := range sizes {
+		sizes[i] = n / heats
+		if i < n%heats {
+			sizes[i]++
+		}
+	}
+	return sizes
+}
+
+// Draw returns the heats of the next round, each a list of user IDs in seed
+// order. Eliminated players are left out.
+func Draw(t *models.Tournament, players []models.TournamentPlayer) [][]string {
+	var remaining []models.TournamentPlayer
+	for _, p := range players {
+		if !p.Eliminated {
+			remaining = append(remaining, p)
+		}
+	}
+	sizes := HeatSizes(len(remaining), t.HeatSize)
+	heats := make([][]string, len(sizes))
+
+	if t.Format == models.TournamentFormatSwiss {
+		// Players on similar points meet
+		sort.SliceStable(remaining, func(i, j int) bool {
+			if remaining[i].Points != remaining[j].Points {
+				return remaining[i].Points > remaining[j].Points
+			}
+			return remaining[i].Seed < remaining[j].Seed
+		})
+		next := 0
+		for i, size := range sizes {
+			heats[i] = seedOrder(remaining[next : next+size])
+			next += size
+		}
+		return heats
+	}
+
+	// Snake seeding: 1 to k across the heats, then k+1 to 2k back again
+	sort.SliceStable(remaining, func(i, j int) bool { return remaining[i].Seed < remaining[j].Seed })
+	for i, p := range remaining {
+		row, col := i/len(heats), i%len(heats)
+		if row%2 == 1 {
+			col = len(heats) - 1 - col
+		}
+		heats[col] = append(heats[col], p.UserID)
+	}
+	return heats
+}
+
+func seedOrder(players []models.TournamentPlayer) []string {
+	sorted := append([]models.TournamentPlayer(nil), players...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Seed < sorted[j].Seed })
+	ids := make([]string, len(sorted))
+	for i, p := range sorted {
+		ids[i] = p.UserID
+	}
+	return ids
+}
+
+// OrderResults returns a heat's players in finishing order: those in
+// finishOrder first, then whoever didn't finish in seed order
+func OrderResults(heatPlayers, finishOrder []string) []string {
+	inHeat := make(map[string]bool, len(heatPlayers))
+	for _, userID := range heatPlayers {
+		inHeat[userID] = true
+	}
+
+	results := make([]string, 0, len(heatPlayers))
+	placed := make(map[string]bool, len(heatPlayers))
+	for _, userID := range finishOrder {
+		if inHeat[userID] && !placed[userID] {
+			results = append(results, userID)
+			placed[userID] = true
+		}
+	}
+	for _, userID := range heatPlayers {
+		if !placed[userID] {
+			results = append(results, userID)
+		}
+	}
+	return results
+}
+
+// advancing returns how many players go through from a bracket heat. At
+// least one player of a contested heat is out, so every round is smaller than
+// the last; a player alone in a heat has a bye.
+func advancing(t *models.Tournament, heatSize int) int {
+	return max(1, min(t.Advance, heatSize-1))
+}
+
+// ScoreHeat returns the points each player of a finished heat scored and,
+// in a bracket round other than the final, who is out. final says whether the
+// heat was the only one of its round.
+func ScoreHeat(t *models.Tournament, heat *models.TournamentHeat, final bool) (points map[string]int, eliminated []string) {
+	points = make(map[string]int, len(heat.Results))
+	for place, userID := range heat.Results {
+		points[userID] = len(heat.Results) - place
+		if t.Format == models.TournamentFormatBracket && !final && place >= advancing(t, len(heat.Results)) {
+			eliminated = append(eliminated, userID)
+		}
+	}
+	return points, eliminated
+}
+
+// RoundHeats returns the heats of one round
+func RoundHeats(heats []models.TournamentHeat, round int) []models.TournamentHeat {
+	var inRound []models.TournamentHeat
+	for _, heat := range heats {
+		if heat.Round == round {
+			inRound = append(inRound, heat)
+		}
+	}
+	return inRound
+}
+
+// RoundFinished reports whether every heat of a round has its results
+func RoundFinished(roundHeats []models.TournamentHeat) bool {
+	for _, heat := range roundHeats {
+		if heat.FinishedAt == nil {
+			return false
+		}
+	}
+	return len(roundHeats) > 0
+}
+
+// Over reports whether a finished round was the tournament's last: a
+// bracket's final, or a Swiss tournament's last round
+func Over(t *models.Tournament, roundHeats []models.TournamentHeat) bool {
+	if t.Format == models.TournamentFormatSwiss {
+		return t.Round >= t.Rounds
+	}
+	return len(roundHeats) <= 1
+}
+
+// Standings ranks a tournament's players. In Swiss the most points come
+// first. In a bracket players who got further come first, then those who
+// placed higher in their last heat. Ties go to the higher seed.
+func Standings(t *models.Tournament, players []models.TournamentPlayer, heats []models.TournamentHeat) []models.TournamentStanding {
+	lastRound := make(map[string]int)
+	lastPlace := make(map[string]int)
+	for _, heat := range heats {
+		for _, userID := range heat.Players {
+			if heat.Round >= lastRound[userID] {
+				lastRound[userID] = heat.Round
+				lastPlace[userID] = 0 // not placed until the heat is over
+			}
+		}
+		for place, userID := range heat.Results {
+			if heat.Round == lastRound[userID] {
+				lastPlace[userID] = place
+			}
+		}
+	}
+
+	standings := make([]models.TournamentStanding, len(players))
+	for i, p := range players {
+		standings[i] = models.TournamentStanding{
+			UserID:      p.UserID,
+			DisplayName: p.DisplayName,
+			Seed:        p.Seed,
+			Points:      p.Points,
+			Round:       lastRound[p.UserID],
+			Eliminated:  p.Eliminated,
+		}
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if t.Format == models.TournamentFormatSwiss {
+			if a.Points != b.Points {
+				return a.Points > b.Points
+			}
+		} else {
+			if a.Round != b.Round {
+				return a.Round > b.Round
+			}
+			if lastPlace[a.UserID] != lastPlace[b.UserID] {
+				return lastPlace[a.UserID] < lastPlace[b.UserID]
+			}
+		}
+		return a.Seed < b.Seed
+	})
+	for i := range standings {
+		standings[i].Rank = i + 1
+	}
+	return standings
+}
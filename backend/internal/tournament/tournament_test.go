@@ -0,0 +1,211 @@
+package tournament
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/internal/models"
+)
+
+func seededPlayers(n int) []models.TournamentPlayer {
+	players := make([]models.TournamentPlayer, n)
+	for i := range players {
+		players[i] = models.TournamentPlayer{UserID: string(rune('a' + i)), Seed: i + 1}
+	}
+	return players
+}
+
+func TestValidate(t *testing.T) {
+	bracket := &models.Tournament{Name: " Friday Cup "}
+	if err := Validate(bracket); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if bracket.Name != "Friday Cup" || bracket.Format != models.TournamentFormatBracket || bracket.HeatSize != 4 || bracket.Advance != 2 {
+		t.Errorf("Bracket defaults = %+v", bracket)
+	}
+
+	swiss := &models.Tournament{Name: "Swiss", Format: models.TournamentFormatSwiss, Advance: 2}
+	if err := Validate(swiss); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if swiss.Rounds != 3 || swiss.Advance != 0 {
+		t.Errorf("Swiss defaults = %+v", swiss)
+	}
+
+	for _, bad := range []models.Tournament{
+		{},
+		{Name: "x", HeatSize: 1},
+		{Name: "x", HeatSize: MaxHeatSize + 1},
+		{Name: "x", HeatSize: 4, Advance: 4},
+		{Name: "x", Format: models.TournamentFormatSwiss, Rounds: MaxRounds + 1},
+		{Name: "x", Format: "knockout"},
+		{Name: "x", Difficulty: "brutal"},
+	} {
+		if err := Validate(&bad); err == nil {
+			t.Errorf("Validate(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	now := time.Now()
+	players := []models.TournamentPlayer{
+		{UserID: "a", Rating: 1000, RegisteredAt: now},
+		{UserID: "b", Rating: 1200, RegisteredAt: now},
+		{UserID: "c", Rating: 1000, RegisteredAt: now.Add(-time.Minute)},
+	}
+	seeded, err := Seed(players)
+	if err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	for i, want := range []string{"b", "c", "a"} {
+		if seeded[i].UserID != want || seeded[i].Seed != i+1 {
+			t.Errorf("Seed %d = %s (%d), want %s", i+1, seeded[i].UserID, seeded[i].Seed, want)
+		}
+	}
+
+	if _, err := Seed(players[:1]); err != ErrNotEnoughPlayers {
+		t.Errorf("Seeding one player: err = %v, want ErrNotEnoughPlayers", err)
+	}
+}
+
+func TestHeatSizes(t *testing.T) {
+	tests := []struct {
+		n, heatSize int
+		want        []int
+	}{
+		{4, 4, []int{4}},
+		{5, 4, []int{3, 2}},
+		{9, 4, []int{3, 3, 3}},
+		{10, 4, []int{4, 3, 3}},
+		{0, 4, nil},
+	}
+	for _, tt := range tests {
+		if got := HeatSizes(tt.n, tt.heatSize); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("HeatSizes(%d, %d) = %v, want %v", tt.n, tt.heatSize, got, tt.want)
+		}
+	}
+}
+
+func TestDraw(t *testing.T) {
+	bracket := &models.Tournament{Format: models.TournamentFormatBracket, HeatSize: 4, Advance: 2}
+	players := seededPlayers(8)
+	heats := Draw(bracket, players)
+	want := [][]string{{"a", "d", "e", "h"}, {"b", "c", "f", "g"}}
+	if !reflect.DeepEqual(heats, want) {
+		t.Errorf("Bracket draw = %v, want snake seeding %v", heats, want)
+	}
+
+	players[0].Eliminated = true
+	if heats := Draw(bracket, players); len(heats[0])+len(heats[1]) != 7 {
+		t.Errorf("Eliminated players shouldn't be drawn, got %v", heats)
+	}
+
+	swiss := &models.Tournament{Format: models.TournamentFormatSwiss, HeatSize: 3, Rounds: 3}
+	players = seededPlayers(6)
+	players[5].Points, players[4].Points, players[0].Points = 6, 5, 5
+	heats = Draw(swiss, players)
+	want = [][]string{{"a", "e", "f"}, {"b", "c", "d"}}
+	if !reflect.DeepEqual(heats, want) {
+		t.Errorf("Swiss draw = %v, want players on similar points together %v", heats, want)
+	}
+}
+
+func TestOrderResults(t *testing.T) {
+	got := OrderResults([]string{"a", "b", "c", "d"}, []string{"c", "x", "a", "c"})
+	if want := []string{"c", "a", "b", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OrderResults = %v, want finishers then the rest by seed %v", got, want)
+	}
+}
+
+func TestScoreHeat(t *testing.T) {
+	bracket := &models.Tournament{Format: models.TournamentFormatBracket, HeatSize: 4, Advance: 2}
+	heat := &models.TournamentHeat{Results: []string{"c", "a", "b", "d"}}
+
+	points, eliminated := ScoreHeat(bracket, heat, false)
+	if points["c"] != 4 || points["d"] != 1 {
+		t.Errorf("Points = %v, want one per place from the bottom", points)
+	}
+	if !reflect.DeepEqual(eliminated, []string{"b", "d"}) {
+		t.Errorf("Eliminated = %v, want the bottom two", eliminated)
+	}
+
+	if _, eliminated := ScoreHeat(bracket, heat, true); eliminated != nil {
+		t.Errorf("Nobody is eliminated in the final, got %v", eliminated)
+	}
+
+	// Two players of a short heat: one still goes out
+	short := &models.TournamentHeat{Results: []string{"a", "b"}}
+	if _, eliminated := ScoreHeat(bracket, short, false); !reflect.DeepEqual(eliminated, []string{"b"}) {
+		t.Errorf("Eliminated from a heat of two = %v, want [b]", eliminated)
+	}
+	bye := &models.TournamentHeat{Results: []string{"a"}}
+	if _, eliminated := ScoreHeat(bracket, bye, false); eliminated != nil {
+		t.Errorf("A player alone in a heat should go through, got %v eliminated", eliminated)
+	}
+
+	swiss := &models.Tournament{Format: models.TournamentFormatSwiss, HeatSize: 4, Rounds: 3}
+	if _, eliminated := ScoreHeat(swiss, heat, false); eliminated != nil {
+		t.Errorf("Nobody is eliminated in Swiss, got %v", eliminated)
+	}
+}
+
+func TestOver(t *testing.T) {
+	now := time.Now()
+	round := []models.TournamentHeat{{Round: 1, FinishedAt: &now}, {Round: 1}}
+	if RoundFinished(round) {
+		t.Error("A round with a heat still playing isn't finished")
+	}
+	round[1].FinishedAt = &now
+	if !RoundFinished(round) {
+		t.Error("A round whose heats all have results is finished")
+	}
+
+	bracket := &models.Tournament{Format: models.TournamentFormatBracket, Round: 1}
+	if Over(bracket, round) || !Over(bracket, round[:1]) {
+		t.Error("A bracket is over after a round with a single heat")
+	}
+	swiss := &models.Tournament{Format: models.TournamentFormatSwiss, Round: 2, Rounds: 3}
+	if Over(swiss, round) {
+		t.Error("Swiss isn't over before its last round")
+	}
+	swiss.Round = 3
+	if !Over(swiss, round) {
+		t.Error("Swiss is over after its last round")
+	}
+}
+
+func TestStandings(t *testing.T) {
+	now := time.Now()
+	bracket := &models.Tournament{Format: models.TournamentFormatBracket, HeatSize: 2, Advance: 1}
+	players := seededPlayers(4)
+	players[2].Eliminated, players[3].Eliminated = true, true
+	players[0].Points, players[1].Points, players[2].Points, players[3].Points = 3, 4, 1, 1
+	heats := []models.TournamentHeat{
+		{Round: 1, Players: []string{"a", "d"}, Results: []string{"a", "d"}, FinishedAt: &now},
+		{Round: 1, Players: []string{"b", "c"}, Results: []string{"b", "c"}, FinishedAt: &now},
+		{Round: 2, Players: []string{"a", "b"}, Results: []string{"b", "a"}, FinishedAt: &now},
+	}
+
+	var got []string
+	for i, standing := range Standings(bracket, players, heats) {
+		got = append(got, standing.UserID)
+		if standing.Rank != i+1 {
+			t.Errorf("Standing %d has rank %d", i, standing.Rank)
+		}
+	}
+	if want := []string{"b", "a", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Bracket standings = %v, want the finalists first %v", got, want)
+	}
+
+	swiss := &models.Tournament{Format: models.TournamentFormatSwiss}
+	players[2].Points = 5
+	got = nil
+	for _, standing := range Standings(swiss, players, heats) {
+		got = append(got, standing.UserID)
+	}
+	if want := []string{"c", "b", "a", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Swiss standings = %v, want most points first %v", got, want)
+	}
+}
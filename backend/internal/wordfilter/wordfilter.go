@@ -0,0 +1,114 @@
+// Package wordfilter matches text against a configurable list of banned
+// words. The same filter cleans room chat and rejects generated puzzles whose
+// answers contain a banned word.
+package wordfilter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Filter is a set of banned words, matched case-insensitively. A nil Filter
+// bans nothing.
+type Filter struct {
+	words map[string]bool
+}
+
+// New returns a filter banning the given words. Blank entries are ignored.
+func New(words []string) *Filter {
+	f := &Filter{words: make(map[string]bool, len(words))}
+	for _, word := range words {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			f.words[word] = true
+		}
+	}
+	return f
+}
+
+// FromEnv builds the filter from BANNED_WORDS, a comma-separated list, and
+// BANNED_WORDS_FILE, a file with one word per line (# starts a comment)
+func FromEnv() (*Filter, error) {
+	words := strings.Split(os.Getenv("BANNED_WORDS"), ",")
+
+	if path := os.Getenv("BANNED_WORDS_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open banned words: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+			words = append(words, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("read banned words: %w", err)
+		}
+	}
+	return New(words), nil
+}
+
+// Len returns the number of banned words
+func (f *Filter) Len() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.words)
+}
+
+// IsBanned reports whether word is one of the banned words
+func (f *Filter) IsBanned(word string) bool {
+	return f != nil && f.words[strings.ToLower(word)]
+}
+
+// ContainedIn returns a banned word that appears anywhere in s, or "" if none
+// does. Crossword answers run their words together, so they are searched
+// rather than split.
+func (f *Filter) ContainedIn(s string) string {
+	if f == nil {
+		return ""
+	}
+	s = strings.ToLower(s)
+	for word := range f.words {
+		if strings.Contains(s, word) {
+			return word
+		}
+	}
+	return ""
+}
+
+// Censor replaces every banned word of text with asterisks, one per letter,
+// and reports whether it replaced any. Words are runs of letters and digits.
+func (f *Filter) Censor(text string) (censored string, changed bool) {
+	if f.Len() == 0 {
+		return text, false
+	}
+
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		if f.IsBanned(string(runes[start:end])) {
+			for i := start; i < end; i++ {
+				runes[i] = '*'
+			}
+			changed = true
+		}
+		start = end
+	}
+	return string(runes), changed
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,78 @@
+package wordfilter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCensor(t *testing.T) {
+	f := New([]string{"Darn", " heck ", ""})
+
+	tests := []struct {
+		text    string
+		want    string
+		changed bool
+	}{
+		{"well darn it", "well **** it", true},
+		{"HECK, Darn!", "****, ****!", true},
+		{"darnation is fine", "darnation is fine", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, changed := f.Censor(tt.text)
+		if got != tt.want || changed != tt.changed {
+			t.Errorf("Censor(%q) = (%q, %v), want (%q, %v)", tt.text, got, changed, tt.want, tt.changed)
+		}
+	}
+	if f.Len() != 2 {
+		t.Errorf("Len() = %d, want 2 (blank entries ignored)", f.Len())
+	}
+}
+
+func TestContainedIn(t *testing.T) {
+	f := New([]string{"darn"})
+	if got := f.ContainedIn("DARNATION"); got != "darn" {
+		t.Errorf("ContainedIn(DARNATION) = %q, want darn", got)
+	}
+	if got := f.ContainedIn("APPLE"); got != "" {
+		t.Errorf("ContainedIn(APPLE) = %q, want nothing", got)
+	}
+}
+
+func TestNilFilter(t *testing.T) {
+	var f *Filter
+	if f.IsBanned("darn") || f.ContainedIn("darn") != "" {
+		t.Error("A nil filter should ban nothing")
+	}
+	if got, changed := f.Censor("darn"); got != "darn" || changed {
+		t.Errorf("Censor on a nil filter = (%q, %v), want the text unchanged", got, changed)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+	if err := os.WriteFile(path, []byte("# chat\nheck\n\ndrat # mild\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BANNED_WORDS", "darn, blast")
+	t.Setenv("BANNED_WORDS_FILE", path)
+
+	f, err := FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv: %v", err)
+	}
+	for _, word := range []string{"darn", "blast", "heck", "drat"} {
+		if !f.IsBanned(word) {
+			t.Errorf("%q should be banned", word)
+		}
+	}
+	if f.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", f.Len())
+	}
+
+	t.Setenv("BANNED_WORDS_FILE", filepath.Join(t.TempDir(), "missing.txt"))
+	if _, err := FromEnv(); err == nil {
+		t.Error("FromEnv should fail when the file is missing")
+	}
+}
@@ -3,9 +3,12 @@ package clues
 import (
 	"database/sql"
 	"fmt"
+	"time"
+
+	"github.com/crossplay/backend/pkg/clues/providers"
 )
 
-// ClueCache provides methods for saving and retrieving cached clues
+// ClueCache is the SQLite-backed ClueStore used by the crossgen CLI
 type ClueCache struct {
 	db *sql.DB
 }
@@ -75,3 +78,92 @@ func (c *ClueCache) SaveClue(word, clue, difficulty string) error {
 
 	return nil
 }
+
+// SaveUsage records the token usage of one LLM call against a batch and puzzle
+func (c *ClueCache) SaveUsage(batchID, puzzleID string, usage providers.Usage) error {
+	if c.db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	_, err := c.db.Exec(`
+		INSERT INTO llm_usage (batch_id, puzzle_id, provider, model, input_tokens, output_tokens, cost_usd)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, batchID, puzzleID, usage.Provider, usage.Model, usage.InputTokens, usage.OutputTokens, usage.CostUSD)
+
+	if err != nil {
+		return fmt.Errorf("failed to save usage: %w", err)
+	}
+
+	return nil
+}
+
+// ListClues returns every cached clue, oldest first
+func (c *ClueCache) ListClues() ([]CachedClue, error) {
+	if c.db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	rows, err := c.db.Query(`
+		SELECT word, clue, difficulty, created_at FROM clue_cache
+		ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clues: %w", err)
+	}
+	defer rows.Close()
+
+	var clues []CachedClue
+	for rows.Next() {
+		var cc CachedClue
+		var createdAt sql.NullTime
+		if err := rows.Scan(&cc.Word, &cc.Clue, &cc.Difficulty, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan clue: %w", err)
+		}
+		cc.CreatedAt = createdAt.Time
+		clues = append(clues, cc)
+	}
+
+	return clues, rows.Err()
+}
+
+// ImportClues inserts clues that aren't already cached (same word, clue and
+// difficulty) and returns how many were added
+func (c *ClueCache) ImportClues(clues []CachedClue) (int, error) {
+	if c.db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for _, cc := range clues {
+		createdAt := cc.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+
+		res, err := tx.Exec(`
+			INSERT INTO clue_cache (word, clue, difficulty, created_at)
+			SELECT ?, ?, ?, ?
+			WHERE NOT EXISTS (
+				SELECT 1 FROM clue_cache WHERE word = ? AND clue = ? AND difficulty = ?
+			)
+		`, cc.Word, cc.Clue, cc.Difficulty, createdAt.UTC(), cc.Word, cc.Clue, cc.Difficulty)
+		if err != nil {
+			return 0, fmt.Errorf("failed to import clue for %s: %w", cc.Word, err)
+		}
+		if n, err := res.RowsAffected(); err == nil {
+			added += int(n)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit import: %w", err)
+	}
+
+	return added, nil
+}
@@ -0,0 +1,125 @@
+package clues
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CrossReference declares that one entry's clue must point at another entry,
+// e.g. a revealer clued "...and a hint to 17-, 24- and 51-Across" or a pair
+// clued "With 40-Down, classic dessert" / "See 20-Across"
+type CrossReference struct {
+	From string `json:"from"` // entry key whose clue contains the reference, e.g. "20-across"
+	To   string `json:"to"`   // entry key being referenced, e.g. "40-down"
+}
+
+// PuzzleContext carries puzzle-wide information that individual clues should be
+// consistent with. A nil or zero-value context produces plain, context-free clues.
+type PuzzleContext struct {
+	Theme           string           // theme description, from puzzle.Metadata.Theme
+	ThemeEntries    []string         // entry keys of theme answers
+	Revealer        string           // entry key of the revealer, if any
+	CrossReferences []CrossReference // entries whose clues must reference each other
+}
+
+// IsEmpty reports whether the context adds nothing to a plain prompt
+func (c *PuzzleContext) IsEmpty() bool {
+	return c == nil || (c.Theme == "" && len(c.ThemeEntries) == 0 && c.Revealer == "" && len(c.CrossReferences) == 0)
+}
+
+// HasEntry reports whether the entry key is a theme entry, the revealer, or
+// part of a cross-reference. Such clues depend on the puzzle and are not cached.
+func (c *PuzzleContext) HasEntry(entryKey string) bool {
+	if c == nil {
+		return false
+	}
+	if c.Revealer == entryKey {
+		return true
+	}
+	for _, key := range c.ThemeEntries {
+		if key == entryKey {
+			return true
+		}
+	}
+	for _, ref := range c.CrossReferences {
+		if ref.From == entryKey || ref.To == entryKey {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatEntryRef converts an entry key such as "17-across" into the form used in
+// clue text, "17-Across"
+func FormatEntryRef(entryKey string) string {
+	number, direction, found := strings.Cut(entryKey, "-")
+	if !found || direction == "" {
+		return entryKey
+	}
+	return number + "-" + strings.ToUpper(direction[:1]) + strings.ToLower(direction[1:])
+}
+
+// buildContextSection renders the puzzle context for the words in a batch.
+// wordEntries maps each word to the entry keys that use it.
+func buildContextSection(words []string, pctx *PuzzleContext, wordEntries map[string][]string) string {
+	if pctx.IsEmpty() {
+		return ""
+	}
+
+	entryWord := make(map[string]string)
+	for _, word := range words {
+		for _, key := range wordEntries[word] {
+			entryWord[key] = word
+		}
+	}
+	inBatch := func(key string) bool {
+		_, ok := entryWord[key]
+		return ok
+	}
+
+	var b strings.Builder
+	b.WriteString("\n\nPuzzle context:\n")
+	if pctx.Theme != "" {
+		b.WriteString(fmt.Sprintf("- Theme: %s. Where it fits naturally, clues may nod to the theme.\n", pctx.Theme))
+	}
+
+	var themeLines []string
+	for _, key := range pctx.ThemeEntries {
+		if inBatch(key) {
+			themeLines = append(themeLines, fmt.Sprintf("%s (%s)", FormatEntryRef(key), entryWord[key]))
+		}
+	}
+	sort.Strings(themeLines)
+	if len(themeLines) > 0 {
+		b.WriteString(fmt.Sprintf("- Theme entries, clue these so they are consistent with the theme: %s\n", strings.Join(themeLines, ", ")))
+	}
+
+	if pctx.Revealer != "" && inBatch(pctx.Revealer) {
+		b.WriteString(fmt.Sprintf("- %s (%s) is the revealer: its clue should explain the theme", FormatEntryRef(pctx.Revealer), entryWord[pctx.Revealer]))
+		if len(pctx.ThemeEntries) > 0 {
+			refs := make([]string, len(pctx.ThemeEntries))
+			for i, key := range pctx.ThemeEntries {
+				refs[i] = FormatEntryRef(key)
+			}
+			b.WriteString(fmt.Sprintf(" and may refer to the theme entries (%s)", strings.Join(refs, ", ")))
+		}
+		b.WriteString("\n")
+	}
+
+	var refLines []string
+	for _, ref := range pctx.CrossReferences {
+		if inBatch(ref.From) {
+			refLines = append(refLines, fmt.Sprintf("  - The clue for %s (%s) must refer to %s, written exactly as \"%s\"",
+				FormatEntryRef(ref.From), entryWord[ref.From], FormatEntryRef(ref.To), FormatEntryRef(ref.To)))
+		}
+	}
+	sort.Strings(refLines)
+	if len(refLines) > 0 {
+		b.WriteString("- Cross-references (use entry numbers exactly as given; never reference entries not listed here):\n")
+		b.WriteString(strings.Join(refLines, "\n"))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,177 @@
+package clues
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/crossplay/backend/pkg/grid"
+)
+
+func TestFormatEntryRef(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"17-across", "17-Across"},
+		{"4-down", "4-Down"},
+		{"bogus", "bogus"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatEntryRef(tt.key); got != tt.want {
+			t.Errorf("FormatEntryRef(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestPuzzleContext_HasEntry(t *testing.T) {
+	pctx := &PuzzleContext{
+		ThemeEntries:    []string{"17-across"},
+		Revealer:        "60-across",
+		CrossReferences: []CrossReference{{From: "20-across", To: "40-down"}},
+	}
+
+	for _, key := range []string{"17-across", "60-across", "20-across", "40-down"} {
+		if !pctx.HasEntry(key) {
+			t.Errorf("HasEntry(%q) = false, want true", key)
+		}
+	}
+	if pctx.HasEntry("1-across") {
+		t.Error("HasEntry(\"1-across\") = true, want false")
+	}
+
+	var nilCtx *PuzzleContext
+	if nilCtx.HasEntry("17-across") || !nilCtx.IsEmpty() {
+		t.Error("nil context should be empty and contain no entries")
+	}
+}
+
+func TestBuildContextSection(t *testing.T) {
+	pctx := &PuzzleContext{
+		Theme:           "Breakfast foods",
+		ThemeEntries:    []string{"17-across"},
+		Revealer:        "60-across",
+		CrossReferences: []CrossReference{{From: "20-across", To: "40-down"}},
+	}
+	wordEntries := map[string][]string{
+		"PANCAKE": {"17-across"},
+		"BRUNCH":  {"60-across"},
+		"ICE":     {"20-across"},
+		"CREAM":   {"40-down"},
+	}
+
+	section := buildContextSection([]string{"PANCAKE", "BRUNCH", "ICE"}, pctx, wordEntries)
+
+	for _, want := range []string{
+		"Theme: Breakfast foods",
+		"17-Across (PANCAKE)",
+		"60-Across (BRUNCH) is the revealer",
+		`must refer to 40-Down, written exactly as "40-Down"`,
+	} {
+		if !strings.Contains(section, want) {
+			t.Errorf("Context section missing %q:\n%s", want, section)
+		}
+	}
+
+	if got := buildContextSection([]string{"PANCAKE"}, nil, wordEntries); got != "" {
+		t.Errorf("Expected empty section for nil context, got %q", got)
+	}
+
+	// Only entries in the batch get entry-specific instructions
+	section = buildContextSection([]string{"CREAM"}, pctx, wordEntries)
+	if strings.Contains(section, "PANCAKE") || strings.Contains(section, "Cross-references") {
+		t.Errorf("Section should not mention entries outside the batch:\n%s", section)
+	}
+	if !strings.Contains(section, "Breakfast foods") {
+		t.Error("Theme description should be included for every batch")
+	}
+}
+
+func TestLintCrossReferences(t *testing.T) {
+	entries := []LintEntry{
+		{Key: "20-across", Answer: "ICE", Clue: "With 40-Down, summer treat"},
+		{Key: "40-down", Answer: "CREAM", Clue: "See 20-Across"},
+		{Key: "1-across", Answer: "EMU", Clue: "Bird in 99-Across"},
+		{Key: "5-down", Answer: "OREO", Clue: "Cookie, like 5-Down"},
+		{Key: "7-down", Answer: "TEA", Clue: "Brewed drink"},
+	}
+	refs := []CrossReference{
+		{From: "20-across", To: "40-down"},
+		{From: "40-down", To: "20-across"},
+		{From: "7-down", To: "20-across"},
+	}
+
+	results := NewLinter().LintCrossReferences(entries, refs)
+
+	if _, ok := results["20-across"]; ok {
+		t.Errorf("Valid cross-reference flagged: %v", results["20-across"])
+	}
+	if _, ok := results["40-down"]; ok {
+		t.Errorf("Valid cross-reference flagged: %v", results["40-down"])
+	}
+	if !hasRule(results["1-across"], RuleCrossRef) {
+		t.Error("Reference to a missing entry should be flagged")
+	}
+	if !hasRule(results["5-down"], RuleCrossRef) {
+		t.Error("Self-reference should be flagged")
+	}
+	if !hasRule(results["7-down"], RuleCrossRef) {
+		t.Error("Missing declared cross-reference should be flagged")
+	}
+}
+
+func TestGenerateCluesWithContext(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cache, _ := NewClueCache(db)
+	cache.SaveClue("CREAM", "Dairy product", "medium")
+
+	client := &sequenceLLMClient{
+		responses: []string{
+			`{"clues": {"ICE": "With 41-Down, summer treat", "CREAM": "See 20-Across"}}`,
+			`{"clues": {"ICE": "With 40-Down, summer treat"}}`,
+		},
+	}
+	gen := NewGenerator(cache, client, DifficultyMedium)
+
+	entries := []*grid.Entry{
+		createTestEntry(20, grid.ACROSS, "ICE"),
+		createTestEntry(40, grid.DOWN, "CREAM"),
+	}
+	pctx := &PuzzleContext{
+		Theme: "Desserts",
+		CrossReferences: []CrossReference{
+			{From: "20-across", To: "40-down"},
+			{From: "40-down", To: "20-across"},
+		},
+	}
+
+	result, err := gen.GenerateCluesWithContext(context.Background(), entries, pctx)
+	if err != nil {
+		t.Fatalf("GenerateCluesWithContext failed: %v", err)
+	}
+
+	if result["20-across"] != "With 40-Down, summer treat" {
+		t.Errorf("Expected corrected cross-reference, got %q", result["20-across"])
+	}
+	if result["40-down"] != "See 20-Across" {
+		t.Errorf("Expected context clue instead of cached clue, got %q", result["40-down"])
+	}
+
+	if len(client.prompts) != 2 {
+		t.Fatalf("Expected 2 LLM calls, got %d", len(client.prompts))
+	}
+	if !strings.Contains(client.prompts[0], "Theme: Desserts") {
+		t.Error("First prompt should include the theme")
+	}
+	if !strings.Contains(client.prompts[1], "41-Down, which is not an entry in this puzzle") {
+		t.Errorf("Regeneration prompt should explain the bad reference:\n%s", client.prompts[1])
+	}
+
+	// Context-specific clues must not pollute the shared cache
+	if clue, _ := cache.GetClue("ICE", "medium"); clue != "" {
+		t.Errorf("Context clue for ICE should not be cached, got %q", clue)
+	}
+}
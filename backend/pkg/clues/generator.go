@@ -3,7 +3,9 @@ package clues
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/crossplay/backend/pkg/clues/providers"
 	"github.com/crossplay/backend/pkg/grid"
@@ -11,31 +13,71 @@ import (
 
 // Generator orchestrates clue generation with caching
 type Generator struct {
-	cache      *ClueCache
-	llmClient  providers.LLMClient
-	difficulty Difficulty
+	cache       ClueStore
+	llmClient   providers.LLMClient
+	difficulty  Difficulty
+	linter      *Linter
+	usage       *UsageTracker
+	concurrency int // max batches in flight (0 = client's limit)
 }
 
 // NewGenerator creates a new clue generator
-func NewGenerator(cache *ClueCache, llmClient providers.LLMClient, difficulty Difficulty) *Generator {
+func NewGenerator(cache ClueStore, llmClient providers.LLMClient, difficulty Difficulty) *Generator {
 	return &Generator{
 		cache:      cache,
 		llmClient:  llmClient,
 		difficulty: difficulty,
+		linter:     NewLinter(),
 	}
 }
 
-// GenerateClues generates clues for all entries in the grid
-// It checks the cache first, batches cache misses, calls the LLM, and saves new clues
-// Returns a map of entry key (e.g., "1-across", "2-down") to clue text
+// SetUsageTracker records token usage of every LLM call on t and stops making
+// calls once t's budget is spent. Pass nil to stop tracking.
+func (g *Generator) SetUsageTracker(t *UsageTracker) {
+	g.usage = t
+}
+
+// SetMaxConcurrency caps how many batches are sent to the LLM at once,
+// overriding the client's own limit. n <= 0 restores the client's limit.
+func (g *Generator) SetMaxConcurrency(n int) {
+	g.concurrency = n
+}
+
+// GenerateClues generates clues for all entries in the grid without puzzle context.
+// See GenerateCluesWithContext.
 func (g *Generator) GenerateClues(ctx context.Context, entries []*grid.Entry) (map[string]string, error) {
+	return g.GenerateCluesWithContext(ctx, entries, nil)
+}
+
+// clueRequest carries the per-call state shared by the batching helpers
+type clueRequest struct {
+	puzzle       *PuzzleContext
+	wordEntries  map[string][]string // word -> entry keys using it
+	contextWords map[string]bool     // words used by at least one context entry
+}
+
+// GenerateCluesWithContext generates clues for all entries in the grid
+// It checks the cache first, batches cache misses, calls the LLM, and saves new clues.
+// Every clue, cached or generated, is run through the linter; failing clues are
+// regenerated with the linter's reasons included in the prompt.
+// Entries named in pctx (theme entries, the revealer, cross-references) are always
+// generated with the puzzle context in the prompt and are never cached.
+// Returns a map of entry key (e.g., "1-across", "2-down") to clue text
+func (g *Generator) GenerateCluesWithContext(ctx context.Context, entries []*grid.Entry, pctx *PuzzleContext) (map[string]string, error) {
 	if len(entries) == 0 {
 		return map[string]string{}, nil
 	}
 
 	result := make(map[string]string)
 	var wordsNeedingClues []string
-	wordToEntryKeys := make(map[string][]string) // maps word to list of entry keys
+	wordToEntryKeys := make(map[string][]string) // maps word to list of entry keys needing clues
+	entryWords := make(map[string]string)        // maps entry key to word
+	rejected := make(map[string]RejectedClue)    // cached clues that no longer pass the linter
+	req := &clueRequest{
+		puzzle:       pctx,
+		wordEntries:  make(map[string][]string),
+		contextWords: make(map[string]bool),
+	}
 
 	// Step 1: Check cache for all entries
 	for _, entry := range entries {
@@ -45,13 +87,22 @@ func (g *Generator) GenerateClues(ctx context.Context, entries []*grid.Entry) (m
 		}
 
 		entryKey := getEntryKey(entry)
+		entryWords[entryKey] = word
+		req.wordEntries[word] = append(req.wordEntries[word], entryKey)
 
-		// Check cache
-		if g.cache != nil {
+		// Check cache, treating clues that no longer pass the linter as misses
+		// whose reasons go into the prompt
+		if pctx.HasEntry(entryKey) {
+			req.contextWords[word] = true
+		} else if g.cache != nil {
 			clue, found := g.cache.GetClue(word, string(g.difficulty))
 			if found {
-				result[entryKey] = clue
-				continue
+				issues := g.linter.LintClue(word, clue)
+				if len(issues) == 0 {
+					result[entryKey] = clue
+					continue
+				}
+				rejected[word] = RejectedClue{Clue: clue, Issues: issues}
 			}
 		}
 
@@ -62,9 +113,9 @@ func (g *Generator) GenerateClues(ctx context.Context, entries []*grid.Entry) (m
 		wordToEntryKeys[word] = append(wordToEntryKeys[word], entryKey)
 	}
 
-	// Step 2: If all clues were found in cache, return early
+	// Step 2: If all clues were found in cache, only puzzle-level checks remain
 	if len(wordsNeedingClues) == 0 {
-		return result, nil
+		return g.regeneratePuzzleIssues(ctx, req, result, entryWords)
 	}
 
 	// Step 3: If no LLM client, return error for cache misses
@@ -72,69 +123,281 @@ func (g *Generator) GenerateClues(ctx context.Context, entries []*grid.Entry) (m
 		return nil, fmt.Errorf("no LLM client available and %d words not in cache", len(wordsNeedingClues))
 	}
 
-	// Step 4: Batch words and call LLM
-	newClues, err := g.generateWithLLM(ctx, wordsNeedingClues)
+	// Step 4: Batch words and call LLM. Clues are cached as each batch (or, when
+	// streaming, each clue) arrives, so a failed batch doesn't lose the others.
+	newClues, err := g.generateWithLLM(ctx, req, wordsNeedingClues, rejected)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate clues with LLM: %w", err)
 	}
 
-	// Step 5: Save new clues to cache and populate result
+	// Step 5: Populate result for all entry keys that use each word
 	for word, clue := range newClues {
-		// Save to cache
-		if g.cache != nil {
-			if err := g.cache.SaveClue(word, clue, string(g.difficulty)); err != nil {
-				// Log error but continue - cache save failure shouldn't stop generation
-				// In production, you'd use a proper logger here
-				_ = err
+		for _, entryKey := range wordToEntryKeys[word] {
+			result[entryKey] = clue
+		}
+	}
+
+	// Step 6: Regenerate duplicate clues and broken cross-references
+	return g.regeneratePuzzleIssues(ctx, req, result, entryWords)
+}
+
+// regeneratePuzzleIssues runs the puzzle-level lint passes (duplicate clues and
+// cross-references) and asks the LLM for new clues for failing entries, up to
+// MaxRegenerationAttempts rounds
+func (g *Generator) regeneratePuzzleIssues(ctx context.Context, req *clueRequest, result map[string]string, entryWords map[string]string) (map[string]string, error) {
+	var refs []CrossReference
+	if req.puzzle != nil {
+		refs = req.puzzle.CrossReferences
+	}
+
+	for round := 0; round < MaxRegenerationAttempts; round++ {
+		lintEntries := make([]LintEntry, 0, len(result))
+		for entryKey, clue := range result {
+			lintEntries = append(lintEntries, LintEntry{Key: entryKey, Answer: entryWords[entryKey], Clue: clue})
+		}
+
+		rejected := make(map[string]RejectedClue)
+		var words []string
+		reject := func(entryKey string, issue LintIssue) {
+			word := entryWords[entryKey]
+			r, exists := rejected[word]
+			if !exists {
+				words = append(words, word)
+				r.Clue = result[entryKey]
 			}
+			r.Issues = append(r.Issues, issue)
+			rejected[word] = r
 		}
 
-		// Populate result for all entry keys that use this word
-		for _, entryKey := range wordToEntryKeys[word] {
-			result[entryKey] = clue
+		for entryKey, issues := range g.linter.LintPuzzle(lintEntries) {
+			for _, issue := range issues {
+				if issue.Rule == RuleDuplicate {
+					reject(entryKey, issue)
+				}
+			}
+		}
+		for entryKey, issues := range g.linter.LintCrossReferences(lintEntries, refs) {
+			for _, issue := range issues {
+				reject(entryKey, issue)
+			}
+		}
+
+		if len(words) == 0 || g.llmClient == nil {
+			return result, nil
+		}
+
+		sort.Strings(words)
+		newClues, err := g.generateWithLLM(ctx, req, words, rejected)
+		if err != nil {
+			return nil, fmt.Errorf("failed to regenerate clues: %w", err)
+		}
+
+		for entryKey, word := range entryWords {
+			if clue, ok := newClues[word]; ok {
+				result[entryKey] = clue
+			}
 		}
 	}
 
 	return result, nil
 }
 
-// generateWithLLM batches words and generates clues using the LLM client
-func (g *Generator) generateWithLLM(ctx context.Context, words []string) (map[string]string, error) {
-	allClues := make(map[string]string)
-
-	// Process words in batches
+// generateWithLLM splits words into batches of MaxWordsPerBatch and generates
+// them concurrently, up to the LLM client's parallelism limit. The first failing
+// batch cancels the rest; clues from batches that already finished stay cached.
+func (g *Generator) generateWithLLM(ctx context.Context, req *clueRequest, words []string, rejected map[string]RejectedClue) (map[string]string, error) {
+	var batches [][]string
 	for i := 0; i < len(words); i += MaxWordsPerBatch {
 		end := i + MaxWordsPerBatch
 		if end > len(words) {
 			end = len(words)
 		}
-		batch := words[i:end]
+		batches = append(batches, words[i:end])
+	}
+
+	limit := g.maxConcurrency()
+	if limit > len(batches) {
+		limit = len(batches)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		allClues = make(map[string]string)
+		firstErr error
+		stopped  bool
+	)
+	sem := make(chan struct{}, limit)
+
+dispatch:
+	for _, batch := range batches {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			stopped = true
+			break dispatch
+		}
+
+		wg.Add(1)
+		go func(batch []string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			batchClues, err := g.generateBatch(ctx, req, batch, rejected)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			for word, clue := range batchClues {
+				allClues[word] = clue
+			}
+		}(batch)
+	}
+
+	wg.Wait()
+
+	if firstErr == nil && stopped {
+		// Dispatch stopped because the caller's context was cancelled
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return allClues, nil
+}
+
+// maxConcurrency returns how many batches may be in flight at once: the limit
+// set with SetMaxConcurrency, else the client's own limit, else one
+func (g *Generator) maxConcurrency() int {
+	if g.concurrency > 0 {
+		return g.concurrency
+	}
+	if limiter, ok := g.llmClient.(providers.ConcurrencyLimiter); ok && limiter.MaxConcurrency() > 0 {
+		return limiter.MaxConcurrency()
+	}
+	return 1
+}
 
-		// Build prompt for this batch
-		prompt, err := buildPrompt(batch, g.difficulty)
+// generateBatch generates clues for a single batch of words, regenerating any
+// clue that fails the linter up to MaxRegenerationAttempts times. If a word still
+// fails after the last attempt, its final clue is returned as-is.
+// Clues that pass the linter are cached as soon as they are received.
+func (g *Generator) generateBatch(ctx context.Context, req *clueRequest, batch []string, rejected map[string]RejectedClue) (map[string]string, error) {
+	clues := make(map[string]string)
+	pending := batch
+	saved := make(map[string]string) // word -> clue already written to the cache
+
+	for attempt := 0; ; attempt++ {
+		// Build prompt for this batch, including rejection reasons on retries
+		contextSection := buildContextSection(pending, req.puzzle, req.wordEntries)
+		var prompt string
+		var err error
+		if len(rejected) > 0 {
+			prompt, err = buildRegenerationPrompt(pending, g.difficulty, contextSection, rejected)
+		} else {
+			prompt, err = buildPromptWithContext(pending, g.difficulty, contextSection)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to build prompt: %w", err)
 		}
 
-		// Call LLM
-		response, err := g.llmClient.Complete(ctx, prompt)
+		// Call LLM, unless the budget has already been spent
+		if err := g.usage.CheckBudget(); err != nil {
+			return nil, err
+		}
+		response, err := g.complete(ctx, prompt, pending, func(word, clue string) {
+			g.cacheClue(req, word, clue, saved)
+		})
 		if err != nil {
 			return nil, fmt.Errorf("LLM completion failed: %w", err)
 		}
 
 		// Parse response
-		batchClues, err := ParseClueResponse(response, batch)
+		batchClues, err := ParseClueResponse(response, pending)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse LLM response: %w", err)
 		}
 
-		// Merge into result
-		for word, clue := range batchClues {
-			allClues[word] = clue
+		// Lint each clue and collect the ones to regenerate
+		rejected = make(map[string]RejectedClue)
+		var retry []string
+		for _, word := range pending {
+			clue := batchClues[word]
+			clues[word] = clue
+			if issues := g.linter.LintClue(word, clue); len(issues) > 0 {
+				rejected[word] = RejectedClue{Clue: clue, Issues: issues}
+				retry = append(retry, word)
+			} else {
+				g.cacheClue(req, word, clue, saved)
+			}
+		}
+
+		if len(retry) == 0 || attempt >= MaxRegenerationAttempts {
+			return clues, nil
 		}
+		pending = retry
 	}
+}
 
-	return allClues, nil
+// complete sends a prompt to the LLM, recording token usage when the client
+// reports it. Streaming clients are read incrementally and onClue is called for
+// each of words whose clue is complete before the whole response has arrived.
+func (g *Generator) complete(ctx context.Context, prompt string, words []string, onClue func(word, clue string)) (string, error) {
+	var (
+		response string
+		usage    providers.Usage
+		err      error
+	)
+
+	switch client := g.llmClient.(type) {
+	case providers.StreamingClient:
+		parser := newClueStreamParser(words)
+		response, usage, err = client.CompleteStream(ctx, prompt, func(chunk string) {
+			for word, clue := range parser.Feed(chunk) {
+				onClue(word, clue)
+			}
+		})
+	case providers.UsageClient:
+		response, usage, err = client.CompleteWithUsage(ctx, prompt)
+	default:
+		return g.llmClient.Complete(ctx, prompt)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// A failure to persist usage shouldn't stop generation; it is still counted
+	_ = g.usage.Record(usage)
+
+	return response, nil
+}
+
+// cacheClue saves a clue that passes the linter, skipping context-specific
+// words and clues already saved for this batch
+func (g *Generator) cacheClue(req *clueRequest, word, clue string, saved map[string]string) {
+	if g.cache == nil || req.contextWords[word] || saved[word] == clue {
+		return
+	}
+	if len(g.linter.LintClue(word, clue)) > 0 {
+		return
+	}
+	if err := g.cache.SaveClue(word, clue, string(g.difficulty)); err != nil {
+		// Log error but continue - cache save failure shouldn't stop generation
+		// In production, you'd use a proper logger here
+		_ = err
+		return
+	}
+	saved[word] = clue
 }
 
 // extractWord extracts the word from an entry's cells
@@ -0,0 +1,368 @@
+package clues
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultMaxClueLength is the longest clue, in characters, the linter accepts
+	DefaultMaxClueLength = 100
+
+	// MaxRegenerationAttempts is how many times a clue that fails linting is sent
+	// back to the LLM before the generator gives up and keeps the last attempt
+	MaxRegenerationAttempts = 2
+)
+
+// Lint rule identifiers
+const (
+	RuleEmpty        = "empty"
+	RuleTooLong      = "too-long"
+	RuleAnswerLeak   = "answer-leak"
+	RuleStemLeak     = "stem-leak"
+	RuleAbbreviation = "abbreviation"
+	RulePlural       = "plural-agreement"
+	RuleTense        = "tense-agreement"
+	RuleDuplicate    = "duplicate"
+	RuleCrossRef     = "cross-reference"
+)
+
+// LintIssue describes a single rule a clue breaks
+type LintIssue struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// String returns the issue in "rule: message" form
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.Rule, i.Message)
+}
+
+// LintEntry is one answer/clue pair checked by LintPuzzle
+type LintEntry struct {
+	Key    string // entry key, e.g. "1-across"
+	Answer string
+	Clue   string
+}
+
+// Linter checks clues against answer leakage and format rules.
+// It is shared by the LLM generator and by importers of existing puzzles.
+type Linter struct {
+	MaxLength int // maximum clue length in characters (0 = DefaultMaxClueLength)
+}
+
+// NewLinter creates a Linter with default settings
+func NewLinter() *Linter {
+	return &Linter{MaxLength: DefaultMaxClueLength}
+}
+
+var (
+	abbrMarkerPattern = regexp.MustCompile(`(?i)\babbr\b\.?`)
+	entryRefPattern   = regexp.MustCompile(`(?i)\b(\d+)-(across|down)\b`)
+	wordPattern       = regexp.MustCompile(`[A-Za-z]+`)
+)
+
+// stemSuffixes are stripped, longest first, when comparing answer and clue stems
+var stemSuffixes = []string{"ING", "IES", "ED", "ES", "ER", "LY", "S"}
+
+// LintClue checks a single clue against its answer and returns every issue found.
+// An empty slice means the clue is acceptable.
+func (l *Linter) LintClue(answer, clue string) []LintIssue {
+	issues := []LintIssue{}
+
+	trimmed := strings.TrimSpace(clue)
+	if trimmed == "" {
+		return append(issues, LintIssue{Rule: RuleEmpty, Message: "clue is empty"})
+	}
+
+	maxLength := l.MaxLength
+	if maxLength <= 0 {
+		maxLength = DefaultMaxClueLength
+	}
+	if len([]rune(trimmed)) > maxLength {
+		issues = append(issues, LintIssue{
+			Rule:    RuleTooLong,
+			Message: fmt.Sprintf("clue is %d characters long (max %d)", len([]rune(trimmed)), maxLength),
+		})
+	}
+
+	answer = normalizeAnswer(answer)
+	tokens := clueTokens(trimmed)
+
+	if leak := findAnswerLeak(answer, tokens); leak != "" {
+		issues = append(issues, LintIssue{
+			Rule:    RuleAnswerLeak,
+			Message: fmt.Sprintf("answer %s appears in clue as %q", answer, strings.ToLower(leak)),
+		})
+	} else if leak := findStemLeak(answer, tokens); leak != "" {
+		issues = append(issues, LintIssue{
+			Rule:    RuleStemLeak,
+			Message: fmt.Sprintf("clue word %q shares a stem with answer %s", strings.ToLower(leak), answer),
+		})
+	}
+
+	for _, marker := range abbrMarkerPattern.FindAllString(trimmed, -1) {
+		if marker != "Abbr." {
+			issues = append(issues, LintIssue{
+				Rule:    RuleAbbreviation,
+				Message: fmt.Sprintf("abbreviation marker %q should be written \"Abbr.\"", marker),
+			})
+			break
+		}
+	}
+
+	// Agreement checks only apply to single-word definitions, where the clue
+	// word and the answer must be the same part of speech
+	definition := strings.TrimSpace(abbrMarkerPattern.ReplaceAllString(trimmed, ""))
+	defTokens := clueTokens(definition)
+	if len(defTokens) == 1 {
+		word := defTokens[0]
+		if isPluralForm(word) && !isPluralForm(answer) {
+			issues = append(issues, LintIssue{
+				Rule:    RulePlural,
+				Message: fmt.Sprintf("clue %q is plural but answer %s is not", strings.ToLower(word), answer),
+			})
+		}
+		if clueTense, answerTense := tenseOf(word), tenseOf(answer); clueTense != "" && answerTense != "" && clueTense != answerTense {
+			issues = append(issues, LintIssue{
+				Rule:    RuleTense,
+				Message: fmt.Sprintf("clue %q is %s tense but answer %s is %s", strings.ToLower(word), clueTense, answer, answerTense),
+			})
+		}
+	}
+
+	return issues
+}
+
+// LintPuzzle lints every entry and additionally flags clues reused for more than
+// one answer in the same puzzle. The returned map only contains entries with issues.
+func (l *Linter) LintPuzzle(entries []LintEntry) map[string][]LintIssue {
+	results := make(map[string][]LintIssue)
+
+	// Sort for deterministic "first use" when reporting duplicates
+	sorted := append([]LintEntry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	firstUse := make(map[string]LintEntry)
+	for _, entry := range sorted {
+		issues := l.LintClue(entry.Answer, entry.Clue)
+
+		normalized := normalizeClue(entry.Clue)
+		if normalized != "" {
+			if prev, seen := firstUse[normalized]; seen && normalizeAnswer(prev.Answer) != normalizeAnswer(entry.Answer) {
+				issues = append(issues, LintIssue{
+					Rule:    RuleDuplicate,
+					Message: fmt.Sprintf("same clue is already used for %s", prev.Key),
+				})
+			} else if !seen {
+				firstUse[normalized] = entry
+			}
+		}
+
+		if len(issues) > 0 {
+			results[entry.Key] = issues
+		}
+	}
+
+	return results
+}
+
+// LintCrossReferences checks entry references in clue text against the puzzle.
+// Every "17-Across"-style reference must name an entry in entries, and each
+// declared cross-reference must appear in its From entry's clue.
+// The returned map only contains entries with issues.
+func (l *Linter) LintCrossReferences(entries []LintEntry, refs []CrossReference) map[string][]LintIssue {
+	results := make(map[string][]LintIssue)
+
+	clueByKey := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		clueByKey[entry.Key] = entry.Clue
+	}
+
+	for _, entry := range entries {
+		for _, match := range entryRefPattern.FindAllStringSubmatch(entry.Clue, -1) {
+			key := match[1] + "-" + strings.ToLower(match[2])
+			if _, exists := clueByKey[key]; !exists {
+				results[entry.Key] = append(results[entry.Key], LintIssue{
+					Rule:    RuleCrossRef,
+					Message: fmt.Sprintf("clue refers to %s, which is not an entry in this puzzle", FormatEntryRef(key)),
+				})
+			} else if key == entry.Key {
+				results[entry.Key] = append(results[entry.Key], LintIssue{
+					Rule:    RuleCrossRef,
+					Message: "clue refers to its own entry",
+				})
+			}
+		}
+	}
+
+	for _, ref := range refs {
+		clue, exists := clueByKey[ref.From]
+		if !exists {
+			continue
+		}
+		if !referencesEntry(clue, ref.To) {
+			results[ref.From] = append(results[ref.From], LintIssue{
+				Rule:    RuleCrossRef,
+				Message: fmt.Sprintf("clue must refer to %s, written as \"%s\"", FormatEntryRef(ref.To), FormatEntryRef(ref.To)),
+			})
+		}
+	}
+
+	return results
+}
+
+// referencesEntry reports whether clue text contains a reference to the entry key
+func referencesEntry(clue, entryKey string) bool {
+	for _, match := range entryRefPattern.FindAllStringSubmatch(clue, -1) {
+		if match[1]+"-"+strings.ToLower(match[2]) == entryKey {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatIssues joins issues into a single human-readable line
+func FormatIssues(issues []LintIssue) string {
+	parts := make([]string, len(issues))
+	for i, issue := range issues {
+		parts[i] = issue.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// normalizeAnswer uppercases an answer and drops anything that isn't a letter
+func normalizeAnswer(answer string) string {
+	var b strings.Builder
+	for _, r := range answer {
+		if unicode.IsLetter(r) {
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// normalizeClue lowercases a clue and collapses whitespace and trailing punctuation
+// so trivially different spellings of the same clue compare equal
+func normalizeClue(clue string) string {
+	clue = strings.ToLower(strings.Join(strings.Fields(clue), " "))
+	return strings.TrimRight(clue, ".!?")
+}
+
+// clueTokens returns the uppercase alphabetic words in a clue
+func clueTokens(clue string) []string {
+	words := wordPattern.FindAllString(clue, -1)
+	for i, w := range words {
+		words[i] = strings.ToUpper(w)
+	}
+	return words
+}
+
+// findAnswerLeak returns the clue word (or run of words) that spells out the answer
+func findAnswerLeak(answer string, tokens []string) string {
+	if len(answer) < 3 {
+		return ""
+	}
+	for i, token := range tokens {
+		if token == answer || (len(answer) >= 4 && strings.Contains(token, answer)) {
+			return token
+		}
+		// Multi-word answers such as ICECREAM clued with "ice cream"
+		joined := token
+		for j := i + 1; j < len(tokens) && len(joined) < len(answer); j++ {
+			joined += tokens[j]
+			if joined == answer {
+				return strings.Join(tokens[i:j+1], " ")
+			}
+		}
+	}
+	return ""
+}
+
+// findStemLeak returns the first clue word sharing a stem with the answer
+func findStemLeak(answer string, tokens []string) string {
+	answerStem := stem(answer)
+	if len(answerStem) < 3 {
+		return ""
+	}
+	for _, token := range tokens {
+		if len(token) < 3 {
+			continue
+		}
+		if stem(token) == answerStem || (len(answerStem) >= 4 && strings.Contains(token, answerStem)) {
+			return token
+		}
+	}
+	return ""
+}
+
+// stem strips a single common inflectional suffix from an uppercase word
+func stem(word string) string {
+	for _, suffix := range stemSuffixes {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 3 {
+			base := strings.TrimSuffix(word, suffix)
+			if suffix == "IES" {
+				base += "Y"
+			}
+			return base
+		}
+	}
+	return word
+}
+
+// singularS are words ending in S that are not plurals. Words ending in -ICS
+// (PHYSICS, POLITICS) are treated the same way.
+var singularS = map[string]bool{
+	"NEWS": true, "SERIES": true, "SPECIES": true, "MEANS": true, "MUMPS": true,
+	"MEASLES": true, "HERPES": true, "DIABETES": true, "RABIES": true, "CHESS": true,
+	"ALWAYS": true, "PERHAPS": true, "WHEREAS": true, "LENS": true, "GAS": true,
+	"CHAOS": true, "ETHOS": true, "PATHOS": true, "BIAS": true, "ATLAS": true,
+	"CANVAS": true, "WALRUS": true, "BONUS": true, "OASIS": true,
+}
+
+// nonVerbs are words ending in -ED or -ING that are not verb forms
+var nonVerbs = map[string]bool{
+	"THING": true, "KING": true, "RING": true, "WING": true, "SPRING": true,
+	"STRING": true, "SWING": true, "STING": true, "SLING": true, "CEILING": true,
+	"MORNING": true, "EVENING": true, "PUDDING": true, "SIBLING": true, "DUCKLING": true,
+	"DARLING": true, "SHILLING": true, "VIKING": true, "NOTHING": true, "SOMETHING": true,
+	"ANYTHING": true, "EVERYTHING": true, "DURING": true, "AWNING": true, "HERRING": true,
+	"STEED": true, "SPEED": true, "BREED": true, "CREED": true, "GREED": true,
+	"TWEED": true, "BLEED": true, "EMBED": true, "HUNDRED": true, "KINDRED": true,
+	"SACRED": true, "NAKED": true, "WICKED": true, "RAGGED": true, "RUGGED": true,
+	"JAGGED": true, "WRETCHED": true, "BELOVED": true,
+}
+
+// isPluralForm reports whether an uppercase or lowercase word looks like a regular plural
+func isPluralForm(word string) bool {
+	word = strings.ToUpper(word)
+	if len(word) < 4 || !strings.HasSuffix(word, "S") || singularS[word] {
+		return false
+	}
+	for _, suffix := range []string{"SS", "US", "IS", "OUS", "ICS"} {
+		if strings.HasSuffix(word, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// tenseOf classifies a word as "past" or "progressive" by its ending
+func tenseOf(word string) string {
+	word = strings.ToUpper(word)
+	if len(word) < 5 || nonVerbs[word] {
+		return ""
+	}
+	switch {
+	case strings.HasSuffix(word, "ING"):
+		return "progressive"
+	case strings.HasSuffix(word, "ED"):
+		return "past"
+	}
+	return ""
+}
@@ -0,0 +1,264 @@
+package clues
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/crossplay/backend/pkg/grid"
+)
+
+func hasRule(issues []LintIssue, rule string) bool {
+	for _, issue := range issues {
+		if issue.Rule == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLintClue(t *testing.T) {
+	tests := []struct {
+		name     string
+		answer   string
+		clue     string
+		wantRule string // empty means the clue should pass
+	}{
+		{"valid clue", "CAT", "Purring companion", ""},
+		{"empty clue", "CAT", "   ", RuleEmpty},
+		{"answer as word", "CAT", "A cat, for one", RuleAnswerLeak},
+		{"answer inside word", "NEST", "Nestled in a tree", RuleAnswerLeak},
+		{"multi-word answer", "ICECREAM", "Ice cream treat", RuleAnswerLeak},
+		{"stem leak", "JUMPING", "Jumped", RuleStemLeak},
+		{"plural stem leak", "CATS", "Cat family", RuleStemLeak},
+		{"short answer substring is fine", "ERA", "Generally long time", ""},
+		{"lowercase abbr marker", "ORG", "Group: abbr.", RuleAbbreviation},
+		{"abbr without period", "ORG", "Group (Abbr)", RuleAbbreviation},
+		{"canonical abbr marker", "ORG", "Group: Abbr.", ""},
+		{"plural clue singular answer", "FELINE", "Cats", RulePlural},
+		{"plural clue plural answer", "FELINES", "Cats", ""},
+		{"non-plural s ending", "GLASS", "Chalice", ""},
+		{"past clue progressive answer", "LEAPING", "Jumped", RuleTense},
+		{"matching tense", "LEAPED", "Jumped", ""},
+		{"irregular past not flagged", "RAN", "Sprinted", ""},
+		{"-ics word is not plural", "SCIENCE", "Physics", ""},
+		{"news is not plural", "REPORT", "News", ""},
+		{"series is not plural", "SET", "Series", ""},
+		{"-ed noun has no tense", "LEAPING", "Steed", ""},
+		{"-ing noun has no tense", "JUMPED", "Thing", ""},
+		{"-ed noun answer has no tense", "STEED", "Charging", ""},
+		{"multi-word clue skips agreement", "FELINE", "Like cats", ""},
+		{"too long", "CAT", strings.Repeat("Purring ", 20), RuleTooLong},
+	}
+
+	linter := NewLinter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := linter.LintClue(tt.answer, tt.clue)
+			if tt.wantRule == "" {
+				if len(issues) != 0 {
+					t.Errorf("LintClue(%q, %q) = %v, want no issues", tt.answer, tt.clue, issues)
+				}
+				return
+			}
+			if !hasRule(issues, tt.wantRule) {
+				t.Errorf("LintClue(%q, %q) = %v, want rule %s", tt.answer, tt.clue, issues, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestLintClue_MaxLength(t *testing.T) {
+	linter := &Linter{MaxLength: 10}
+
+	if issues := linter.LintClue("CAT", "Purring pet"); !hasRule(issues, RuleTooLong) {
+		t.Errorf("Expected too-long issue with MaxLength 10, got %v", issues)
+	}
+	if issues := linter.LintClue("CAT", "Purrer"); len(issues) != 0 {
+		t.Errorf("Expected no issues, got %v", issues)
+	}
+}
+
+func TestLintPuzzle_Duplicates(t *testing.T) {
+	entries := []LintEntry{
+		{Key: "1-across", Answer: "CAT", Clue: "House pet"},
+		{Key: "2-down", Answer: "DOG", Clue: "house pet."},
+		{Key: "3-across", Answer: "CAT", Clue: "House pet"},
+		{Key: "4-down", Answer: "EMU", Clue: "Flightless bird"},
+	}
+
+	results := NewLinter().LintPuzzle(entries)
+
+	if !hasRule(results["2-down"], RuleDuplicate) {
+		t.Errorf("Expected 2-down to be flagged as duplicate, got %v", results["2-down"])
+	}
+	if _, ok := results["1-across"]; ok {
+		t.Errorf("First use of a clue should not be flagged, got %v", results["1-across"])
+	}
+	if _, ok := results["3-across"]; ok {
+		t.Errorf("Same answer reusing its clue should not be flagged, got %v", results["3-across"])
+	}
+	if _, ok := results["4-down"]; ok {
+		t.Errorf("Unique clue should not be flagged, got %v", results["4-down"])
+	}
+}
+
+func TestBuildRegenerationPrompt(t *testing.T) {
+	rejected := map[string]RejectedClue{
+		"CAT": {
+			Clue:   "A cat",
+			Issues: []LintIssue{{Rule: RuleAnswerLeak, Message: "answer CAT appears in clue"}},
+		},
+	}
+
+	prompt, err := buildRegenerationPrompt([]string{"CAT"}, DifficultyEasy, "", rejected)
+	if err != nil {
+		t.Fatalf("buildRegenerationPrompt failed: %v", err)
+	}
+
+	for _, want := range []string{"CAT", `"A cat"`, "answer CAT appears in clue", "rejected"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Prompt missing %q", want)
+		}
+	}
+}
+
+// sequenceLLMClient returns a different response on each call and records prompts
+type sequenceLLMClient struct {
+	responses []string
+	prompts   []string
+}
+
+func (s *sequenceLLMClient) Complete(ctx context.Context, prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	i := len(s.prompts) - 1
+	if i >= len(s.responses) {
+		i = len(s.responses) - 1
+	}
+	return s.responses[i], nil
+}
+
+func TestGenerateClues_RegeneratesLintFailures(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cache, _ := NewClueCache(db)
+	client := &sequenceLLMClient{
+		responses: []string{
+			`{"clues": {"CAT": "A cat, basically", "DOG": "Loyal animal"}}`,
+			`{"clues": {"CAT": "Purring companion"}}`,
+		},
+	}
+	gen := NewGenerator(cache, client, DifficultyMedium)
+
+	entries := []*grid.Entry{
+		createTestEntry(1, grid.ACROSS, "CAT"),
+		createTestEntry(2, grid.DOWN, "DOG"),
+	}
+
+	result, err := gen.GenerateClues(context.Background(), entries)
+	if err != nil {
+		t.Fatalf("GenerateClues failed: %v", err)
+	}
+
+	if result["1-across"] != "Purring companion" {
+		t.Errorf("Expected regenerated clue for CAT, got %q", result["1-across"])
+	}
+	if result["2-down"] != "Loyal animal" {
+		t.Errorf("Expected original clue for DOG, got %q", result["2-down"])
+	}
+
+	if len(client.prompts) != 2 {
+		t.Fatalf("Expected 2 LLM calls, got %d", len(client.prompts))
+	}
+	retry := client.prompts[1]
+	if !strings.Contains(retry, "A cat, basically") || !strings.Contains(retry, "answer CAT appears in clue") {
+		t.Errorf("Retry prompt should include the rejected clue and reason, got:\n%s", retry)
+	}
+	if strings.Contains(retry, "Words: CAT, DOG") {
+		t.Error("Retry prompt should only include the failing word")
+	}
+}
+
+func TestGenerateClues_GivesUpAfterMaxAttempts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cache, _ := NewClueCache(db)
+	client := &sequenceLLMClient{
+		responses: []string{`{"clues": {"CAT": "A cat"}}`},
+	}
+	gen := NewGenerator(cache, client, DifficultyEasy)
+
+	result, err := gen.GenerateClues(context.Background(), []*grid.Entry{
+		createTestEntry(1, grid.ACROSS, "CAT"),
+	})
+	if err != nil {
+		t.Fatalf("GenerateClues failed: %v", err)
+	}
+
+	if len(client.prompts) != MaxRegenerationAttempts+1 {
+		t.Errorf("Expected %d LLM calls, got %d", MaxRegenerationAttempts+1, len(client.prompts))
+	}
+	if result["1-across"] != "A cat" {
+		t.Errorf("Expected last attempt to be kept, got %q", result["1-across"])
+	}
+	if _, found := cache.GetClue("CAT", "easy"); found {
+		t.Error("Clue failing lint should not be cached")
+	}
+}
+
+func TestGenerateClues_RegeneratesDuplicateClues(t *testing.T) {
+	client := &sequenceLLMClient{
+		responses: []string{
+			`{"clues": {"CAT": "House pet", "DOG": "House pet"}}`,
+			`{"clues": {"DOG": "Loyal animal"}}`,
+		},
+	}
+	gen := NewGenerator(nil, client, DifficultyEasy)
+
+	result, err := gen.GenerateClues(context.Background(), []*grid.Entry{
+		createTestEntry(1, grid.ACROSS, "CAT"),
+		createTestEntry(2, grid.DOWN, "DOG"),
+	})
+	if err != nil {
+		t.Fatalf("GenerateClues failed: %v", err)
+	}
+
+	if result["1-across"] != "House pet" {
+		t.Errorf("Expected first use to be kept, got %q", result["1-across"])
+	}
+	if result["2-down"] != "Loyal animal" {
+		t.Errorf("Expected duplicate to be regenerated, got %q", result["2-down"])
+	}
+	if len(client.prompts) != 2 || !strings.Contains(client.prompts[1], "same clue is already used for 1-across") {
+		t.Errorf("Expected a regeneration prompt mentioning the duplicate, got %d prompts", len(client.prompts))
+	}
+}
+
+func TestGenerateClues_SkipsCachedClueFailingLint(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cache, _ := NewClueCache(db)
+	cache.SaveClue("CAT", "Cat-like", "easy")
+
+	client := &sequenceLLMClient{
+		responses: []string{`{"clues": {"CAT": "Purring companion"}}`},
+	}
+	gen := NewGenerator(cache, client, DifficultyEasy)
+
+	result, err := gen.GenerateClues(context.Background(), []*grid.Entry{
+		createTestEntry(1, grid.ACROSS, "CAT"),
+	})
+	if err != nil {
+		t.Fatalf("GenerateClues failed: %v", err)
+	}
+
+	if result["1-across"] != "Purring companion" {
+		t.Errorf("Expected cached clue failing lint to be replaced, got %q", result["1-across"])
+	}
+	if len(client.prompts) != 1 || !strings.Contains(client.prompts[0], `"Cat-like" was rejected`) {
+		t.Errorf("Expected the prompt to say why the cached clue was rejected, got:\n%s", strings.Join(client.prompts, "\n---\n"))
+	}
+}
@@ -0,0 +1,190 @@
+package clues
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/crossplay/backend/pkg/clues/providers"
+)
+
+// PostgresSchema defines the clue cache tables in the server's Postgres database.
+// Unlike the SQLite schema, clues are unique per word, clue and difficulty so
+// repeated syncs never duplicate rows.
+const PostgresSchema = `
+CREATE TABLE IF NOT EXISTS clue_cache (
+	id SERIAL PRIMARY KEY,
+	word VARCHAR(50) NOT NULL,
+	clue TEXT NOT NULL,
+	difficulty VARCHAR(10) NOT NULL CHECK (difficulty IN ('easy', 'medium', 'hard')),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(word, clue, difficulty)
+);
+
+CREATE INDEX IF NOT EXISTS idx_clue_cache_word_difficulty ON clue_cache(word, difficulty);
+
+CREATE TABLE IF NOT EXISTS llm_usage (
+	id SERIAL PRIMARY KEY,
+	batch_id VARCHAR(64) NOT NULL DEFAULT '',
+	puzzle_id VARCHAR(64) NOT NULL DEFAULT '',
+	provider VARCHAR(50) NOT NULL,
+	model VARCHAR(100) NOT NULL,
+	input_tokens INTEGER NOT NULL DEFAULT 0,
+	output_tokens INTEGER NOT NULL DEFAULT 0,
+	cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_llm_usage_batch ON llm_usage(batch_id);
+`
+
+// PostgresClueCache is a ClueStore backed by the server's Postgres database
+type PostgresClueCache struct {
+	db *sql.DB
+}
+
+// NewPostgresClueCache creates a clue cache on an open Postgres connection.
+// The tables are created by InitPostgresDB or the server's InitSchema.
+func NewPostgresClueCache(db *sql.DB) (*PostgresClueCache, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	return &PostgresClueCache{db: db}, nil
+}
+
+// InitPostgresDB creates the clue cache tables in a Postgres database
+func InitPostgresDB(db *sql.DB) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	if _, err := db.Exec(PostgresSchema); err != nil {
+		return fmt.Errorf("failed to initialize database schema: %w", err)
+	}
+
+	return nil
+}
+
+// GetClue retrieves a random cached clue for the given word and difficulty
+func (c *PostgresClueCache) GetClue(word, difficulty string) (string, bool) {
+	var clue string
+	err := c.db.QueryRow(`
+		SELECT clue FROM clue_cache
+		WHERE word = $1 AND difficulty = $2
+		ORDER BY RANDOM()
+		LIMIT 1
+	`, word, difficulty).Scan(&clue)
+
+	if err != nil {
+		return "", false
+	}
+
+	return clue, true
+}
+
+// SaveClue inserts a new clue; saving a clue that already exists is a no-op
+func (c *PostgresClueCache) SaveClue(word, clue, difficulty string) error {
+	if word == "" {
+		return fmt.Errorf("word cannot be empty")
+	}
+
+	if clue == "" {
+		return fmt.Errorf("clue cannot be empty")
+	}
+
+	if difficulty == "" {
+		return fmt.Errorf("difficulty cannot be empty")
+	}
+
+	_, err := c.db.Exec(`
+		INSERT INTO clue_cache (word, clue, difficulty)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (word, clue, difficulty) DO NOTHING
+	`, word, clue, difficulty)
+
+	if err != nil {
+		return fmt.Errorf("failed to save clue: %w", err)
+	}
+
+	return nil
+}
+
+// SaveUsage records the token usage of one LLM call against a batch and puzzle
+func (c *PostgresClueCache) SaveUsage(batchID, puzzleID string, usage providers.Usage) error {
+	_, err := c.db.Exec(`
+		INSERT INTO llm_usage (batch_id, puzzle_id, provider, model, input_tokens, output_tokens, cost_usd)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, batchID, puzzleID, usage.Provider, usage.Model, usage.InputTokens, usage.OutputTokens, usage.CostUSD)
+
+	if err != nil {
+		return fmt.Errorf("failed to save usage: %w", err)
+	}
+
+	return nil
+}
+
+// ListClues returns every cached clue, oldest first
+func (c *PostgresClueCache) ListClues() ([]CachedClue, error) {
+	rows, err := c.db.Query(`
+		SELECT word, clue, difficulty, created_at FROM clue_cache
+		ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clues: %w", err)
+	}
+	defer rows.Close()
+
+	var clues []CachedClue
+	for rows.Next() {
+		var cc CachedClue
+		var createdAt sql.NullTime
+		if err := rows.Scan(&cc.Word, &cc.Clue, &cc.Difficulty, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan clue: %w", err)
+		}
+		cc.CreatedAt = createdAt.Time
+		clues = append(clues, cc)
+	}
+
+	return clues, rows.Err()
+}
+
+// ImportClues inserts clues that aren't already cached and returns how many were added
+func (c *PostgresClueCache) ImportClues(clues []CachedClue) (int, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO clue_cache (word, clue, difficulty, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (word, clue, difficulty) DO NOTHING
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare import: %w", err)
+	}
+	defer stmt.Close()
+
+	added := 0
+	for _, cc := range clues {
+		createdAt := cc.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+
+		res, err := stmt.Exec(cc.Word, cc.Clue, cc.Difficulty, createdAt.UTC())
+		if err != nil {
+			return 0, fmt.Errorf("failed to import clue for %s: %w", cc.Word, err)
+		}
+		if n, err := res.RowsAffected(); err == nil {
+			added += int(n)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit import: %w", err)
+	}
+
+	return added, nil
+}
@@ -28,6 +28,12 @@ type ClueResponse struct {
 // buildPrompt constructs an LLM prompt for generating crossword clues
 // It accepts a batch of words (up to MaxWordsPerBatch) and a difficulty level
 func buildPrompt(words []string, difficulty Difficulty) (string, error) {
+	return buildPromptWithContext(words, difficulty, "")
+}
+
+// buildPromptWithContext constructs a clue prompt with an optional puzzle context
+// section (see buildContextSection) placed after the word list
+func buildPromptWithContext(words []string, difficulty Difficulty, contextSection string) (string, error) {
 	if len(words) == 0 {
 		return "", fmt.Errorf("words slice cannot be empty")
 	}
@@ -58,7 +64,7 @@ func buildPrompt(words []string, difficulty Difficulty) (string, error) {
 Difficulty: %s
 %s
 
-Words: %s
+Words: %s%s
 
 Requirements:
 - Generate exactly one clue for each word
@@ -74,11 +80,43 @@ Return ONLY the JSON object with all clues filled in. Do not include any explana
 		difficulty,
 		guidelines,
 		wordList,
+		strings.TrimRight(contextSection, "\n"),
 		string(exampleBytes))
 
 	return prompt, nil
 }
 
+// RejectedClue records a clue that failed linting and why, so the LLM can be
+// told what to fix when the word is regenerated
+type RejectedClue struct {
+	Clue   string
+	Issues []LintIssue
+}
+
+// buildRegenerationPrompt constructs a prompt for words whose previous clues were
+// rejected by the linter. It extends buildPrompt with the rejected clue and the
+// reasons for each word.
+func buildRegenerationPrompt(words []string, difficulty Difficulty, contextSection string, rejected map[string]RejectedClue) (string, error) {
+	prompt, err := buildPromptWithContext(words, difficulty, contextSection)
+	if err != nil {
+		return "", err
+	}
+
+	var feedback strings.Builder
+	for _, word := range words {
+		r, ok := rejected[word]
+		if !ok {
+			continue
+		}
+		feedback.WriteString(fmt.Sprintf("- %s: %q was rejected (%s)\n", word, r.Clue, FormatIssues(r.Issues)))
+	}
+	if feedback.Len() == 0 {
+		return prompt, nil
+	}
+
+	return prompt + "\n\nYour previous clues for these words were rejected. Write new clues that fix these problems:\n" + feedback.String(), nil
+}
+
 // getDifficultyGuidelines returns specific guidelines for each difficulty level
 func getDifficultyGuidelines(difficulty Difficulty) string {
 	switch difficulty {
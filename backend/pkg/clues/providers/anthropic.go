@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -8,6 +9,7 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -23,6 +25,9 @@ const (
 	defaultTemperature = 1.0
 	defaultTimeout     = 30 * time.Second
 
+	// defaultAnthropicConcurrency is how many requests a client sends in parallel
+	defaultAnthropicConcurrency = 4
+
 	// Retry configuration
 	maxRetries     = 3
 	initialBackoff = 1 * time.Second
@@ -31,21 +36,24 @@ const (
 
 // AnthropicClient implements LLMClient for Anthropic's Claude API
 type AnthropicClient struct {
-	apiKey      string
-	model       string
-	maxTokens   int
-	temperature float64
-	timeout     time.Duration
-	httpClient  *http.Client
+	apiKey         string
+	apiURL         string
+	model          string
+	maxTokens      int
+	temperature    float64
+	timeout        time.Duration
+	maxConcurrency int
+	httpClient     *http.Client
 }
 
 // AnthropicConfig holds configuration for the Anthropic client
 type AnthropicConfig struct {
-	APIKey      string
-	Model       string
-	MaxTokens   int
-	Temperature float64
-	Timeout     time.Duration
+	APIKey         string
+	Model          string
+	MaxTokens      int
+	Temperature    float64
+	Timeout        time.Duration
+	MaxConcurrency int // parallel requests allowed (0 = default)
 }
 
 // anthropicRequest represents the API request format
@@ -54,6 +62,7 @@ type anthropicRequest struct {
 	MaxTokens   int                 `json:"max_tokens"`
 	Messages    []anthropicMessage  `json:"messages"`
 	Temperature float64             `json:"temperature,omitempty"`
+	Stream      bool                `json:"stream,omitempty"`
 }
 
 // anthropicMessage represents a message in the conversation
@@ -69,15 +78,36 @@ type anthropicResponse struct {
 	Role    string              `json:"role"`
 	Content []anthropicContent  `json:"content"`
 	Model   string              `json:"model"`
+	Usage   anthropicUsage      `json:"usage"`
 	Error   *anthropicError     `json:"error,omitempty"`
 }
 
+// anthropicUsage represents the token counts reported with a response
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
 // anthropicContent represents content blocks in the response
 type anthropicContent struct {
 	Type string `json:"type"`
 	Text string `json:"text"`
 }
 
+// anthropicStreamEvent represents one server-sent event of a streamed response
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message *struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message,omitempty"`
+	Delta *struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta,omitempty"`
+	Usage *anthropicUsage `json:"usage,omitempty"`
+	Error *anthropicError `json:"error,omitempty"`
+}
+
 // anthropicError represents an API error
 type anthropicError struct {
 	Type    string `json:"type"`
@@ -106,12 +136,18 @@ func NewAnthropicClient(config AnthropicConfig) (*AnthropicClient, error) {
 		config.Timeout = defaultTimeout
 	}
 
+	if config.MaxConcurrency <= 0 {
+		config.MaxConcurrency = defaultAnthropicConcurrency
+	}
+
 	return &AnthropicClient{
-		apiKey:      config.APIKey,
-		model:       config.Model,
-		maxTokens:   config.MaxTokens,
-		temperature: config.Temperature,
-		timeout:     config.Timeout,
+		apiKey:         config.APIKey,
+		apiURL:         anthropicAPIURL,
+		model:          config.Model,
+		maxTokens:      config.MaxTokens,
+		temperature:    config.Temperature,
+		timeout:        config.Timeout,
+		maxConcurrency: config.MaxConcurrency,
 		httpClient: &http.Client{
 			Timeout: config.Timeout,
 		},
@@ -120,6 +156,13 @@ func NewAnthropicClient(config AnthropicConfig) (*AnthropicClient, error) {
 
 // Complete sends a prompt to Claude API and returns the response text
 func (c *AnthropicClient) Complete(ctx context.Context, prompt string) (string, error) {
+	response, _, err := c.CompleteWithUsage(ctx, prompt)
+	return response, err
+}
+
+// CompleteWithUsage sends a prompt to Claude API and returns the response text
+// along with the token usage and estimated cost of the successful request
+func (c *AnthropicClient) CompleteWithUsage(ctx context.Context, prompt string) (string, Usage, error) {
 	var lastErr error
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
@@ -128,32 +171,38 @@ func (c *AnthropicClient) Complete(ctx context.Context, prompt string) (string,
 			select {
 			case <-time.After(backoff):
 			case <-ctx.Done():
-				return "", ctx.Err()
+				return "", Usage{}, ctx.Err()
 			}
 		}
 
-		response, err := c.sendRequest(ctx, prompt)
+		response, usage, err := c.sendRequest(ctx, prompt)
 		if err == nil {
-			return response, nil
+			return response, usage, nil
 		}
 
 		lastErr = err
 
 		// Don't retry on context cancellation or non-retryable errors
 		if ctx.Err() != nil || !isRetryableError(err) {
-			return "", err
+			return "", Usage{}, err
 		}
 	}
 
-	return "", fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
+	return "", Usage{}, fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
 }
 
-// sendRequest sends a single request to the Anthropic API
-func (c *AnthropicClient) sendRequest(ctx context.Context, prompt string) (string, error) {
+// MaxConcurrency returns how many requests may be sent in parallel
+func (c *AnthropicClient) MaxConcurrency() int {
+	return c.maxConcurrency
+}
+
+// newRequest builds a Messages API request for a prompt
+func (c *AnthropicClient) newRequest(ctx context.Context, prompt string, stream bool) (*http.Request, error) {
 	reqBody := anthropicRequest{
 		Model:       c.model,
 		MaxTokens:   c.maxTokens,
 		Temperature: c.temperature,
+		Stream:      stream,
 		Messages: []anthropicMessage{
 			{
 				Role:    "user",
@@ -164,47 +213,183 @@ func (c *AnthropicClient) sendRequest(ctx context.Context, prompt string) (strin
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", anthropicAPIURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("x-api-key", c.apiKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 
+	return req, nil
+}
+
+// sendRequest sends a single request to the Anthropic API
+func (c *AnthropicClient) sendRequest(ctx context.Context, prompt string) (string, Usage, error) {
+	req, err := c.newRequest(ctx, prompt, false)
+	if err != nil {
+		return "", Usage{}, err
+	}
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", &RetryableError{Err: fmt.Errorf("failed to send request: %w", err)}
+		return "", Usage{}, &RetryableError{Err: fmt.Errorf("failed to send request: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", Usage{}, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", handleHTTPError(resp.StatusCode, body)
+		return "", Usage{}, handleHTTPError(resp.StatusCode, body)
 	}
 
 	var apiResp anthropicResponse
 	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		return "", Usage{}, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
 	if apiResp.Error != nil {
-		return "", fmt.Errorf("API error: %s - %s", apiResp.Error.Type, apiResp.Error.Message)
+		return "", Usage{}, fmt.Errorf("API error: %s - %s", apiResp.Error.Type, apiResp.Error.Message)
 	}
 
 	if len(apiResp.Content) == 0 {
-		return "", fmt.Errorf("empty response content")
+		return "", Usage{}, fmt.Errorf("empty response content")
+	}
+
+	usage := Usage{
+		Provider:     "anthropic",
+		Model:        c.model,
+		InputTokens:  apiResp.Usage.InputTokens,
+		OutputTokens: apiResp.Usage.OutputTokens,
+	}
+	usage.CostUSD = EstimateCost(usage.Model, usage.InputTokens, usage.OutputTokens)
+
+	return apiResp.Content[0].Text, usage, nil
+}
+
+// CompleteStream sends a prompt to Claude API with streaming enabled, calling
+// onText with each piece of response text as it arrives. Failed requests are
+// retried only if no text has been delivered yet.
+func (c *AnthropicClient) CompleteStream(ctx context.Context, prompt string, onText func(chunk string)) (string, Usage, error) {
+	var lastErr error
+
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := calculateBackoff(attempt)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return "", Usage{}, ctx.Err()
+			}
+		}
+
+		delivered := false
+		response, usage, err := c.sendStreamRequest(ctx, prompt, func(chunk string) {
+			delivered = true
+			onText(chunk)
+		})
+		if err == nil {
+			return response, usage, nil
+		}
+
+		lastErr = err
+
+		// Don't retry once text has been delivered, on context cancellation or on non-retryable errors
+		if delivered || ctx.Err() != nil || !isRetryableError(err) {
+			return "", Usage{}, err
+		}
+	}
+
+	return "", Usage{}, fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
+}
+
+// sendStreamRequest sends a single streaming request and reads its server-sent events
+func (c *AnthropicClient) sendStreamRequest(ctx context.Context, prompt string, onText func(chunk string)) (string, Usage, error) {
+	req, err := c.newRequest(ctx, prompt, true)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", Usage{}, &RetryableError{Err: fmt.Errorf("failed to send request: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", Usage{}, fmt.Errorf("failed to read response: %w", err)
+		}
+		return "", Usage{}, handleHTTPError(resp.StatusCode, body)
+	}
+
+	usage := Usage{Provider: "anthropic", Model: c.model}
+	var text strings.Builder
+	done := false
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue // event names, comments and blank separators
+		}
+
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return "", Usage{}, fmt.Errorf("failed to unmarshal stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				usage.InputTokens = event.Message.Usage.InputTokens
+				usage.OutputTokens = event.Message.Usage.OutputTokens
+			}
+		case "content_block_delta":
+			if event.Delta != nil && event.Delta.Text != "" {
+				text.WriteString(event.Delta.Text)
+				onText(event.Delta.Text)
+			}
+		case "message_delta":
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "message_stop":
+			done = true
+		case "error":
+			if event.Error != nil {
+				err := fmt.Errorf("API error: %s - %s", event.Error.Type, event.Error.Message)
+				if event.Error.Type == "overloaded_error" {
+					return "", Usage{}, &RetryableError{Err: err}
+				}
+				return "", Usage{}, err
+			}
+			return "", Usage{}, fmt.Errorf("API error in stream")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", Usage{}, &RetryableError{Err: fmt.Errorf("failed to read stream: %w", err)}
+	}
+
+	if !done {
+		return "", Usage{}, &RetryableError{Err: fmt.Errorf("stream ended before message_stop")}
+	}
+	if text.Len() == 0 {
+		return "", Usage{}, fmt.Errorf("empty response content")
 	}
 
-	return apiResp.Content[0].Text, nil
+	usage.CostUSD = EstimateCost(usage.Model, usage.InputTokens, usage.OutputTokens)
+	return text.String(), usage, nil
 }
 
 // handleHTTPError converts HTTP status codes to appropriate errors
@@ -7,3 +7,19 @@ type LLMClient interface {
 	// Complete sends a prompt to the LLM and returns the response text
 	Complete(ctx context.Context, prompt string) (string, error)
 }
+
+// ConcurrencyLimiter is implemented by LLM clients that limit how many requests
+// may be in flight at once. Callers dispatching requests in parallel should not
+// exceed MaxConcurrency.
+type ConcurrencyLimiter interface {
+	MaxConcurrency() int
+}
+
+// StreamingClient is implemented by LLM clients that can stream responses
+type StreamingClient interface {
+	UsageClient
+
+	// CompleteStream sends a prompt and calls onText with each piece of response
+	// text as it arrives. It returns the full text and usage once complete.
+	CompleteStream(ctx context.Context, prompt string, onText func(chunk string)) (string, Usage, error)
+}
@@ -1,12 +1,14 @@
 package providers
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -24,21 +26,26 @@ const (
 	defaultOllamaModel      = ModelLlama3
 	defaultOllamaTimeout    = 60 * time.Second
 	defaultOllamaMaxRetries = 3
+
+	// A local model serves one request at a time by default
+	defaultOllamaConcurrency = 1
 )
 
 // OllamaClient implements LLMClient for local Ollama models
 type OllamaClient struct {
-	baseURL    string
-	model      string
-	timeout    time.Duration
-	httpClient *http.Client
+	baseURL        string
+	model          string
+	timeout        time.Duration
+	maxConcurrency int
+	httpClient     *http.Client
 }
 
 // OllamaConfig holds configuration for the Ollama client
 type OllamaConfig struct {
-	BaseURL string
-	Model   string
-	Timeout time.Duration
+	BaseURL        string
+	Model          string
+	Timeout        time.Duration
+	MaxConcurrency int // parallel requests allowed (0 = default)
 }
 
 // ollamaRequest represents the API request format
@@ -55,6 +62,10 @@ type ollamaResponse struct {
 	Response  string `json:"response"`
 	Done      bool   `json:"done"`
 	Error     string `json:"error,omitempty"`
+
+	// Token counts, reported once generation is done
+	PromptEvalCount int `json:"prompt_eval_count,omitempty"`
+	EvalCount       int `json:"eval_count,omitempty"`
 }
 
 // NewOllamaClient creates a new Ollama API client
@@ -71,10 +82,15 @@ func NewOllamaClient(config OllamaConfig) (*OllamaClient, error) {
 		config.Timeout = defaultOllamaTimeout
 	}
 
+	if config.MaxConcurrency <= 0 {
+		config.MaxConcurrency = defaultOllamaConcurrency
+	}
+
 	return &OllamaClient{
-		baseURL: config.BaseURL,
-		model:   config.Model,
-		timeout: config.Timeout,
+		baseURL:        config.BaseURL,
+		model:          config.Model,
+		timeout:        config.Timeout,
+		maxConcurrency: config.MaxConcurrency,
 		httpClient: &http.Client{
 			Timeout: config.Timeout,
 		},
@@ -83,6 +99,13 @@ func NewOllamaClient(config OllamaConfig) (*OllamaClient, error) {
 
 // Complete sends a prompt to Ollama and returns the response text
 func (c *OllamaClient) Complete(ctx context.Context, prompt string) (string, error) {
+	response, _, err := c.CompleteWithUsage(ctx, prompt)
+	return response, err
+}
+
+// CompleteWithUsage sends a prompt to Ollama and returns the response text along
+// with the token usage of the successful request. Local models have no cost.
+func (c *OllamaClient) CompleteWithUsage(ctx context.Context, prompt string) (string, Usage, error) {
 	var lastErr error
 
 	for attempt := 0; attempt < defaultOllamaMaxRetries; attempt++ {
@@ -91,75 +114,202 @@ func (c *OllamaClient) Complete(ctx context.Context, prompt string) (string, err
 			select {
 			case <-time.After(backoff):
 			case <-ctx.Done():
-				return "", ctx.Err()
+				return "", Usage{}, ctx.Err()
 			}
 		}
 
-		response, err := c.sendRequest(ctx, prompt)
+		response, usage, err := c.sendRequest(ctx, prompt)
 		if err == nil {
-			return response, nil
+			return response, usage, nil
 		}
 
 		lastErr = err
 
 		// Don't retry on context cancellation or non-retryable errors
 		if ctx.Err() != nil || !isRetryableError(err) {
-			return "", err
+			return "", Usage{}, err
 		}
 	}
 
-	return "", fmt.Errorf("failed after %d retries: %w", defaultOllamaMaxRetries, lastErr)
+	return "", Usage{}, fmt.Errorf("failed after %d retries: %w", defaultOllamaMaxRetries, lastErr)
 }
 
-// sendRequest sends a single request to the Ollama API
-func (c *OllamaClient) sendRequest(ctx context.Context, prompt string) (string, error) {
+// MaxConcurrency returns how many requests may be sent in parallel
+func (c *OllamaClient) MaxConcurrency() int {
+	return c.maxConcurrency
+}
+
+// newRequest builds a generate API request for a prompt
+func (c *OllamaClient) newRequest(ctx context.Context, prompt string, stream bool) (*http.Request, error) {
 	reqBody := ollamaRequest{
 		Model:  c.model,
 		Prompt: prompt,
-		Stream: false,
+		Stream: stream,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
+	return req, nil
+}
+
+// sendRequest sends a single request to the Ollama API
+func (c *OllamaClient) sendRequest(ctx context.Context, prompt string) (string, Usage, error) {
+	req, err := c.newRequest(ctx, prompt, false)
+	if err != nil {
+		return "", Usage{}, err
+	}
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", &RetryableError{Err: fmt.Errorf("failed to connect to Ollama: %w", err)}
+		return "", Usage{}, &RetryableError{Err: fmt.Errorf("failed to connect to Ollama: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", Usage{}, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", handleOllamaHTTPError(resp.StatusCode, body)
+		return "", Usage{}, handleOllamaHTTPError(resp.StatusCode, body)
 	}
 
 	var apiResp ollamaResponse
 	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		return "", Usage{}, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
 	if apiResp.Error != "" {
-		return "", fmt.Errorf("Ollama API error: %s", apiResp.Error)
+		return "", Usage{}, fmt.Errorf("Ollama API error: %s", apiResp.Error)
 	}
 
 	if apiResp.Response == "" {
-		return "", fmt.Errorf("empty response from Ollama")
+		return "", Usage{}, fmt.Errorf("empty response from Ollama")
+	}
+
+	usage := Usage{
+		Provider:     "ollama",
+		Model:        c.model,
+		InputTokens:  apiResp.PromptEvalCount,
+		OutputTokens: apiResp.EvalCount,
+	}
+
+	return apiResp.Response, usage, nil
+}
+
+// CompleteStream sends a prompt to Ollama with streaming enabled, calling onText
+// with each piece of response text as it arrives. Failed requests are retried
+// only if no text has been delivered yet.
+func (c *OllamaClient) CompleteStream(ctx context.Context, prompt string, onText func(chunk string)) (string, Usage, error) {
+	var lastErr error
+
+	for attempt := 0; attempt < defaultOllamaMaxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := calculateBackoff(attempt)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return "", Usage{}, ctx.Err()
+			}
+		}
+
+		delivered := false
+		response, usage, err := c.sendStreamRequest(ctx, prompt, func(chunk string) {
+			delivered = true
+			onText(chunk)
+		})
+		if err == nil {
+			return response, usage, nil
+		}
+
+		lastErr = err
+
+		// Don't retry once text has been delivered, on context cancellation or on non-retryable errors
+		if delivered || ctx.Err() != nil || !isRetryableError(err) {
+			return "", Usage{}, err
+		}
+	}
+
+	return "", Usage{}, fmt.Errorf("failed after %d retries: %w", defaultOllamaMaxRetries, lastErr)
+}
+
+// sendStreamRequest sends a single streaming request; Ollama streams one JSON
+// object per line, the last of which has done set and carries the token counts
+func (c *OllamaClient) sendStreamRequest(ctx context.Context, prompt string, onText func(chunk string)) (string, Usage, error) {
+	req, err := c.newRequest(ctx, prompt, true)
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", Usage{}, &RetryableError{Err: fmt.Errorf("failed to connect to Ollama: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", Usage{}, fmt.Errorf("failed to read response: %w", err)
+		}
+		return "", Usage{}, handleOllamaHTTPError(resp.StatusCode, body)
+	}
+
+	usage := Usage{Provider: "ollama", Model: c.model}
+	var text strings.Builder
+	done := false
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk ollamaResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return "", Usage{}, fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+
+		if chunk.Error != "" {
+			return "", Usage{}, fmt.Errorf("Ollama API error: %s", chunk.Error)
+		}
+
+		if chunk.Response != "" {
+			text.WriteString(chunk.Response)
+			onText(chunk.Response)
+		}
+
+		if chunk.Done {
+			usage.InputTokens = chunk.PromptEvalCount
+			usage.OutputTokens = chunk.EvalCount
+			done = true
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", Usage{}, &RetryableError{Err: fmt.Errorf("failed to read stream: %w", err)}
+	}
+
+	if !done {
+		return "", Usage{}, &RetryableError{Err: fmt.Errorf("stream ended before completion")}
+	}
+	if text.Len() == 0 {
+		return "", Usage{}, fmt.Errorf("empty response from Ollama")
 	}
 
-	return apiResp.Response, nil
+	return text.String(), usage, nil
 }
 
 // handleOllamaHTTPError converts HTTP status codes to appropriate errors
@@ -0,0 +1,126 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicClient_CompleteStream(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"usage":{"input_tokens":1200,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"{\"clues\": "}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"{\"CAT\": \"Purrer\"}}"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":300}}`,
+		`{"type":"message_stop"}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(readBody(r), `"stream":true`) {
+			t.Error("Expected stream to be requested")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", event)
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewAnthropicClient(AnthropicConfig{APIKey: "test-key", Model: ModelHaiku})
+	client.apiURL = server.URL
+
+	var chunks []string
+	text, usage, err := client.CompleteStream(context.Background(), "prompt", func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream() unexpected error = %v", err)
+	}
+
+	if text != `{"clues": {"CAT": "Purrer"}}` {
+		t.Errorf("CompleteStream() text = %q", text)
+	}
+	if len(chunks) != 2 {
+		t.Errorf("Expected 2 chunks, got %d", len(chunks))
+	}
+	if usage.InputTokens != 1200 || usage.OutputTokens != 300 {
+		t.Errorf("Usage tokens = %d/%d, want 1200/300", usage.InputTokens, usage.OutputTokens)
+	}
+	if usage.CostUSD != EstimateCost(ModelHaiku, 1200, 300) {
+		t.Errorf("Usage cost = %v", usage.CostUSD)
+	}
+}
+
+func TestAnthropicClient_CompleteStream_Incomplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"partial\"}}\n\n")
+	}))
+	defer server.Close()
+
+	client, _ := NewAnthropicClient(AnthropicConfig{APIKey: "test-key"})
+	client.apiURL = server.URL
+
+	calls := 0
+	_, _, err := client.CompleteStream(context.Background(), "prompt", func(chunk string) { calls++ })
+	if err == nil {
+		t.Fatal("Expected error for stream without message_stop")
+	}
+	if calls != 1 {
+		t.Errorf("Request with delivered text should not be retried, got %d chunks", calls)
+	}
+}
+
+func TestOllamaClient_CompleteStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(readBody(r), `"stream":true`) {
+			t.Error("Expected stream to be requested")
+		}
+		fmt.Fprintln(w, `{"model":"llama3","response":"{\"clues\": ","done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3","response":"{\"CAT\": \"Purrer\"}}","done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3","response":"","done":true,"prompt_eval_count":80,"eval_count":20}`)
+	}))
+	defer server.Close()
+
+	client, _ := NewOllamaClient(OllamaConfig{BaseURL: server.URL})
+
+	var chunks []string
+	text, usage, err := client.CompleteStream(context.Background(), "prompt", func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream() unexpected error = %v", err)
+	}
+	if text != `{"clues": {"CAT": "Purrer"}}` || len(chunks) != 2 {
+		t.Errorf("CompleteStream() text = %q in %d chunks", text, len(chunks))
+	}
+	if usage.InputTokens != 80 || usage.OutputTokens != 20 {
+		t.Errorf("Usage tokens = %d/%d, want 80/20", usage.InputTokens, usage.OutputTokens)
+	}
+}
+
+func TestClients_MaxConcurrency(t *testing.T) {
+	anthropic, _ := NewAnthropicClient(AnthropicConfig{APIKey: "test-key"})
+	if anthropic.MaxConcurrency() != defaultAnthropicConcurrency {
+		t.Errorf("Anthropic default concurrency = %d", anthropic.MaxConcurrency())
+	}
+	ollama, _ := NewOllamaClient(OllamaConfig{MaxConcurrency: 3})
+	if ollama.MaxConcurrency() != 3 {
+		t.Errorf("Ollama concurrency = %d, want 3", ollama.MaxConcurrency())
+	}
+
+	var _ StreamingClient = anthropic
+	var _ StreamingClient = ollama
+	var _ ConcurrencyLimiter = anthropic
+	var _ ConcurrencyLimiter = ollama
+}
+
+func readBody(r *http.Request) string {
+	body, _ := io.ReadAll(r.Body)
+	return string(body)
+}
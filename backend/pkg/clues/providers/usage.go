@@ -0,0 +1,56 @@
+package providers
+
+import "context"
+
+// Usage records the tokens consumed by a single completion and what they cost
+type Usage struct {
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// TotalTokens returns input plus output tokens
+func (u Usage) TotalTokens() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// UsageClient is implemented by LLM clients that report token usage.
+// Clients that don't implement it are treated as free and untracked.
+type UsageClient interface {
+	LLMClient
+
+	// CompleteWithUsage behaves like Complete and also returns the usage
+	// reported by the provider for the successful request
+	CompleteWithUsage(ctx context.Context, prompt string) (string, Usage, error)
+}
+
+// ModelPricing is a model's price in USD per million tokens
+type ModelPricing struct {
+	InputPerMTok  float64
+	OutputPerMTok float64
+}
+
+// modelPricing holds list prices for hosted models. Local models (Ollama) are
+// not listed and cost nothing.
+var modelPricing = map[string]ModelPricing{
+	ModelHaiku:  {InputPerMTok: 0.80, OutputPerMTok: 4.00},
+	ModelSonnet: {InputPerMTok: 3.00, OutputPerMTok: 15.00},
+}
+
+// PricingFor returns the pricing for a model and whether it is known
+func PricingFor(model string) (ModelPricing, bool) {
+	pricing, ok := modelPricing[model]
+	return pricing, ok
+}
+
+// EstimateCost returns the USD cost of the given token counts for a model.
+// Unknown models cost nothing.
+func EstimateCost(model string, inputTokens, outputTokens int) float64 {
+	pricing, ok := modelPricing[model]
+	if !ok {
+		return 0
+	}
+	return (float64(inputTokens)*pricing.InputPerMTok + float64(outputTokens)*pricing.OutputPerMTok) / 1_000_000
+}
@@ -0,0 +1,103 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEstimateCost(t *testing.T) {
+	tests := []struct {
+		name         string
+		model        string
+		inputTokens  int
+		outputTokens int
+		want         float64
+	}{
+		{"haiku", ModelHaiku, 1_000_000, 1_000_000, 4.80},
+		{"sonnet", ModelSonnet, 2000, 500, 0.0135},
+		{"local model is free", ModelLlama3, 5000, 5000, 0},
+		{"no tokens", ModelSonnet, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EstimateCost(tt.model, tt.inputTokens, tt.outputTokens)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("EstimateCost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnthropicClient_CompleteWithUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := anthropicResponse{
+			ID:      "msg_123",
+			Type:    "message",
+			Role:    "assistant",
+			Content: []anthropicContent{{Type: "text", Text: "response"}},
+			Model:   ModelSonnet,
+			Usage:   anthropicUsage{InputTokens: 2000, OutputTokens: 500},
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client, _ := NewAnthropicClient(AnthropicConfig{APIKey: "test-key", Model: ModelSonnet})
+	client.apiURL = server.URL
+
+	text, usage, err := client.CompleteWithUsage(context.Background(), "test prompt")
+	if err != nil {
+		t.Fatalf("CompleteWithUsage() unexpected error = %v", err)
+	}
+	if text != "response" {
+		t.Errorf("CompleteWithUsage() text = %q, want %q", text, "response")
+	}
+	if usage.Provider != "anthropic" || usage.Model != ModelSonnet {
+		t.Errorf("Usage provider/model = %s/%s", usage.Provider, usage.Model)
+	}
+	if usage.InputTokens != 2000 || usage.OutputTokens != 500 {
+		t.Errorf("Usage tokens = %d/%d, want 2000/500", usage.InputTokens, usage.OutputTokens)
+	}
+	if math.Abs(usage.CostUSD-0.0135) > 1e-9 {
+		t.Errorf("Usage cost = %v, want 0.0135", usage.CostUSD)
+	}
+}
+
+func TestOllamaClient_CompleteWithUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := ollamaResponse{
+			Model:           ModelLlama3,
+			Response:        "response",
+			Done:            true,
+			PromptEvalCount: 120,
+			EvalCount:       45,
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client, _ := NewOllamaClient(OllamaConfig{BaseURL: server.URL})
+
+	_, usage, err := client.CompleteWithUsage(context.Background(), "test prompt")
+	if err != nil {
+		t.Fatalf("CompleteWithUsage() unexpected error = %v", err)
+	}
+	if usage.Provider != "ollama" || usage.InputTokens != 120 || usage.OutputTokens != 45 {
+		t.Errorf("Usage = %+v, want ollama with 120/45 tokens", usage)
+	}
+	if usage.CostUSD != 0 {
+		t.Errorf("Local model should be free, got $%v", usage.CostUSD)
+	}
+}
+
+func TestClients_ImplementUsageClient(t *testing.T) {
+	var _ UsageClient = (*AnthropicClient)(nil)
+	var _ UsageClient = (*OllamaClient)(nil)
+}
@@ -20,6 +20,23 @@ CREATE TABLE IF NOT EXISTS clue_cache (
 -- Index for fast lookups by word and difficulty
 CREATE INDEX IF NOT EXISTS idx_clue_cache_word_difficulty
 ON clue_cache(word, difficulty);
+
+-- llm_usage table recording tokens and cost of every LLM call
+CREATE TABLE IF NOT EXISTS llm_usage (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	batch_id TEXT NOT NULL DEFAULT '',
+	puzzle_id TEXT NOT NULL DEFAULT '',
+	provider TEXT NOT NULL,
+	model TEXT NOT NULL,
+	input_tokens INTEGER NOT NULL DEFAULT 0,
+	output_tokens INTEGER NOT NULL DEFAULT 0,
+	cost_usd REAL NOT NULL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for per-batch cost summaries
+CREATE INDEX IF NOT EXISTS idx_llm_usage_batch
+ON llm_usage(batch_id);
 `
 
 // InitDB initializes the database schema
@@ -0,0 +1,56 @@
+package clues
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/crossplay/backend/pkg/clues/providers"
+)
+
+// CachedClue is a single clue held in a clue store
+type CachedClue struct {
+	Word       string    `json:"word"`
+	Clue       string    `json:"clue"`
+	Difficulty string    `json:"difficulty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ClueStore persists generated clues and LLM usage. ClueCache keeps them in a
+// local SQLite file; PostgresClueCache keeps them in the server's database.
+type ClueStore interface {
+	// GetClue returns a random cached clue for the word and difficulty
+	GetClue(word, difficulty string) (string, bool)
+
+	// SaveClue adds a clue to the cache
+	SaveClue(word, clue, difficulty string) error
+
+	// SaveUsage records the token usage of one LLM call
+	SaveUsage(batchID, puzzleID string, usage providers.Usage) error
+
+	// ListClues returns every cached clue
+	ListClues() ([]CachedClue, error)
+
+	// ImportClues adds clues not already present and returns how many were added
+	ImportClues(clues []CachedClue) (int, error)
+}
+
+var (
+	_ ClueStore = (*ClueCache)(nil)
+	_ ClueStore = (*PostgresClueCache)(nil)
+)
+
+// SyncClues copies every clue in src that dst doesn't have yet into dst and
+// returns how many were added
+func SyncClues(src, dst ClueStore) (int, error) {
+	clues, err := src.ListClues()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read source clues: %w", err)
+	}
+
+	added, err := dst.ImportClues(clues)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write destination clues: %w", err)
+	}
+
+	return added, nil
+}
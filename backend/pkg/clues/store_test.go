@@ -0,0 +1,72 @@
+package clues
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClueCache_ListAndImport(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cache, _ := NewClueCache(db)
+	cache.SaveClue("CAT", "Purring pet", "easy")
+	cache.SaveClue("DOG", "Loyal companion", "medium")
+
+	listed, err := cache.ListClues()
+	if err != nil {
+		t.Fatalf("ListClues failed: %v", err)
+	}
+	if len(listed) != 2 || listed[0].Word != "CAT" || listed[0].CreatedAt.IsZero() {
+		t.Fatalf("Unexpected clues: %+v", listed)
+	}
+
+	created := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	added, err := cache.ImportClues([]CachedClue{
+		{Word: "CAT", Clue: "Purring pet", Difficulty: "easy"},                         // already cached
+		{Word: "CAT", Clue: "Purring pet", Difficulty: "hard"},                         // new difficulty
+		{Word: "EMU", Clue: "Flightless bird", Difficulty: "easy", CreatedAt: created}, // new clue
+	})
+	if err != nil {
+		t.Fatalf("ImportClues failed: %v", err)
+	}
+	if added != 2 {
+		t.Errorf("Expected 2 clues added, got %d", added)
+	}
+
+	var emuCreated time.Time
+	db.QueryRow("SELECT created_at FROM clue_cache WHERE word = 'EMU'").Scan(&emuCreated)
+	if !emuCreated.Equal(created) {
+		t.Errorf("Imported clue should keep its creation time, got %v", emuCreated)
+	}
+}
+
+func TestSyncClues(t *testing.T) {
+	srcDB := setupTestDB(t)
+	defer srcDB.Close()
+	dstDB := setupTestDB(t)
+	defer dstDB.Close()
+
+	src, _ := NewClueCache(srcDB)
+	dst, _ := NewClueCache(dstDB)
+
+	src.SaveClue("CAT", "Purring pet", "easy")
+	src.SaveClue("DOG", "Loyal companion", "medium")
+	dst.SaveClue("DOG", "Loyal companion", "medium")
+
+	added, err := SyncClues(src, dst)
+	if err != nil {
+		t.Fatalf("SyncClues failed: %v", err)
+	}
+	if added != 1 {
+		t.Errorf("Expected 1 clue synced, got %d", added)
+	}
+	if clue, _ := dst.GetClue("CAT", "easy"); clue != "Purring pet" {
+		t.Errorf("Synced clue missing, got %q", clue)
+	}
+
+	// Syncing again is a no-op
+	if added, _ := SyncClues(src, dst); added != 0 {
+		t.Errorf("Repeated sync added %d clues", added)
+	}
+}
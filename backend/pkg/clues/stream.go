@@ -0,0 +1,64 @@
+package clues
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// streamPairPattern matches a complete "KEY": "value" pair, allowing escaped
+// characters in the value
+var streamPairPattern = regexp.MustCompile(`"([^"\\]+)"\s*:\s*("(?:[^"\\]|\\.)*")`)
+
+// clueStreamParser extracts clues from a response that is still arriving, so each
+// clue can be linted and cached as soon as it is complete. The final response is
+// still parsed with ParseClueResponse; the stream parser only surfaces clues early.
+type clueStreamParser struct {
+	words   map[string]bool // requested words; other keys are ignored
+	emitted map[string]bool
+	buf     strings.Builder
+	offset  int // position in buf after the last complete pair
+}
+
+// newClueStreamParser creates a parser that looks for clues for words
+func newClueStreamParser(words []string) *clueStreamParser {
+	p := &clueStreamParser{
+		words:   make(map[string]bool, len(words)),
+		emitted: make(map[string]bool, len(words)),
+	}
+	for _, word := range words {
+		p.words[word] = true
+	}
+	return p
+}
+
+// Feed appends a chunk of response text and returns any clues completed by it
+func (p *clueStreamParser) Feed(chunk string) map[string]string {
+	p.buf.WriteString(chunk)
+	text := p.buf.String()
+
+	var found map[string]string
+	for {
+		loc := streamPairPattern.FindStringSubmatchIndex(text[p.offset:])
+		if loc == nil {
+			return found
+		}
+		word := text[p.offset+loc[2] : p.offset+loc[3]]
+		rawClue := text[p.offset+loc[4] : p.offset+loc[5]]
+		p.offset += loc[1]
+
+		if !p.words[word] || p.emitted[word] {
+			continue
+		}
+		var clue string
+		if err := json.Unmarshal([]byte(rawClue), &clue); err != nil || strings.TrimSpace(clue) == "" {
+			continue
+		}
+
+		p.emitted[word] = true
+		if found == nil {
+			found = make(map[string]string)
+		}
+		found[word] = clue
+	}
+}
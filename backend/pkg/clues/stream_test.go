@@ -0,0 +1,258 @@
+package clues
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crossplay/backend/pkg/clues/providers"
+	"github.com/crossplay/backend/pkg/grid"
+)
+
+func TestClueStreamParser_Feed(t *testing.T) {
+	parser := newClueStreamParser([]string{"CAT", "DOG", "EMU"})
+	response := `{"clues": {"CAT": "Purring \"pet\"", "DOG": "Loyal companion", "OWL": "Not requested", "EMU": "Flightless bird"}}`
+
+	got := make(map[string]string)
+	var order []string
+	for i := 0; i < len(response); i += 7 {
+		end := i + 7
+		if end > len(response) {
+			end = len(response)
+		}
+		for word, clue := range parser.Feed(response[i:end]) {
+			if _, dup := got[word]; dup {
+				t.Errorf("Clue for %s emitted twice", word)
+			}
+			got[word] = clue
+			order = append(order, word)
+		}
+	}
+
+	want := map[string]string{"CAT": `Purring "pet"`, "DOG": "Loyal companion", "EMU": "Flightless bird"}
+	for word, clue := range want {
+		if got[word] != clue {
+			t.Errorf("Clue for %s = %q, want %q", word, got[word], clue)
+		}
+	}
+	if _, ok := got["OWL"]; ok {
+		t.Error("Unrequested word should be ignored")
+	}
+	if strings.Join(order, ",") != "CAT,DOG,EMU" {
+		t.Errorf("Clues should be emitted as they complete, got order %v", order)
+	}
+}
+
+// batchLLMClient answers every prompt with a clue for each word in it, tracks
+// how many calls are in flight, and fails prompts containing failWord
+type batchLLMClient struct {
+	limit    int
+	failWord string
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	calls       int
+}
+
+func (b *batchLLMClient) MaxConcurrency() int { return b.limit }
+
+func (b *batchLLMClient) Complete(ctx context.Context, prompt string) (string, error) {
+	b.mu.Lock()
+	b.calls++
+	b.inFlight++
+	if b.inFlight > b.maxInFlight {
+		b.maxInFlight = b.inFlight
+	}
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		b.inFlight--
+		b.mu.Unlock()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	words := promptWords(prompt)
+	for _, word := range words {
+		if word == b.failWord {
+			return "", fmt.Errorf("provider unavailable")
+		}
+	}
+
+	pairs := make([]string, len(words))
+	for i, word := range words {
+		pairs[i] = fmt.Sprintf("%q: %q", word, "Clue number "+word[len(word)-2:])
+	}
+	return `{"clues": {` + strings.Join(pairs, ", ") + `}}`, nil
+}
+
+// promptWords extracts the word list from a clue prompt
+func promptWords(prompt string) []string {
+	for _, line := range strings.Split(prompt, "\n") {
+		if list, ok := strings.CutPrefix(line, "Words: "); ok {
+			return strings.Split(list, ", ")
+		}
+	}
+	return nil
+}
+
+// testWords returns n distinct answers that share no stem with their clues
+func testWords(n int) []string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = fmt.Sprintf("W%c%c%02d", 'A'+i/26, 'A'+i%26, i)
+	}
+	return words
+}
+
+func TestGenerateClues_ConcurrentBatches(t *testing.T) {
+	client := &batchLLMClient{limit: 2}
+	gen := NewGenerator(nil, client, DifficultyMedium)
+
+	// Entries are only used for their words here, so letters can be anything
+	var entries []*grid.Entry
+	for i, word := range testWords(MaxWordsPerBatch*4 + 1) {
+		entries = append(entries, createTestEntry(i+1, grid.ACROSS, word))
+	}
+
+	result, err := gen.GenerateClues(context.Background(), entries)
+	if err != nil {
+		t.Fatalf("GenerateClues failed: %v", err)
+	}
+
+	if len(result) != len(entries) {
+		t.Errorf("Expected %d clues, got %d", len(entries), len(result))
+	}
+	if client.calls != 5 {
+		t.Errorf("Expected 5 batches, got %d", client.calls)
+	}
+	if client.maxInFlight > 2 {
+		t.Errorf("Provider limit of 2 exceeded: %d requests in flight", client.maxInFlight)
+	}
+	if client.maxInFlight < 2 {
+		t.Errorf("Expected batches to run concurrently, max in flight was %d", client.maxInFlight)
+	}
+
+	gen.SetMaxConcurrency(1)
+	client.maxInFlight = 0
+	if _, err := gen.GenerateClues(context.Background(), entries); err != nil {
+		t.Fatalf("GenerateClues failed: %v", err)
+	}
+	if client.maxInFlight != 1 {
+		t.Errorf("SetMaxConcurrency(1) should serialize batches, max in flight was %d", client.maxInFlight)
+	}
+}
+
+func TestGenerateClues_CachesCompletedBatchesOnFailure(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	db.SetMaxOpenConns(1) // each in-memory connection is a separate database
+
+	words := testWords(MaxWordsPerBatch + 5)
+	client := &batchLLMClient{limit: 1, failWord: words[len(words)-1]}
+	cache, _ := NewClueCache(db)
+	gen := NewGenerator(cache, client, DifficultyMedium)
+
+	var entries []*grid.Entry
+	for i, word := range words {
+		entries = append(entries, createTestEntry(i+1, grid.ACROSS, word))
+	}
+
+	if _, err := gen.GenerateClues(context.Background(), entries); err == nil {
+		t.Fatal("Expected an error from the failing batch")
+	}
+
+	if _, found := cache.GetClue(words[0], "medium"); !found {
+		t.Error("Clues from the completed batch should have been cached")
+	}
+	if _, found := cache.GetClue(words[len(words)-1], "medium"); found {
+		t.Error("Word from the failed batch should not be cached")
+	}
+}
+
+// streamLLMClient streams a canned response in small chunks and can fail after
+// the text has been sent
+type streamLLMClient struct {
+	response  string
+	failAtEnd bool
+	chunks    int
+}
+
+func (s *streamLLMClient) Complete(ctx context.Context, prompt string) (string, error) {
+	return s.response, nil
+}
+
+func (s *streamLLMClient) CompleteWithUsage(ctx context.Context, prompt string) (string, providers.Usage, error) {
+	return s.response, providers.Usage{}, nil
+}
+
+func (s *streamLLMClient) CompleteStream(ctx context.Context, prompt string, onText func(chunk string)) (string, providers.Usage, error) {
+	for i := 0; i < len(s.response); i += 5 {
+		end := i + 5
+		if end > len(s.response) {
+			end = len(s.response)
+		}
+		s.chunks++
+		onText(s.response[i:end])
+	}
+	if s.failAtEnd {
+		return "", providers.Usage{}, fmt.Errorf("connection reset")
+	}
+	return s.response, providers.Usage{Provider: "test", InputTokens: 10, OutputTokens: 5}, nil
+}
+
+func TestGenerateClues_StreamingCachesCluesAsTheyArrive(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cache, _ := NewClueCache(db)
+	// The response is cut off before DOG's clue is complete
+	client := &streamLLMClient{
+		response:  `{"clues": {"CAT": "Purring companion", "DOG": "Loyal`,
+		failAtEnd: true,
+	}
+	gen := NewGenerator(cache, client, DifficultyEasy)
+
+	_, err := gen.GenerateClues(context.Background(), []*grid.Entry{
+		createTestEntry(1, grid.ACROSS, "CAT"),
+		createTestEntry(2, grid.DOWN, "DOG"),
+	})
+	if err == nil {
+		t.Fatal("Expected an error from the interrupted stream")
+	}
+	if client.chunks < 2 {
+		t.Fatalf("Expected the response to be streamed, got %d chunks", client.chunks)
+	}
+
+	if clue, _ := cache.GetClue("CAT", "easy"); clue != "Purring companion" {
+		t.Errorf("Streamed clue for CAT should be cached, got %q", clue)
+	}
+	if _, found := cache.GetClue("DOG", "easy"); found {
+		t.Error("Incomplete clue for DOG should not be cached")
+	}
+}
+
+func TestGenerateClues_StreamingRecordsUsage(t *testing.T) {
+	client := &streamLLMClient{response: `{"clues": {"CAT": "Purring companion"}}`}
+	tracker := NewUsageTracker(nil, "batch-1", 0)
+	gen := NewGenerator(nil, client, DifficultyEasy)
+	gen.SetUsageTracker(tracker)
+
+	result, err := gen.GenerateClues(context.Background(), []*grid.Entry{
+		createTestEntry(1, grid.ACROSS, "CAT"),
+	})
+	if err != nil {
+		t.Fatalf("GenerateClues failed: %v", err)
+	}
+	if result["1-across"] != "Purring companion" {
+		t.Errorf("Unexpected clue %q", result["1-across"])
+	}
+	if total := tracker.Total(); total.Calls != 1 || total.InputTokens != 10 {
+		t.Errorf("Streamed usage not recorded: %+v", total)
+	}
+}
@@ -0,0 +1,168 @@
+package clues
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/crossplay/backend/pkg/clues/providers"
+)
+
+// ErrBudgetExceeded is returned instead of making an LLM call once a tracker's
+// budget has been spent
+var ErrBudgetExceeded = errors.New("LLM budget exceeded")
+
+// UsageTotals aggregates token usage and cost over a number of LLM calls
+type UsageTotals struct {
+	Calls        int     `json:"calls"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+func (t *UsageTotals) add(usage providers.Usage) {
+	t.Calls++
+	t.InputTokens += usage.InputTokens
+	t.OutputTokens += usage.OutputTokens
+	t.CostUSD += usage.CostUSD
+}
+
+// UsageTracker accumulates LLM usage for one batch run, broken down per
+// provider and per puzzle. Each call is persisted to the clue cache database
+// when a cache is set. A positive budget (USD) stops further LLM calls once it
+// has been reached; because the cost of a call is only known afterwards, the
+// final call may take spending slightly past the budget.
+// A nil tracker records nothing and has no budget.
+type UsageTracker struct {
+	mu        sync.Mutex
+	cache     ClueStore
+	batchID   string
+	budgetUSD float64
+	puzzleID  string
+	total     UsageTotals
+	providers map[string]*UsageTotals // keyed by "provider/model"
+	puzzles   map[string]*UsageTotals
+}
+
+// NewUsageTracker creates a tracker for a batch. cache may be nil to keep usage
+// in memory only; budgetUSD <= 0 means unlimited.
+func NewUsageTracker(cache ClueStore, batchID string, budgetUSD float64) *UsageTracker {
+	return &UsageTracker{
+		cache:     cache,
+		batchID:   batchID,
+		budgetUSD: budgetUSD,
+		providers: make(map[string]*UsageTotals),
+		puzzles:   make(map[string]*UsageTotals),
+	}
+}
+
+// BatchID returns the batch identifier usage is recorded under
+func (t *UsageTracker) BatchID() string {
+	if t == nil {
+		return ""
+	}
+	return t.batchID
+}
+
+// Budget returns the budget in USD (0 = unlimited)
+func (t *UsageTracker) Budget() float64 {
+	if t == nil {
+		return 0
+	}
+	return t.budgetUSD
+}
+
+// SetPuzzle attributes subsequent usage to the given puzzle
+func (t *UsageTracker) SetPuzzle(puzzleID string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.puzzleID = puzzleID
+}
+
+// Record adds a call's usage to the totals and persists it. The usage is
+// always counted; an error only means it could not be saved.
+func (t *UsageTracker) Record(usage providers.Usage) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	t.total.add(usage)
+	providerKey := usage.Provider + "/" + usage.Model
+	if t.providers[providerKey] == nil {
+		t.providers[providerKey] = &UsageTotals{}
+	}
+	t.providers[providerKey].add(usage)
+	puzzleID := t.puzzleID
+	if t.puzzles[puzzleID] == nil {
+		t.puzzles[puzzleID] = &UsageTotals{}
+	}
+	t.puzzles[puzzleID].add(usage)
+	t.mu.Unlock()
+
+	if t.cache == nil {
+		return nil
+	}
+	return t.cache.SaveUsage(t.batchID, puzzleID, usage)
+}
+
+// Exceeded reports whether spending has reached the budget
+func (t *UsageTracker) Exceeded() bool {
+	if t == nil || t.budgetUSD <= 0 {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total.CostUSD >= t.budgetUSD
+}
+
+// CheckBudget returns ErrBudgetExceeded if no further LLM calls should be made
+func (t *UsageTracker) CheckBudget() error {
+	if t.Exceeded() {
+		return fmt.Errorf("%w: spent $%.4f of $%.4f", ErrBudgetExceeded, t.Total().CostUSD, t.budgetUSD)
+	}
+	return nil
+}
+
+// Total returns the usage accumulated so far
+func (t *UsageTracker) Total() UsageTotals {
+	if t == nil {
+		return UsageTotals{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total
+}
+
+// ByProvider returns usage keyed by "provider/model"
+func (t *UsageTracker) ByProvider() map[string]UsageTotals {
+	if t == nil {
+		return map[string]UsageTotals{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return copyTotals(t.providers)
+}
+
+// ByPuzzle returns usage keyed by puzzle ID. Usage recorded before any call to
+// SetPuzzle is keyed by the empty string.
+func (t *UsageTracker) ByPuzzle() map[string]UsageTotals {
+	if t == nil {
+		return map[string]UsageTotals{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return copyTotals(t.puzzles)
+}
+
+// copyTotals snapshots a totals map so callers can't race with Record
+func copyTotals(m map[string]*UsageTotals) map[string]UsageTotals {
+	out := make(map[string]UsageTotals, len(m))
+	for key, totals := range m {
+		out[key] = *totals
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package clues
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/crossplay/backend/pkg/clues/providers"
+	"github.com/crossplay/backend/pkg/grid"
+)
+
+// usageLLMClient is a sequenceLLMClient that reports a fixed usage per call
+type usageLLMClient struct {
+	sequenceLLMClient
+	usage providers.Usage
+}
+
+func (u *usageLLMClient) CompleteWithUsage(ctx context.Context, prompt string) (string, providers.Usage, error) {
+	response, err := u.Complete(ctx, prompt)
+	return response, u.usage, err
+}
+
+func TestUsageTracker_Totals(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cache, _ := NewClueCache(db)
+	tracker := NewUsageTracker(cache, "batch-1", 0)
+
+	tracker.SetPuzzle("puzzle_001")
+	tracker.Record(providers.Usage{Provider: "anthropic", Model: "m1", InputTokens: 100, OutputTokens: 20, CostUSD: 0.5})
+	tracker.Record(providers.Usage{Provider: "ollama", Model: "m2", InputTokens: 50, OutputTokens: 10})
+	tracker.SetPuzzle("puzzle_002")
+	tracker.Record(providers.Usage{Provider: "anthropic", Model: "m1", InputTokens: 10, OutputTokens: 5, CostUSD: 0.25})
+
+	total := tracker.Total()
+	if total.Calls != 3 || total.InputTokens != 160 || total.OutputTokens != 35 || total.CostUSD != 0.75 {
+		t.Errorf("Total = %+v", total)
+	}
+	if got := tracker.ByProvider()["anthropic/m1"]; got.Calls != 2 || got.CostUSD != 0.75 {
+		t.Errorf("ByProvider[anthropic/m1] = %+v", got)
+	}
+	if got := tracker.ByPuzzle()["puzzle_001"]; got.Calls != 2 || got.InputTokens != 150 {
+		t.Errorf("ByPuzzle[puzzle_001] = %+v", got)
+	}
+
+	var rows int
+	var cost float64
+	db.QueryRow(`SELECT COUNT(*), SUM(cost_usd) FROM llm_usage WHERE batch_id = 'batch-1'`).Scan(&rows, &cost)
+	if rows != 3 || cost != 0.75 {
+		t.Errorf("Persisted %d rows costing %v, want 3 rows costing 0.75", rows, cost)
+	}
+
+	var puzzleRows int
+	db.QueryRow(`SELECT COUNT(*) FROM llm_usage WHERE puzzle_id = 'puzzle_002'`).Scan(&puzzleRows)
+	if puzzleRows != 1 {
+		t.Errorf("Expected 1 row for puzzle_002, got %d", puzzleRows)
+	}
+}
+
+func TestUsageTracker_NilIsNoop(t *testing.T) {
+	var tracker *UsageTracker
+
+	tracker.SetPuzzle("p")
+	if err := tracker.Record(providers.Usage{CostUSD: 1}); err != nil {
+		t.Errorf("Record on nil tracker returned %v", err)
+	}
+	if tracker.Exceeded() || tracker.CheckBudget() != nil {
+		t.Error("Nil tracker should have no budget")
+	}
+}
+
+func TestGenerateClues_StopsAtBudget(t *testing.T) {
+	client := &usageLLMClient{
+		sequenceLLMClient: sequenceLLMClient{
+			responses: []string{`{"clues": {"CAT": "Purring companion"}}`},
+		},
+		usage: providers.Usage{Provider: "anthropic", Model: "m1", InputTokens: 100, OutputTokens: 20, CostUSD: 0.6},
+	}
+	tracker := NewUsageTracker(nil, "batch-1", 1.0)
+	gen := NewGenerator(nil, client, DifficultyEasy)
+	gen.SetUsageTracker(tracker)
+
+	entries := []*grid.Entry{createTestEntry(1, grid.ACROSS, "CAT")}
+
+	// First call is under budget
+	if _, err := gen.GenerateClues(context.Background(), entries); err != nil {
+		t.Fatalf("First GenerateClues failed: %v", err)
+	}
+	// Second call is allowed to take spending past the budget
+	if _, err := gen.GenerateClues(context.Background(), entries); err != nil {
+		t.Fatalf("Second GenerateClues failed: %v", err)
+	}
+	if !tracker.Exceeded() {
+		t.Fatalf("Expected budget to be exceeded after spending $%.2f", tracker.Total().CostUSD)
+	}
+
+	_, err := gen.GenerateClues(context.Background(), entries)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Expected ErrBudgetExceeded, got %v", err)
+	}
+	if len(client.prompts) != 2 {
+		t.Errorf("Expected no LLM call once over budget, got %d calls", len(client.prompts))
+	}
+}
@@ -38,6 +38,12 @@ type Config struct {
 	Title  string // Puzzle title (optional, will use default if empty)
 	Author string // Puzzle author (optional, will use default if empty)
 	Theme  string // Puzzle theme (optional)
+
+	// Clue context (optional). Entry keys use the "17-across" form and must
+	// exist in the generated grid.
+	ThemeEntries    []string               // Theme answer entry keys
+	Revealer        string                 // Revealer entry key
+	CrossReferences []clues.CrossReference // Entries whose clues reference each other
 }
 
 // Generator orchestrates the complete puzzle generation pipeline
@@ -85,6 +91,18 @@ func (g *Generator) GeneratePuzzle(ctx context.Context, config Config) (*Puzzle,
 		return nil, fmt.Errorf("%w: %v", ErrGridGenerationFailed, err)
 	}
 
+	// The clue context names entries by slot, which the grid has before it is
+	// filled, so a bad entry key fails here rather than after the fill
+	puzzleContext := &clues.PuzzleContext{
+		Theme:           config.Theme,
+		ThemeEntries:    config.ThemeEntries,
+		Revealer:        config.Revealer,
+		CrossReferences: config.CrossReferences,
+	}
+	if err := validateContextEntries(puzzleContext, generatedGrid); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
 	// Step 2: Fill grid with words
 	fillConfig := fill.FillConfig{
 		MinScore:   config.MinScore,
@@ -96,10 +114,11 @@ func (g *Generator) GeneratePuzzle(ctx context.Context, config Config) (*Puzzle,
 		return nil, fmt.Errorf("%w: %v", ErrFillFailed, err)
 	}
 
-	// Step 3: Generate clues for all entries
-	cluesMap, err := g.clueGenerator.GenerateClues(ctx, generatedGrid.Entries)
+	// Step 3: Generate clues for all entries, with the theme and cross-references
+	// as puzzle context
+	cluesMap, err := g.clueGenerator.GenerateCluesWithContext(ctx, generatedGrid.Entries, puzzleContext)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrClueGenerationFailed, err)
+		return nil, fmt.Errorf("%w: %w", ErrClueGenerationFailed, err)
 	}
 
 	// Step 4: Create puzzle metadata
@@ -138,6 +157,30 @@ func validateConfig(config Config) error {
 	return nil
 }
 
+// validateContextEntries checks that every entry named in the clue context
+// is a slot of the generated grid
+func validateContextEntries(pctx *clues.PuzzleContext, g *grid.Grid) error {
+	entryKeys := make(map[string]bool, len(g.Entries))
+	for _, entry := range g.Entries {
+		entryKeys[fmt.Sprintf("%d-%s", entry.Number, entry.Direction.String())] = true
+	}
+
+	keys := append([]string(nil), pctx.ThemeEntries...)
+	if pctx.Revealer != "" {
+		keys = append(keys, pctx.Revealer)
+	}
+	for _, ref := range pctx.CrossReferences {
+		keys = append(keys, ref.From, ref.To)
+	}
+
+	for _, key := range keys {
+		if !entryKeys[key] {
+			return fmt.Errorf("entry %s does not exist in the grid", key)
+		}
+	}
+	return nil
+}
+
 // setDefaults sets default values for optional configuration fields
 func setDefaults(config Config) Config {
 	if config.Size == 0 {
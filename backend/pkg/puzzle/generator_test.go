@@ -380,3 +380,66 @@ func TestDifficultyValidation(t *testing.T) {
 		t.Error("Invalid difficulty should produce an error")
 	}
 }
+
+func TestValidateContextEntries(t *testing.T) {
+	g := &grid.Grid{
+		Entries: []*grid.Entry{
+			{Number: 1, Direction: grid.ACROSS},
+			{Number: 1, Direction: grid.DOWN},
+			{Number: 17, Direction: grid.ACROSS},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		pctx    *clues.PuzzleContext
+		wantErr bool
+	}{
+		{"empty context", &clues.PuzzleContext{Theme: "Breakfast"}, false},
+		{"valid theme entries", &clues.PuzzleContext{ThemeEntries: []string{"1-across", "17-across"}}, false},
+		{"missing theme entry", &clues.PuzzleContext{ThemeEntries: []string{"20-across"}}, true},
+		{"missing revealer", &clues.PuzzleContext{Revealer: "60-across"}, true},
+		{"valid cross-reference", &clues.PuzzleContext{CrossReferences: []clues.CrossReference{{From: "1-down", To: "17-across"}}}, false},
+		{"cross-reference to missing entry", &clues.PuzzleContext{CrossReferences: []clues.CrossReference{{From: "1-down", To: "40-down"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateContextEntries(tt.pctx, g)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateContextEntries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// countingWordlist records how often the fill asks it for words
+type countingWordlist struct {
+	mockWordlist
+	calls int
+}
+
+func (c *countingWordlist) MatchWithScores(pattern string, minScore int) []fill.WordCandidate {
+	c.calls++
+	return c.mockWordlist.MatchWithScores(pattern, minScore)
+}
+
+func TestGeneratePuzzleRejectsMissingEntryBeforeFill(t *testing.T) {
+	wordlist := &countingWordlist{mockWordlist: mockWordlist{words: make(map[string][]string)}}
+	gen := NewGenerator(wordlist, nil)
+
+	config := Config{
+		Size:         15,
+		Difficulty:   grid.Easy,
+		Seed:         12345,
+		ThemeEntries: []string{"99-across"},
+	}
+
+	_, err := gen.GeneratePuzzle(context.Background(), config)
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("Expected ErrInvalidConfig, got %v", err)
+	}
+	if wordlist.calls != 0 {
+		t.Errorf("The grid should not be filled when a context entry is missing, wordlist was queried %d times", wordlist.calls)
+	}
+}
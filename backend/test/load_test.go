@@ -3,10 +3,12 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -21,8 +23,20 @@ const (
 	testDuration     = 30 * time.Second
 	apiRampUpTime    = 5 * time.Second
 	wsRampUpTime     = 10 * time.Second
+
+	// A run fails against its baseline if cell round trips get this much slower
+	// or throughput this much lower
+	regressionTolerance = 0.10
 )
 
+// cellResult is what is compared between runs: saved with -save and checked
+// against with -baseline
+type cellResult struct {
+	AvgRTTMicros  int64   `json:"avgRttMicros"`
+	MaxRTTMicros  int64   `json:"maxRttMicros"`
+	UpdatesPerSec float64 `json:"updatesPerSec"`
+}
+
 type Stats struct {
 	apiRequests       int64
 	apiSuccess        int64
@@ -35,11 +49,19 @@ type Stats struct {
 	wsMessages        int64
 	wsTotalLatency    int64
 	wsMaxLatency      int64
+	cellUpdates       int64
+	cellAcks          int64
+	cellTotalRTT      int64
+	cellMaxRTT        int64
 }
 
 var stats Stats
 
 func main() {
+	saveFile := flag.String("save", "", "Write this run's cell update results to a JSON file")
+	baselineFile := flag.String("baseline", "", "Compare cell update results with a file written by -save, failing on a regression")
+	flag.Parse()
+
 	fmt.Printf("Starting load test with %d concurrent users for %v\n", concurrentUsers, testDuration)
 	fmt.Println("===========================================")
 
@@ -137,8 +159,90 @@ func main() {
 		}
 	}
 
+	// Cell update round trips: time from sending cell_update until the
+	// server's cell_updated broadcast comes back to the sender
+	cellSent := atomic.LoadInt64(&stats.cellUpdates)
+	cellAcks := atomic.LoadInt64(&stats.cellAcks)
+	cellRTT := atomic.LoadInt64(&stats.cellTotalRTT)
+	cellMaxRTT := atomic.LoadInt64(&stats.cellMaxRTT)
+
+	fmt.Println("\nCell Updates:")
+	fmt.Printf("  Sent: %d\n", cellSent)
+	fmt.Printf("  Acknowledged: %d\n", cellAcks)
+	if cellAcks > 0 {
+		avgRTT := time.Duration(cellRTT/cellAcks) * time.Microsecond
+		fmt.Printf("  Avg Round Trip: %v\n", avgRTT)
+		fmt.Printf("  Max Round Trip: %v\n", time.Duration(cellMaxRTT)*time.Microsecond)
+		fmt.Printf("  Updates/sec: %.2f\n", float64(cellAcks)/elapsed.Seconds())
+
+		if avgRTT > 100*time.Millisecond {
+			fmt.Printf("  ⚠️  WARNING: Avg cell round trip (%v) exceeds 100ms target\n", avgRTT)
+		} else {
+			fmt.Printf("  ✓ Avg cell round trip (%v) meets <100ms target\n", avgRTT)
+		}
+	}
+
+	// Grid state is written behind to the database; report how far it lagged
+	printGridPersistence()
+
+	// Before/after: save a run of one build, then compare another against it
+	result := cellResult{MaxRTTMicros: cellMaxRTT, UpdatesPerSec: float64(cellAcks) / elapsed.Seconds()}
+	if cellAcks > 0 {
+		result.AvgRTTMicros = cellRTT / cellAcks
+	}
+	if *saveFile != "" {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		if err := os.WriteFile(*saveFile, data, 0644); err != nil {
+			log.Fatalf("Failed to save results: %v", err)
+		}
+		fmt.Printf("\nCell update results saved to %s\n", *saveFile)
+	}
+	passed := true
+	if *baselineFile != "" {
+		passed = compareBaseline(*baselineFile, result)
+	}
+
 	fmt.Println("\n===========================================")
 	fmt.Println("Load test completed!")
+	if !passed {
+		os.Exit(1)
+	}
+}
+
+// compareBaseline prints this run's cell update results next to a saved run's
+// and reports whether they are no worse, within regressionTolerance
+func compareBaseline(filename string, after cellResult) bool {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		log.Fatalf("Failed to read baseline: %v", err)
+	}
+	var before cellResult
+	if err := json.Unmarshal(data, &before); err != nil {
+		log.Fatalf("Failed to parse baseline: %v", err)
+	}
+
+	change := func(before, after float64) float64 {
+		if before == 0 {
+			return 0
+		}
+		return (after - before) / before
+	}
+	rttChange := change(float64(before.AvgRTTMicros), float64(after.AvgRTTMicros))
+	rateChange := change(before.UpdatesPerSec, after.UpdatesPerSec)
+
+	fmt.Printf("\nCell Updates vs Baseline (%s):\n", filename)
+	fmt.Printf("  Avg Round Trip: %v -> %v (%+.1f%%)\n",
+		time.Duration(before.AvgRTTMicros)*time.Microsecond, time.Duration(after.AvgRTTMicros)*time.Microsecond, rttChange*100)
+	fmt.Printf("  Max Round Trip: %v -> %v\n",
+		time.Duration(before.MaxRTTMicros)*time.Microsecond, time.Duration(after.MaxRTTMicros)*time.Microsecond)
+	fmt.Printf("  Updates/sec: %.2f -> %.2f (%+.1f%%)\n", before.UpdatesPerSec, after.UpdatesPerSec, rateChange*100)
+
+	if after.AvgRTTMicros == 0 || rttChange > regressionTolerance || rateChange < -regressionTolerance {
+		fmt.Println("  ✗ Cell updates regressed against the baseline")
+		return false
+	}
+	fmt.Println("  ✓ Cell updates are no worse than the baseline")
+	return true
 }
 
 func runAPILoadTest(userID int, stopChan <-chan struct{}) {
@@ -243,30 +347,80 @@ func runWebSocketTest(userID int, stopChan <-chan struct{}) {
 
 	atomic.AddInt64(&stats.wsSuccess, 1)
 
-	// Send periodic messages
-	ticker := time.NewTicker(500 * time.Millisecond)
+	// Join the room and start the game so cell updates are accepted
+	if err := sendWS(wsConn, "join_room", map[string]interface{}{"roomCode": roomCode}); err != nil {
+		return
+	}
+	if err := sendWS(wsConn, "start_game", map[string]interface{}{}); err != nil {
+		return
+	}
+
+	// Match cell_updated broadcasts to the cell_update that caused them
+	var pendingMutex sync.Mutex
+	pending := make(map[string]time.Time) // "x,y" -> sent at
+	go func() {
+		for {
+			var msg struct {
+				Type    string `json:"type"`
+				Payload struct {
+					X int `json:"x"`
+					Y int `json:"y"`
+				} `json:"payload"`
+			}
+			if err := wsConn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg.Type != "cell_updated" {
+				continue
+			}
+
+			key := fmt.Sprintf("%d,%d", msg.Payload.X, msg.Payload.Y)
+			pendingMutex.Lock()
+			sentAt, ok := pending[key]
+			delete(pending, key)
+			pendingMutex.Unlock()
+			if !ok {
+				continue
+			}
+
+			rtt := time.Since(sentAt).Microseconds()
+			atomic.AddInt64(&stats.cellAcks, 1)
+			atomic.AddInt64(&stats.cellTotalRTT, rtt)
+			for {
+				oldMax := atomic.LoadInt64(&stats.cellMaxRTT)
+				if rtt <= oldMax || atomic.CompareAndSwapInt64(&stats.cellMaxRTT, oldMax, rtt) {
+					break
+				}
+			}
+		}
+	}()
+
+	// Type a letter every 100ms, walking across the top row
+	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
-	for {
+	for i := 0; ; i++ {
 		select {
 		case <-stopChan:
 			return
 		case <-ticker.C:
 			start := time.Now()
-
-			// Send a cursor move message
-			msg := map[string]interface{}{
-				"type": "cursor_move",
-				"payload": map[string]interface{}{
-					"x": userID % 15,
-					"y": userID % 15,
-				},
-			}
-
-			err := wsConn.WriteJSON(msg)
+			x, y := i%5, 0
+			letter := string(rune('A' + i%26))
+
+			pendingMutex.Lock()
+			pending[fmt.Sprintf("%d,%d", x, y)] = start
+			pendingMutex.Unlock()
+
+			err := sendWS(wsConn, "cell_update", map[string]interface{}{
+				"x":     x,
+				"y":     y,
+				"value": letter,
+			})
 			if err != nil {
 				return
 			}
+			atomic.AddInt64(&stats.cellUpdates, 1)
 
 			latency := time.Since(start).Milliseconds()
 			atomic.AddInt64(&stats.wsMessages, 1)
@@ -283,6 +437,34 @@ func runWebSocketTest(userID int, stopChan <-chan struct{}) {
 	}
 }
 
+// sendWS writes a message; each connection is only written from one goroutine
+func sendWS(conn *websocket.Conn, msgType string, payload interface{}) error {
+	return conn.WriteJSON(map[string]interface{}{
+		"type":    msgType,
+		"payload": payload,
+	})
+}
+
+func printGridPersistence() {
+	resp, err := http.Get(baseURL + "/metrics")
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var metrics struct {
+		GridPersistence map[string]interface{} `json:"grid_persistence"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&metrics); err != nil || metrics.GridPersistence == nil {
+		return
+	}
+
+	fmt.Println("\nGrid Persistence (write-behind):")
+	for _, key := range []string{"flushes", "grids_flushed", "flush_failures", "avg_flush_lag_ms", "max_flush_lag_ms", "last_flush_duration_ms", "dirty_grids"} {
+		fmt.Printf("  %s: %v\n", key, metrics.GridPersistence[key])
+	}
+}
+
 func createGuestUser(client *http.Client, id int) (string, error) {
 	payload := map[string]string{
 		"displayName": fmt.Sprintf("LoadTestUser%d", id),
@@ -307,8 +489,14 @@ func createGuestUser(client *http.Client, id int) (string, error) {
 }
 
 func createRoom(client *http.Client, token string) (string, error) {
+	puzzleID, err := getPuzzleID(client, token)
+	if err != nil {
+		return "", err
+	}
+
 	payload := map[string]interface{}{
-		"mode": "collaborative",
+		"puzzleId": puzzleID,
+		"mode":     "collaborative",
 		"config": map[string]interface{}{
 			"timeLimit": 0,
 		},
@@ -337,3 +525,25 @@ func createRoom(client *http.Client, token string) (string, error) {
 
 	return result.Room.Code, nil
 }
+
+func getPuzzleID(client *http.Client, token string) (string, error) {
+	for _, endpoint := range []string{"/api/puzzles/today", "/api/puzzles/random"} {
+		req, _ := http.NewRequest("GET", baseURL+endpoint, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+
+		var result struct {
+			ID string `json:"id"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err == nil && resp.StatusCode == http.StatusOK && result.ID != "" {
+			return result.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no puzzle available")
+}